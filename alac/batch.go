@@ -0,0 +1,133 @@
+package alac
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// packetsPerJob is the number of packets each decoder takes in a batch: enough to amortize the
+// synchronization of concurrent decoding, few enough to bound the packets and PCM held in flight.
+const packetsPerJob = 16
+
+// packetResult is the outcome of decoding one packet.
+type packetResult struct {
+	pcm []byte
+	err error
+}
+
+// packetBatch holds consecutive packets of the sample table, read in order from the stream, and
+// decodes them concurrently. ALAC packets are independent, so the decoders can take any of them.
+type packetBatch struct {
+	data    []byte         // the packets, end to end
+	packets [][]byte       // each packet, within data
+	output  []byte         // room for the PCM of every packet
+	results []packetResult // the PCM of each packet, within output
+}
+
+func newPacketBatch(jobs int) *packetBatch {
+	size := jobs * packetsPerJob
+
+	return &packetBatch{
+		packets: make([][]byte, 0, size),
+		results: make([]packetResult, 0, size),
+	}
+}
+
+// size returns the number of packets a batch holds.
+func (b *packetBatch) size() int {
+	return cap(b.packets)
+}
+
+// read reads the packets of samples, the first of which has index first in the sample table. It
+// stops at the first packet that lies past the end of the stream, leaving the batch short.
+func (b *packetBatch) read(reader io.ReadSeeker, samples []sampleInfo, first int) error {
+	total := 0
+	for _, sample := range samples {
+		total += int(sample.size)
+	}
+
+	if total > cap(b.data) {
+		b.data = make([]byte, total)
+	}
+
+	b.data = b.data[:total]
+	b.packets = b.packets[:0]
+
+	pos := 0
+
+	for idx, sample := range samples {
+		packet := b.data[pos : pos+int(sample.size)]
+
+		if _, err := reader.Seek(int64(sample.offset), io.SeekStart); err != nil {
+			return fmt.Errorf("seeking to sample %d at offset %d: %w", first+idx, sample.offset, err)
+		}
+
+		if _, err := io.ReadFull(reader, packet); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+
+			return fmt.Errorf("reading sample %d: %w", first+idx, err)
+		}
+
+		b.packets = append(b.packets, packet)
+		pos += len(packet)
+	}
+
+	return nil
+}
+
+// decode decodes the packets read, spreading them over the decoders, one goroutine each, and
+// collects the results in packet order.
+func (b *packetBatch) decode(decoders []*Decoder) {
+	room := decoders[0].BufferSize()
+	if len(b.output) < b.size()*room {
+		b.output = make([]byte, b.size()*room)
+	}
+
+	b.results = b.results[:len(b.packets)]
+
+	decodeOne := func(dec *Decoder, idx int) {
+		out := b.output[idx*room : (idx+1)*room]
+		n, err := dec.DecodePacketInto(out, b.packets[idx])
+		b.results[idx] = packetResult{pcm: out[:n], err: err}
+	}
+
+	if len(decoders) == 1 {
+		for idx := range b.packets {
+			decodeOne(decoders[0], idx)
+		}
+
+		return
+	}
+
+	var group sync.WaitGroup
+
+	for job, dec := range decoders {
+		group.Go(func() {
+			for idx := job; idx < len(b.packets); idx += len(decoders) {
+				decodeOne(dec, idx)
+			}
+		})
+	}
+
+	group.Wait()
+}
+
+// newDecoders creates one decoder per concurrent job, at least one.
+func newDecoders(config Config, jobs int) ([]*Decoder, error) {
+	decoders := make([]*Decoder, max(jobs, 1))
+
+	for idx := range decoders {
+		dec, err := NewDecoder(config)
+		if err != nil {
+			return nil, err
+		}
+
+		decoders[idx] = dec
+	}
+
+	return decoders, nil
+}
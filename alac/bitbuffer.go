@@ -3,25 +3,28 @@ package alac
 // bitBuffer provides bit-level reading from a byte buffer.
 // Ported from ALACBitUtilities.c.
 //
-// The buffer is padded with 4 zero bytes to allow safe reads near the end
-// without bounds checking in hot paths.
+// The buffer is padded with zero bytes to allow safe reads near the end
+// without bounds checking in hot paths. Element decoders check with has that
+// the bits they are about to read lie within the data.
 type bitBuffer struct {
-	buf    []byte // padded data (original + 4 zero bytes)
+	buf    []byte // padded data (original + 8 zero bytes)
 	pos    int    // current byte position within buf
 	bitIdx uint32 // 0-7, bit offset within current byte
 	size   int    // original (unpadded) byte size
 }
 
-const bitBufferPadding = 4
-
-func newBitBuffer(data []byte) *bitBuffer {
-	padded := make([]byte, len(data)+bitBufferPadding)
-	copy(padded, data)
-
-	return &bitBuffer{
-		buf:  padded,
-		size: len(data),
-	}
+// bitBufferPadding covers the widest read that can start inside the data: an escaped
+// residual loads 5 bytes from up to 2 bytes past its prefix.
+const bitBufferPadding = 8
+
+// reset points the buffer at the start of data, copied into the padded buffer, which is reused
+// from one packet to the next.
+func (b *bitBuffer) reset(data []byte) {
+	b.buf = append(b.buf[:0], data...)
+	b.buf = append(b.buf, make([]byte, bitBufferPadding)...)
+	b.pos = 0
+	b.bitIdx = 0
+	b.size = len(data)
 }
 
 // read reads up to 16 bits and returns them right-aligned.
@@ -85,6 +88,16 @@ func (b *bitBuffer) pastEnd() bool {
 	return b.pos >= b.size
 }
 
+// has reports whether numBits more bits lie within the original data.
+func (b *bitBuffer) has(numBits uint64) bool {
+	return uint64(b.pos)*8+uint64(b.bitIdx)+numBits <= uint64(b.size)*8
+}
+
+// overrun returns true if more bits were consumed than the original data holds.
+func (b *bitBuffer) overrun() bool {
+	return b.pos > b.size || (b.pos == b.size && b.bitIdx > 0)
+}
+
 // copy returns a snapshot of the current bitBuffer state.
 // The copy shares the underlying data but has independent position tracking.
 func (b *bitBuffer) copy() bitBuffer {
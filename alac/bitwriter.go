@@ -0,0 +1,57 @@
+package alac
+
+// bitWriter provides MSB-first bit-level writing into a growing byte buffer.
+// Counterpart of bitBuffer (ALACBitUtilities.c BitBufferWrite).
+type bitWriter struct {
+	buf     []byte
+	pending uint64 // bits not yet flushed, right-aligned
+	count   uint32 // number of pending bits (always < 8 between calls)
+}
+
+// write appends the low numBits bits of value (numBits <= 32).
+func (w *bitWriter) write(value uint32, numBits uint32) {
+	if numBits == 0 {
+		return
+	}
+
+	w.pending = w.pending<<numBits | uint64(value)&(1<<numBits-1)
+	w.count += numBits
+
+	for w.count >= 8 {
+		w.count -= 8
+		w.buf = append(w.buf, byte(w.pending>>w.count))
+	}
+}
+
+// byteAlign pads with zero bits up to the next byte boundary.
+func (w *bitWriter) byteAlign() {
+	if w.count != 0 {
+		w.write(0, 8-w.count)
+	}
+}
+
+// bitLen returns the total number of bits written so far.
+func (w *bitWriter) bitLen() int {
+	return len(w.buf)*8 + int(w.count)
+}
+
+// appendBits copies every bit written to other onto w.
+func (w *bitWriter) appendBits(other *bitWriter) {
+	for _, b := range other.buf {
+		w.write(uint32(b), 8)
+	}
+
+	w.write(uint32(other.pending), other.count)
+}
+
+// reset empties the writer while keeping its buffer.
+func (w *bitWriter) reset() {
+	w.buf = w.buf[:0]
+	w.pending = 0
+	w.count = 0
+}
+
+// bytes returns the written data. The writer must be byte-aligned.
+func (w *bitWriter) bytes() []byte {
+	return w.buf
+}
@@ -0,0 +1,98 @@
+package alac
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/farcloser/saprobe"
+)
+
+// Blocks decodes the first ALAC track of an M4A/MP4 stream packet by packet, straight into planar
+// samples, and yields a block per packet (see saprobe.Block and Decoder.DecodePacketInt32).
+// Decoding stops at the first error, which is yielded as Decode would return it; packets past the
+// end of the file end the stream without error.
+func Blocks(reader io.ReadSeeker) iter.Seq2[saprobe.Block, error] {
+	return func(yield func(saprobe.Block, error) bool) {
+		tracked := saprobe.TrackReader(reader)
+
+		err := decodeBlocks(tracked, func(block saprobe.Block) bool {
+			return yield(block, nil)
+		})
+		if err != nil {
+			yield(saprobe.Block{}, wrapError(tracked, err))
+		}
+	}
+}
+
+// decodeBlocks decodes every packet of the track, handing each to yield until it returns false.
+func decodeBlocks(reader io.ReadSeeker, yield func(saprobe.Block) bool) error {
+	track, err := findALACTrack(reader)
+	if err != nil {
+		return err
+	}
+
+	config, err := ParseConfig(track.cookie)
+	if err != nil {
+		return fmt.Errorf("parsing ALAC config: %w", err)
+	}
+
+	dec, err := NewDecoder(config)
+	if err != nil {
+		return err
+	}
+
+	planes := make([][]int32, config.NumChannels)
+	for ch := range planes {
+		planes[ch] = make([]int32, config.FrameLength)
+	}
+
+	block := saprobe.Block{Format: dec.Format(), Samples: make([][]int32, len(planes))}
+
+	var packet []byte
+
+	for idx, sample := range track.samples {
+		if int(sample.size) > cap(packet) {
+			packet = make([]byte, sample.size)
+		}
+
+		packet = packet[:sample.size]
+
+		if _, err := reader.Seek(int64(sample.offset), io.SeekStart); err != nil {
+			return fmt.Errorf("seeking to sample %d at offset %d: %w", idx, sample.offset, err)
+		}
+
+		if _, err := io.ReadFull(reader, packet); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil // the file ends early
+			}
+
+			return fmt.Errorf("reading sample %d: %w", idx, err)
+		}
+
+		block.Start += int64(block.Len())
+
+		samples, err := dec.DecodePacketInt32(planes, packet)
+		if err != nil {
+			return &saprobe.DecodeError{
+				Codec:  codecName,
+				Kind:   saprobe.ErrCorrupt,
+				Offset: int64(sample.offset), //nolint:gosec // file offsets fit in int64.
+				Frame:  idx,
+				Sample: block.Start,
+				Err:    err,
+			}
+		}
+
+		for ch, plane := range planes {
+			block.Samples[ch] = plane[:samples]
+		}
+
+		if !yield(block) {
+			return nil
+		}
+	}
+
+	return nil
+}
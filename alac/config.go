@@ -40,12 +40,12 @@ func ParseConfig(cookie []byte) (Config, error) {
 	}
 
 	if len(data) < configSize {
-		return Config{}, errInvalidCookie
+		return Config{}, ErrInvalidCookie
 	}
 
 	compatibleVersion := data[4]
 	if compatibleVersion > 0 {
-		return Config{}, fmt.Errorf("%w: %d", errUnsupportedVersion, compatibleVersion)
+		return Config{}, fmt.Errorf("%w: %d", ErrUnsupportedVersion, compatibleVersion)
 	}
 
 	return Config{
@@ -61,3 +61,22 @@ func ParseConfig(cookie []byte) (Config, error) {
 		SampleRate:    binary.BigEndian.Uint32(data[20:24]),
 	}, nil
 }
+
+// Cookie serializes the configuration as a bare ALACSpecificConfig magic cookie.
+func (c Config) Cookie() []byte {
+	cookie := make([]byte, configSize)
+
+	binary.BigEndian.PutUint32(cookie[0:4], c.FrameLength)
+	cookie[4] = 0 // compatible version
+	cookie[5] = c.BitDepth
+	cookie[6] = c.PB
+	cookie[7] = c.MB
+	cookie[8] = c.KB
+	cookie[9] = c.NumChannels
+	binary.BigEndian.PutUint16(cookie[10:12], c.MaxRun)
+	binary.BigEndian.PutUint32(cookie[12:16], c.MaxFrameBytes)
+	binary.BigEndian.PutUint32(cookie[16:20], c.AvgBitRate)
+	binary.BigEndian.PutUint32(cookie[20:24], c.SampleRate)
+
+	return cookie
+}
@@ -10,61 +10,147 @@ import (
 	"github.com/farcloser/saprobe"
 )
 
+const codecName = "alac"
+
 // Decode reads an M4A/MP4 stream and decodes the first ALAC audio track
 // to interleaved little-endian signed PCM bytes.
 func Decode(reader io.ReadSeeker) ([]byte, saprobe.PCMFormat, error) {
-	cookie, samples, err := findALACTrack(reader)
+	pcm, format, _, err := DecodeWithOptions(reader, saprobe.Options{})
+
+	return pcm, format, err
+}
+
+// DecodeWithOptions is Decode with shared decoder options. Packets that lie past the end of the
+// file are not an error: decoding stops there, and the shortfall against the duration declared by
+// stts (or mdhd) is reported as a Truncation, or fails in strict mode. In resilient mode a packet
+// that fails to decode is concealed and listed in the Report.
+func DecodeWithOptions(reader io.ReadSeeker, opts saprobe.Options) ([]byte, saprobe.PCMFormat, saprobe.Report, error) {
+	tracked := saprobe.TrackReader(reader)
+	pcm, format, report, err := decode(tracked, opts)
+
+	return pcm, format, report, wrapError(tracked, err)
+}
+
+// wrapError categorizes a decoding error as a *saprobe.DecodeError.
+func wrapError(reader *saprobe.TrackedReader, err error) error {
+	return saprobe.WrapDecodeError(codecName, reader, err, unsupportedErrors...)
+}
+
+func decode(reader io.ReadSeeker, opts saprobe.Options) ([]byte, saprobe.PCMFormat, saprobe.Report, error) {
+	var report saprobe.Report
+
+	track, err := findALACTrack(reader)
 	if err != nil {
-		return nil, saprobe.PCMFormat{}, err
+		return nil, saprobe.PCMFormat{}, report, err
 	}
 
-	config, err := ParseConfig(cookie)
+	samples := track.samples
+
+	config, err := ParseConfig(track.cookie)
 	if err != nil {
-		return nil, saprobe.PCMFormat{}, fmt.Errorf("parsing ALAC config: %w", err)
+		return nil, saprobe.PCMFormat{}, report, fmt.Errorf("parsing ALAC config: %w", err)
 	}
 
-	dec, err := NewDecoder(config)
+	decoders, err := newDecoders(config, opts.Jobs)
 	if err != nil {
-		return nil, saprobe.PCMFormat{}, err
+		return nil, saprobe.PCMFormat{}, report, err
 	}
 
-	format := dec.Format()
+	format := decoders[0].Format()
 	bps := format.BitDepth.BytesPerSample()
 
-	// Rough capacity estimate (last frame may be shorter).
-	pcm := make([]byte, 0, len(samples)*int(config.FrameLength)*int(config.NumChannels)*bps)
+	frameBytes := int(config.NumChannels) * bps
+	declared := declaredSamples(reader, track, config)
 
-	var packetBuf []byte
+	// Rough capacity estimate (last frame may be shorter), within the declared length.
+	capacity := len(samples) * int(config.FrameLength)
+	if declared > 0 {
+		capacity = int(min(int64(capacity), declared))
+	}
 
-	for idx, sample := range samples {
-		if int(sample.size) > len(packetBuf) {
-			packetBuf = make([]byte, sample.size)
-		}
+	pcm := make([]byte, 0, capacity*frameBytes)
+
+	batch := newPacketBatch(len(decoders))
 
-		packet := packetBuf[:sample.size]
+	for first := 0; first < len(samples); first += batch.size() {
+		next := samples[first:min(first+batch.size(), len(samples))]
 
-		if _, err := reader.Seek(int64(sample.offset), io.SeekStart); err != nil {
-			return nil, saprobe.PCMFormat{}, fmt.Errorf(
-				"seeking to sample %d at offset %d: %w",
-				idx,
-				sample.offset,
-				err,
-			)
+		if err := batch.read(reader, next, first); err != nil {
+			return nil, saprobe.PCMFormat{}, report, err
 		}
 
-		if _, err := io.ReadFull(reader, packet); err != nil {
-			return nil, saprobe.PCMFormat{}, fmt.Errorf("reading sample %d: %w", idx, err)
+		batch.decode(decoders)
+
+		for idx, result := range batch.results {
+			if result.err != nil {
+				err = &saprobe.DecodeError{
+					Codec:  codecName,
+					Kind:   saprobe.ErrCorrupt,
+					Offset: int64(next[idx].offset), //nolint:gosec // file offsets fit in int64.
+					Frame:  first + idx,
+					Sample: int64(len(pcm) / frameBytes),
+					Err:    result.err,
+				}
+				if !opts.Resilient {
+					return nil, saprobe.PCMFormat{}, report, err
+				}
+
+				// Packets are independent: conceal this one and carry on with the next.
+				pcm = concealPacket(pcm, &report, frameBytes, int64(config.FrameLength), declared, err)
+
+				continue
+			}
+
+			pcm = append(pcm, result.pcm...)
 		}
 
-		decoded, err := dec.DecodePacket(packet)
-		if err != nil {
-			return nil, saprobe.PCMFormat{}, fmt.Errorf("decoding packet %d: %w", idx, err)
+		if len(batch.results) < len(next) {
+			break // the file ends early; reported below
 		}
+	}
 
-		pcm = append(pcm, decoded...)
+	decodedSamples := int64(len(pcm) / frameBytes)
+
+	err = saprobe.CheckTruncation(opts, &report, declared, decodedSamples, format.SampleRate)
+	if err != nil {
+		return nil, saprobe.PCMFormat{}, report, err
 	}
 
-	return pcm, format, nil
+	if opts.Conceal != saprobe.ConcealSilence {
+		saprobe.Conceal(pcm, format, report.Damaged, opts.Conceal)
+	}
+
+	return pcm, format, report, nil
+}
+
+// concealPacket appends silence for a packet that failed to decode and records the damage. A
+// packet holds frameLength samples, except the last one, which ends at the declared length.
+func concealPacket(pcm []byte, report *saprobe.Report, frameBytes int, frameLength, declared int64, err error) []byte {
+	start := int64(len(pcm) / frameBytes)
+
+	end := start + frameLength
+	if declared > start && declared < end {
+		end = declared
+	}
+
+	report.Damaged = append(report.Damaged, saprobe.Damage{Start: start, End: end, Err: err})
+
+	return append(pcm, make([]byte, int(end-start)*frameBytes)...)
+}
+
+// declaredSamples returns the track length in samples from stts, falling back to the mdhd
+// duration. It returns 0 when neither is usable.
+func declaredSamples(reader io.ReadSeeker, track alacTrack, config Config) int64 {
+	timescale, duration, err := readMediaDuration(reader, track.mdia)
+	if err != nil || timescale == 0 {
+		return 0
+	}
+
+	if total, ok := readSttsTotal(reader, track.stbl); ok && total > 0 {
+		return rescale(total, timescale, config.SampleRate)
+	}
+
+	return rescale(duration, timescale, config.SampleRate)
 }
 
 // sampleInfo holds the byte offset and size of a single encoded ALAC packet
@@ -74,32 +160,47 @@ type sampleInfo struct {
 	size   uint32
 }
 
+// alacTrack locates an ALAC track: its magic cookie, its flat sample table, and its mdia
+// and stbl boxes for callers that need more of the track's tables.
+type alacTrack struct {
+	cookie  []byte
+	samples []sampleInfo
+	mdia    *mp4.BoxInfo
+	stbl    *mp4.BoxInfo
+}
+
 // findALACTrack walks the MP4 box tree to locate the first track containing
-// an ALAC sample entry. It returns the magic cookie and a flat sample table.
-func findALACTrack(reader io.ReadSeeker) ([]byte, []sampleInfo, error) {
-	stbls, err := mp4.ExtractBox(reader, nil, mp4.BoxPath{
+// an ALAC sample entry.
+func findALACTrack(reader io.ReadSeeker) (alacTrack, error) {
+	mdias, err := mp4.ExtractBox(reader, nil, mp4.BoxPath{
 		mp4.BoxTypeMoov(), mp4.BoxTypeTrak(), mp4.BoxTypeMdia(),
-		mp4.BoxTypeMinf(), mp4.BoxTypeStbl(),
 	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("reading container structure: %w", err)
+		return alacTrack{}, fmt.Errorf("reading container structure: %w", err)
 	}
 
-	for _, stbl := range stbls {
-		cookie, err := extractCookie(reader, stbl)
+	for _, mdia := range mdias {
+		stbls, err := mp4.ExtractBox(reader, mdia, mp4.BoxPath{mp4.BoxTypeMinf(), mp4.BoxTypeStbl()})
 		if err != nil {
-			continue // not an ALAC track
+			return alacTrack{}, fmt.Errorf("reading container structure: %w", err)
 		}
 
-		samples, err := buildSampleTable(reader, stbl)
-		if err != nil {
-			return nil, nil, fmt.Errorf("building sample table: %w", err)
-		}
+		for _, stbl := range stbls {
+			cookie, err := extractCookie(reader, stbl)
+			if err != nil {
+				continue // not an ALAC track
+			}
 
-		return cookie, samples, nil
+			samples, err := buildSampleTable(reader, stbl)
+			if err != nil {
+				return alacTrack{}, fmt.Errorf("building sample table: %w", err)
+			}
+
+			return alacTrack{cookie: cookie, samples: samples, mdia: mdia, stbl: stbl}, nil
+		}
 	}
 
-	return nil, nil, errNoALACTrack
+	return alacTrack{}, ErrNoALACTrack
 }
 
 const (
@@ -116,23 +217,16 @@ const (
 func extractCookie(reader io.ReadSeeker, stbl *mp4.BoxInfo) ([]byte, error) {
 	stsds, err := mp4.ExtractBox(reader, stbl, mp4.BoxPath{mp4.BoxTypeStsd()})
 	if err != nil || len(stsds) == 0 {
-		return nil, errNoALACTrack
-	}
-
-	stsd := stsds[0]
-	payloadSize := int(stsd.Size - stsd.HeaderSize)
-	data := make([]byte, payloadSize)
-
-	if _, err := reader.Seek(int64(stsd.Offset+stsd.HeaderSize), io.SeekStart); err != nil {
-		return nil, fmt.Errorf("seeking to stsd payload: %w", err)
+		return nil, ErrNoALACTrack
 	}
 
-	if _, err := io.ReadFull(reader, data); err != nil {
+	data, err := readPayload(reader, stsds[0])
+	if err != nil {
 		return nil, fmt.Errorf("reading stsd payload: %w", err)
 	}
 
 	if len(data) < stsdPayloadHeader {
-		return nil, errNoALACTrack
+		return nil, ErrNoALACTrack
 	}
 
 	entryCount := binary.BigEndian.Uint32(data[4:8])
@@ -170,17 +264,47 @@ func extractCookie(reader io.ReadSeeker, stbl *mp4.BoxInfo) ([]byte, error) {
 		cookieEnd := pos + entrySize
 
 		if cookieStart >= cookieEnd {
-			return nil, errInvalidCookie
+			return nil, ErrInvalidCookie
 		}
 
 		return data[cookieStart:cookieEnd], nil
 	}
 
-	return nil, errNoALACTrack
+	return nil, ErrNoALACTrack
+}
+
+// readPayload reads the payload of box, which must lie within the stream: its size is not trusted
+// with an allocation before that.
+func readPayload(reader io.ReadSeeker, box *mp4.BoxInfo) ([]byte, error) {
+	end, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("seeking to end: %w", err)
+	}
+
+	//nolint:gosec // stream sizes are positive.
+	if box.Offset+box.Size > uint64(end) || box.Size < box.HeaderSize {
+		return nil, fmt.Errorf("%w: %s box of %d bytes at offset %d",
+			io.ErrUnexpectedEOF, box.Type, box.Size, box.Offset)
+	}
+
+	data := make([]byte, box.Size-box.HeaderSize)
+
+	if _, err := reader.Seek(int64(box.Offset+box.HeaderSize), io.SeekStart); err != nil { //nolint:gosec // idem.
+		return nil, fmt.Errorf("seeking to %s payload: %w", box.Type, err)
+	}
+
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, fmt.Errorf("reading %s payload: %w", box.Type, err)
+	}
+
+	return data, nil
 }
 
 // buildSampleTable constructs a flat list of sample offsets and sizes from
 // the stco/co64, stsc, and stsz boxes within the given stbl box.
+// Packets are read whole, and reading stops at the first one past the end of the stream: the
+// table stops there too, that packet cut to a byte past the end, so that reading it fails as
+// before without room allocated for a size the file cannot hold.
 func buildSampleTable(reader io.ReadSeeker, stbl *mp4.BoxInfo) ([]sampleInfo, error) {
 	chunkOffsets, err := readChunkOffsets(reader, stbl)
 	if err != nil {
@@ -197,7 +321,19 @@ func buildSampleTable(reader io.ReadSeeker, stbl *mp4.BoxInfo) ([]sampleInfo, er
 		return nil, err
 	}
 
-	samples := make([]sampleInfo, 0, sampleCount)
+	streamEnd, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("seeking to end: %w", err)
+	}
+
+	end := uint64(streamEnd) //nolint:gosec // stream sizes are positive.
+
+	capacity := len(entrySizes)
+	if constantSize != 0 {
+		capacity = int(min(uint64(sampleCount), end/uint64(constantSize)+1)) //nolint:gosec // bounded.
+	}
+
+	samples := make([]sampleInfo, 0, capacity)
 	sampleIdx := 0
 
 	for chunkIdx := range chunkOffsets {
@@ -206,10 +342,20 @@ func buildSampleTable(reader io.ReadSeeker, stbl *mp4.BoxInfo) ([]sampleInfo, er
 
 		for s := uint32(0); s < spc && sampleIdx < int(sampleCount); s++ {
 			var size uint32
-			if constantSize != 0 {
+
+			switch {
+			case constantSize != 0:
 				size = constantSize
-			} else {
+			case sampleIdx < len(entrySizes):
 				size = entrySizes[sampleIdx]
+			default:
+				return samples, nil // stsz lists fewer sizes than it declares
+			}
+
+			if offset >= end || uint64(size) > end-offset {
+				size = uint32(min(uint64(size), max(end, offset)-offset+1)) //nolint:gosec // bounded.
+
+				return append(samples, sampleInfo{offset: offset, size: size}), nil
 			}
 
 			samples = append(samples, sampleInfo{offset: offset, size: size})
@@ -238,12 +384,12 @@ func readChunkOffsets(reader io.ReadSeeker, stbl *mp4.BoxInfo) ([]uint64, error)
 	// Fall back to 64-bit co64.
 	boxes, err := mp4.ExtractBoxWithPayload(reader, stbl, mp4.BoxPath{mp4.BoxTypeCo64()})
 	if err != nil || len(boxes) == 0 {
-		return nil, errNoChunkOffset
+		return nil, ErrNoChunkOffset
 	}
 
 	co64, ok := boxes[0].Payload.(*mp4.Co64)
 	if !ok {
-		return nil, errInvalidCo64
+		return nil, ErrInvalidCo64
 	}
 
 	return co64.ChunkOffset, nil
@@ -252,12 +398,12 @@ func readChunkOffsets(reader io.ReadSeeker, stbl *mp4.BoxInfo) ([]uint64, error)
 func readStsc(reader io.ReadSeeker, stbl *mp4.BoxInfo) ([]mp4.StscEntry, error) {
 	boxes, err := mp4.ExtractBoxWithPayload(reader, stbl, mp4.BoxPath{mp4.BoxTypeStsc()})
 	if err != nil || len(boxes) == 0 {
-		return nil, errNoStsc
+		return nil, ErrNoStsc
 	}
 
 	stsc, ok := boxes[0].Payload.(*mp4.Stsc)
 	if !ok {
-		return nil, errInvalidStsc
+		return nil, ErrInvalidStsc
 	}
 
 	return stsc.Entries, nil
@@ -267,12 +413,12 @@ func readStsc(reader io.ReadSeeker, stbl *mp4.BoxInfo) ([]mp4.StscEntry, error)
 func readStsz(reader io.ReadSeeker, stbl *mp4.BoxInfo) ([]uint32, uint32, uint32, error) {
 	boxes, err := mp4.ExtractBoxWithPayload(reader, stbl, mp4.BoxPath{mp4.BoxTypeStsz()})
 	if err != nil || len(boxes) == 0 {
-		return nil, 0, 0, errNoStsz
+		return nil, 0, 0, ErrNoStsz
 	}
 
 	stsz, ok := boxes[0].Payload.(*mp4.Stsz)
 	if !ok {
-		return nil, 0, 0, errInvalidStsz
+		return nil, 0, 0, ErrInvalidStsz
 	}
 
 	return stsz.EntrySize, stsz.SampleSize, stsz.SampleCount, nil
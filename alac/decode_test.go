@@ -0,0 +1,206 @@
+package alac_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/alac"
+	"github.com/farcloser/saprobe/tests/testutils"
+)
+
+// TestDecodeJobs checks that decoding packets concurrently outputs the same bytes, and reports
+// the same damage, as decoding them one after the other.
+func TestDecodeJobs(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []saprobe.PCMFormat{
+		{SampleRate: 44100, BitDepth: saprobe.Depth16, Channels: 2},
+		{SampleRate: 48000, BitDepth: saprobe.Depth24, Channels: 6},
+	} {
+		// Quiet noise compresses: louder packets escape to verbatim samples, which no damage upsets.
+		var buf bytes.Buffer
+		if err := alac.Encode(&buf, testutils.Noise(format, 100000, -60), format, saprobe.Metadata{}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Damage the audio in a few places, for resilient decoding.
+		damaged := slices.Clone(buf.Bytes())
+		mdat := bytes.Index(damaged, []byte("mdat"))
+
+		for _, at := range []int{1000, 50000, 90000} {
+			for idx := range 64 {
+				damaged[mdat+at+idx] = 0xFF
+			}
+		}
+
+		type decodeCase struct {
+			name string
+			data []byte
+			opts saprobe.Options
+		}
+
+		cases := []decodeCase{
+			{"clean", buf.Bytes(), saprobe.Options{}},
+			{"wiped", damaged, saprobe.Options{Resilient: true}},
+		}
+
+		// Flip random bits of the packets, which sends entropy decoding astray rather than
+		// stopping it at once: with a few seeds, some packet header claims more than it holds.
+		payloadEnd := mdat - 4 + int(binary.BigEndian.Uint32(damaged[mdat-4:]))
+
+		for seed := range uint64(10) {
+			flipped := slices.Clone(buf.Bytes())
+			payload := flipped[mdat+4 : payloadEnd]
+			random := rand.New(rand.NewPCG(seed, uint64(format.BitDepth))) //nolint:gosec // test data.
+
+			for range 100 {
+				payload[random.IntN(len(payload))] ^= 1 << random.IntN(8)
+			}
+
+			name := fmt.Sprintf("flipped %d", seed)
+			cases = append(cases, decodeCase{name, flipped, saprobe.Options{Resilient: true}})
+		}
+
+		for _, test := range cases {
+			name := fmt.Sprintf("%d-bit %d channels, %s", format.BitDepth, format.Channels, test.name)
+
+			_, _, report, err := alac.DecodeWithOptions(bytes.NewReader(test.data), test.opts)
+			if err != nil || test.opts.Resilient != (len(report.Damaged) != 0) {
+				t.Fatalf("%s: damage %v (%v)", name, report.Damaged, err)
+			}
+
+			testutils.SameWithJobs(t, name, func(jobs int) ([]byte, string, error) {
+				opts := test.opts
+				opts.Jobs = jobs
+
+				pcm, _, report, err := alac.DecodeWithOptions(bytes.NewReader(test.data), opts)
+
+				return pcm, fmt.Sprint(report.Damaged), err
+			})
+		}
+	}
+}
+
+// TestDecodeCorruptPacket checks that a packet whose header sends the decoder past its end fails
+// as corrupt in strict mode, and is concealed in resilient mode.
+func TestDecodeCorruptPacket(t *testing.T) {
+	t.Parallel()
+
+	format := saprobe.PCMFormat{SampleRate: 44100, BitDepth: saprobe.Depth16, Channels: 2}
+
+	var buf bytes.Buffer
+	if err := alac.Encode(&buf, testutils.Noise(format, 20000, -60), format, saprobe.Metadata{}); err != nil {
+		t.Fatal(err)
+	}
+
+	clean, _, err := alac.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first packet follows the mdat header. Its CPE header ends with the partial frame, shift
+	// and escape flags: claim 2 shifted bytes, 16 kB of shift bits the packet does not hold.
+	data := slices.Clone(buf.Bytes())
+	data[bytes.Index(data, []byte("mdat"))+4+2] |= 0x08
+
+	_, _, _, err = alac.DecodeWithOptions(bytes.NewReader(data), saprobe.Options{})
+
+	var decodeErr *saprobe.DecodeError
+	if !errors.Is(err, saprobe.ErrCorrupt) || !errors.Is(err, alac.ErrBitstreamOverrun) ||
+		!errors.As(err, &decodeErr) || decodeErr.Frame != 0 {
+		t.Fatalf("strict decode: %v, want a bitstream overrun in frame 0", err)
+	}
+
+	pcm, _, report, err := alac.DecodeWithOptions(bytes.NewReader(data), saprobe.Options{Resilient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The packet of 4096 samples of 4 bytes is silenced, the others decode as before.
+	const packetBytes = 4096 * 4
+	if len(report.Damaged) != 1 || report.Damaged[0].Start != 0 || report.Damaged[0].End != 4096 ||
+		!bytes.Equal(pcm[:packetBytes], make([]byte, packetBytes)) ||
+		!bytes.Equal(pcm[packetBytes:], clean[packetBytes:]) {
+		t.Errorf("resilient decode: damage %v, want the first packet concealed", report.Damaged)
+	}
+}
+
+// TestDecodePacketEnd checks that decoding holds packets to the size the sample table gives them,
+// as Verify does: a packet with bytes past its END element fails strict decoding, and is concealed
+// by resilient decoding.
+func TestDecodePacketEnd(t *testing.T) {
+	t.Parallel()
+
+	format := saprobe.PCMFormat{SampleRate: 44100, BitDepth: saprobe.Depth16, Channels: 2}
+
+	var buf bytes.Buffer
+	if err := alac.Encode(&buf, testutils.Noise(format, 20000, -60), format, saprobe.Metadata{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Grow the last stsz entry by a byte: the packet then ends with a byte its elements leave out.
+	data := slices.Clone(buf.Bytes())
+	stsz := bytes.Index(data, []byte("stsz"))
+	count := int(binary.BigEndian.Uint32(data[stsz+12:]))
+	last := data[stsz+16+4*(count-1):]
+	binary.BigEndian.PutUint32(last, binary.BigEndian.Uint32(last)+1)
+
+	verification, err := alac.Verify(bytes.NewReader(data))
+	if err != nil || verification.OK() {
+		t.Fatalf("verify: findings %v (%v), want a bitstream finding", verification.Findings, err)
+	}
+
+	_, _, _, err = alac.DecodeWithOptions(bytes.NewReader(data), saprobe.Options{})
+	if !errors.Is(err, saprobe.ErrCorrupt) || !errors.Is(err, alac.ErrPacketEnd) {
+		t.Fatalf("strict decode: %v, want ErrPacketEnd", err)
+	}
+
+	err = nil
+	for _, err = range alac.Blocks(bytes.NewReader(data)) {
+		if err != nil {
+			break
+		}
+	}
+
+	if !errors.Is(err, alac.ErrPacketEnd) {
+		t.Errorf("blocks: %v, want ErrPacketEnd", err)
+	}
+
+	_, _, report, err := alac.DecodeWithOptions(bytes.NewReader(data), saprobe.Options{Resilient: true})
+	if err != nil || len(report.Damaged) != 1 || report.Damaged[0].Start != int64(count-1)*4096 {
+		t.Errorf("resilient decode: damage %v (%v), want the last packet concealed", report.Damaged, err)
+	}
+}
+
+// TestReadFormat checks that the format and sample count read from the track headers match the
+// decode.
+func TestReadFormat(t *testing.T) {
+	t.Parallel()
+
+	format := saprobe.PCMFormat{SampleRate: 44100, BitDepth: saprobe.Depth16, Channels: 2}
+
+	var buf bytes.Buffer
+	if err := alac.Encode(&buf, testutils.Noise(format, 30000, -6), format, saprobe.Metadata{}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, decoded, err := alac.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read, samples, err := alac.ReadFormat(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if read != decoded || samples != 30000 {
+		t.Errorf("read %+v, %d samples, want %+v, 30000", read, samples, decoded)
+	}
+}
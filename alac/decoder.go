@@ -24,10 +24,62 @@ const (
 	// Special numActive value that triggers first-order delta decode mode.
 	numActiveDelta = 31
 
+	// Largest frame length accepted from a magic cookie, far above the 4096 encoders use: the
+	// decoder allocates buffers of that many samples.
+	maxFrameLength = 1 << 16
+
 	// Unused header field size in SCE/CPE (spec-defined).
 	unusedHeaderBits = 12
+
+	// SCE/CPE header fields after the element tag (see elementHeaderBits): instance tag, unused
+	// bits, then the partial frame, shift and escape flags.
+	elementFieldsBits = 4 + unusedHeaderBits + 4
+
+	// Mixing parameters, then the mode, shift, factor and order of each channel predictor, which
+	// are followed by their 16-bit coefficients.
+	mixHeaderBits       = 16
+	predictorHeaderBits = 16
+	coefBits            = 16
 )
 
+// speakerLayouts holds the speaker assignments of the element sequences Apple uses for 1-8
+// channels: the center comes first, and the LFE last.
+//
+//nolint:gochecknoglobals // constant table
+var speakerLayouts = [...]saprobe.ChannelLayout{
+	1: saprobe.LayoutMono,
+	2: saprobe.LayoutStereo,
+	3: {saprobe.SpeakerFrontCenter, saprobe.SpeakerFrontLeft, saprobe.SpeakerFrontRight},
+	4: {saprobe.SpeakerFrontCenter, saprobe.SpeakerFrontLeft, saprobe.SpeakerFrontRight, saprobe.SpeakerBackCenter},
+	5: {
+		saprobe.SpeakerFrontCenter, saprobe.SpeakerFrontLeft, saprobe.SpeakerFrontRight,
+		saprobe.SpeakerBackLeft, saprobe.SpeakerBackRight,
+	},
+	6: {
+		saprobe.SpeakerFrontCenter, saprobe.SpeakerFrontLeft, saprobe.SpeakerFrontRight,
+		saprobe.SpeakerBackLeft, saprobe.SpeakerBackRight, saprobe.SpeakerLowFrequency,
+	},
+	7: {
+		saprobe.SpeakerFrontCenter, saprobe.SpeakerFrontLeft, saprobe.SpeakerFrontRight,
+		saprobe.SpeakerBackLeft, saprobe.SpeakerBackRight, saprobe.SpeakerBackCenter, saprobe.SpeakerLowFrequency,
+	},
+	8: {
+		saprobe.SpeakerFrontCenter, saprobe.SpeakerFrontLeftOfCenter, saprobe.SpeakerFrontRightOfCenter,
+		saprobe.SpeakerFrontLeft, saprobe.SpeakerFrontRight, saprobe.SpeakerBackLeft, saprobe.SpeakerBackRight,
+		saprobe.SpeakerLowFrequency,
+	},
+}
+
+// Layout returns the speakers of the channels of an ALAC stream, in the order Decode returns and
+// Encode takes them, or the unknown layout beyond 8 channels.
+func Layout(channels uint) saprobe.ChannelLayout {
+	if channels >= uint(len(speakerLayouts)) {
+		return saprobe.ChannelLayout{}
+	}
+
+	return speakerLayouts[channels]
+}
+
 // Decoder decodes ALAC audio packets into interleaved LE signed PCM.
 type Decoder struct {
 	config      Config
@@ -36,16 +88,37 @@ type Decoder struct {
 	mixBufferV  []int32
 	predictor   []int32
 	shiftBuffer []uint16
+	bits        bitBuffer
+	ended       bool // the last packet decoded stopped at its END element
+}
+
+// packetOutput is where a packet decodes to: interleaved PCM bytes, or one plane of int32 samples
+// per channel.
+type packetOutput struct {
+	pcm    []byte
+	planes [][]int32
 }
 
 // NewDecoder creates a new ALAC decoder from the given configuration.
 func NewDecoder(config Config) (*Decoder, error) {
 	bitDepth, err := saprobe.ToBitDepth(config.BitDepth)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %w", errBitDepth, err)
+		return nil, fmt.Errorf("%w: %w", ErrBitDepth, err)
 	}
 
-	frameLen := int(config.FrameLength)
+	switch {
+	case config.NumChannels == 0 || config.FrameLength == 0:
+		return nil, fmt.Errorf("%w: %d channels, frame length %d",
+			ErrInvalidCookie, config.NumChannels, config.FrameLength)
+	case config.NumChannels > maxChannels:
+		return nil, fmt.Errorf("%w: %d", ErrChannelCount, config.NumChannels)
+	case config.FrameLength > maxFrameLength:
+		return nil, fmt.Errorf("%w: frame length %d", ErrInvalidCookie, config.FrameLength)
+	default:
+	}
+
+	// The predictor warms up on its first maxCoefs samples, whatever the frame length.
+	frameLen := max(int(config.FrameLength), maxCoefs)
 
 	return &Decoder{
 		config: config,
@@ -53,6 +126,7 @@ func NewDecoder(config Config) (*Decoder, error) {
 			SampleRate: int(config.SampleRate),
 			BitDepth:   bitDepth,
 			Channels:   uint(config.NumChannels),
+			Layout:     Layout(uint(config.NumChannels)),
 		},
 		mixBufferU:  make([]int32, frameLen),
 		mixBufferV:  make([]int32, frameLen),
@@ -66,20 +140,72 @@ func (d *Decoder) Format() saprobe.PCMFormat {
 	return d.format
 }
 
+// BufferSize returns the size, in bytes, of the PCM of a full packet: the room DecodePacketInto
+// needs.
+func (d *Decoder) BufferSize() int {
+	return int(d.config.FrameLength) * int(d.config.NumChannels) * d.format.BitDepth.BytesPerSample()
+}
+
 // DecodePacket decodes a single ALAC packet into interleaved LE signed PCM bytes.
 func (d *Decoder) DecodePacket(packet []byte) ([]byte, error) {
-	bits := newBitBuffer(packet)
+	output := make([]byte, d.BufferSize())
+
+	n, err := d.DecodePacketInto(output, packet)
+	if err != nil {
+		return nil, err
+	}
+
+	return output[:n], nil
+}
+
+// DecodePacketInto decodes a single ALAC packet into dst as interleaved LE signed PCM bytes, and
+// returns the number of bytes written. dst must hold BufferSize bytes. Once the decoder has seen
+// its largest packet, decoding allocates nothing.
+func (d *Decoder) DecodePacketInto(dst, packet []byte) (int, error) {
+	if len(dst) < d.BufferSize() {
+		return 0, fmt.Errorf("%w: %d bytes, need %d", ErrShortBuffer, len(dst), d.BufferSize())
+	}
+
+	numSamples, err := d.decodePacket(packetOutput{pcm: dst}, packet)
+	if err != nil {
+		return 0, err
+	}
+
+	return numSamples * int(d.config.NumChannels) * d.format.BitDepth.BytesPerSample(), nil
+}
+
+// DecodePacketInt32 decodes a single ALAC packet into dst, one plane per channel, and returns the
+// number of samples written per channel. dst must hold NumChannels planes of FrameLength samples.
+// Samples are right-aligned at the stream bit depth: 20-bit samples range over [-2^19, 2^19).
+// Once the decoder has seen its largest packet, decoding allocates nothing.
+func (d *Decoder) DecodePacketInt32(dst [][]int32, packet []byte) (int, error) {
+	if len(dst) < int(d.config.NumChannels) {
+		return 0, fmt.Errorf("%w: %d planes, need %d", ErrShortBuffer, len(dst), d.config.NumChannels)
+	}
+
+	for _, plane := range dst[:d.config.NumChannels] {
+		if len(plane) < int(d.config.FrameLength) {
+			return 0, fmt.Errorf("%w: %d samples per plane, need %d", ErrShortBuffer, len(plane), d.config.FrameLength)
+		}
+	}
+
+	return d.decodePacket(packetOutput{planes: dst}, packet)
+}
+
+// decodePacket decodes a packet into output, and returns the number of samples per channel.
+// Channels the packet has no element for are silent.
+func (d *Decoder) decodePacket(output packetOutput, packet []byte) (int, error) {
+	bits := &d.bits
+	bits.reset(packet)
+
 	numSamples := d.config.FrameLength
 	numChan := int(d.config.NumChannels)
-	bps := d.format.BitDepth.BytesPerSample()
 	chanIdx := 0
-
-	// Allocate output for max possible size (Go zero-fills, so remaining channels are silent).
-	output := make([]byte, int(numSamples)*numChan*bps)
+	d.ended = false
 
 	for {
 		if bits.pastEnd() {
-			return nil, errBitstreamOverrun
+			return 0, ErrBitstreamOverrun
 		}
 
 		tag := bits.readSmall(3)
@@ -88,7 +214,7 @@ func (d *Decoder) DecodePacket(packet []byte) ([]byte, error) {
 		case elemSCE, elemLFE:
 			ns, err := d.decodeSCE(bits, output, chanIdx, numChan, numSamples)
 			if err != nil {
-				return nil, fmt.Errorf("alac: SCE/LFE decode: %w", err)
+				return 0, fmt.Errorf("alac: SCE/LFE decode: %w", err)
 			}
 
 			numSamples = ns
@@ -96,33 +222,35 @@ func (d *Decoder) DecodePacket(packet []byte) ([]byte, error) {
 
 		case elemCPE:
 			if chanIdx+2 > numChan {
-				goto done
+				return 0, ErrExtraElement
 			}
 
 			ns, err := d.decodeCPE(bits, output, chanIdx, numChan, numSamples)
 			if err != nil {
-				return nil, fmt.Errorf("alac: CPE decode: %w", err)
+				return 0, fmt.Errorf("alac: CPE decode: %w", err)
 			}
 
 			numSamples = ns
 			chanIdx += 2
 
 		case elemCCE, elemPCE:
-			return nil, errUnsupportedElement
+			return 0, ErrUnsupportedElement
 
 		case elemDSE:
 			if err := d.skipDSE(bits); err != nil {
-				return nil, err
+				return 0, err
 			}
 
 		case elemFIL:
 			if err := d.skipFIL(bits); err != nil {
-				return nil, err
+				return 0, err
 			}
 
 		case elemEND:
 			bits.byteAlign()
 
+			d.ended = true
+
 			goto done
 
 		default:
@@ -134,19 +262,101 @@ func (d *Decoder) DecodePacket(packet []byte) ([]byte, error) {
 	}
 
 done:
-	actualSize := int(numSamples) * numChan * bps
+	// The elements, END included, fill the packet: bytes left over or missing mean the packet
+	// decoded to something other than what was encoded.
+	end, err := d.packetEnd()
+	if err != nil {
+		return 0, err
+	}
+
+	if end != len(packet) {
+		return 0, fmt.Errorf("%w: elements end at byte %d of %d", ErrPacketEnd, end, len(packet))
+	}
+
+	d.silence(output, chanIdx, int(numSamples))
+
+	return int(numSamples), nil
+}
+
+// packetEnd reads on from the last channel element to the packet's END element, and returns the
+// number of bytes the packet's elements take up, END included. Only fill and data stream elements
+// may follow the last channel. Reads past the end land in zero padding; a well-formed packet never
+// gets there.
+func (d *Decoder) packetEnd() (int, error) {
+	bits := &d.bits
+
+	for !d.ended {
+		if bits.pastEnd() {
+			return 0, ErrBitstreamOverrun
+		}
+
+		switch bits.readSmall(3) {
+		case elemDSE:
+			if err := d.skipDSE(bits); err != nil {
+				return 0, err
+			}
+
+		case elemFIL:
+			if err := d.skipFIL(bits); err != nil {
+				return 0, err
+			}
+
+		case elemEND:
+			bits.byteAlign()
+
+			d.ended = true
+
+		default:
+			return 0, ErrExtraElement
+		}
+	}
+
+	if bits.overrun() {
+		return 0, ErrBitstreamOverrun
+	}
+
+	return bits.pos, nil
+}
+
+// silence zeroes the channels from chanIdx on, which the packet had no element for.
+func (d *Decoder) silence(output packetOutput, chanIdx, numSamples int) {
+	numChan := int(d.config.NumChannels)
+
+	if output.planes != nil {
+		for ch := chanIdx; ch < numChan; ch++ {
+			clear(output.planes[ch][:numSamples])
+		}
 
-	return output[:actualSize], nil
+		return
+	}
+
+	bps := d.format.BitDepth.BytesPerSample()
+
+	for ch := chanIdx; ch < numChan; ch++ {
+		for idx := range numSamples {
+			pos := (idx*numChan + ch) * bps
+			clear(output.pcm[pos : pos+bps])
+		}
+	}
 }
 
 // decodeSCE decodes a Single Channel Element (mono) or LFE element.
-func (d *Decoder) decodeSCE(bits *bitBuffer, output []byte, chanIdx, numChan int, numSamples uint32) (uint32, error) {
+func (d *Decoder) decodeSCE(
+	bits *bitBuffer,
+	output packetOutput,
+	chanIdx, numChan int,
+	numSamples uint32,
+) (uint32, error) {
+	if !bits.has(elementFieldsBits) {
+		return 0, ErrBitstreamOverrun
+	}
+
 	_ = bits.readSmall(4) // element instance tag
 
 	// 12 unused header bits (must be 0).
 	unusedHeader := bits.read(unusedHeaderBits)
 	if unusedHeader != 0 {
-		return 0, errInvalidHeader
+		return 0, ErrInvalidHeader
 	}
 
 	headerByte := bits.read(4)
@@ -154,15 +364,23 @@ func (d *Decoder) decodeSCE(bits *bitBuffer, output []byte, chanIdx, numChan int
 	bytesShifted := int((headerByte >> 1) & 0x3)
 
 	if bytesShifted == 3 {
-		return 0, errInvalidShift
+		return 0, ErrInvalidShift
 	}
 
 	escapeFlag := headerByte & 0x1
 	chanBits := uint32(d.config.BitDepth) - uint32(bytesShifted)*8
 
 	if partialFrame != 0 {
+		if !bits.has(partialFrameBits) {
+			return 0, ErrBitstreamOverrun
+		}
+
 		numSamples = bits.read(16) << 16
 		numSamples |= bits.read(16)
+
+		if numSamples > d.config.FrameLength {
+			return 0, fmt.Errorf("%w: %d samples in a frame of %d", ErrSampleOverrun, numSamples, d.config.FrameLength)
+		}
 	}
 
 	if escapeFlag == 0 {
@@ -170,6 +388,10 @@ func (d *Decoder) decodeSCE(bits *bitBuffer, output []byte, chanIdx, numChan int
 			return 0, err
 		}
 	} else {
+		if !bits.has(uint64(chanBits) * uint64(numSamples)) {
+			return 0, ErrBitstreamOverrun
+		}
+
 		d.decodeSCEEscape(bits, chanBits, int(numSamples))
 
 		bytesShifted = 0
@@ -178,15 +400,22 @@ func (d *Decoder) decodeSCE(bits *bitBuffer, output []byte, chanIdx, numChan int
 	// Write output.
 	sampleCount := int(numSamples)
 
+	if output.planes != nil {
+		writeMonoPlane(output.planes[chanIdx], d.mixBufferU, sampleCount,
+			d.shiftBuffer, bytesShifted, d.config.BitDepth)
+
+		return numSamples, nil
+	}
+
 	switch d.config.BitDepth {
 	case 16:
-		writeMono16(output, d.mixBufferU, chanIdx, numChan, sampleCount)
+		writeMono16(output.pcm, d.mixBufferU, chanIdx, numChan, sampleCount)
 	case 20:
-		writeMono20(output, d.mixBufferU, chanIdx, numChan, sampleCount)
+		writeMono20(output.pcm, d.mixBufferU, chanIdx, numChan, sampleCount)
 	case 24:
-		writeMono24(output, d.mixBufferU, chanIdx, numChan, sampleCount, d.shiftBuffer, bytesShifted)
+		writeMono24(output.pcm, d.mixBufferU, chanIdx, numChan, sampleCount, d.shiftBuffer, bytesShifted)
 	case 32:
-		writeMono32(output, d.mixBufferU, chanIdx, numChan, sampleCount, d.shiftBuffer, bytesShifted)
+		writeMono32(output.pcm, d.mixBufferU, chanIdx, numChan, sampleCount, d.shiftBuffer, bytesShifted)
 
 	default:
 		panic(fmt.Sprintf("alac: decodeSCE called with unsupported bit depth %d", d.config.BitDepth))
@@ -196,6 +425,10 @@ func (d *Decoder) decodeSCE(bits *bitBuffer, output []byte, chanIdx, numChan int
 }
 
 func (d *Decoder) decodeSCECompressed(bits *bitBuffer, chanBits uint32, bytesShifted, numSamples int) error {
+	if !bits.has(mixHeaderBits + predictorHeaderBits) {
+		return ErrBitstreamOverrun
+	}
+
 	_ = bits.read(8) // mixBits (unused for mono)
 	_ = bits.read(8) // mixRes (unused for mono)
 
@@ -207,6 +440,10 @@ func (d *Decoder) decodeSCECompressed(bits *bitBuffer, chanBits uint32, bytesShi
 	pbFactorU := headerByte >> 5
 	numU := headerByte & 0x1f
 
+	if !bits.has(uint64(numU) * coefBits) {
+		return ErrBitstreamOverrun
+	}
+
 	var coefsU [maxCoefs]int16
 	for i := range numU {
 		coefsU[i] = int16(bits.read(16))
@@ -215,6 +452,10 @@ func (d *Decoder) decodeSCECompressed(bits *bitBuffer, chanBits uint32, bytesShi
 	// Save shift bits position, skip past them.
 	var shiftBits bitBuffer
 	if bytesShifted != 0 {
+		if !bits.has(uint64(bytesShifted) * 8 * uint64(numSamples)) {
+			return ErrBitstreamOverrun
+		}
+
 		shiftBits = bits.copy()
 		bits.advance(uint32(bytesShifted) * 8 * uint32(numSamples))
 	}
@@ -269,12 +510,21 @@ func (d *Decoder) decodeSCEEscape(bits *bitBuffer, chanBits uint32, numSamples i
 }
 
 // decodeCPE decodes a Channel Pair Element (stereo).
-func (d *Decoder) decodeCPE(bits *bitBuffer, output []byte, chanIdx, numChan int, numSamples uint32) (uint32, error) {
+func (d *Decoder) decodeCPE(
+	bits *bitBuffer,
+	output packetOutput,
+	chanIdx, numChan int,
+	numSamples uint32,
+) (uint32, error) {
+	if !bits.has(elementFieldsBits) {
+		return 0, ErrBitstreamOverrun
+	}
+
 	_ = bits.readSmall(4) // element instance tag
 
 	unusedHeader := bits.read(unusedHeaderBits)
 	if unusedHeader != 0 {
-		return 0, errInvalidHeader
+		return 0, ErrInvalidHeader
 	}
 
 	headerByte := bits.read(4)
@@ -282,7 +532,7 @@ func (d *Decoder) decodeCPE(bits *bitBuffer, output []byte, chanIdx, numChan int
 	bytesShifted := int((headerByte >> 1) & 0x3)
 
 	if bytesShifted == 3 {
-		return 0, errInvalidShift
+		return 0, ErrInvalidShift
 	}
 
 	escapeFlag := headerByte & 0x1
@@ -290,8 +540,16 @@ func (d *Decoder) decodeCPE(bits *bitBuffer, output []byte, chanIdx, numChan int
 	chanBits := uint32(d.config.BitDepth) - uint32(bytesShifted)*8 + 1
 
 	if partialFrame != 0 {
+		if !bits.has(partialFrameBits) {
+			return 0, ErrBitstreamOverrun
+		}
+
 		numSamples = bits.read(16) << 16
 		numSamples |= bits.read(16)
+
+		if numSamples > d.config.FrameLength {
+			return 0, fmt.Errorf("%w: %d samples in a frame of %d", ErrSampleOverrun, numSamples, d.config.FrameLength)
+		}
 	}
 
 	var mixBits, mixRes int32
@@ -305,6 +563,10 @@ func (d *Decoder) decodeCPE(bits *bitBuffer, output []byte, chanIdx, numChan int
 		}
 	} else {
 		chanBits = uint32(d.config.BitDepth) // Reset for escape.
+		if !bits.has(2 * uint64(chanBits) * uint64(numSamples)) {
+			return 0, ErrBitstreamOverrun
+		}
+
 		d.decodeCPEEscape(bits, chanBits, int(numSamples))
 
 		bytesShifted = 0
@@ -313,16 +575,23 @@ func (d *Decoder) decodeCPE(bits *bitBuffer, output []byte, chanIdx, numChan int
 	// Unmix and write output.
 	sampleCount := int(numSamples)
 
+	if output.planes != nil {
+		writeStereoPlanes(output.planes[chanIdx], output.planes[chanIdx+1], d.mixBufferU, d.mixBufferV,
+			sampleCount, mixBits, mixRes, d.shiftBuffer, bytesShifted, d.config.BitDepth)
+
+		return numSamples, nil
+	}
+
 	switch d.config.BitDepth {
 	case 16:
-		writeStereo16(output, d.mixBufferU, d.mixBufferV, chanIdx, numChan, sampleCount, mixBits, mixRes)
+		writeStereo16(output.pcm, d.mixBufferU, d.mixBufferV, chanIdx, numChan, sampleCount, mixBits, mixRes)
 	case 20:
-		writeStereo20(output, d.mixBufferU, d.mixBufferV, chanIdx, numChan, sampleCount, mixBits, mixRes)
+		writeStereo20(output.pcm, d.mixBufferU, d.mixBufferV, chanIdx, numChan, sampleCount, mixBits, mixRes)
 	case 24:
-		writeStereo24(output, d.mixBufferU, d.mixBufferV, chanIdx, numChan, sampleCount,
+		writeStereo24(output.pcm, d.mixBufferU, d.mixBufferV, chanIdx, numChan, sampleCount,
 			mixBits, mixRes, d.shiftBuffer, bytesShifted)
 	case 32:
-		writeStereo32(output, d.mixBufferU, d.mixBufferV, chanIdx, numChan, sampleCount,
+		writeStereo32(output.pcm, d.mixBufferU, d.mixBufferV, chanIdx, numChan, sampleCount,
 			mixBits, mixRes, d.shiftBuffer, bytesShifted)
 
 	default:
@@ -337,6 +606,10 @@ func (d *Decoder) decodeCPECompressed(
 	chanBits uint32,
 	bytesShifted, numSamples int,
 ) (int32, int32, error) { //revive:disable-line:confusing-results
+	if !bits.has(mixHeaderBits + predictorHeaderBits) {
+		return 0, 0, ErrBitstreamOverrun
+	}
+
 	mixBits := int32(bits.read(8))
 	mixRes := int32(int8(bits.read(8)))
 
@@ -349,6 +622,10 @@ func (d *Decoder) decodeCPECompressed(
 	pbFactorU := headerByte >> 5
 	numU := headerByte & 0x1f
 
+	if !bits.has(uint64(numU)*coefBits + predictorHeaderBits) {
+		return 0, 0, ErrBitstreamOverrun
+	}
+
 	var coefsU [maxCoefs]int16
 	for i := range numU {
 		coefsU[i] = int16(bits.read(16))
@@ -363,6 +640,10 @@ func (d *Decoder) decodeCPECompressed(
 	pbFactorV := headerByte >> 5
 	numV := headerByte & 0x1f
 
+	if !bits.has(uint64(numV) * coefBits) {
+		return 0, 0, ErrBitstreamOverrun
+	}
+
 	var coefsV [maxCoefs]int16
 	for i := range numV {
 		coefsV[i] = int16(bits.read(16))
@@ -371,6 +652,10 @@ func (d *Decoder) decodeCPECompressed(
 	// Save shift bits position, skip past interleaved shift data.
 	var shiftBits bitBuffer
 	if bytesShifted != 0 {
+		if !bits.has(uint64(bytesShifted) * 8 * 2 * uint64(numSamples)) {
+			return 0, 0, ErrBitstreamOverrun
+		}
+
 		shiftBits = bits.copy()
 		bits.advance(uint32(bytesShifted) * 8 * 2 * uint32(numSamples))
 	}
@@ -457,7 +742,7 @@ func (*Decoder) skipFIL(bits *bitBuffer) error {
 	bits.advance(uint32(count) * 8)
 
 	if bits.pastEnd() {
-		return errBitstreamOverrun
+		return ErrBitstreamOverrun
 	}
 
 	return nil
@@ -480,7 +765,7 @@ func (*Decoder) skipDSE(bits *bitBuffer) error {
 	bits.advance(uint32(count) * 8)
 
 	if bits.pastEnd() {
-		return errBitstreamOverrun
+		return ErrBitstreamOverrun
 	}
 
 	return nil
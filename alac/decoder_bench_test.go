@@ -0,0 +1,105 @@
+package alac_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/alac"
+)
+
+// benchPacket encodes one full packet of a stereo sine sweep at the given bit depth, and returns
+// it with a decoder for it and its length in samples per channel.
+func benchPacket(b *testing.B, depth saprobe.BitDepth) (*alac.Decoder, []byte, int) {
+	b.Helper()
+
+	format := saprobe.PCMFormat{SampleRate: 44100, BitDepth: depth, Channels: 2}
+
+	enc, err := alac.NewEncoder(format)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	bps := depth.BytesPerSample()
+	frames := int(enc.Config().FrameLength)
+	pcm := make([]byte, frames*2*bps)
+	amplitude := float64(int64(1) << (8*bps - 2))
+
+	for idx := range frames * 2 {
+		value := int64(amplitude * math.Sin(float64(idx*idx)/1e5))
+		for k := range bps {
+			pcm[idx*bps+k] = byte(value >> (8 * k))
+		}
+	}
+
+	packet, err := enc.EncodePacket(pcm)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	dec, err := alac.NewDecoder(enc.Config())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return dec, packet, frames
+}
+
+func BenchmarkDecodePacket(b *testing.B) {
+	for _, depth := range []saprobe.BitDepth{saprobe.Depth16, saprobe.Depth24} {
+		b.Run(fmt.Sprintf("%d-bit", depth), func(b *testing.B) {
+			dec, packet, _ := benchPacket(b, depth)
+
+			b.ReportAllocs()
+
+			for b.Loop() {
+				if _, err := dec.DecodePacket(packet); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDecodePacketInto(b *testing.B) {
+	for _, depth := range []saprobe.BitDepth{saprobe.Depth16, saprobe.Depth24} {
+		b.Run(fmt.Sprintf("%d-bit", depth), func(b *testing.B) {
+			dec, packet, _ := benchPacket(b, depth)
+			dst := make([]byte, dec.BufferSize())
+
+			b.ReportAllocs()
+
+			for b.Loop() {
+				if _, err := dec.DecodePacketInto(dst, packet); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			if allocs := testing.AllocsPerRun(10, func() { _, _ = dec.DecodePacketInto(dst, packet) }); allocs != 0 {
+				b.Errorf("DecodePacketInto allocates %v times per packet", allocs)
+			}
+		})
+	}
+}
+
+func BenchmarkDecodePacketInt32(b *testing.B) {
+	for _, depth := range []saprobe.BitDepth{saprobe.Depth16, saprobe.Depth24} {
+		b.Run(fmt.Sprintf("%d-bit", depth), func(b *testing.B) {
+			dec, packet, frames := benchPacket(b, depth)
+			dst := [][]int32{make([]int32, frames), make([]int32, frames)}
+
+			b.ReportAllocs()
+
+			for b.Loop() {
+				if _, err := dec.DecodePacketInt32(dst, packet); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			if allocs := testing.AllocsPerRun(10, func() { _, _ = dec.DecodePacketInt32(dst, packet) }); allocs != 0 {
+				b.Errorf("DecodePacketInt32 allocates %v times per packet", allocs)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package alac
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/farcloser/saprobe"
+)
+
+// MP4 container constants for writing.
+const (
+	boxHeaderSize      = 8
+	largeBoxHeaderSize = 16
+	trackID            = 1
+	languageUndefined  = 0x55C4 // ISO-639-2 "und", packed
+	fixedPointOne      = 0x00010000
+	volumeFull         = 0x0100
+	tkhdFlags          = 0x7 // enabled | in movie | in preview
+	drefSelfContained  = 0x1
+)
+
+// Encode encodes pcm (interleaved little-endian signed, in ALAC channel order as produced
+// by Decode) and writes it as an M4A file with a single ALAC track. Tags and pictures are
+// written to an iTunes-style ilst. The exact sample count is preserved through the final
+// partial packet and the stts/mdhd durations.
+func Encode(writer io.Writer, pcm []byte, format saprobe.PCMFormat, metadata saprobe.Metadata) error {
+	enc, err := NewEncoder(format)
+	if err != nil {
+		return err
+	}
+
+	config := enc.Config()
+	frameBytes := int(config.NumChannels) * format.BitDepth.BytesPerSample()
+	packetBytes := int(config.FrameLength) * frameBytes
+	totalSamples := uint64(len(pcm) / frameBytes)
+
+	var (
+		packets   [][]byte
+		dataSize  uint64
+		maxPacket int
+	)
+
+	for start := 0; start+frameBytes <= len(pcm); start += packetBytes {
+		end := min(start+packetBytes, len(pcm)-len(pcm)%frameBytes)
+
+		packet, err := enc.EncodePacket(pcm[start:end])
+		if err != nil {
+			return fmt.Errorf("encoding packet %d: %w", len(packets), err)
+		}
+
+		packets = append(packets, packet)
+		dataSize += uint64(len(packet))
+		maxPacket = max(maxPacket, len(packet))
+	}
+
+	config.MaxFrameBytes = uint32(maxPacket)
+	if totalSamples > 0 {
+		bitRate := dataSize * 8 * uint64(config.SampleRate) / totalSamples
+		config.AvgBitRate = uint32(min(bitRate, math.MaxUint32))
+	}
+
+	ftyp := box("ftyp", []byte("M4A "), u32(0), []byte("M4A mp42isom\x00\x00\x00\x00"))
+
+	mdatHeader := boxHeaderSize
+	if dataSize+boxHeaderSize > math.MaxUint32 {
+		mdatHeader = largeBoxHeaderSize
+	}
+
+	chunkOffset := uint64(len(ftyp) + mdatHeader)
+	moov := buildMoov(config, packets, totalSamples, chunkOffset, metadata)
+
+	if _, err := writer.Write(ftyp); err != nil {
+		return fmt.Errorf("writing ftyp: %w", err)
+	}
+
+	if _, err := writer.Write(mdatBoxHeader(dataSize, mdatHeader)); err != nil {
+		return fmt.Errorf("writing mdat header: %w", err)
+	}
+
+	for idx, packet := range packets {
+		if _, err := writer.Write(packet); err != nil {
+			return fmt.Errorf("writing packet %d: %w", idx, err)
+		}
+	}
+
+	if _, err := writer.Write(moov); err != nil {
+		return fmt.Errorf("writing moov: %w", err)
+	}
+
+	return nil
+}
+
+func mdatBoxHeader(dataSize uint64, headerSize int) []byte {
+	if headerSize == largeBoxHeaderSize {
+		out := append(u32(1), "mdat"...)
+
+		return binary.BigEndian.AppendUint64(out, dataSize+largeBoxHeaderSize)
+	}
+
+	return append(u32(uint32(dataSize+boxHeaderSize)), "mdat"...)
+}
+
+// buildMoov assembles the complete moov box for a single ALAC track stored as one chunk.
+func buildMoov(config Config, packets [][]byte, totalSamples, chunkOffset uint64, metadata saprobe.Metadata) []byte {
+	timescale := config.SampleRate
+
+	stbl := box("stbl",
+		fullBox("stsd", 0, 0, u32(1), sampleEntry(config)),
+		buildStts(len(packets), config.FrameLength, totalSamples),
+		fullBox("stsc", 0, 0, u32(1), u32(1), u32(uint32(len(packets))), u32(1)),
+		buildStsz(packets),
+		buildChunkOffset(chunkOffset),
+	)
+
+	minf := box("minf",
+		fullBox("smhd", 0, 0, u16(0), u16(0)),
+		box("dinf", fullBox("dref", 0, 0, u32(1), fullBox("url ", 0, drefSelfContained))),
+		stbl,
+	)
+
+	mdia := box("mdia",
+		timedFullBox("mdhd", 0, totalSamples, func(duration []byte) [][]byte {
+			return [][]byte{u32(timescale), duration, u16(languageUndefined), u16(0)}
+		}),
+		fullBox("hdlr", 0, 0, u32(0), []byte("soun"), make([]byte, 12), []byte("SoundHandler\x00")),
+		minf,
+	)
+
+	tkhd := timedFullBox("tkhd", tkhdFlags, totalSamples, func(duration []byte) [][]byte {
+		return [][]byte{
+			u32(trackID), u32(0), duration, make([]byte, 8),
+			u16(0), u16(1), u16(volumeFull), u16(0), unityMatrix(), u32(0), u32(0),
+		}
+	})
+
+	mvhd := timedFullBox("mvhd", 0, totalSamples, func(duration []byte) [][]byte {
+		return [][]byte{
+			u32(timescale), duration, u32(fixedPointOne), u16(volumeFull),
+			make([]byte, 10), unityMatrix(), make([]byte, 24), u32(trackID + 1),
+		}
+	})
+
+	children := [][]byte{mvhd, box("trak", tkhd, mdia)}
+
+	if ilst := buildIlst(metadata); ilst != nil {
+		hdlr := fullBox("hdlr", 0, 0, u32(0), []byte("mdirappl"), make([]byte, 8), []byte{0})
+		children = append(children, box("udta", fullBox("meta", 0, 0, hdlr, ilst)))
+	}
+
+	return box("moov", children...)
+}
+
+// timedFullBox builds mvhd/tkhd/mdhd, which share a creation/modification/duration
+// layout whose field width depends on the box version.
+func timedFullBox(typ string, flags uint32, duration uint64, fields func(duration []byte) [][]byte) []byte {
+	if duration > math.MaxUint32 {
+		body := fields(binary.BigEndian.AppendUint64(nil, duration))
+
+		return fullBox(typ, 1, flags, append([][]byte{make([]byte, 16)}, body...)...)
+	}
+
+	times := make([]byte, 8)
+	body := fields(u32(uint32(duration)))
+
+	return fullBox(typ, 0, flags, append([][]byte{times}, body...)...)
+}
+
+func sampleEntry(config Config) []byte {
+	rate := config.SampleRate << 16
+	if config.SampleRate > math.MaxUint16 {
+		rate = 0 // does not fit 16.16; the cookie carries the real rate
+	}
+
+	return box("alac",
+		make([]byte, 6), u16(1), // reserved, data reference index
+		u16(0), u16(0), u32(0), // version, revision, vendor
+		u16(uint16(config.NumChannels)), u16(uint16(config.BitDepth)),
+		u16(0), u16(0), u32(rate), // compression ID, packet size, sample rate
+		fullBox("alac", 0, 0, config.Cookie()),
+	)
+}
+
+func buildStts(numPackets int, frameLength uint32, totalSamples uint64) []byte {
+	if numPackets == 0 {
+		return fullBox("stts", 0, 0, u32(0))
+	}
+
+	last := uint32(totalSamples - uint64(numPackets-1)*uint64(frameLength))
+	if last == frameLength {
+		return fullBox("stts", 0, 0, u32(1), u32(uint32(numPackets)), u32(frameLength))
+	}
+
+	if numPackets == 1 {
+		return fullBox("stts", 0, 0, u32(1), u32(1), u32(last))
+	}
+
+	return fullBox("stts", 0, 0, u32(2), u32(uint32(numPackets-1)), u32(frameLength), u32(1), u32(last))
+}
+
+func buildStsz(packets [][]byte) []byte {
+	sizes := make([]byte, 0, 4*len(packets))
+	for _, packet := range packets {
+		sizes = binary.BigEndian.AppendUint32(sizes, uint32(len(packet)))
+	}
+
+	return fullBox("stsz", 0, 0, u32(0), u32(uint32(len(packets))), sizes)
+}
+
+func buildChunkOffset(offset uint64) []byte {
+	if offset > math.MaxUint32 {
+		return fullBox("co64", 0, 0, u32(1), binary.BigEndian.AppendUint64(nil, offset))
+	}
+
+	return fullBox("stco", 0, 0, u32(1), u32(uint32(offset)))
+}
+
+func unityMatrix() []byte {
+	matrix := make([]byte, 0, 36)
+	for _, v := range [...]uint32{fixedPointOne, 0, 0, 0, fixedPointOne, 0, 0, 0, 0x40000000} {
+		matrix = binary.BigEndian.AppendUint32(matrix, v)
+	}
+
+	return matrix
+}
+
+// box serializes an MP4 box from its type and concatenated payload parts.
+func box(typ string, parts ...[]byte) []byte {
+	size := boxHeaderSize
+	for _, part := range parts {
+		size += len(part)
+	}
+
+	out := make([]byte, 0, size)
+	out = binary.BigEndian.AppendUint32(out, uint32(size))
+	out = append(out, typ...)
+
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+
+	return out
+}
+
+// fullBox serializes an MP4 full box (version + 24-bit flags before the payload).
+func fullBox(typ string, version uint8, flags uint32, parts ...[]byte) []byte {
+	header := binary.BigEndian.AppendUint32(nil, uint32(version)<<24|flags&0xFFFFFF)
+
+	return box(typ, append([][]byte{header}, parts...)...)
+}
+
+func u32(v uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+func u16(v uint16) []byte {
+	return binary.BigEndian.AppendUint16(nil, v)
+}
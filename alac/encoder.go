@@ -0,0 +1,312 @@
+package alac
+
+import (
+	"fmt"
+
+	"github.com/farcloser/saprobe"
+)
+
+// Encoder tuning, matching the Apple reference defaults (ALACEncoder.cpp).
+const (
+	defaultFrameLength = 4096
+	defaultPB          = 40
+	defaultMB          = 10
+	defaultKB          = 14
+	defaultMaxRun      = 255
+	defaultMixBits     = 2
+	defaultMixRes      = 2
+	defaultDenShift    = 9
+	defaultPBFactor    = 4
+	numPredictorCoefs  = 8
+	maxChannels        = 8
+
+	// Element header: tag (3) + instance (4) + unused (12) + partial (1) + shift (2) + escape (1).
+	elementHeaderBits = 23
+	partialFrameBits  = 32
+)
+
+// channelLayouts lists the element sequence Apple uses for each channel count.
+//
+//nolint:gochecknoglobals // constant table
+var channelLayouts = [maxChannels + 1][]uint32{
+	1: {elemSCE},
+	2: {elemCPE},
+	3: {elemSCE, elemCPE},
+	4: {elemSCE, elemCPE, elemSCE},
+	5: {elemSCE, elemCPE, elemCPE},
+	6: {elemSCE, elemCPE, elemCPE, elemLFE},
+	7: {elemSCE, elemCPE, elemCPE, elemSCE, elemLFE},
+	8: {elemSCE, elemCPE, elemCPE, elemCPE, elemLFE},
+}
+
+// Encoder encodes interleaved LE signed PCM into ALAC packets.
+// Input channel order is the ALAC element order, i.e. the order DecodePacket produces.
+type Encoder struct {
+	config       Config
+	format       saprobe.PCMFormat
+	bytesShifted int
+	inputL       []int32
+	inputR       []int32
+	mixBufferU   []int32
+	mixBufferV   []int32
+	predictorU   []int32
+	predictorV   []int32
+	shiftBuffer  []uint16
+	scratch      [2]bitWriter
+}
+
+// NewEncoder creates an ALAC encoder for the given PCM format.
+func NewEncoder(format saprobe.PCMFormat) (*Encoder, error) {
+	if _, err := saprobe.ToBitDepth(uint8(format.BitDepth)); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBitDepth, err)
+	}
+
+	if format.Channels == 0 || format.Channels > maxChannels {
+		return nil, fmt.Errorf("%w: %d", ErrChannelCount, format.Channels)
+	}
+
+	frameLen := defaultFrameLength
+
+	// 32-bit audio keeps its low two bytes uncompressed, as the reference encoder does.
+	bytesShifted := 0
+	if format.BitDepth == saprobe.Depth32 {
+		bytesShifted = 2
+	}
+
+	return &Encoder{
+		config: Config{
+			FrameLength: uint32(frameLen),
+			BitDepth:    uint8(format.BitDepth),
+			NumChannels: uint8(format.Channels),
+			PB:          defaultPB,
+			MB:          defaultMB,
+			KB:          defaultKB,
+			MaxRun:      defaultMaxRun,
+			SampleRate:  uint32(format.SampleRate),
+		},
+		format:       format,
+		bytesShifted: bytesShifted,
+		inputL:       make([]int32, frameLen),
+		inputR:       make([]int32, frameLen),
+		mixBufferU:   make([]int32, frameLen),
+		mixBufferV:   make([]int32, frameLen),
+		predictorU:   make([]int32, frameLen),
+		predictorV:   make([]int32, frameLen),
+		shiftBuffer:  make([]uint16, frameLen*2),
+	}, nil
+}
+
+// Config returns the decoder configuration matching the packets this encoder produces.
+// MaxFrameBytes and AvgBitRate are left for the container writer to fill in.
+func (e *Encoder) Config() Config {
+	return e.config
+}
+
+// EncodePacket encodes up to FrameLength interleaved samples into a single ALAC packet.
+// Shorter input produces a partial frame (used for the final packet of a stream).
+func (e *Encoder) EncodePacket(pcm []byte) ([]byte, error) {
+	numChan := int(e.config.NumChannels)
+	frameBytes := numChan * e.format.BitDepth.BytesPerSample()
+
+	if len(pcm) == 0 || len(pcm)%frameBytes != 0 || len(pcm)/frameBytes > int(e.config.FrameLength) {
+		return nil, fmt.Errorf("%w: %d bytes", ErrPacketSize, len(pcm))
+	}
+
+	numSamples := len(pcm) / frameBytes
+	writer := &bitWriter{buf: make([]byte, 0, len(pcm)+len(pcm)/8)}
+	chanIdx := 0
+
+	for _, tag := range channelLayouts[numChan] {
+		if tag == elemCPE {
+			e.encodeCPE(writer, pcm, chanIdx, numSamples)
+			chanIdx += 2
+		} else {
+			e.encodeSCE(writer, tag, pcm, chanIdx, numSamples)
+			chanIdx++
+		}
+	}
+
+	writer.write(elemEND, 3)
+	writer.byteAlign()
+
+	return writer.bytes(), nil
+}
+
+// writeElementHeader writes the common SCE/CPE/LFE header.
+func (e *Encoder) writeElementHeader(w *bitWriter, tag uint32, numSamples, bytesShifted int, escape bool) {
+	partial := numSamples != int(e.config.FrameLength)
+
+	w.write(tag, 3)
+	w.write(0, 4)                // element instance tag
+	w.write(0, unusedHeaderBits) // unused header bits (must be 0)
+
+	var flags uint32
+	if partial {
+		flags |= 1 << 3
+	}
+
+	flags |= uint32(bytesShifted) << 1
+
+	if escape {
+		flags |= 1
+	}
+
+	w.write(flags, 4)
+
+	if partial {
+		w.write(uint32(numSamples), partialFrameBits)
+	}
+}
+
+// escapeBits returns the size of an uncompressed element carrying numChans channels.
+func (e *Encoder) escapeBits(numSamples, numChans int) int {
+	bits := elementHeaderBits + numSamples*numChans*int(e.config.BitDepth)
+	if numSamples != int(e.config.FrameLength) {
+		bits += partialFrameBits
+	}
+
+	return bits
+}
+
+// compressChannel converges predictor coefficients on in, then writes the predictor
+// parameters. It returns the coefficients the residuals must be computed with.
+func (e *Encoder) compressChannel(w *bitWriter, in, pred []int32, numSamples int, chanBits uint32) [maxCoefs]int16 {
+	var coefs [maxCoefs]int16
+
+	// One adaptation pass over the block yields better starting coefficients.
+	initCoefs(coefs[:numPredictorCoefs], defaultDenShift)
+	pcBlock(in, pred, numSamples, coefs[:numPredictorCoefs], numPredictorCoefs, chanBits, defaultDenShift)
+
+	w.write(0<<4|defaultDenShift, 8) // mode 0, denShift
+	w.write(defaultPBFactor<<5|numPredictorCoefs, 8)
+
+	for i := range numPredictorCoefs {
+		w.write(uint32(uint16(coefs[i])), 16)
+	}
+
+	return coefs
+}
+
+// entropyCode predicts in with coefs and entropy codes the residuals.
+func (e *Encoder) entropyCode(w *bitWriter, in, pred []int32, coefs [maxCoefs]int16, numSamples int, chanBits uint32) {
+	pcBlock(in, pred, numSamples, coefs[:numPredictorCoefs], numPredictorCoefs, chanBits, defaultDenShift)
+
+	var agP agParams
+	setAGParams(&agP, uint32(e.config.MB), (uint32(e.config.PB)*defaultPBFactor)/4, uint32(e.config.KB),
+		uint32(numSamples), uint32(numSamples), uint32(e.config.MaxRun))
+
+	dynComp(&agP, pred, w, numSamples, int(chanBits))
+}
+
+// encodeSCE encodes a Single Channel Element (mono) or LFE element.
+func (e *Encoder) encodeSCE(w *bitWriter, tag uint32, pcm []byte, chanIdx, numSamples int) {
+	numChan := int(e.config.NumChannels)
+	readChannel(pcm, e.format.BitDepth, chanIdx, numChan, numSamples, e.inputL)
+
+	comp := &e.scratch[0]
+	comp.reset()
+
+	// The adaptive predictor needs more samples than coefficients.
+	if numSamples > numPredictorCoefs+1 {
+		chanBits := uint32(e.config.BitDepth) - uint32(e.bytesShifted)*8
+		splitShift(e.inputL, e.mixBufferU, numSamples, e.shiftBuffer, 1, 0, e.bytesShifted)
+
+		e.writeElementHeader(comp, tag, numSamples, e.bytesShifted, false)
+		comp.write(0, 8) // mixBits (unused for mono)
+		comp.write(0, 8) // mixRes (unused for mono)
+
+		coefs := e.compressChannel(comp, e.mixBufferU, e.predictorU, numSamples, chanBits)
+
+		if e.bytesShifted != 0 {
+			shift := uint32(e.bytesShifted) * 8
+			for i := range numSamples {
+				comp.write(uint32(e.shiftBuffer[i]), shift)
+			}
+		}
+
+		e.entropyCode(comp, e.mixBufferU, e.predictorU, coefs, numSamples, chanBits)
+
+		if comp.bitLen() < e.escapeBits(numSamples, 1) {
+			w.appendBits(comp)
+
+			return
+		}
+	}
+
+	// Escape: raw samples at full bit depth.
+	chanBits := uint32(e.config.BitDepth)
+
+	e.writeElementHeader(w, tag, numSamples, 0, true)
+
+	for i := range numSamples {
+		w.write(uint32(e.inputL[i]), chanBits)
+	}
+}
+
+// encodeCPE encodes a Channel Pair Element (stereo), picking the cheapest stereo matrix.
+func (e *Encoder) encodeCPE(w *bitWriter, pcm []byte, chanIdx, numSamples int) {
+	numChan := int(e.config.NumChannels)
+	readChannel(pcm, e.format.BitDepth, chanIdx, numChan, numSamples, e.inputL)
+	readChannel(pcm, e.format.BitDepth, chanIdx+1, numChan, numSamples, e.inputR)
+
+	var best *bitWriter
+
+	if numSamples > numPredictorCoefs+1 {
+		for i, mixRes := range [...]int32{0, defaultMixRes} {
+			candidate := &e.scratch[i]
+			candidate.reset()
+			e.compressCPE(candidate, numSamples, defaultMixBits, mixRes)
+
+			if best == nil || candidate.bitLen() < best.bitLen() {
+				best = candidate
+			}
+		}
+	}
+
+	if best != nil && best.bitLen() < e.escapeBits(numSamples, 2) {
+		w.appendBits(best)
+
+		return
+	}
+
+	// Escape: raw interleaved samples at full bit depth.
+	chanBits := uint32(e.config.BitDepth)
+
+	e.writeElementHeader(w, elemCPE, numSamples, 0, true)
+
+	for i := range numSamples {
+		w.write(uint32(e.inputL[i]), chanBits)
+		w.write(uint32(e.inputR[i]), chanBits)
+	}
+}
+
+func (e *Encoder) compressCPE(w *bitWriter, numSamples int, mixBits, mixRes int32) {
+	// CPE has +1 bit for decorrelation.
+	chanBits := uint32(e.config.BitDepth) - uint32(e.bytesShifted)*8 + 1
+
+	// Split off shifted bytes (interleaved U/V), then mix the remaining upper bits.
+	splitShift(e.inputL, e.predictorU, numSamples, e.shiftBuffer, 2, 0, e.bytesShifted)
+	splitShift(e.inputR, e.predictorV, numSamples, e.shiftBuffer, 2, 1, e.bytesShifted)
+	mixStereo(e.predictorU, e.predictorV, e.mixBufferU, e.mixBufferV, numSamples, mixBits, mixRes)
+
+	if mixRes == 0 {
+		mixBits = 0
+	}
+
+	e.writeElementHeader(w, elemCPE, numSamples, e.bytesShifted, false)
+	w.write(uint32(mixBits), 8)
+	w.write(uint32(uint8(int8(mixRes))), 8)
+
+	coefsU := e.compressChannel(w, e.mixBufferU, e.predictorU, numSamples, chanBits)
+	coefsV := e.compressChannel(w, e.mixBufferV, e.predictorV, numSamples, chanBits)
+
+	if e.bytesShifted != 0 {
+		shift := uint32(e.bytesShifted) * 8
+		for i := range numSamples * 2 {
+			w.write(uint32(e.shiftBuffer[i]), shift)
+		}
+	}
+
+	e.entropyCode(w, e.mixBufferU, e.predictorU, coefsU, numSamples, chanBits)
+	e.entropyCode(w, e.mixBufferV, e.predictorV, coefsV, numSamples, chanBits)
+}
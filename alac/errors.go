@@ -2,20 +2,46 @@ package alac
 
 import "errors"
 
+// Bitstream errors: the packet or magic cookie is corrupt.
 var (
-	errInvalidCookie      = errors.New("alac: invalid magic cookie")
-	errUnsupportedVersion = errors.New("alac: unsupported compatible version")
-	errUnsupportedElement = errors.New("alac: unsupported element type (CCE/PCE)")
-	errInvalidHeader      = errors.New("alac: invalid frame header")
-	errInvalidShift       = errors.New("alac: invalid bytesShifted value")
-	errBitstreamOverrun   = errors.New("alac: bitstream overrun")
-	errSampleOverrun      = errors.New("alac: sample count exceeds buffer")
-	errBitDepth           = errors.New("alac: unsupported bit depth")
-	errNoALACTrack        = errors.New("alac: no ALAC track found in container")
-	errNoChunkOffset      = errors.New("alac: no chunk offset box (stco/co64)")
-	errInvalidCo64        = errors.New("alac: invalid co64 payload")
-	errNoStsc             = errors.New("alac: no stsc box")
-	errInvalidStsc        = errors.New("alac: invalid stsc payload")
-	errNoStsz             = errors.New("alac: no stsz box")
-	errInvalidStsz        = errors.New("alac: invalid stsz payload")
+	ErrInvalidCookie    = errors.New("alac: invalid magic cookie")
+	ErrInvalidHeader    = errors.New("alac: invalid frame header")
+	ErrInvalidShift     = errors.New("alac: invalid bytesShifted value")
+	ErrBitstreamOverrun = errors.New("alac: bitstream overrun")
+	ErrSampleOverrun    = errors.New("alac: sample count exceeds buffer")
+	ErrExtraElement     = errors.New("alac: audio element past the last channel")
+	ErrPacketEnd        = errors.New("alac: packet elements do not end at the packet size")
 )
+
+// Unsupported stream errors: the stream is valid but uses a feature this decoder does not implement.
+var (
+	ErrUnsupportedVersion = errors.New("alac: unsupported compatible version")
+	ErrUnsupportedElement = errors.New("alac: unsupported element type (CCE/PCE)")
+	ErrBitDepth           = errors.New("alac: unsupported bit depth")
+	ErrChannelCount       = errors.New("alac: unsupported channel count")
+)
+
+// Container errors: the MP4 boxes describing the track are missing or malformed.
+var (
+	ErrNoALACTrack   = errors.New("alac: no ALAC track found in container")
+	ErrNoChunkOffset = errors.New("alac: no chunk offset box (stco/co64)")
+	ErrInvalidCo64   = errors.New("alac: invalid co64 payload")
+	ErrNoStsc        = errors.New("alac: no stsc box")
+	ErrInvalidStsc   = errors.New("alac: invalid stsc payload")
+	ErrNoStsz        = errors.New("alac: no stsz box")
+	ErrInvalidStsz   = errors.New("alac: invalid stsz payload")
+	ErrNoMdhd        = errors.New("alac: no mdhd box")
+)
+
+// ErrShortBuffer is returned by DecodePacketInto and DecodePacketInt32 for a destination too small
+// for a full packet.
+var ErrShortBuffer = errors.New("alac: destination buffer too small for a packet")
+
+// ErrPacketSize is returned by the encoder for input that is not a whole number of frames within
+// FrameLength.
+var ErrPacketSize = errors.New("alac: packet input is not a whole number of frames within FrameLength")
+
+// unsupportedErrors lists the errors categorized as saprobe.ErrUnsupported.
+//
+//nolint:gochecknoglobals // constant table
+var unsupportedErrors = []error{ErrUnsupportedVersion, ErrUnsupportedElement, ErrBitDepth, ErrChannelCount}
@@ -165,9 +165,13 @@ func dynGet32Bit(input []byte, bitPos *uint32, golombM uint32, golombK, maxBits
 // dynDecomp performs adaptive Golomb-Rice entropy decoding of a sample block.
 // Writes decoded prediction residuals into predCoefs.
 func dynDecomp(params *agParams, bitBuf *bitBuffer, predCoefs []int32, numSamples, maxSize int) error {
+	if bitBuf.pos > bitBuf.size {
+		return ErrBitstreamOverrun
+	}
+
 	input := bitBuf.buf[bitBuf.pos:]
 	startPos := bitBuf.bitIdx
-	maxPos := uint32(bitBuf.size) * 8
+	maxPos := uint32(bitBuf.size-bitBuf.pos) * 8
 	bitPos := startPos
 
 	meanAccum := params.mb0
@@ -180,7 +184,7 @@ func dynDecomp(params *agParams, bitBuf *bitBuffer, predCoefs []int32, numSample
 
 	for count < numSamples {
 		if bitPos >= maxPos {
-			return errBitstreamOverrun
+			return ErrBitstreamOverrun
 		}
 
 		m := meanAccum >> qbShift
@@ -215,10 +219,14 @@ func dynDecomp(params *agParams, bitBuf *bitBuffer, predCoefs []int32, numSample
 
 			mz := ((uint32(1) << uint32(k32)) - 1) & wbLocal
 
+			if bitPos >= maxPos {
+				return ErrBitstreamOverrun
+			}
+
 			residual = dynGet(input, &bitPos, mz, uint32(k32))
 
 			if count+int(residual) > numSamples {
-				return errSampleOverrun
+				return ErrSampleOverrun
 			}
 
 			for range residual {
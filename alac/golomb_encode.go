@@ -0,0 +1,113 @@
+package alac
+
+// Adaptive Golomb-Rice entropy encoder.
+// Ported from ag_enc.c; mirrors dynDecomp bit for bit.
+
+// dynCode writes one Golomb-coded value (16-bit variant used for zero-run counts).
+func dynCode(w *bitWriter, golombM, golombK, value uint32) {
+	div := value / golombM
+
+	if div < maxPrefix16 {
+		mod := value % golombM
+
+		var de uint32
+		if mod == 0 {
+			de = 1
+		}
+
+		numBits := div + golombK + 1 - de
+		if numBits <= maxPrefix16+maxDatatype16 {
+			w.write((1<<div-1)<<(numBits-div)+mod+1-de, numBits)
+
+			return
+		}
+	}
+
+	w.write(1<<maxPrefix16-1, maxPrefix16)
+	w.write(value, maxDatatype16)
+}
+
+// dynCode32Bit writes one Golomb-coded value (32-bit variant used for sample residuals).
+func dynCode32Bit(w *bitWriter, golombM, golombK, value uint32, maxBits uint32) {
+	div := value / golombM
+
+	if div < maxPrefix32 {
+		mod := value - golombM*div
+
+		var de uint32
+		if mod == 0 {
+			de = 1
+		}
+
+		numBits := div + golombK + 1 - de
+		w.write((1<<div-1)<<(numBits-div)+mod+1-de, numBits)
+
+		return
+	}
+
+	w.write(1<<maxPrefix32-1, maxPrefix32)
+	w.write(value, maxBits)
+}
+
+// dynComp performs adaptive Golomb-Rice entropy coding of a block of prediction residuals.
+func dynComp(params *agParams, predCoefs []int32, w *bitWriter, numSamples, maxSize int) {
+	meanAccum := params.mb0
+	zmode := uint32(0)
+	count := 0
+
+	pbLocal := params.pb
+	kbLocal := params.kb
+	wbLocal := params.wb
+
+	for count < numSamples {
+		m := meanAccum >> qbShift
+		k := min(lg3a(int32(m)), int32(kbLocal))
+
+		m = (1 << uint32(k)) - 1
+
+		// Fold the sign into the LSB: 0, -1, 1, -2, 2... map to 0, 1, 2, 3, 4...
+		del := predCoefs[count]
+		count++
+
+		var ndecode uint32
+		if del < 0 {
+			ndecode = uint32(-del)*2 - 1
+		} else {
+			ndecode = uint32(del) * 2
+		}
+
+		residual := ndecode - zmode
+		dynCode32Bit(w, m, uint32(k), residual, uint32(maxSize))
+
+		// Update mean.
+		meanAccum = pbLocal*(residual+zmode) + meanAccum - ((pbLocal * meanAccum) >> qbShift)
+		if residual > nMaxMeanClamp {
+			meanAccum = nMeanClampVal
+		}
+
+		zmode = 0
+
+		// Enter zero run mode exactly where the decoder does.
+		if (meanAccum<<mmulShift) < quantBits && count < numSamples {
+			zmode = 1
+
+			run := uint32(0)
+			for count+int(run) < numSamples && predCoefs[count+int(run)] == 0 && run < maxZeroRun {
+				run++
+			}
+
+			k32 := max(lead(int32(meanAccum))-bitoff+int32((meanAccum+moff)>>mdenShift), 0)
+			mz := ((uint32(1) << uint32(k32)) - 1) & wbLocal
+
+			dynCode(w, mz, uint32(k32), run)
+
+			count += int(run)
+
+			if run >= maxZeroRun {
+				zmode = 0
+			}
+
+			meanAccum = 0
+		}
+	}
+}
@@ -5,7 +5,7 @@ import "encoding/binary"
 // Matrix unmix and output byte formatting.
 // Ported from matrix_dec.c.
 //
-// All output is interleaved little-endian signed PCM.
+// Output is interleaved little-endian signed PCM, or planes of int32 samples.
 
 // --- Stereo unmix (channel pair) ---
 
@@ -210,3 +210,45 @@ func writeMono32(out []byte, mixU []int32, chanIdx, numChan, numSamples int, shi
 		binary.LittleEndian.PutUint32(out[pos:], uint32(val))
 	}
 }
+
+// --- Planar output (int32 samples) ---
+
+//revive:disable-next-line:argument-limit
+func writeStereoPlanes(left, right, mixU, mixV []int32, numSamples int,
+	mixBits, mixRes int32, shiftBuf []uint16, bytesShifted int, bitDepth uint8,
+) {
+	shift := bytesShifted * 8
+	headroom := 32 - bitDepth
+
+	for idx := range numSamples {
+		l, r := mixU[idx], mixV[idx]
+
+		if mixRes != 0 {
+			l = mixU[idx] + mixV[idx] - ((mixRes * mixV[idx]) >> mixBits)
+			r = l - mixV[idx]
+		}
+
+		if bytesShifted != 0 {
+			l = (l << shift) | int32(shiftBuf[idx*2+0])
+			r = (r << shift) | int32(shiftBuf[idx*2+1])
+		}
+
+		// Sign-extend from the bit depth, as the byte output truncates to it.
+		left[idx] = (l << headroom) >> headroom
+		right[idx] = (r << headroom) >> headroom
+	}
+}
+
+func writeMonoPlane(plane, mixU []int32, numSamples int, shiftBuf []uint16, bytesShifted int, bitDepth uint8) {
+	shift := bytesShifted * 8
+	headroom := 32 - bitDepth
+
+	for idx := range numSamples {
+		val := mixU[idx]
+		if bytesShifted != 0 {
+			val = (val << shift) | int32(shiftBuf[idx])
+		}
+
+		plane[idx] = (val << headroom) >> headroom
+	}
+}
@@ -0,0 +1,65 @@
+package alac
+
+import (
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/internal/pcmio"
+)
+
+// Input parsing, shift-byte extraction and stereo mixing.
+// Ported from matrix_enc.c.
+//
+// All input is interleaved little-endian signed PCM in the layout DecodePacket produces.
+
+// readChannel extracts one channel from interleaved PCM into dst as native-range samples.
+// 20-bit samples are stored left-aligned in 24 bits and are shifted back down.
+func readChannel(in []byte, depth saprobe.BitDepth, chanIdx, numChan, numSamples int, dst []int32) {
+	bps := depth.BytesPerSample()
+	stride := numChan * bps
+	pos := chanIdx * bps
+	shift := 8*uint(bps) - uint(depth)
+
+	for idx := range numSamples {
+		dst[idx] = int32(pcmio.ReadSample(in[pos:], bps) >> shift) //nolint:gosec // fits the bit depth.
+		pos += stride
+	}
+}
+
+// splitShift moves the low bytesShifted*8 bits of each sample into shiftBuf
+// (at shiftBuf[idx*step+offset]) and writes the remaining upper bits to out.
+func splitShift(in, out []int32, numSamples int, shiftBuf []uint16, step, offset, bytesShifted int) {
+	if bytesShifted == 0 {
+		copy(out[:numSamples], in[:numSamples])
+
+		return
+	}
+
+	shift := bytesShifted * 8
+	mask := int32(1)<<shift - 1
+
+	for idx := range numSamples {
+		shiftBuf[idx*step+offset] = uint16(in[idx] & mask)
+		out[idx] = in[idx] >> shift
+	}
+}
+
+// mixStereo converts a left/right pair into the U/V representation undone by writeStereo*.
+// With mixRes == 0 the channels are stored independently.
+func mixStereo(left, right, mixU, mixV []int32, numSamples int, mixBits, mixRes int32) {
+	if mixRes == 0 {
+		copy(mixU[:numSamples], left[:numSamples])
+		copy(mixV[:numSamples], right[:numSamples])
+
+		return
+	}
+
+	mod := int32(1) << mixBits
+	m2 := mod - mixRes
+
+	for idx := range numSamples {
+		l := left[idx]
+		r := right[idx]
+
+		mixU[idx] = (mixRes*l + m2*r) >> mixBits
+		mixV[idx] = l - r
+	}
+}
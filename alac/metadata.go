@@ -0,0 +1,304 @@
+package alac
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	mp4 "github.com/abema/go-mp4"
+
+	"github.com/farcloser/saprobe"
+)
+
+// iTunes metadata constants.
+const (
+	dataTypeImplicit = 0
+	dataTypeUTF8     = 1
+	dataTypeJPEG     = 13
+	dataTypePNG      = 14
+	dataTypeBMP      = 27
+	dataHeaderSize   = 8 // type indicator (4) + locale (4)
+	pairAtomSize     = 8 // reserved (2) + number (2) + total (2) + reserved (2)
+	freeformMean     = "com.apple.iTunes"
+)
+
+// ilstKeys maps iTunes ilst item types to saprobe tag keys.
+//
+//nolint:gochecknoglobals // constant table
+var ilstKeys = []struct {
+	atom string
+	key  string
+}{
+	{"\xa9nam", saprobe.TagTitle},
+	{"\xa9ART", saprobe.TagArtist},
+	{"\xa9alb", saprobe.TagAlbum},
+	{"aART", saprobe.TagAlbumArtist},
+	{"\xa9day", saprobe.TagDate},
+	{"\xa9gen", saprobe.TagGenre},
+	{"\xa9cmt", saprobe.TagComment},
+	{"\xa9wrt", saprobe.TagComposer},
+	{"cprt", saprobe.TagCopyright},
+	{"\xa9too", saprobe.TagEncoder},
+	{"\xa9grp", saprobe.TagGrouping},
+	{"\xa9lyr", saprobe.TagLyrics},
+}
+
+// ReadMetadata returns the iTunes-style tags (moov/udta/meta/ilst) and cover art of an M4A stream.
+// Freeform "----" items in the com.apple.iTunes namespace are returned under their own name.
+func ReadMetadata(reader io.ReadSeeker) (saprobe.Metadata, error) {
+	var metadata saprobe.Metadata
+
+	ilsts, err := mp4.ExtractBox(reader, nil, mp4.BoxPath{
+		mp4.BoxTypeMoov(), mp4.BoxTypeUdta(), mp4.BoxTypeMeta(), mp4.BoxTypeIlst(),
+	})
+	if err != nil {
+		return metadata, fmt.Errorf("reading container structure: %w", err)
+	}
+
+	for _, ilst := range ilsts {
+		payload, err := readPayload(reader, ilst)
+		if err != nil {
+			return metadata, err
+		}
+
+		for atom, item := range children(payload) {
+			decodeItem(&metadata, atom, item)
+		}
+	}
+
+	return metadata, nil
+}
+
+// ReadFormat returns the format of the PCM Decode outputs and the samples per channel the track
+// declares in stts, or else in mdhd, 0 when neither is usable. Only the sample tables are read.
+func ReadFormat(reader io.ReadSeeker) (saprobe.PCMFormat, int64, error) {
+	track, err := findALACTrack(reader)
+	if err != nil {
+		return saprobe.PCMFormat{}, 0, err
+	}
+
+	config, err := ParseConfig(track.cookie)
+	if err != nil {
+		return saprobe.PCMFormat{}, 0, fmt.Errorf("parsing ALAC config: %w", err)
+	}
+
+	dec, err := NewDecoder(config)
+	if err != nil {
+		return saprobe.PCMFormat{}, 0, err
+	}
+
+	return dec.Format(), declaredSamples(reader, track, config), nil
+}
+
+// children iterates over the boxes packed in data, yielding each type and payload.
+// Iteration stops at the first malformed box.
+func children(data []byte) func(yield func(string, []byte) bool) {
+	return func(yield func(string, []byte) bool) {
+		for len(data) >= boxHeaderSize {
+			size := int(binary.BigEndian.Uint32(data[0:4]))
+			if size < boxHeaderSize || size > len(data) {
+				return
+			}
+
+			if !yield(string(data[4:8]), data[boxHeaderSize:size]) {
+				return
+			}
+
+			data = data[size:]
+		}
+	}
+}
+
+func decodeItem(metadata *saprobe.Metadata, atom string, item []byte) {
+	var (
+		name   string
+		mean   string
+		values [][]byte
+		types  []uint32
+	)
+
+	for typ, payload := range children(item) {
+		switch typ {
+		case "mean":
+			if len(payload) >= 4 {
+				mean = string(payload[4:])
+			}
+		case "name":
+			if len(payload) >= 4 {
+				name = string(payload[4:])
+			}
+		case "data":
+			if len(payload) >= dataHeaderSize {
+				types = append(types, binary.BigEndian.Uint32(payload[0:4])&0xFFFFFF)
+				values = append(values, payload[dataHeaderSize:])
+			}
+		}
+	}
+
+	for idx, value := range values {
+		switch atom {
+		case "trkn":
+			addPair(metadata, value, saprobe.TagTrackNumber, saprobe.TagTrackTotal)
+		case "disk":
+			addPair(metadata, value, saprobe.TagDiscNumber, saprobe.TagDiscTotal)
+		case "covr":
+			addPicture(metadata, types[idx], value)
+		case "----":
+			if mean == freeformMean && name != "" {
+				metadata.Add(name, string(value))
+			}
+		default:
+			for _, k := range ilstKeys {
+				if k.atom == atom {
+					metadata.Add(k.key, string(value))
+
+					break
+				}
+			}
+		}
+	}
+}
+
+func addPair(metadata *saprobe.Metadata, value []byte, numberKey, totalKey string) {
+	if len(value) < 6 {
+		return
+	}
+
+	if number := binary.BigEndian.Uint16(value[2:4]); number != 0 {
+		metadata.Add(numberKey, strconv.Itoa(int(number)))
+	}
+
+	if total := binary.BigEndian.Uint16(value[4:6]); total != 0 {
+		metadata.Add(totalKey, strconv.Itoa(int(total)))
+	}
+}
+
+func addPicture(metadata *saprobe.Metadata, dataType uint32, value []byte) {
+	var mime string
+
+	switch dataType {
+	case dataTypeJPEG:
+		mime = "image/jpeg"
+	case dataTypePNG:
+		mime = "image/png"
+	case dataTypeBMP:
+		mime = "image/bmp"
+	}
+
+	metadata.Pictures = append(metadata.Pictures, saprobe.Picture{
+		Type: saprobe.PictureFrontCover,
+		MIME: mime,
+		Data: value,
+	})
+}
+
+// buildIlst serializes tags and pictures as an iTunes ilst box. Tags without a dedicated
+// atom are written as com.apple.iTunes freeform items. Returns nil when there is nothing to write.
+func buildIlst(metadata saprobe.Metadata) []byte {
+	var items [][]byte
+
+	handled := map[string]bool{}
+
+	for _, k := range ilstKeys {
+		handled[k.key] = true
+
+		if value, ok := metadata.Get(k.key); ok {
+			items = append(items, box(k.atom, dataBox(dataTypeUTF8, []byte(value))))
+		}
+	}
+
+	for _, pair := range [...]struct {
+		atom                string
+		numberKey, totalKey string
+	}{
+		{"trkn", saprobe.TagTrackNumber, saprobe.TagTrackTotal},
+		{"disk", saprobe.TagDiscNumber, saprobe.TagDiscTotal},
+	} {
+		handled[pair.numberKey] = true
+		handled[pair.totalKey] = true
+
+		number, total := pairValue(metadata, pair.numberKey)
+		if value, _ := pairValue(metadata, pair.totalKey); value != 0 {
+			total = value
+		}
+
+		if number == 0 && total == 0 {
+			continue
+		}
+
+		value := make([]byte, 0, pairAtomSize)
+		value = append(value, u16(0)...)
+		value = append(value, u16(number)...)
+		value = append(value, u16(total)...)
+		value = append(value, u16(0)...)
+
+		items = append(items, box(pair.atom, dataBox(dataTypeImplicit, value)))
+	}
+
+	for _, tag := range metadata.Tags {
+		key := strings.ToUpper(tag.Key)
+		if handled[key] {
+			continue
+		}
+
+		items = append(items, box("----",
+			fullBox("mean", 0, 0, []byte(freeformMean)),
+			fullBox("name", 0, 0, []byte(key)),
+			dataBox(dataTypeUTF8, []byte(tag.Value)),
+		))
+	}
+
+	var covers [][]byte
+
+	for _, picture := range metadata.Pictures {
+		dataType := uint32(dataTypeImplicit)
+
+		switch picture.MIME {
+		case "image/jpeg", "image/jpg":
+			dataType = dataTypeJPEG
+		case "image/png":
+			dataType = dataTypePNG
+		case "image/bmp":
+			dataType = dataTypeBMP
+		}
+
+		covers = append(covers, dataBox(dataType, picture.Data))
+	}
+
+	if len(covers) > 0 {
+		items = append(items, box("covr", covers...))
+	}
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	return box("ilst", items...)
+}
+
+// pairValue parses a tag such as "3" or "3/12" into its number and optional total.
+func pairValue(metadata saprobe.Metadata, key string) (uint16, uint16) {
+	value, ok := metadata.Get(key)
+	if !ok {
+		return 0, 0
+	}
+
+	number, total, _ := strings.Cut(value, "/")
+
+	return parseUint16(number), parseUint16(total)
+}
+
+func parseUint16(value string) uint16 {
+	number, err := strconv.ParseUint(strings.TrimSpace(value), 10, 16)
+	if err != nil {
+		return 0
+	}
+
+	return uint16(number)
+}
+
+func dataBox(dataType uint32, value []byte) []byte {
+	return box("data", u32(dataType), u32(0), value)
+}
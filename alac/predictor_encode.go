@@ -0,0 +1,99 @@
+package alac
+
+// Dynamic predictor (forward linear prediction).
+// Ported from dp_enc.c; adapts coefficients exactly as unpcBlock does.
+
+// Apple reference initial coefficients (dplib.h AINIT/BINIT/CINIT), scaled by 1<<denShift>>4.
+const (
+	coefInitA = 38
+	coefInitB = -29
+	coefInitC = -2
+)
+
+// initCoefs fills coefs with the reference starting predictor.
+func initCoefs(coefs []int16, denShift uint32) {
+	den := int32(1) << denShift
+
+	clear(coefs)
+	coefs[0] = int16((coefInitA * den) >> 4)
+	coefs[1] = int16((coefInitB * den) >> 4)
+	coefs[2] = int16((coefInitC * den) >> 4)
+}
+
+// pcBlock computes prediction residuals for in into pc1, the inverse of unpcBlock.
+// coefs is updated in place the same way the decoder will update it.
+// Requires num > numActive when 0 < numActive < numActiveDelta.
+func pcBlock(in, pc1 []int32, num int, coefs []int16, numActive int32, chanBits, denShift uint32) {
+	chanShift := uint32(32) - chanBits
+
+	var denHalf int32
+	if denShift > 0 {
+		denHalf = 1 << (denShift - 1)
+	}
+
+	pc1[0] = in[0]
+
+	if numActive == 0 {
+		copy(pc1[1:num], in[1:num])
+
+		return
+	}
+
+	if numActive == numActiveDelta {
+		for idx := 1; idx < num; idx++ {
+			del := in[idx] - in[idx-1]
+			pc1[idx] = (del << chanShift) >> chanShift
+		}
+
+		return
+	}
+
+	// Warm-up phase mirrors the decoder's growing first-order reconstruction.
+	for idx := 1; idx <= int(numActive); idx++ {
+		del := in[idx] - in[idx-1]
+		pc1[idx] = (del << chanShift) >> chanShift
+	}
+
+	lim := int(numActive) + 1
+
+	for idx := lim; idx < num; idx++ {
+		var sum1 int32
+
+		top := in[idx-lim]
+
+		for k := range numActive {
+			sum1 += int32(coefs[k]) * (in[idx-1-int(k)] - top)
+		}
+
+		del := in[idx] - top - ((sum1 + denHalf) >> denShift)
+		del = (del << chanShift) >> chanShift
+		pc1[idx] = del
+
+		del0 := del
+		sign := signOfInt(del)
+
+		if sign > 0 {
+			for k := numActive - 1; k >= 0; k-- {
+				dd := top - in[idx-1-int(k)]
+				sgn := signOfInt(dd)
+				coefs[k] -= int16(sgn)
+
+				del0 -= (numActive - k) * ((sgn * dd) >> int32(denShift))
+				if del0 <= 0 {
+					break
+				}
+			}
+		} else if sign < 0 {
+			for k := numActive - 1; k >= 0; k-- {
+				dd := top - in[idx-1-int(k)]
+				sgn := signOfInt(dd)
+				coefs[k] += int16(sgn)
+
+				del0 -= (numActive - k) * ((-sgn * dd) >> int32(denShift))
+				if del0 >= 0 {
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package alac
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	mp4 "github.com/abema/go-mp4"
+
+	"github.com/farcloser/saprobe"
+)
+
+// Verify decodes every packet without keeping the PCM, reporting bitstream errors (including
+// overruns, and packets whose elements do not end exactly at their stsz size) with their packet
+// index, file offset and sample position. It then checks that the sample table is complete and
+// that the decoded sample count agrees with the stts table and the mdhd duration. Integrity
+// failures are returned as findings; the error is reserved for streams that cannot be read at all.
+func Verify(reader io.ReadSeeker) (saprobe.Verification, error) {
+	tracked := saprobe.TrackReader(reader)
+	verification, err := verify(tracked)
+
+	return verification, wrapError(tracked, err)
+}
+
+func verify(reader io.ReadSeeker) (saprobe.Verification, error) {
+	var verification saprobe.Verification
+
+	track, err := findALACTrack(reader)
+	if err != nil {
+		return verification, err
+	}
+
+	config, err := ParseConfig(track.cookie)
+	if err != nil {
+		return verification, fmt.Errorf("parsing ALAC config: %w", err)
+	}
+
+	dec, err := NewDecoder(config)
+	if err != nil {
+		return verification, err
+	}
+
+	frameBytes := int(config.NumChannels) * dec.Format().BitDepth.BytesPerSample()
+	verification.Examined(saprobe.CheckBitstream)
+
+	var (
+		decoded   int64
+		damaged   bool
+		packetBuf []byte
+	)
+
+	pcm := make([]byte, dec.BufferSize())
+
+	for idx, sample := range track.samples {
+		if int(sample.size) > len(packetBuf) {
+			packetBuf = make([]byte, sample.size)
+		}
+
+		packet := packetBuf[:sample.size]
+
+		if _, err := reader.Seek(int64(sample.offset), io.SeekStart); err != nil {
+			return verification, fmt.Errorf("seeking to sample %d at offset %d: %w", idx, sample.offset, err)
+		}
+
+		if _, err := io.ReadFull(reader, packet); err != nil {
+			if !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+				return verification, fmt.Errorf("reading sample %d: %w", idx, err)
+			}
+
+			// The sample table stops at this packet: count them in stsz.
+			_, _, packetCount, _ := readStsz(reader, track.stbl)
+
+			verification.Fail(finding(saprobe.CheckBitstream, idx, sample.offset, decoded,
+				fmt.Sprintf("packet extends past the end of the file (%d of %d packets readable)",
+					idx, packetCount)))
+
+			return verification, nil
+		}
+
+		n, err := dec.DecodePacketInto(pcm, packet)
+		if err != nil {
+			verification.Fail(finding(saprobe.CheckBitstream, idx, sample.offset, decoded, err.Error()))
+
+			damaged = true
+			decoded += int64(config.FrameLength)
+
+			continue
+		}
+
+		decoded += int64(n / frameBytes)
+	}
+
+	if err := checkSampleCount(&verification, reader, track, config, decoded, damaged); err != nil {
+		return verification, err
+	}
+
+	return verification, nil
+}
+
+// checkSampleCount compares the packet and sample counts with what the container declares.
+// The decoded count is only meaningful when every packet decoded.
+//
+//revive:disable-next-line:flag-parameter
+func checkSampleCount(
+	verification *saprobe.Verification,
+	reader io.ReadSeeker,
+	track alacTrack,
+	config Config,
+	decoded int64,
+	damaged bool,
+) error {
+	verification.Examined(saprobe.CheckSampleCount)
+
+	_, _, packetCount, err := readStsz(reader, track.stbl)
+	if err != nil {
+		return err
+	}
+
+	if int(packetCount) != len(track.samples) {
+		verification.Fail(finding(saprobe.CheckSampleCount, -1, 0, -1,
+			fmt.Sprintf("stsz declares %d packets, chunk tables locate %d", packetCount, len(track.samples))))
+	}
+
+	if damaged {
+		return nil
+	}
+
+	timescale, duration, err := readMediaDuration(reader, track.mdia)
+	if err != nil {
+		return err
+	}
+
+	if timescale == 0 {
+		return nil
+	}
+
+	if sttsTotal, ok := readSttsTotal(reader, track.stbl); ok {
+		if expected := rescale(sttsTotal, timescale, config.SampleRate); expected != decoded {
+			verification.Fail(finding(saprobe.CheckSampleCount, -1, 0, -1,
+				fmt.Sprintf("decoded %d samples, stts declares %d", decoded, expected)))
+		}
+	}
+
+	if expected := rescale(duration, timescale, config.SampleRate); expected != decoded {
+		verification.Fail(finding(saprobe.CheckSampleCount, -1, 0, -1,
+			fmt.Sprintf("decoded %d samples, mdhd duration declares %d", decoded, expected)))
+	}
+
+	return nil
+}
+
+// readMediaDuration returns the mdhd timescale and duration of the track.
+func readMediaDuration(reader io.ReadSeeker, mdia *mp4.BoxInfo) (uint32, uint64, error) {
+	boxes, err := mp4.ExtractBoxWithPayload(reader, mdia, mp4.BoxPath{mp4.BoxTypeMdhd()})
+	if err != nil || len(boxes) == 0 {
+		return 0, 0, ErrNoMdhd
+	}
+
+	mdhd, ok := boxes[0].Payload.(*mp4.Mdhd)
+	if !ok {
+		return 0, 0, ErrNoMdhd
+	}
+
+	if mdhd.GetVersion() == 1 {
+		return mdhd.Timescale, mdhd.DurationV1, nil
+	}
+
+	return mdhd.Timescale, uint64(mdhd.DurationV0), nil
+}
+
+// readSttsTotal returns the total duration in the stts table, in media timescale units.
+func readSttsTotal(reader io.ReadSeeker, stbl *mp4.BoxInfo) (uint64, bool) {
+	boxes, err := mp4.ExtractBoxWithPayload(reader, stbl, mp4.BoxPath{mp4.BoxTypeStts()})
+	if err != nil || len(boxes) == 0 {
+		return 0, false
+	}
+
+	stts, ok := boxes[0].Payload.(*mp4.Stts)
+	if !ok {
+		return 0, false
+	}
+
+	var total uint64
+	for _, entry := range stts.Entries {
+		total += uint64(entry.SampleCount) * uint64(entry.SampleDelta)
+	}
+
+	return total, true
+}
+
+// rescale converts a duration from the media timescale to samples at sampleRate.
+func rescale(duration uint64, timescale, sampleRate uint32) int64 {
+	if timescale == sampleRate {
+		return int64(duration) //nolint:gosec // durations fit in int64.
+	}
+
+	return int64((duration*uint64(sampleRate) + uint64(timescale)/2) / uint64(timescale)) //nolint:gosec // idem.
+}
+
+func finding(check saprobe.Check, packet int, offset uint64, sample int64, detail string) saprobe.Finding {
+	return saprobe.Finding{
+		Check:  check,
+		Frame:  packet,
+		Offset: int64(offset), //nolint:gosec // file offsets fit in int64.
+		Sample: sample,
+		Detail: detail,
+	}
+}
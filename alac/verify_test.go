@@ -0,0 +1,53 @@
+package alac_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/alac"
+	"github.com/farcloser/saprobe/tests/testutils"
+)
+
+// TestVerifyCorruptPacket checks that a packet damaged in a way the decoder does not trip on
+// still fails verification, as its elements no longer end where stsz says the packet does.
+func TestVerifyCorruptPacket(t *testing.T) {
+	t.Parallel()
+
+	format := saprobe.PCMFormat{SampleRate: 44100, BitDepth: saprobe.Depth16, Channels: 2}
+
+	var buf bytes.Buffer
+	if err := alac.Encode(&buf, testutils.Noise(format, 100000, -60), format, saprobe.Metadata{}); err != nil {
+		t.Fatal(err)
+	}
+
+	verification, err := alac.Verify(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !verification.OK() {
+		t.Fatalf("clean stream: findings %v", verification.Findings)
+	}
+
+	mdat := bytes.Index(buf.Bytes(), []byte("mdat"))
+
+	for _, at := range []int{1000, 30000, 60000} {
+		data := slices.Clone(buf.Bytes())
+		for idx := range 300 {
+			data[mdat+at+idx] ^= 0x5A
+		}
+
+		verification, err := alac.Verify(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !slices.ContainsFunc(verification.Findings, func(finding saprobe.Finding) bool {
+			return finding.Check == saprobe.CheckBitstream
+		}) {
+			t.Errorf("damage at %d: findings %v, want a bitstream finding", at, verification.Findings)
+		}
+	}
+}
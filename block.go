@@ -0,0 +1,84 @@
+package saprobe
+
+import "github.com/farcloser/saprobe/internal/pcmio"
+
+// BlockSize is the number of samples per channel of the blocks codecs cut their output into when
+// the stream has no unit of its own, like a FLAC frame or an ALAC packet.
+const BlockSize = 4096
+
+// Block is a run of decoded samples, planar: one slice per channel, all of the same length.
+// Samples are right-aligned at Format.BitDepth: full scale is 1 << (BitDepth - 1), so that 20-bit
+// samples range over [-2^19, 2^19), although the interleaved PCM left-aligns them in 24 bits.
+//
+// The codec iterators that yield blocks reuse their samples: a block is only valid until the
+// iteration moves on.
+type Block struct {
+	Format PCMFormat
+	// Start is the position of the first sample of the block in the stream, per channel.
+	Start   int64
+	Samples [][]int32
+}
+
+// Len returns the number of samples per channel.
+func (b *Block) Len() int {
+	if len(b.Samples) == 0 {
+		return 0
+	}
+
+	return len(b.Samples[0])
+}
+
+// AppendPCM appends the samples to dst as interleaved little-endian signed PCM in the block
+// format, as the codec Decode functions return it.
+func (b *Block) AppendPCM(dst []byte) []byte {
+	bps := b.Format.BitDepth.BytesPerSample()
+	shift := alignment(b.Format.BitDepth)
+
+	var sample [4]byte
+
+	for idx := range b.Len() {
+		for _, plane := range b.Samples {
+			pcmio.WriteSample(sample[:], bps, int64(plane[idx])<<shift)
+			dst = append(dst, sample[:bps]...)
+		}
+	}
+
+	return dst
+}
+
+// SetPCM sets the samples of the block from interleaved little-endian signed PCM in the block
+// format, reusing its planes when they have room. A trailing partial frame is ignored.
+func (b *Block) SetPCM(pcm []byte) {
+	bps := b.Format.BitDepth.BytesPerSample()
+	shift := alignment(b.Format.BitDepth)
+	channels := int(b.Format.Channels) //nolint:gosec // channel counts are small.
+	length := len(pcm) / (bps * channels)
+
+	if cap(b.Samples) < channels {
+		b.Samples = make([][]int32, channels)
+	}
+
+	b.Samples = b.Samples[:channels]
+
+	for ch := range b.Samples {
+		if cap(b.Samples[ch]) < length {
+			b.Samples[ch] = make([]int32, length)
+		}
+
+		b.Samples[ch] = b.Samples[ch][:length]
+	}
+
+	pos := 0
+
+	for idx := range length {
+		for _, plane := range b.Samples {
+			plane[idx] = int32(pcmio.ReadSample(pcm[pos:], bps) >> shift) //nolint:gosec // fits the bit depth.
+			pos += bps
+		}
+	}
+}
+
+// alignment returns the left shift of samples of the given depth in their interleaved container.
+func alignment(depth BitDepth) uint {
+	return uint(8*depth.BytesPerSample()) - uint(depth)
+}
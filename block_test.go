@@ -0,0 +1,149 @@
+package saprobe_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"iter"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/alac"
+	"github.com/farcloser/saprobe/flac"
+	"github.com/farcloser/saprobe/mp3"
+	"github.com/farcloser/saprobe/tests/testutils"
+	"github.com/farcloser/saprobe/vorbis"
+	"github.com/farcloser/saprobe/wav"
+)
+
+type (
+	decodeFunc func(io.ReadSeeker) ([]byte, saprobe.PCMFormat, error)
+	blocksFunc func(io.ReadSeeker) iter.Seq2[saprobe.Block, error]
+	encodeFunc func(io.Writer, []byte, saprobe.PCMFormat, saprobe.Metadata) error
+)
+
+// blocksCase is a stream, and the codec functions decoding it.
+type blocksCase struct {
+	name   string
+	data   []byte
+	decode decodeFunc
+	blocks blocksFunc
+}
+
+// smpb is an iTunSMPB comment: a delay of 0x210 samples, a padding of 0x3C0 and 0x5430 samples,
+// what remains of 20 frames.
+const smpb = " 00000000 00000210 000003C0 0000000000005430 00000000 00000000"
+
+// mp3Stream returns an ID3v2.3 tag carrying smpb, followed by count MPEG-1 Layer II frames of 256
+// kbit/s stereo at 48 kHz. The first two subbands of both channels are allocated 3-bit samples, and
+// their scalefactors and samples are random.
+func mp3Stream(count int) []byte {
+	const frameSize = 768
+
+	comment := append([]byte{0, 'e', 'n', 'g'}, "iTunSMPB\x00"+smpb...)
+	frame := binary.BigEndian.AppendUint32([]byte("COMM"), uint32(len(comment))) //nolint:gosec // small.
+	frame = append(append(frame, 0, 0), comment...)
+
+	size := len(frame)
+	data := append([]byte{'I', 'D', '3', 3, 0, 0, 0, 0, byte(size >> 7), byte(size & 0x7F)}, frame...)
+	random := rand.New(rand.NewPCG(uint64(count), frameSize)) //nolint:gosec // test signal.
+
+	for range count {
+		frame := make([]byte, frameSize)
+		copy(frame, []byte{0xFF, 0xFD, 0xC4, 0x00, 0x33, 0x33})
+
+		for pos := 4 + 24; pos < frameSize; pos++ {
+			frame[pos] = byte(random.Uint32())
+		}
+
+		data = append(data, frame...)
+	}
+
+	return data
+}
+
+// encodeCases returns a stream per format, encoded from a sine of a level per channel.
+func encodeCases(t *testing.T, name string, encode encodeFunc, decode decodeFunc, blocks blocksFunc) []blocksCase {
+	t.Helper()
+
+	var cases []blocksCase
+
+	for _, format := range []saprobe.PCMFormat{
+		{SampleRate: 44100, BitDepth: saprobe.Depth16, Channels: 2},
+		{SampleRate: 48000, BitDepth: saprobe.Depth20, Channels: 1},
+		{SampleRate: 96000, BitDepth: saprobe.Depth24, Channels: 6},
+	} {
+		signal := testutils.Sine(format, 997, 30, testutils.Segment{
+			Seconds: 0.5,
+			Levels:  []float64{-1, -3, -6, -10, -20, -40},
+		})
+
+		var buf bytes.Buffer
+		if err := encode(&buf, signal.AppendPCM(nil), format, saprobe.Metadata{}); err != nil {
+			t.Fatal(err)
+		}
+
+		cases = append(cases, blocksCase{
+			name:   fmt.Sprintf("%s %d-bit", name, format.BitDepth),
+			data:   buf.Bytes(),
+			decode: decode,
+			blocks: blocks,
+		})
+	}
+
+	return cases
+}
+
+// TestBlocks checks that the blocks of a stream follow each other, and that together they hold
+// the samples Decode returns, trimming included.
+func TestBlocks(t *testing.T) {
+	t.Parallel()
+
+	ogg, err := os.ReadFile(filepath.Join("vorbis", "testdata", "test.ogg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []blocksCase{
+		{"mp3", mp3Stream(20), mp3.Decode, mp3.Blocks},
+		{"vorbis", ogg, vorbis.Decode, vorbis.Blocks},
+	}
+	cases = append(cases, encodeCases(t, "flac", flac.Encode, flac.Decode, flac.Blocks)...)
+	cases = append(cases, encodeCases(t, "alac", alac.Encode, alac.Decode, alac.Blocks)...)
+	cases = append(cases, encodeCases(t, "wav", wav.Encode, wav.Decode, wav.Blocks)...)
+
+	for _, test := range cases {
+		want, format, err := test.decode(bytes.NewReader(test.data))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		var (
+			got   []byte
+			start int64
+		)
+
+		for block, err := range test.blocks(bytes.NewReader(test.data)) {
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+
+			if block.Start != start || block.Format.BitDepth != format.BitDepth ||
+				len(block.Samples) != int(format.Channels) {
+				t.Fatalf("%s: block of %d %d-bit channels at %d, want %d %d-bit channels at %d", test.name,
+					len(block.Samples), block.Format.BitDepth, block.Start, format.Channels, format.BitDepth, start)
+			}
+
+			start += int64(block.Len())
+			got = block.AppendPCM(got)
+		}
+
+		if len(want) == 0 || !bytes.Equal(got, want) {
+			t.Errorf("%s: %d bytes of blocks differ from the %d decoded", test.name, len(got), len(want))
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"iter"
+	"os"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/alac"
+	"github.com/farcloser/saprobe/detect"
+	"github.com/farcloser/saprobe/flac"
+	"github.com/farcloser/saprobe/mp3"
+	"github.com/farcloser/saprobe/vorbis"
+	"github.com/farcloser/saprobe/wav"
+)
+
+type blocksFunc func(io.ReadSeeker) iter.Seq2[saprobe.Block, error]
+
+// streamFile decodes path block by block, handing every block to process.
+func streamFile(path string, process func(saprobe.Block) error) error {
+	file, err := os.Open(path) //nolint:gosec // CLI tool opens user-specified audio files
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer file.Close()
+
+	blocks, err := blocksOf(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	for block, err := range blocks(file) {
+		if err != nil {
+			return fmt.Errorf("decoding %s: %w", path, err)
+		}
+
+		if err := process(block); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// blocksOf returns the block iterator of the codec of rs.
+func blocksOf(rs io.ReadSeeker) (blocksFunc, error) {
+	codec, err := detect.Identify(rs)
+	if err != nil {
+		return nil, fmt.Errorf("detecting codec: %w", err)
+	}
+
+	switch codec {
+	case detect.FLAC:
+		return flac.Blocks, nil
+	case detect.ALAC:
+		return alac.Blocks, nil
+	case detect.MP3, detect.MP2, detect.MP1:
+		return mp3.Blocks, nil
+	case detect.Vorbis:
+		return vorbis.Blocks, nil
+	case detect.WAV:
+		return wav.Blocks, nil
+	case detect.Unknown:
+	}
+
+	return nil, errUnsupportedFormat
+}
@@ -5,7 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/urfave/cli/v3"
 
@@ -14,13 +19,22 @@ import (
 	"github.com/farcloser/saprobe/detect"
 	"github.com/farcloser/saprobe/flac"
 	"github.com/farcloser/saprobe/mp3"
+	"github.com/farcloser/saprobe/remix"
+	"github.com/farcloser/saprobe/resample"
 	"github.com/farcloser/saprobe/vorbis"
+	"github.com/farcloser/saprobe/wav"
 )
 
 var (
 	errUnsupportedFormat = errors.New("unsupported audio format")
 	errBitDepthMismatch  = errors.New("bit depth conversion is not yet implemented")
 	errInvalidArgCount   = errors.New("expected exactly one argument: file path")
+	errMD5Mismatch       = errors.New("decoded audio does not match the STREAMINFO MD5")
+	errConcealment       = errors.New("unknown concealment mode")
+	errResampleQuality   = errors.New("unknown resampling quality")
+	errDownmix           = errors.New("unknown downmix target")
+	errRemixFlags        = errors.New("--channels, --downmix and --matrix are mutually exclusive")
+	errReplayGainMode    = errors.New("unknown ReplayGain mode")
 )
 
 func decodeCommand() *cli.Command {
@@ -41,13 +55,64 @@ func decodeCommand() *cli.Command {
 				Value:   0,
 				Usage:   "force output bit depth (16, 24, 32); 0 preserves native",
 			},
+			&cli.StringFlag{
+				Name:  "replaygain",
+				Value: "off",
+				Usage: "apply the stored ReplayGain: track, album or off; the stored peak prevents clipping",
+			},
+			&cli.StringFlag{
+				Name:  "channels",
+				Usage: "keep these channels, by index, in the given order (0,1 for the first two)",
+			},
+			&cli.StringFlag{
+				Name:  "downmix",
+				Usage: "downmix to stereo or mono (ITU-R BS.775), following the channel layout",
+			},
+			&cli.StringFlag{
+				Name:  "matrix",
+				Usage: "mix with these gains: one row per output channel, separated by ';' (0.5,0.5 for mono)",
+			},
+			&cli.IntFlag{
+				Name:  "sample-rate",
+				Value: 0,
+				Usage: "resample the output to this rate in Hz; 0 preserves native",
+			},
+			&cli.StringFlag{
+				Name:  "resample-quality",
+				Value: resample.QualityHigh.String(),
+				Usage: "filter used by --sample-rate: high, medium or low",
+			},
 			&cli.BoolFlag{
 				Name:    "info",
 				Aliases: []string{"i"},
 				Usage:   "print format info and exit without decoding",
 			},
+			&cli.BoolFlag{
+				Name:  "verify-md5",
+				Usage: "FLAC only: check the decoded audio against the STREAMINFO MD5 and fail on mismatch",
+			},
+			&cli.BoolFlag{
+				Name:  "strict",
+				Usage: "fail when the stream decodes fewer samples than it declares, instead of warning",
+			},
+			&cli.BoolFlag{
+				Name:  "resilient",
+				Usage: "conceal damaged frames and resume at the next valid one instead of failing",
+			},
+			&cli.StringFlag{
+				Name:  "conceal",
+				Value: saprobe.ConcealSilence.String(),
+				Usage: "how --resilient fills damaged ranges: silence or interpolate",
+			},
+			&cli.IntFlag{
+				Name:    "jobs",
+				Aliases: []string{"j"},
+				Value:   runtime.NumCPU(),
+				Usage:   "ALAC and FLAC only: number of packets or frames decoded concurrently",
+			},
 		},
-		Action: runDecode,
+		OnUsageError: onUsageError,
+		Action:       runDecode,
 	}
 }
 
@@ -69,15 +134,26 @@ func runDecode(_ context.Context, cmd *cli.Command) error {
 		return fmt.Errorf("detecting codec: %w", err)
 	}
 
+	// The headers tell the format without decoding, unless the FLAC MD5 is to be checked.
+	if cmd.Bool("info") && codec != detect.Unknown && (codec != detect.FLAC || !cmd.Bool("verify-md5")) {
+		return printInfo(codec, file)
+	}
+
 	switch codec {
 	case detect.FLAC:
-		return decodeAndOutput(cmd, "FLAC", file, flac.Decode)
-	case detect.MP3:
-		return decodeAndOutput(cmd, "MP3", file, mp3.Decode)
+		return decodeAndOutput(cmd, "FLAC", file, func(rs io.ReadSeeker, opts saprobe.Options) (
+			[]byte, saprobe.PCMFormat, saprobe.Report, error,
+		) {
+			return decodeFLAC(cmd, rs, opts)
+		}, metadataGain(flac.ReadMetadata))
+	case detect.MP3, detect.MP2, detect.MP1:
+		return decodeAndOutput(cmd, codec.String(), file, decodeMP3, mp3.ReadReplayGain)
 	case detect.Vorbis:
-		return decodeAndOutput(cmd, "Vorbis", file, vorbis.Decode)
+		return decodeAndOutput(cmd, "Vorbis", file, vorbis.DecodeWithOptions, metadataGain(vorbis.ReadMetadata))
 	case detect.ALAC:
-		return decodeAndOutput(cmd, "ALAC", file, alac.Decode)
+		return decodeAndOutput(cmd, "ALAC", file, alac.DecodeWithOptions, metadataGain(alac.ReadMetadata))
+	case detect.WAV:
+		return decodeAndOutput(cmd, "WAV", file, wav.DecodeWithOptions, metadataGain(wav.ReadMetadata))
 	case detect.Unknown:
 		return fmt.Errorf("%s: %w", path, errUnsupportedFormat)
 	}
@@ -85,24 +161,79 @@ func runDecode(_ context.Context, cmd *cli.Command) error {
 	return fmt.Errorf("%s: %w", path, errUnsupportedFormat)
 }
 
-type decodeFunc func(io.ReadSeeker) ([]byte, saprobe.PCMFormat, error)
+type (
+	decodeFunc        func(io.ReadSeeker) ([]byte, saprobe.PCMFormat, error)
+	decodeOptionsFunc func(io.ReadSeeker, saprobe.Options) ([]byte, saprobe.PCMFormat, saprobe.Report, error)
+	replayGainFunc    func(io.ReadSeeker) (saprobe.ReplayGain, error)
+	formatFunc        func(io.ReadSeeker) (saprobe.PCMFormat, int64, error)
+)
 
-func decodeAndOutput(cmd *cli.Command, codecName string, rs io.ReadSeeker, decode decodeFunc) error {
-	pcm, format, err := decode(rs)
+func decodeAndOutput(
+	cmd *cli.Command, codecName string, rs io.ReadSeeker, decode decodeOptionsFunc, readGain replayGainFunc,
+) error {
+	conceal, err := parseConcealment(cmd.String("conceal"))
+	if err != nil {
+		return err
+	}
+
+	quality, err := parseQuality(cmd.String("resample-quality"))
+	if err != nil {
+		return err
+	}
+
+	gainMode := cmd.String("replaygain")
+	if gainMode != "track" && gainMode != "album" && gainMode != "off" {
+		return fmt.Errorf("%w: %q", errReplayGainMode, gainMode)
+	}
+
+	pcm, format, report, err := decode(rs, saprobe.Options{
+		Strict:    cmd.Bool("strict"),
+		Resilient: cmd.Bool("resilient"),
+		Conceal:   conceal,
+		Jobs:      cmd.Int("jobs"),
+	})
 	if err != nil {
 		return fmt.Errorf("decoding %s: %w", codecName, err)
 	}
 
+	for _, damage := range report.Damaged {
+		_, _ = fmt.Fprintf(os.Stderr, "damaged: %s\n", damage.Describe(format.SampleRate))
+	}
+
+	if report.Truncation != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "warning: %v\n", report.Truncation)
+	}
+
 	if cmd.Bool("info") {
-		_, _ = fmt.Fprintf(os.Stderr, "codec:       %s\n", codecName)
-		_, _ = fmt.Fprintf(os.Stderr, "sample rate: %d Hz\n", format.SampleRate)
-		_, _ = fmt.Fprintf(os.Stderr, "bit depth:   %d\n", format.BitDepth)
-		_, _ = fmt.Fprintf(os.Stderr, "channels:    %d\n", format.Channels)
+		printFormat(codecName, format, int(format.BitDepth)) //nolint:gosec // small.
 		_, _ = fmt.Fprintf(os.Stderr, "pcm bytes:   %d\n", len(pcm))
 
 		return nil
 	}
 
+	if gainMode != "off" {
+		if err := applyReplayGain(rs, readGain, gainMode, pcm, format); err != nil {
+			return err
+		}
+	}
+
+	matrix, layout, err := remixMatrix(cmd, format)
+	if err != nil {
+		return err
+	}
+
+	if matrix != nil {
+		if pcm, format, err = remix.Remix(pcm, format, matrix, layout); err != nil {
+			return fmt.Errorf("remixing: %w", err)
+		}
+	}
+
+	if rate := cmd.Int("sample-rate"); rate > 0 && rate != format.SampleRate {
+		if pcm, format, err = resample.Resample(pcm, format, rate, quality); err != nil {
+			return fmt.Errorf("resampling: %w", err)
+		}
+	}
+
 	requestedDepth := cmd.Int("bit-depth")
 	if requestedDepth > 0 && saprobe.BitDepth(requestedDepth) != format.BitDepth {
 		return fmt.Errorf(
@@ -114,6 +245,284 @@ func decodeAndOutput(cmd *cli.Command, codecName string, rs io.ReadSeeker, decod
 	return writePCM(cmd.String("output"), pcm)
 }
 
+func parseConcealment(name string) (saprobe.Concealment, error) {
+	for _, mode := range []saprobe.Concealment{saprobe.ConcealSilence, saprobe.ConcealInterpolate} {
+		if name == mode.String() {
+			return mode, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %q", errConcealment, name)
+}
+
+// applyReplayGain scales pcm by the stored gain --replaygain asks for: the track or album gain,
+// falling back to the other one when it is missing. It warns when there is none.
+func applyReplayGain(
+	rs io.ReadSeeker, readGain replayGainFunc, mode string, pcm []byte, format saprobe.PCMFormat,
+) error {
+	gains, err := readGain(rs)
+	if err != nil {
+		return fmt.Errorf("reading ReplayGain: %w", err)
+	}
+
+	candidates := []struct {
+		name string
+		gain saprobe.Gain
+	}{{"track", gains.Track}, {"album", gains.Album}}
+
+	if mode == "album" {
+		candidates[0], candidates[1] = candidates[1], candidates[0]
+	}
+
+	for _, candidate := range candidates {
+		if !candidate.gain.Set {
+			continue
+		}
+
+		// Clipping only depends on the peak of this track, which an album peak merely bounds.
+		if candidate.gain.Peak == 0 {
+			candidate.gain.Peak = gains.Track.Peak
+		}
+
+		factor := candidate.gain.Factor()
+		saprobe.ApplyGain(pcm, format, factor)
+
+		_, _ = fmt.Fprintf(os.Stderr, "replaygain:  %s %+.2f dB, applied %+.2f dB\n",
+			candidate.name, candidate.gain.Gain, 20*math.Log10(factor))
+
+		return nil
+	}
+
+	_, _ = fmt.Fprintln(os.Stderr, "warning: no ReplayGain found, output left as is")
+
+	return nil
+}
+
+// metadataGain reads the ReplayGain from the tags read returns.
+func metadataGain(read metadataFunc) replayGainFunc {
+	return func(rs io.ReadSeeker) (saprobe.ReplayGain, error) {
+		metadata, err := read(rs)
+
+		return metadata.ReplayGain(), err
+	}
+}
+
+// remixMatrix returns the matrix and output layout --channels, --downmix or --matrix ask for, or a
+// nil matrix when none is set.
+func remixMatrix(cmd *cli.Command, format saprobe.PCMFormat) (remix.Matrix, saprobe.ChannelLayout, error) {
+	set := 0
+
+	for _, name := range []string{"channels", "downmix", "matrix"} {
+		if cmd.String(name) != "" {
+			set++
+		}
+	}
+
+	if set > 1 {
+		return nil, saprobe.ChannelLayout{}, errRemixFlags
+	}
+
+	switch {
+	case cmd.String("channels") != "":
+		var channels []int
+
+		for field := range strings.SplitSeq(cmd.String("channels"), ",") {
+			channel, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				return nil, saprobe.ChannelLayout{}, fmt.Errorf("parsing --channels: %w", err)
+			}
+
+			channels = append(channels, channel)
+		}
+
+		matrix, layout, err := remix.Select(format, channels)
+		if err != nil {
+			return nil, saprobe.ChannelLayout{}, fmt.Errorf("selecting channels: %w", err)
+		}
+
+		return matrix, layout, nil
+	case cmd.String("downmix") != "":
+		target := saprobe.LayoutStereo
+
+		switch cmd.String("downmix") {
+		case "stereo":
+		case "mono":
+			target = saprobe.LayoutMono
+		default:
+			return nil, saprobe.ChannelLayout{}, fmt.Errorf("%w: %q", errDownmix, cmd.String("downmix"))
+		}
+
+		matrix, err := remix.Downmix(format.Layout, target)
+		if err != nil {
+			return nil, saprobe.ChannelLayout{}, fmt.Errorf("downmixing %s: %w", format.Layout, err)
+		}
+
+		return matrix, target, nil
+	case cmd.String("matrix") != "":
+		matrix, err := remix.ParseMatrix(cmd.String("matrix"))
+		if err != nil {
+			return nil, saprobe.ChannelLayout{}, fmt.Errorf("parsing --matrix: %w", err)
+		}
+
+		return matrix, saprobe.ChannelLayout{}, nil
+	default:
+	}
+
+	return nil, saprobe.ChannelLayout{}, nil
+}
+
+func parseQuality(name string) (resample.Quality, error) {
+	for _, quality := range []resample.Quality{resample.QualityHigh, resample.QualityMedium, resample.QualityLow} {
+		if name == quality.String() {
+			return quality, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %q", errResampleQuality, name)
+}
+
+// decodeFLAC decodes rs, checking the STREAMINFO MD5 when --verify-md5 is set.
+func decodeFLAC(cmd *cli.Command, rs io.ReadSeeker, opts saprobe.Options) (
+	[]byte, saprobe.PCMFormat, saprobe.Report, error,
+) {
+	flacOpts := flac.Options{Options: opts, VerifyMD5: cmd.Bool("verify-md5")}
+
+	pcm, format, report, err := flac.DecodeWithOptions(rs, flacOpts)
+	if err != nil {
+		return nil, format, report.Report, err
+	}
+
+	if report.MD5 != flac.MD5NotChecked {
+		_, _ = fmt.Fprintf(os.Stderr, "md5:         %s\n", report.MD5)
+	}
+
+	if report.MD5 == flac.MD5Mismatch {
+		return nil, format, report.Report, fmt.Errorf(
+			"%w: stored %x, computed %x", errMD5Mismatch, report.StoredMD5, report.ComputedMD5,
+		)
+	}
+
+	return pcm, format, report.Report, nil
+}
+
+// decodeMP3 decodes an MPEG audio stream of any layer.
+func decodeMP3(rs io.ReadSeeker, opts saprobe.Options) ([]byte, saprobe.PCMFormat, saprobe.Report, error) {
+	pcm, format, report, err := mp3.DecodeWithOptions(rs, opts)
+
+	return pcm, format, report.Report, err
+}
+
+// printInfo prints the format of the stream and the length of its PCM from its headers alone. The
+// length is unknown when the headers do not declare it.
+func printInfo(codec detect.Codec, rs io.ReadSeeker) error {
+	var (
+		readFormat formatFunc
+		readBits   bitsFunc
+	)
+
+	switch codec {
+	case detect.MP3, detect.MP2, detect.MP1:
+		return printMPEGInfo(codec, rs)
+	case detect.FLAC:
+		readFormat, readBits = flac.ReadFormat, flac.ReadBitsPerSample
+	case detect.ALAC:
+		readFormat = alac.ReadFormat
+	case detect.Vorbis:
+		readFormat = vorbis.ReadFormat
+	case detect.WAV:
+		readFormat, readBits = wav.ReadFormat, wav.ReadBitsPerSample
+	case detect.Unknown:
+		return errUnsupportedFormat
+	}
+
+	format, samples, err := readFormat(rs)
+	if err != nil {
+		return fmt.Errorf("reading %s headers: %w", codec, err)
+	}
+
+	bits := int(format.BitDepth) //nolint:gosec // small.
+	if readBits != nil {
+		if bits, err = readBits(rs); err != nil {
+			return fmt.Errorf("reading %s headers: %w", codec, err)
+		}
+	}
+
+	printFormat(codec.String(), format, bits)
+
+	if samples <= 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "pcm bytes:   unknown")
+
+		return nil
+	}
+
+	frameSize := int64(format.Channels) * int64(format.BitDepth.BytesPerSample()) //nolint:gosec // small.
+	duration := time.Duration(samples) * time.Second / time.Duration(format.SampleRate)
+
+	_, _ = fmt.Fprintf(os.Stderr, "pcm bytes:   %d\n", samples*frameSize)
+	_, _ = fmt.Fprintf(os.Stderr, "duration:    %s (%d samples)\n", duration.Round(time.Millisecond), samples)
+
+	return nil
+}
+
+// printFormat prints the codec and the PCM format, with the bits per sample the stream stores when
+// the PCM holds them in a wider bit depth.
+func printFormat(codecName string, format saprobe.PCMFormat, bits int) {
+	_, _ = fmt.Fprintf(os.Stderr, "codec:       %s\n", codecName)
+	_, _ = fmt.Fprintf(os.Stderr, "sample rate: %d Hz\n", format.SampleRate)
+
+	if bits != int(format.BitDepth) { //nolint:gosec // small.
+		_, _ = fmt.Fprintf(os.Stderr, "bit depth:   %d (decoded to %d-bit PCM)\n", bits, format.BitDepth)
+	} else {
+		_, _ = fmt.Fprintf(os.Stderr, "bit depth:   %d\n", format.BitDepth)
+	}
+
+	_, _ = fmt.Fprintf(os.Stderr, "channels:    %d\n", format.Channels)
+	_, _ = fmt.Fprintf(os.Stderr, "layout:      %s\n", format.Layout)
+}
+
+// printMPEGInfo prints the format of an MPEG audio stream from its frame index, without decoding
+// it, along with its duration, bitrate and gapless metadata.
+func printMPEGInfo(codec detect.Codec, rs io.ReadSeeker) error {
+	index, err := mp3.Index(rs)
+	if err != nil {
+		return fmt.Errorf("indexing %s: %w", codec, err)
+	}
+
+	_, _ = fmt.Fprintf(os.Stderr, "codec:       %s\n", codec)
+	_, _ = fmt.Fprintf(os.Stderr, "sample rate: %d Hz\n", index.SampleRate)
+	_, _ = fmt.Fprintf(os.Stderr, "bit depth:   %d\n", saprobe.Depth24)
+	_, _ = fmt.Fprintf(os.Stderr, "channels:    %d\n", index.Channels)
+	//nolint:gosec // 1 or 2.
+	_, _ = fmt.Fprintf(os.Stderr, "layout:      %s\n", saprobe.DefaultLayout(uint(index.Channels)))
+
+	frameSize := int64(index.Channels * saprobe.Depth24.BytesPerSample())
+
+	_, _ = fmt.Fprintf(os.Stderr, "pcm bytes:   %d\n", index.Samples()*frameSize)
+	_, _ = fmt.Fprintf(os.Stderr, "duration:    %s (%d samples)\n",
+		index.Duration().Round(time.Millisecond), index.Samples())
+	_, _ = fmt.Fprintf(os.Stderr, "bitrate:     %d kbit/s %s\n", index.Bitrate(), index.Mode)
+	_, _ = fmt.Fprintf(os.Stderr, "frames:      %d\n", len(index.Frames))
+	_, _ = fmt.Fprintf(os.Stderr, "gapless:     %s", index.Gapless)
+
+	if index.Gapless != mp3.GaplessNone {
+		_, _ = fmt.Fprintf(os.Stderr, " (delay %d, padding %d)", index.Delay, index.Padding)
+	}
+
+	_, _ = fmt.Fprintln(os.Stderr)
+
+	if index.Declared != 0 && index.Declared != len(index.Frames) {
+		_, _ = fmt.Fprintf(os.Stderr, "warning: the stream header declares %d frames, found %d\n",
+			index.Declared, len(index.Frames))
+	}
+
+	if index.DeclaredSamples > index.Samples() {
+		_, _ = fmt.Fprintf(os.Stderr, "warning: iTunSMPB declares %d samples, found %d\n",
+			index.DeclaredSamples, index.Samples())
+	}
+
+	return nil
+}
+
 func writePCM(output string, data []byte) error {
 	if output == "-" {
 		if _, err := os.Stdout.Write(data); err != nil {
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/farcloser/saprobe"
+)
+
+// errFlagUsage marks the errors of the command line parser: an unknown flag, or a flag value of
+// the wrong type.
+var errFlagUsage = errors.New("invalid command line")
+
+// Exit codes.
+const (
+	exitFailure     = 1 // anything not categorized below
+	exitUsage       = 2 // wrong number of arguments, an unknown flag, or an invalid flag value
+	exitCorrupt     = 3 // the stream violates its format, or failed verification (verify or transcode)
+	exitUnsupported = 4 // the stream is valid, but uses a format or feature saprobe does not handle
+	exitIO          = 5 // reading or writing a file failed
+	exitTruncated   = 6 // the stream ends early (--strict)
+)
+
+// exitCode maps an error to the exit code of its category.
+func exitCode(err error) int {
+	var pathErr *fs.PathError
+
+	switch {
+	case isUsageError(err):
+		return exitUsage
+	case errors.Is(err, saprobe.ErrIO), errors.As(err, &pathErr):
+		return exitIO
+	case errors.Is(err, saprobe.ErrTruncated):
+		return exitTruncated
+	case errors.Is(err, saprobe.ErrUnsupported), errors.Is(err, errUnsupportedFormat), errors.Is(err, errLossySource):
+		return exitUnsupported
+	case errors.Is(err, saprobe.ErrCorrupt), errors.Is(err, errMD5Mismatch), errors.Is(err, errVerifyFailed),
+		errors.Is(err, errVerification):
+		return exitCorrupt
+	}
+
+	return exitFailure
+}
+
+// isUsageError reports whether err is a mistake in the command line rather than in a file.
+func isUsageError(err error) bool {
+	for _, usage := range []error{
+		errFlagUsage, errInvalidArgCount, errTranscodeArgCount, errVerifyArgCount, errLoudnessArgCount, errStatsArgCount,
+		errConcealment, errResampleQuality, errDownmix, errRemixFlags, errReplayGainMode, errUnsupportedTarget,
+	} {
+		if errors.Is(err, usage) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// onUsageError marks the errors of the command line parser as usage errors, for exitCode.
+func onUsageError(_ context.Context, _ *cli.Command, err error, _ bool) error {
+	return fmt.Errorf("%w: %w", errFlagUsage, err)
+}
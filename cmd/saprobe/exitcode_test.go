@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/tests/testutils"
+	"github.com/farcloser/saprobe/wav"
+)
+
+// TestExitCodes runs commands on damaged, unsupported, missing and truncated files and checks the
+// exit code their error maps to.
+func TestExitCodes(t *testing.T) {
+	t.Parallel()
+
+	format := saprobe.PCMFormat{SampleRate: 44100, BitDepth: saprobe.Depth16, Channels: 2, Layout: saprobe.LayoutStereo}
+	pcm := testutils.Noise(format, 20000, -6)
+	source := writeFLAC(t, pcm, format, saprobe.Metadata{})
+	dir := filepath.Dir(source)
+
+	data, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, outputMode); err != nil {
+			t.Fatal(err)
+		}
+
+		return path
+	}
+
+	// The stream ends with the CRC-16 of its last frame.
+	badCRC := write("crc.flac", append(bytes.Clone(data[:len(data)-1]), data[len(data)-1]^0x01))
+
+	// "fLaC", the metadata block header, then 18 bytes of STREAMINFO before the MD5.
+	badMD5 := bytes.Clone(data)
+	badMD5[4+4+18] ^= 0x01
+
+	var float bytes.Buffer
+	if err := wav.Encode(&float, pcm, format, saprobe.Metadata{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The format tag follows the RIFF header and the fmt chunk header: 3 is IEEE float.
+	binary.LittleEndian.PutUint16(float.Bytes()[20:], 3)
+
+	output := filepath.Join(dir, "decoded.pcm")
+
+	for _, test := range []struct {
+		name    string
+		command *cli.Command
+		args    []string
+		want    int
+	}{
+		{"no argument", decodeCommand(), []string{"-o", output}, exitUsage},
+		{"unknown downmix", decodeCommand(), []string{"--downmix", "quad", "-o", output, source}, exitUsage},
+		{"unknown flag", decodeCommand(), []string{"--bogus", "-o", output, source}, exitUsage},
+		{"bad flag value", decodeCommand(), []string{"--jobs", "many", "-o", output, source}, exitUsage},
+		{"short flag with value", decodeCommand(), []string{"-j1", "-o", output, source}, exitUsage},
+		{"unknown verify flag", verifyCommand(), []string{"--bogus", source}, exitUsage},
+		{"unknown transcode flag", transcodeCommand(), []string{"--bogus", source, output}, exitUsage},
+		{"bad frame CRC", decodeCommand(), []string{"-o", output, badCRC}, exitCorrupt},
+		{"MD5 mismatch", decodeCommand(), []string{
+			"--verify-md5", "-o", output, write("md5.flac", badMD5),
+		}, exitCorrupt},
+		{"failed verification", verifyCommand(), []string{"--quiet", badCRC}, exitCorrupt},
+		{"float WAV", decodeCommand(), []string{"-o", output, write("float.wav", float.Bytes())}, exitUnsupported},
+		{"unknown format", decodeCommand(), []string{"-o", output, write("noise.bin", pcm)}, exitUnsupported},
+		{"missing file", decodeCommand(), []string{"-o", output, filepath.Join(dir, "missing.flac")}, exitIO},
+		{"strict truncation", decodeCommand(), []string{
+			"--strict", "-o", output, write("cut.flac", data[:len(data)/2]),
+		}, exitTruncated},
+	} {
+		err := test.command.Run(context.Background(), append([]string{test.command.Name}, test.args...))
+		if err == nil {
+			t.Errorf("%s: no error", test.name)
+
+			continue
+		}
+
+		if code := exitCode(err); code != test.want {
+			t.Errorf("%s: exit code %d (%v), want %d", test.name, code, err, test.want)
+		}
+	}
+
+	// A transcode whose output does not decode back to the source failed verification.
+	if code := exitCode(fmt.Errorf("%w: sha256 mismatch", errVerification)); code != exitCorrupt {
+		t.Errorf("transcode verification: exit code %d, want %d", code, exitCorrupt)
+	}
+
+	// The same truncated stream only warns without --strict.
+	err = decodeCommand().Run(context.Background(), []string{"decode", "-o", output, filepath.Join(dir, "cut.flac")})
+	if err != nil {
+		t.Errorf("lenient truncation: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/loudness"
+)
+
+var errLoudnessArgCount = errors.New("expected at least one file")
+
+func loudnessCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "loudness",
+		Usage:     "Measure loudness (EBU R 128, ITU-R BS.1770-4) and compute ReplayGain 2.0 values",
+		ArgsUsage: "<file> [<file>...]",
+		Description: "Decodes each file and prints its integrated loudness, loudness range, maximum momentary\n" +
+			"and short-term loudness, sample peak and 4x oversampled true peak, with the ReplayGain 2.0\n" +
+			"track gain (to -18 LUFS) and peak. --album measures the files together as well, for the\n" +
+			"album gain.",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "album",
+				Usage: "also measure the files as one album",
+			},
+		},
+		OnUsageError: onUsageError,
+		Action:       runLoudness,
+	}
+}
+
+func runLoudness(_ context.Context, cmd *cli.Command) error {
+	if cmd.NArg() == 0 {
+		return errLoudnessArgCount
+	}
+
+	meters := make([]*loudness.Meter, 0, cmd.NArg())
+
+	for _, path := range cmd.Args().Slice() {
+		meter, err := measureFile(path)
+		if err != nil {
+			return err
+		}
+
+		meters = append(meters, meter)
+
+		printLoudness(path, meter.Result(), "track")
+	}
+
+	if cmd.Bool("album") {
+		printLoudness("album", loudness.Album(meters...), "album")
+	}
+
+	return nil
+}
+
+// measureFile streams the audio of path through a loudness meter.
+func measureFile(path string) (*loudness.Meter, error) {
+	var meter *loudness.Meter
+
+	err := streamFile(path, func(block saprobe.Block) error {
+		if meter == nil {
+			meter = loudness.New(block.Format)
+		}
+
+		return meter.Add(block)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if meter == nil {
+		meter = loudness.New(saprobe.PCMFormat{})
+	}
+
+	return meter, nil
+}
+
+func printLoudness(name string, result loudness.Result, gainName string) {
+	_, _ = fmt.Fprintf(os.Stdout, "%s\n", name)
+	_, _ = fmt.Fprintf(os.Stdout, "  integrated:  %.1f LUFS\n", result.Integrated)
+	_, _ = fmt.Fprintf(os.Stdout, "  range:       %.1f LU\n", result.Range)
+	_, _ = fmt.Fprintf(os.Stdout, "  momentary:   %.1f LUFS max\n", result.MomentaryMax)
+	_, _ = fmt.Fprintf(os.Stdout, "  short-term:  %.1f LUFS max\n", result.ShortTermMax)
+	_, _ = fmt.Fprintf(os.Stdout, "  sample peak: %.1f dBFS\n", result.SamplePeak)
+	_, _ = fmt.Fprintf(os.Stdout, "  true peak:   %.1f dBTP\n", result.TruePeak)
+
+	if gain := result.ReplayGain(); gain.Set {
+		_, _ = fmt.Fprintf(os.Stdout, "  replaygain:  %s %+.2f dB, peak %.6f\n", gainName, gain.Gain, gain.Peak)
+	} else {
+		_, _ = fmt.Fprintf(os.Stdout, "  replaygain:  %s none, too quiet to measure\n", gainName)
+	}
+}
@@ -22,12 +22,17 @@ func main() {
 		Version: version.Version() + " (" + version.Commit() + " - " + version.Date() + ")",
 		Commands: []*cli.Command{
 			decodeCommand(),
+			transcodeCommand(),
+			verifyCommand(),
+			loudnessCommand(),
+			statsCommand(),
 		},
+		OnUsageError: onUsageError,
 	}
 
 	if err := appl.Run(ctx, os.Args); err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "error: %v\n", err)
 
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
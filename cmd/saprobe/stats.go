@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/stats"
+)
+
+var errStatsArgCount = errors.New("expected at least one file")
+
+// fileStats is the JSON report of a file. Levels are in dBFS, and null for silence.
+type fileStats struct {
+	File              string         `json:"file"`
+	SampleRate        int            `json:"sample_rate"`
+	BitDepth          uint           `json:"bit_depth"`
+	EffectiveBitDepth uint           `json:"effective_bit_depth"`
+	Layout            string         `json:"layout"`
+	Samples           int64          `json:"samples"`
+	Duration          float64        `json:"duration"`
+	HeadSilence       float64        `json:"head_silence"`
+	TailSilence       float64        `json:"tail_silence"`
+	Channels          []channelStats `json:"channels"`
+}
+
+type channelStats struct {
+	Speaker           string   `json:"speaker,omitempty"`
+	Peak              *float64 `json:"peak"`
+	RMS               *float64 `json:"rms"`
+	Crest             *float64 `json:"crest"` // dB
+	DCOffset          float64  `json:"dc_offset"`
+	ClippedRuns       int      `json:"clipped_runs"`
+	ClippedSamples    int64    `json:"clipped_samples"`
+	EffectiveBitDepth uint     `json:"effective_bit_depth"`
+}
+
+func statsCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "stats",
+		Usage:     "Print quality-control statistics of audio files as JSON",
+		ArgsUsage: "<file> [<file>...]",
+		Description: "Decodes each file and reports, per channel, the peak and RMS levels (dBFS), crest factor\n" +
+			"(dB), DC offset (fraction of full scale), runs of clipped samples (consecutive samples at\n" +
+			"full scale) and effective bit depth (the bit depth less the low bits no sample sets), with\n" +
+			"the silence at the head and tail of the file (seconds). Prints a JSON array, one object per file.",
+		Flags: []cli.Flag{
+			&cli.FloatFlag{
+				Name:  "silence",
+				Value: -60,
+				Usage: "level in dBFS at or under which samples count as silent",
+			},
+		},
+		OnUsageError: onUsageError,
+		Action:       runStats,
+	}
+}
+
+func runStats(_ context.Context, cmd *cli.Command) error {
+	if cmd.NArg() == 0 {
+		return errStatsArgCount
+	}
+
+	reports := make([]fileStats, 0, cmd.NArg())
+
+	for _, path := range cmd.Args().Slice() {
+		var analyzer *stats.Analyzer
+
+		err := streamFile(path, func(block saprobe.Block) error {
+			if analyzer == nil {
+				analyzer = stats.New(block.Format, cmd.Float("silence"))
+			}
+
+			return analyzer.Add(block)
+		})
+		if err != nil {
+			return err
+		}
+
+		report := fileStats{File: path, Channels: []channelStats{}}
+		if analyzer != nil {
+			report = statsReport(path, analyzer.Result())
+		}
+
+		reports = append(reports, report)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(reports); err != nil {
+		return fmt.Errorf("writing stats: %w", err)
+	}
+
+	return nil
+}
+
+func statsReport(path string, result stats.Result) fileStats {
+	rate := float64(result.Format.SampleRate)
+
+	report := fileStats{
+		File:              path,
+		SampleRate:        result.Format.SampleRate,
+		BitDepth:          uint(result.Format.BitDepth),
+		EffectiveBitDepth: result.EffectiveBitDepth,
+		Layout:            result.Format.Layout.String(),
+		Samples:           result.Samples,
+		Duration:          float64(result.Samples) / rate,
+		HeadSilence:       float64(result.HeadSilence) / rate,
+		TailSilence:       float64(result.TailSilence) / rate,
+		Channels:          make([]channelStats, len(result.Channels)),
+	}
+
+	speakers := result.Format.Layout.Speakers()
+
+	for ch, channel := range result.Channels {
+		stat := channelStats{
+			Peak:              decibels(channel.Peak),
+			RMS:               decibels(channel.RMS),
+			Crest:             decibels(channel.Crest()),
+			DCOffset:          channel.DCOffset,
+			ClippedRuns:       channel.ClippedRuns,
+			ClippedSamples:    channel.ClippedSamples,
+			EffectiveBitDepth: channel.EffectiveBitDepth,
+		}
+
+		if ch < len(speakers) {
+			stat.Speaker = speakers[ch].String()
+		}
+
+		report.Channels[ch] = stat
+	}
+
+	return report
+}
+
+// decibels returns ratio in dB, rounded to the hundredth, or nil for 0.
+func decibels(ratio float64) *float64 {
+	if ratio <= 0 {
+		return nil
+	}
+
+	level := math.Round(2000*math.Log10(ratio)) / 100
+
+	return &level
+}
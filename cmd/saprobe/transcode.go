@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/alac"
+	"github.com/farcloser/saprobe/detect"
+	"github.com/farcloser/saprobe/flac"
+	"github.com/farcloser/saprobe/remix"
+	"github.com/farcloser/saprobe/wav"
+)
+
+var (
+	errTranscodeArgCount = errors.New("expected exactly two arguments: input and output paths")
+	errUnsupportedTarget = errors.New("unsupported output extension (expected .flac, .m4a or .wav)")
+	errLossySource       = errors.New("source is lossy; only FLAC, ALAC and WAV can be transcoded")
+	errOutputExists      = errors.New("output file already exists (use --force to overwrite)")
+	errSamePath          = errors.New("input and output are the same file")
+	errChannelLayout     = errors.New("the output format has no channel order for the speakers of the input")
+	errVerification      = errors.New("verification failed: output does not decode to the source PCM")
+)
+
+// outputMode is applied to the temporary file before it is moved into place.
+const outputMode = 0o644
+
+// encodeFunc encodes PCM holding samples of the given bits per sample.
+type encodeFunc func(io.Writer, []byte, saprobe.PCMFormat, int, saprobe.Metadata) error
+
+type metadataFunc func(io.ReadSeeker) (saprobe.Metadata, error)
+
+// bitsFunc returns the bits per sample a stream stores, which may be fewer than its PCM bit depth.
+type bitsFunc func(io.ReadSeeker) (int, error)
+
+// layoutFunc returns the order a codec stores the channels of format in.
+type layoutFunc func(format saprobe.PCMFormat) saprobe.ChannelLayout
+
+// losslessCodec groups the operations transcode needs for one lossless format.
+type losslessCodec struct {
+	codec        detect.Codec
+	decode       decodeFunc
+	encode       encodeFunc
+	readMetadata metadataFunc
+	readBits     bitsFunc // nil when samples are stored at their PCM bit depth
+	layout       layoutFunc
+	pictures     bool
+}
+
+//nolint:gochecknoglobals // constant table
+var losslessCodecs = []losslessCodec{
+	{
+		detect.FLAC, flac.Decode, flac.EncodeBits, flac.ReadMetadata, flac.ReadBitsPerSample,
+		fixedOrder(flac.Layout), true,
+	},
+	{
+		detect.ALAC, alac.Decode, encodeALAC, alac.ReadMetadata, nil,
+		fixedOrder(alac.Layout), true,
+	},
+	{
+		detect.WAV, wav.Decode, wav.EncodeBits, wav.ReadMetadata, wav.ReadBitsPerSample,
+		maskOrder, false,
+	},
+}
+
+// encodeALAC is the encodeFunc of ALAC, which stores samples at their PCM bit depth.
+func encodeALAC(writer io.Writer, pcm []byte, format saprobe.PCMFormat, _ int, metadata saprobe.Metadata) error {
+	return alac.Encode(writer, pcm, format, metadata)
+}
+
+// fixedOrder returns the layoutFunc of a codec that has one channel order per channel count.
+func fixedOrder(layout func(channels uint) saprobe.ChannelLayout) layoutFunc {
+	return func(format saprobe.PCMFormat) saprobe.ChannelLayout {
+		return layout(format.Channels)
+	}
+}
+
+// maskOrder is the layoutFunc of WAV, whose channel mask records any speakers, in bit order.
+func maskOrder(format saprobe.PCMFormat) saprobe.ChannelLayout {
+	speakers := format.Layout.Speakers()
+	if len(speakers) != int(format.Channels) { //nolint:gosec // channel count is small.
+		return saprobe.DefaultLayout(format.Channels)
+	}
+
+	layout := format.Layout
+	slices.Sort(layout[:len(speakers)])
+
+	return layout
+}
+
+func transcodeCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "transcode",
+		Usage:     "Losslessly convert between FLAC, ALAC (.m4a) and WAV, verifying the result",
+		ArgsUsage: "<input> <output>",
+		Description: "Picks the target from the output extension, carries tags and artwork over, moves the\n" +
+			"channels into the target's speaker order, and only writes the output once it decodes\n" +
+			"back to the source PCM. FLAC output is limited to 16, 20 and 24-bit audio. Samples of fewer\n" +
+			"bits (8-bit, say) keep their size in FLAC and WAV, and are widened in ALAC.",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:    "force",
+				Aliases: []string{"f"},
+				Usage:   "overwrite the output file if it exists",
+			},
+		},
+		OnUsageError: onUsageError,
+		Action:       runTranscode,
+	}
+}
+
+func runTranscode(_ context.Context, cmd *cli.Command) error {
+	if cmd.NArg() != 2 {
+		return fmt.Errorf("%w: got %d", errTranscodeArgCount, cmd.NArg())
+	}
+
+	input, output := cmd.Args().Get(0), cmd.Args().Get(1)
+
+	target, ok := codecForExtension(filepath.Ext(output))
+	if !ok {
+		return fmt.Errorf("%s: %w", output, errUnsupportedTarget)
+	}
+
+	if err := checkOutput(input, output, cmd.Bool("force")); err != nil {
+		return err
+	}
+
+	file, err := os.Open(input) //nolint:gosec // CLI tool opens user-specified audio files
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", input, err)
+	}
+	defer file.Close()
+
+	source, err := identifyLossless(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", input, err)
+	}
+
+	pcm, format, err := source.decode(file)
+	if err != nil {
+		return fmt.Errorf("decoding %s: %w", source.codec, err)
+	}
+
+	// Damaged tags are no reason to give up on the audio: carry over what could be read.
+	metadata, err := source.readMetadata(file)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "warning: reading %s metadata: %v; keeping %d tag(s) and %d picture(s)\n",
+			source.codec, err, len(metadata.Tags), len(metadata.Pictures))
+	}
+
+	pcm, format, err = reorder(pcm, format, source, target)
+	if err != nil {
+		return err
+	}
+
+	if len(metadata.Pictures) > 0 && !target.pictures {
+		_, _ = fmt.Fprintf(os.Stderr, "warning: %s cannot hold artwork, dropping %d picture(s)\n",
+			target.codec, len(metadata.Pictures))
+	}
+
+	bits, err := storedBits(file, source, format)
+	if err != nil {
+		return err
+	}
+
+	// Widened samples decode to the same PCM: carry on, but say so.
+	if bits < int(format.BitDepth) && target.readBits == nil { //nolint:gosec // small.
+		_, _ = fmt.Fprintf(os.Stderr, "warning: %s cannot store %d-bit samples, widening them to %d bits\n",
+			target.codec, bits, format.BitDepth)
+
+		bits = int(format.BitDepth) //nolint:gosec // small.
+	}
+
+	sum, err := writeVerified(output, target, pcm, format, bits, metadata)
+	if err != nil {
+		return err
+	}
+
+	frameSize := format.BitDepth.BytesPerSample() * int(format.Channels) //nolint:gosec // channel count is small.
+
+	_, _ = fmt.Fprintf(os.Stderr, "%s (%s) -> %s (%s): %d samples, %d Hz, %d-bit, %d channels, verified sha256 %x\n",
+		input, source.codec, output, target.codec,
+		len(pcm)/frameSize, format.SampleRate, bits, format.Channels, sum)
+
+	return nil
+}
+
+// reorder moves the channels of pcm into the order target stores the speakers of format in. ALAC
+// orders channels by element (C, L/R, ...), FLAC and WAV use the WAVE order (L, R, C, ...).
+// Between FLAC and WAV, speakers the target has no order for, or an unknown layout, are carried
+// over as they are; to or from ALAC, the speakers must be the same on both sides. Mono and stereo
+// are carried over as they are.
+func reorder(
+	pcm []byte, format saprobe.PCMFormat, source, target losslessCodec,
+) ([]byte, saprobe.PCMFormat, error) {
+	layout := target.layout(format)
+	if format.Channels <= 2 || layout == format.Layout {
+		return pcm, format, nil
+	}
+
+	channels := make([]int, 0, format.Channels)
+
+	for _, speaker := range layout.Speakers() {
+		if ch := format.Layout.Index(speaker); ch >= 0 {
+			channels = append(channels, ch)
+		}
+	}
+
+	if len(channels) != int(format.Channels) { //nolint:gosec // channel count is small.
+		if source.codec != detect.ALAC && target.codec != detect.ALAC {
+			return pcm, format, nil
+		}
+
+		return nil, format, fmt.Errorf("%d channels (%s) to %s: %w",
+			format.Channels, format.Layout, target.codec, errChannelLayout)
+	}
+
+	matrix, layout, err := remix.Select(format, channels)
+	if err != nil {
+		return nil, format, fmt.Errorf("reordering channels: %w", err)
+	}
+
+	pcm, format, err = remix.Remix(pcm, format, matrix, layout)
+	if err != nil {
+		return nil, format, fmt.Errorf("reordering channels: %w", err)
+	}
+
+	return pcm, format, nil
+}
+
+// storedBits returns the bits per sample the source stores, which its PCM may hold left-aligned in
+// a wider bit depth.
+func storedBits(rs io.ReadSeeker, source losslessCodec, format saprobe.PCMFormat) (int, error) {
+	if source.readBits == nil {
+		return int(format.BitDepth), nil //nolint:gosec // small.
+	}
+
+	bits, err := source.readBits(rs)
+	if err != nil {
+		return 0, fmt.Errorf("reading %s bits per sample: %w", source.codec, err)
+	}
+
+	return bits, nil
+}
+
+func codecForExtension(ext string) (losslessCodec, bool) {
+	var want detect.Codec
+
+	switch strings.ToLower(ext) {
+	case ".flac":
+		want = detect.FLAC
+	case ".m4a":
+		want = detect.ALAC
+	case ".wav":
+		want = detect.WAV
+	default:
+		return losslessCodec{}, false
+	}
+
+	for _, c := range losslessCodecs {
+		if c.codec == want {
+			return c, true
+		}
+	}
+
+	return losslessCodec{}, false
+}
+
+func identifyLossless(rs io.ReadSeeker) (losslessCodec, error) {
+	codec, err := detect.Identify(rs)
+	if err != nil {
+		return losslessCodec{}, fmt.Errorf("detecting codec: %w", err)
+	}
+
+	for _, c := range losslessCodecs {
+		if c.codec == codec {
+			return c, nil
+		}
+	}
+
+	if codec == detect.Unknown {
+		return losslessCodec{}, errUnsupportedFormat
+	}
+
+	return losslessCodec{}, fmt.Errorf("%s: %w", codec, errLossySource)
+}
+
+func checkOutput(input, output string, force bool) error {
+	outInfo, err := os.Stat(output)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("checking %s: %w", output, err)
+	}
+
+	if inInfo, err := os.Stat(input); err == nil && os.SameFile(inInfo, outInfo) {
+		return fmt.Errorf("%s: %w", output, errSamePath)
+	}
+
+	if !force {
+		return fmt.Errorf("%s: %w", output, errOutputExists)
+	}
+
+	return nil
+}
+
+// writeVerified encodes into a temporary file next to output, decodes it back and only
+// renames it into place when the decoded PCM, format and bits per sample match the source exactly.
+// It returns the SHA-256 of the verified PCM.
+func writeVerified(
+	output string,
+	target losslessCodec,
+	pcm []byte,
+	format saprobe.PCMFormat,
+	bits int,
+	metadata saprobe.Metadata,
+) ([]byte, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(output), ".saprobe-*"+filepath.Ext(output))
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary file: %w", err)
+	}
+
+	tmpPath := tmp.Name()
+	committed := false
+
+	defer func() {
+		_ = tmp.Close()
+
+		if !committed {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	buffered := bufio.NewWriter(tmp)
+
+	if err := target.encode(buffered, pcm, format, bits, metadata); err != nil {
+		return nil, fmt.Errorf("encoding %s: %w", target.codec, err)
+	}
+
+	if err := buffered.Flush(); err != nil {
+		return nil, fmt.Errorf("writing %s: %w", tmpPath, err)
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("rewinding %s: %w", tmpPath, err)
+	}
+
+	decoded, decodedFormat, err := target.decode(tmp)
+	if err != nil {
+		return nil, fmt.Errorf("%w: decoding %s: %w", errVerification, target.codec, err)
+	}
+
+	want := sha256.Sum256(pcm)
+	got := sha256.Sum256(decoded)
+
+	// The PCM is carried over in its channel order, which codecs may assign other speakers to.
+	decodedFormat.Layout = format.Layout
+
+	if decodedFormat != format || want != got {
+		return nil, fmt.Errorf("%w: expected %v sha256 %x, got %v sha256 %x",
+			errVerification, format, want, decodedFormat, got)
+	}
+
+	decodedBits, err := storedBits(tmp, target, format)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errVerification, err)
+	}
+
+	if decodedBits != bits {
+		return nil, fmt.Errorf("%w: expected %d-bit samples, got %d-bit", errVerification, bits, decodedBits)
+	}
+
+	if err := tmp.Chmod(outputMode); err != nil {
+		return nil, fmt.Errorf("setting permissions on %s: %w", tmpPath, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("closing %s: %w", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, output); err != nil {
+		return nil, fmt.Errorf("moving %s into place: %w", output, err)
+	}
+
+	committed = true
+
+	return want[:], nil
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/alac"
+	"github.com/farcloser/saprobe/flac"
+	"github.com/farcloser/saprobe/tests/testutils"
+)
+
+//nolint:gochecknoglobals // test fixture
+var tags = []saprobe.Tag{
+	{Key: saprobe.TagTitle, Value: "Saprobe"},
+	{Key: saprobe.TagArtist, Value: "Mycota"},
+	{Key: saprobe.TagAlbum, Value: "Hyphae"},
+	{Key: saprobe.TagDate, Value: "2026"},
+}
+
+// writeFLAC encodes pcm with metadata into a FLAC file in a fresh directory.
+func writeFLAC(t *testing.T, pcm []byte, format saprobe.PCMFormat, metadata saprobe.Metadata) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := flac.Encode(&buf, pcm, format, metadata); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "source.flac")
+	if err := os.WriteFile(path, buf.Bytes(), outputMode); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func transcode(t *testing.T, input, output string) {
+	t.Helper()
+
+	if err := transcodeCommand().Run(context.Background(), []string{"transcode", input, output}); err != nil {
+		t.Fatalf("%s -> %s: %v", filepath.Base(input), filepath.Base(output), err)
+	}
+}
+
+// readBack decodes path and reads its metadata.
+func readBack(t *testing.T, path string) ([]byte, saprobe.PCMFormat, saprobe.Metadata) {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	source, err := identifyLossless(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pcm, format, err := source.decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err := source.readMetadata(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pcm, format, metadata
+}
+
+// TestTranscodeRoundTrip converts FLAC to ALAC, ALAC to WAV and WAV back to FLAC, checking the
+// samples, the tags and the artwork at every step.
+func TestTranscodeRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, depth := range []saprobe.BitDepth{saprobe.Depth16, saprobe.Depth24} {
+		format := saprobe.PCMFormat{SampleRate: 48000, BitDepth: depth, Channels: 2, Layout: saprobe.LayoutStereo}
+		pcm := testutils.Noise(format, 10000, -6)
+		picture := saprobe.Picture{Type: 3, MIME: "image/png", Data: []byte("not really a png")}
+
+		source := writeFLAC(t, pcm, format, saprobe.Metadata{Tags: tags, Pictures: []saprobe.Picture{picture}})
+		dir := filepath.Dir(source)
+
+		for _, step := range []struct {
+			output   string
+			pictures bool
+		}{
+			{"converted.m4a", true},
+			{"converted.wav", false},
+			{"converted.flac", false},
+		} {
+			output := filepath.Join(dir, step.output)
+			transcode(t, source, output)
+
+			got, gotFormat, metadata := readBack(t, output)
+			if !bytes.Equal(got, pcm) || gotFormat.SampleRate != format.SampleRate ||
+				gotFormat.BitDepth != depth || gotFormat.Channels != format.Channels {
+				t.Errorf("%d-bit %s: %v, samples differ: %t", depth, step.output, gotFormat, !bytes.Equal(got, pcm))
+			}
+
+			for _, tag := range tags {
+				if value, _ := metadata.Get(tag.Key); value != tag.Value {
+					t.Errorf("%d-bit %s: %s is %q, want %q", depth, step.output, tag.Key, value, tag.Value)
+				}
+			}
+
+			if step.pictures != (len(metadata.Pictures) == 1 &&
+				bytes.Equal(metadata.Pictures[0].Data, picture.Data)) {
+				t.Errorf("%d-bit %s: pictures %d, want the artwork: %t",
+					depth, step.output, len(metadata.Pictures), step.pictures)
+			}
+
+			source = output
+		}
+	}
+}
+
+// TestTranscodeBitsPerSample checks that 8-bit samples, which decode to 16-bit PCM, stay 8-bit in
+// FLAC and WAV, and are widened to 16 bits in ALAC, which cannot store them.
+func TestTranscodeBitsPerSample(t *testing.T) {
+	t.Parallel()
+
+	format := saprobe.PCMFormat{SampleRate: 22050, BitDepth: saprobe.Depth16, Channels: 1, Layout: saprobe.LayoutMono}
+	pcm := testutils.Noise(format, 10000, -6)
+
+	// Keep the top byte of each sample.
+	for idx := 0; idx < len(pcm); idx += 2 {
+		pcm[idx] = 0
+	}
+
+	var buf bytes.Buffer
+	if err := flac.EncodeBits(&buf, pcm, format, 8, saprobe.Metadata{}); err != nil {
+		t.Fatal(err)
+	}
+
+	source := filepath.Join(t.TempDir(), "source.flac")
+	if err := os.WriteFile(source, buf.Bytes(), outputMode); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		output string
+		bits   int
+	}{
+		{"converted.wav", 8},
+		{"converted.flac", 8},
+		{"converted.m4a", 16},
+	} {
+		output := filepath.Join(filepath.Dir(source), test.output)
+		transcode(t, source, output)
+
+		got, gotFormat, _ := readBack(t, output)
+		if !bytes.Equal(got, pcm) || gotFormat.BitDepth != saprobe.Depth16 {
+			t.Errorf("%s: %v, samples differ: %t", test.output, gotFormat, !bytes.Equal(got, pcm))
+		}
+
+		file, err := os.Open(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		target, _ := codecForExtension(filepath.Ext(output))
+
+		bits, err := storedBits(file, target, gotFormat)
+		if err != nil || bits != test.bits {
+			t.Errorf("%s: %d bits per sample (%v), want %d", test.output, bits, err, test.bits)
+		}
+
+		_ = file.Close()
+	}
+}
+
+// TestTranscodeDamagedMetadata checks that a malformed PICTURE block does not stop the transcode,
+// and that the tags before it are carried over.
+func TestTranscodeDamagedMetadata(t *testing.T) {
+	t.Parallel()
+
+	format := saprobe.PCMFormat{SampleRate: 44100, BitDepth: saprobe.Depth16, Channels: 2, Layout: saprobe.LayoutStereo}
+	pcm := testutils.Noise(format, 5000, -6)
+	source := writeFLAC(t, pcm, format, saprobe.Metadata{
+		Tags:     tags,
+		Pictures: []saprobe.Picture{{Type: 3, MIME: "image/png", Data: []byte("not really a png")}},
+	})
+
+	// Make the MIME type of the picture run past the end of its block.
+	data, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mime := bytes.Index(data, []byte("image/png"))
+	binary.BigEndian.PutUint32(data[mime-4:], 1000)
+
+	if err := os.WriteFile(source, data, outputMode); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(filepath.Dir(source), "converted.m4a")
+	transcode(t, source, output)
+
+	file, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	metadata, err := alac.ReadMetadata(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(metadata.Tags, tags) || len(metadata.Pictures) != 0 {
+		t.Errorf("carried over %v, want the tags alone", metadata)
+	}
+}
+
+// speakerPCM returns the samples of the channel at speaker, or nil when the layout has none.
+func speakerPCM(pcm []byte, format saprobe.PCMFormat, speaker saprobe.Speaker) []byte {
+	ch := format.Layout.Index(speaker)
+	if ch < 0 {
+		return nil
+	}
+
+	width := format.BitDepth.BytesPerSample()
+	frameSize := width * int(format.Channels)
+
+	var out []byte
+	for pos := ch * width; pos < len(pcm); pos += frameSize {
+		out = append(out, pcm[pos:pos+width]...)
+	}
+
+	return out
+}
+
+// TestTranscodeSurround converts 5.1 FLAC to ALAC, ALAC to WAV and WAV back to FLAC, checking that
+// every speaker keeps its samples through the channel orders, and that a layout ALAC has no order
+// for fails.
+func TestTranscodeSurround(t *testing.T) {
+	t.Parallel()
+
+	format := saprobe.PCMFormat{SampleRate: 48000, BitDepth: saprobe.Depth24, Channels: 6, Layout: flac.Layout(6)}
+	pcm := testutils.Noise(format, 5000, -6)
+	source := writeFLAC(t, pcm, format, saprobe.Metadata{})
+	dir := filepath.Dir(source)
+
+	for _, name := range []string{"converted.m4a", "converted.wav", "converted.flac"} {
+		output := filepath.Join(dir, name)
+		transcode(t, source, output)
+
+		got, gotFormat, _ := readBack(t, output)
+
+		for _, speaker := range format.Layout.Speakers() {
+			if !bytes.Equal(speakerPCM(got, gotFormat, speaker), speakerPCM(pcm, format, speaker)) {
+				t.Errorf("%s (%s): %s samples differ", name, gotFormat.Layout, speaker)
+			}
+		}
+
+		source = output
+	}
+
+	// ALAC has center surround for 4 channels, FLAC quadraphonic back speakers.
+	format = saprobe.PCMFormat{SampleRate: 48000, BitDepth: saprobe.Depth16, Channels: 4, Layout: flac.Layout(4)}
+	source = writeFLAC(t, testutils.Noise(format, 5000, -6), format, saprobe.Metadata{})
+
+	err := transcodeCommand().Run(context.Background(),
+		[]string{"transcode", source, filepath.Join(filepath.Dir(source), "converted.m4a")})
+	if !errors.Is(err, errChannelLayout) {
+		t.Errorf("quadraphonic FLAC to ALAC: %v, want errChannelLayout", err)
+	}
+}
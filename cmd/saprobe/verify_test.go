@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/alac"
+	"github.com/farcloser/saprobe/detect"
+	"github.com/farcloser/saprobe/tests/testutils"
+)
+
+// TestVerifyDamagedALAC checks that an ALAC packet that cannot be decoded fails verification of its
+// file, and of the scan it is part of, instead of stopping it.
+func TestVerifyDamagedALAC(t *testing.T) {
+	t.Parallel()
+
+	format := saprobe.PCMFormat{
+		SampleRate: 44100, BitDepth: saprobe.Depth16, Channels: 2, Layout: saprobe.LayoutStereo,
+	}
+
+	var buf bytes.Buffer
+	if err := alac.Encode(&buf, testutils.Noise(format, 20000, -6), format, saprobe.Metadata{}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "good.m4a"), buf.Bytes(), outputMode); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first packet follows the mdat header: flag its CPE as a partial frame, whose sample count
+	// is then read from the audio that follows, far more than a frame holds.
+	data := bytes.Clone(buf.Bytes())
+	data[bytes.Index(data, []byte("mdat"))+4+2] |= 0x10
+
+	bad := filepath.Join(dir, "bad.m4a")
+	if err := os.WriteFile(bad, data, outputMode); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		out   bytes.Buffer
+		tally verifyTally
+	)
+
+	verifyFile(&out, bad, saprobe.Options{}, false, &tally)
+
+	if tally.failed != 1 || !strings.HasPrefix(out.String(), "FAIL  "+bad+" ("+detect.ALAC.String()+")\n") ||
+		!strings.Contains(out.String(), alac.ErrSampleOverrun.Error()) {
+		t.Errorf("%+v, output:\n%s\nwant a sample overrun", tally, out.String())
+	}
+
+	err := verifyCommand().Run(context.Background(), []string{"verify", "--quiet", dir})
+	if code := exitCode(err); code != exitCorrupt {
+		t.Errorf("verify %s: exit code %d (%v), want %d", dir, code, err, exitCorrupt)
+	}
+}
@@ -0,0 +1,99 @@
+package saprobe
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/farcloser/saprobe/internal/pcmio"
+)
+
+// Concealment selects how resilient decoding fills the samples it could not decode.
+type Concealment uint8
+
+const (
+	// ConcealSilence fills damaged ranges with digital silence.
+	ConcealSilence Concealment = iota
+	// ConcealInterpolate fills damaged ranges with a linear ramp between the last sample before
+	// the damage and the first sample after it, per channel, avoiding a click at either edge.
+	ConcealInterpolate
+)
+
+// String returns the name of the concealment mode.
+func (c Concealment) String() string {
+	switch c {
+	case ConcealSilence:
+		return "silence"
+	case ConcealInterpolate:
+		return "interpolate"
+	}
+
+	return "unknown"
+}
+
+// Damage is a range of samples, per channel and end-exclusive, that could not be decoded and was
+// concealed in resilient mode.
+type Damage struct {
+	Start int64
+	End   int64
+	// Err is the decoding error that caused the damage.
+	Err error
+}
+
+// Len returns the number of concealed samples per channel.
+func (d Damage) Len() int64 {
+	return d.End - d.Start
+}
+
+// Describe formats the damaged range as sample positions and, when sampleRate is known, as time.
+func (d Damage) Describe(sampleRate int) string {
+	if sampleRate <= 0 {
+		return fmt.Sprintf("samples %d-%d: %v", d.Start, d.End, d.Err)
+	}
+
+	at := func(sample int64) time.Duration {
+		return (time.Duration(sample) * time.Second / time.Duration(sampleRate)).Round(time.Millisecond)
+	}
+
+	return fmt.Sprintf("samples %d-%d (%s-%s): %v", d.Start, d.End, at(d.Start), at(d.End), d.Err)
+}
+
+// Conceal overwrites every damaged range of pcm (interleaved little-endian signed PCM in format)
+// according to mode. Ranges are clamped to the buffer.
+func Conceal(pcm []byte, format PCMFormat, damaged []Damage, mode Concealment) {
+	bps := format.BitDepth.BytesPerSample()
+	channels := int(format.Channels) //nolint:gosec // channel counts are small.
+	frameBytes := bps * channels
+	total := int64(len(pcm) / frameBytes)
+
+	for _, damage := range damaged {
+		start, end := max(damage.Start, 0), min(damage.End, total)
+		if start >= end {
+			continue
+		}
+
+		if mode != ConcealInterpolate {
+			clear(pcm[start*int64(frameBytes) : end*int64(frameBytes)])
+
+			continue
+		}
+
+		steps := end - start + 1
+
+		for ch := range channels {
+			var before, after int64
+
+			if start > 0 {
+				before = pcmio.ReadSample(pcm[(start-1)*int64(frameBytes)+int64(ch*bps):], bps)
+			}
+
+			if end < total {
+				after = pcmio.ReadSample(pcm[end*int64(frameBytes)+int64(ch*bps):], bps)
+			}
+
+			for pos := start; pos < end; pos++ {
+				value := before + (after-before)*(pos-start+1)/steps
+				pcmio.WriteSample(pcm[pos*int64(frameBytes)+int64(ch*bps):], bps, value)
+			}
+		}
+	}
+}
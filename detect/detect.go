@@ -1,6 +1,8 @@
 package detect
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 )
@@ -19,6 +21,12 @@ const (
 	MP3
 	// Vorbis is Ogg Vorbis.
 	Vorbis
+	// WAV is integer PCM in a RIFF WAVE container.
+	WAV
+	// MP2 is MPEG-1/2 Audio Layer II.
+	MP2
+	// MP1 is MPEG-1/2 Audio Layer I.
+	MP1
 )
 
 // String returns the human-readable name of the codec.
@@ -34,6 +42,12 @@ func (c Codec) String() string {
 		return "MP3"
 	case Vorbis:
 		return "Vorbis"
+	case WAV:
+		return "WAV"
+	case MP2:
+		return "MP2"
+	case MP1:
+		return "MP1"
 	}
 
 	return "unknown"
@@ -42,15 +56,25 @@ func (c Codec) String() string {
 // headerSize is the minimum number of bytes needed to identify any supported codec.
 // FLAC: 4 bytes at offset 0 ("fLaC").
 // ALAC: 4 bytes at offset 4 ("ftyp" in an M4A/MP4 container).
-// MP3:  3 bytes at offset 0 ("ID3") or 2-byte MPEG sync word (0xFF 0xE0 mask).
+// MPEG: 3 bytes at offset 0 ("ID3") or 2-byte MPEG sync word (0xFF 0xE0 mask); the layer bits of
+// the first frame tell MP3 from MP2 and MP1.
 // OGG:  4 bytes at offset 0 ("OggS").
+// WAV:  "RIFF" at offset 0 and "WAVE" at offset 8.
 const (
-	headerSize = 8
+	headerSize = 12
 
 	// mpegSyncByte is the first byte of an MPEG audio frame sync word.
 	mpegSyncByte = 0xFF
 	// mpegSyncMask masks the upper 3 bits of the second byte in the sync word.
 	mpegSyncMask = 0xE0
+
+	// id3v2HeaderSize is the length of the ID3v2 tag header, whose last 4 bytes hold the syncsafe
+	// tag size; id3v2FooterFlag marks an ID3v2.4 footer repeating the header after the tag.
+	id3v2HeaderSize = 10
+	id3v2FooterFlag = 0x10
+
+	// mpegProbeSize bounds the search for the first frame after an ID3v2 tag.
+	mpegProbeSize = 64 << 10
 )
 
 // Identify reads the header from rs and returns the detected audio codec.
@@ -76,21 +100,84 @@ func Identify(reader io.ReadSeeker) (Codec, error) {
 		return Vorbis, nil
 	}
 
+	// RIFF WAVE: "RIFF" followed by the chunk size, then "WAVE".
+	if string(header[:4]) == "RIFF" && string(header[8:12]) == "WAVE" {
+		return WAV, nil
+	}
+
 	// M4A/MP4 container (ALAC): bytes 4-7 are "ftyp".
 	if string(header[4:8]) == "ftyp" {
 		return ALAC, nil
 	}
 
-	// MP3: ID3v2 tag header starts with "ID3".
+	// MPEG audio: ID3v2 tag header starts with "ID3", frames with a sync word (11 set bits).
+	//nolint:gosec // header is a fixed-size array
+	if string(header[:3]) == "ID3" || header[0] == mpegSyncByte && header[1]&mpegSyncMask == mpegSyncMask {
+		return mpegLayer(reader, header[:])
+	}
+
+	return Unknown, nil
+}
+
+// mpegLayer returns the codec of the first MPEG audio frame, past any ID3v2 tag: Layer III, II or
+// I, unless FLAC follows the tag. Streams whose first frame cannot be found are assumed to be MP3.
+// The reader position is reset to the start before returning.
+func mpegLayer(reader io.ReadSeeker, header []byte) (Codec, error) {
+	start := 0
+
 	if string(header[:3]) == "ID3" {
-		return MP3, nil
+		size := int(header[6]&0x7F)<<21 | int(header[7]&0x7F)<<14 | int(header[8]&0x7F)<<7 | int(header[9]&0x7F)
+
+		start = id3v2HeaderSize + size
+		if header[5]&id3v2FooterFlag != 0 {
+			start += id3v2HeaderSize
+		}
 	}
 
-	// MP3: MPEG frame sync word (11 set bits).
-	//nolint:gosec // header is a fixed [8]byte array
-	if header[0] == mpegSyncByte && header[1]&mpegSyncMask == mpegSyncMask {
-		return MP3, nil
+	if _, err := reader.Seek(int64(start), io.SeekStart); err != nil {
+		return Unknown, fmt.Errorf("seeking past ID3v2 tag: %w", err)
 	}
 
-	return Unknown, nil
+	probe := make([]byte, mpegProbeSize)
+
+	read, err := io.ReadFull(reader, probe)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return Unknown, fmt.Errorf("reading first frame: %w", err)
+	}
+
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return Unknown, fmt.Errorf("seeking to start: %w", err)
+	}
+
+	probe = probe[:read]
+
+	// Some taggers put an ID3v2 tag before the FLAC stream marker too.
+	if bytes.HasPrefix(probe, []byte("fLaC")) {
+		return FLAC, nil
+	}
+
+	for idx := 0; idx+4 <= len(probe); idx++ {
+		if probe[idx] != mpegSyncByte || probe[idx+1]&mpegSyncMask != mpegSyncMask {
+			continue
+		}
+
+		version, layer := probe[idx+1]>>3&0x03, probe[idx+1]>>1&0x03
+		bitrate, rate := probe[idx+2]>>4, probe[idx+2]>>2&0x03
+
+		// Reserved version, layer, bitrate or sample rate: not a frame header.
+		if version == 0x01 || layer == 0x00 || bitrate == 0x0F || rate == 0x03 {
+			continue
+		}
+
+		switch layer {
+		case 0x02:
+			return MP2, nil
+		case 0x03:
+			return MP1, nil
+		default:
+			return MP3, nil
+		}
+	}
+
+	return MP3, nil
 }
@@ -0,0 +1,157 @@
+package saprobe
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Error categories. Every error returned by a codec's Decode functions matches (via errors.Is)
+// exactly one of them, or ErrTruncated in strict mode.
+var (
+	// ErrCorrupt means the stream violates its format: bad checksums, impossible header values,
+	// bitstream overruns.
+	ErrCorrupt = errors.New("corrupt stream")
+	// ErrUnsupported means the stream is valid but uses a feature saprobe does not decode.
+	ErrUnsupported = errors.New("unsupported stream")
+	// ErrIO means the underlying reader failed.
+	ErrIO = errors.New("i/o error")
+)
+
+// DecodeError is the error returned by codec decoders. It matches its Kind and its cause with
+// errors.Is, and locates the failure as precisely as the format allows: Offset, Frame and Sample
+// are -1 when unknown.
+type DecodeError struct {
+	// Codec names the decoder that failed ("flac", "alac", "mp3", "vorbis", "wav").
+	Codec string
+	// Kind is ErrCorrupt, ErrUnsupported, ErrIO or ErrTruncated.
+	Kind error
+	// Offset is the byte offset of the frame, packet or page in the file.
+	Offset int64
+	// Frame is the index of the frame, packet or page.
+	Frame int
+	// Sample is the position, in samples per channel, at which the failure occurred.
+	Sample int64
+	// Err is the underlying cause.
+	Err error
+}
+
+// NewDecodeError returns a DecodeError with an unknown location.
+func NewDecodeError(codec string, kind, err error) *DecodeError {
+	return &DecodeError{Codec: codec, Kind: kind, Offset: -1, Frame: -1, Sample: -1, Err: err}
+}
+
+// Error formats the failure with its category and location. The category is omitted when the
+// cause already names it.
+func (e *DecodeError) Error() string {
+	var parts []string
+
+	if !errors.Is(e.Err, e.Kind) {
+		parts = append(parts, e.Kind.Error())
+	}
+
+	var location []string
+
+	if e.Frame >= 0 {
+		location = append(location, fmt.Sprintf("frame %d", e.Frame))
+	}
+
+	if e.Offset >= 0 {
+		location = append(location, fmt.Sprintf("offset %d", e.Offset))
+	}
+
+	if e.Sample >= 0 {
+		location = append(location, fmt.Sprintf("sample %d", e.Sample))
+	}
+
+	if len(location) > 0 {
+		parts = append(parts, strings.Join(location, ", "))
+	}
+
+	parts = append(parts, e.Err.Error())
+
+	return e.Codec + ": " + strings.Join(parts, ": ")
+}
+
+// Unwrap exposes both the category and the cause to errors.Is and errors.As.
+func (e *DecodeError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// Finding expresses the failure as an integrity finding of the given check.
+func (e *DecodeError) Finding(check Check) Finding {
+	return Finding{Check: check, Frame: e.Frame, Offset: e.Offset, Sample: e.Sample, Detail: e.Err.Error()}
+}
+
+// TrackedReader remembers the last failure of the reader it wraps, other than io.EOF, so that a
+// decoder can tell I/O errors from format errors whatever path they took.
+type TrackedReader struct {
+	rs  io.ReadSeeker
+	err error
+}
+
+// TrackReader wraps rs.
+func TrackReader(rs io.ReadSeeker) *TrackedReader {
+	return &TrackedReader{rs: rs}
+}
+
+// Read reads from the wrapped reader.
+func (t *TrackedReader) Read(p []byte) (int, error) {
+	n, err := t.rs.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		t.err = err
+	}
+
+	return n, err //nolint:wrapcheck // io.Reader passthrough.
+}
+
+// Seek seeks the wrapped reader.
+func (t *TrackedReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := t.rs.Seek(offset, whence)
+	if err != nil {
+		t.err = err
+	}
+
+	return pos, err //nolint:wrapcheck // io.Seeker passthrough.
+}
+
+// Err returns the last failure of the wrapped reader, or nil.
+func (t *TrackedReader) Err() error {
+	return t.err
+}
+
+// WrapDecodeError turns an error from a codec decoder into a *DecodeError. An error that already is
+// one keeps its location; the category is ErrIO when reader failed, ErrTruncated for a strict-mode
+// Truncation, ErrUnsupported when err matches one of unsupported, and ErrCorrupt otherwise.
+func WrapDecodeError(codec string, reader *TrackedReader, err error, unsupported ...error) error {
+	if err == nil {
+		return nil
+	}
+
+	var (
+		decodeErr  *DecodeError
+		truncation *Truncation
+	)
+
+	if !errors.As(err, &decodeErr) {
+		decodeErr = NewDecodeError(codec, ErrCorrupt, err)
+	}
+
+	if errors.As(err, &truncation) {
+		decodeErr.Kind = ErrTruncated
+		decodeErr.Sample = truncation.Decoded
+	}
+
+	for _, sentinel := range unsupported {
+		if errors.Is(err, sentinel) {
+			decodeErr.Kind = ErrUnsupported
+		}
+	}
+
+	if reader != nil && reader.Err() != nil {
+		decodeErr.Kind = ErrIO
+	}
+
+	return decodeErr
+}
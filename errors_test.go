@@ -0,0 +1,99 @@
+package saprobe_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+)
+
+var (
+	errBitstream = errors.New("invalid bitstream")
+	errFeature   = errors.New("feature not handled")
+	errDevice    = errors.New("device gone")
+)
+
+// failingReader fails every read after the first limit bytes.
+type failingReader struct {
+	io.ReadSeeker
+	limit int64
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	pos, _ := f.Seek(0, io.SeekCurrent)
+	if pos >= f.limit {
+		return 0, errDevice
+	}
+
+	return f.ReadSeeker.Read(p[:min(int64(len(p)), f.limit-pos)])
+}
+
+// TestWrapDecodeError checks that every error is sorted into its category, and keeps its cause and
+// location.
+func TestWrapDecodeError(t *testing.T) {
+	t.Parallel()
+
+	located := &saprobe.DecodeError{
+		Codec: "flac", Kind: saprobe.ErrCorrupt, Offset: 1234, Frame: 5, Sample: 20480, Err: errBitstream,
+	}
+
+	for _, test := range []struct {
+		name   string
+		err    error
+		kind   error
+		sample int64
+	}{
+		{"format error", errBitstream, saprobe.ErrCorrupt, -1},
+		{"unsupported feature", errFeature, saprobe.ErrUnsupported, -1},
+		{"located error", located, saprobe.ErrCorrupt, 20480},
+		{"truncation", &saprobe.Truncation{Declared: 100, Decoded: 40, SampleRate: 8000}, saprobe.ErrTruncated, 40},
+	} {
+		err := saprobe.WrapDecodeError("flac", saprobe.TrackReader(strings.NewReader("")), test.err, errFeature)
+
+		var decodeErr *saprobe.DecodeError
+		if !errors.As(err, &decodeErr) || decodeErr.Kind != test.kind || decodeErr.Sample != test.sample {
+			t.Errorf("%s: %#v, want kind %v at sample %d", test.name, err, test.kind, test.sample)
+
+			continue
+		}
+
+		if !errors.Is(err, test.kind) || !errors.Is(err, test.err) {
+			t.Errorf("%s: %v does not match its kind and cause", test.name, err)
+		}
+
+		// Exactly one category matches.
+		matches := 0
+
+		for _, kind := range []error{saprobe.ErrCorrupt, saprobe.ErrUnsupported, saprobe.ErrIO, saprobe.ErrTruncated} {
+			if errors.Is(err, kind) {
+				matches++
+			}
+		}
+
+		if matches != 1 {
+			t.Errorf("%s: %v matches %d categories", test.name, err, matches)
+		}
+	}
+
+	if want := "flac: corrupt stream: frame 5, offset 1234, sample 20480: invalid bitstream"; located.Error() != want {
+		t.Errorf("message %q, want %q", located.Error(), want)
+	}
+
+	// A reader failure makes any error an I/O error, whatever the decoder made of it.
+	reader := saprobe.TrackReader(&failingReader{ReadSeeker: strings.NewReader("fLaC and more"), limit: 4})
+
+	if _, err := io.ReadAll(reader); !errors.Is(err, errDevice) {
+		t.Fatalf("read: %v", err)
+	}
+
+	err := saprobe.WrapDecodeError("flac", reader, io.ErrUnexpectedEOF)
+	if !errors.Is(err, saprobe.ErrIO) || errors.Is(err, saprobe.ErrCorrupt) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("reader failure: %v, want an I/O error", err)
+	}
+
+	if saprobe.WrapDecodeError("flac", reader, nil) != nil {
+		t.Error("nil is wrapped")
+	}
+}
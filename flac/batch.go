@@ -0,0 +1,160 @@
+package flac
+
+import (
+	"bytes"
+	"sync"
+)
+
+// framesPerJob is the number of frames each decoder takes in a batch: enough to amortize the
+// synchronization of concurrent decoding, few enough to bound the frames and PCM held in flight.
+const framesPerJob = 16
+
+// frameResult is the outcome of decoding one frame.
+type frameResult struct {
+	pcm   []byte
+	frame Frame
+	err   error
+}
+
+// frameBatch splits the buffered stream into consecutive frames and decodes them concurrently.
+// FLAC frames carry no length, so the boundaries come from a scan for the next frame header: a
+// sync code lookalike would need a valid CRC-8, the stream parameters and the next frame number
+// to pass, and the decoded size of each frame is checked against the boundaries anyway.
+type frameBatch struct {
+	bounds  []int // frame idx spans data[bounds[idx]:bounds[idx+1]]
+	results []frameResult
+}
+
+func newFrameBatch(jobs int) *frameBatch {
+	size := jobs * framesPerJob
+
+	return &frameBatch{
+		bounds:  make([]int, 0, size+1),
+		results: make([]frameResult, size),
+	}
+}
+
+// size returns the number of frames a batch holds.
+func (b *frameBatch) size() int {
+	return len(b.results)
+}
+
+// decode decodes the frames at the read position of frames, spreading them over the decoders,
+// one goroutine each, and moves past those that decoded. It returns their results, in stream
+// order; it stops short of a frame that fails, or that the scan may have misplaced, leaving it
+// to the sequential path.
+func (b *frameBatch) decode(frames *frameReader, decoders []*Decoder, info *StreamInfo) []frameResult {
+	data := frames.window(b.size() * frames.bound)
+	if frames.err != nil {
+		return nil
+	}
+
+	b.scan(data, info, frames.eof)
+
+	count := len(b.bounds) - 1
+	if count <= 0 {
+		return nil
+	}
+
+	decodeOne := func(dec *Decoder, idx int) {
+		result := &b.results[idx]
+		result.pcm, result.frame, result.err = dec.AppendFrame(result.pcm[:0], data[b.bounds[idx]:b.bounds[idx+1]])
+	}
+
+	var group sync.WaitGroup
+
+	for job, dec := range decoders {
+		group.Go(func() {
+			for idx := job; idx < count; idx += len(decoders) {
+				decodeOne(dec, idx)
+			}
+		})
+	}
+
+	group.Wait()
+
+	decoded := 0
+
+	for idx, result := range b.results[:count] {
+		if result.err != nil {
+			break
+		}
+
+		frames.advance(result.frame.Size)
+		decoded++
+
+		if result.frame.Size != b.bounds[idx+1]-b.bounds[idx] {
+			break
+		}
+	}
+
+	return b.results[:decoded]
+}
+
+// scan finds the boundaries of up to size frames at the start of data. The last frame found only
+// counts when its end is known: the next header follows, or data holds the rest of the stream.
+func (b *frameBatch) scan(data []byte, info *StreamInfo, eof bool) {
+	b.bounds = b.bounds[:0]
+
+	header, err := parseFrameHeader(data, info)
+	if err != nil || !header.matches(info) {
+		return
+	}
+
+	pos := 0
+	b.bounds = append(b.bounds, pos)
+
+	for len(b.bounds) <= b.size() {
+		next, nextHeader, found := nextFrame(data, pos+header.size, info, &header)
+		if !found {
+			if eof {
+				b.bounds = append(b.bounds, len(data))
+			}
+
+			return
+		}
+
+		pos, header = next, nextHeader
+		b.bounds = append(b.bounds, pos)
+	}
+}
+
+// nextFrame returns the position in data, from from on, of the header of the frame following the
+// one prev heads, and that header.
+func nextFrame(data []byte, from int, info *StreamInfo, prev *frameHeader) (int, frameHeader, bool) {
+	for from < len(data)-1 {
+		found := bytes.IndexByte(data[from:len(data)-1], syncByte)
+		if found < 0 {
+			break
+		}
+
+		from += found
+
+		if data[from+1]&syncMask == syncLow {
+			header, err := parseFrameHeader(data[from:], info)
+			if err == nil && header.matches(info) && header.follows(prev) {
+				return from, header, true
+			}
+		}
+
+		from++
+	}
+
+	return 0, frameHeader{}, false
+}
+
+// newDecoders creates one decoder per concurrent job.
+func newDecoders(info StreamInfo, jobs int) ([]*Decoder, error) {
+	decoders := make([]*Decoder, jobs)
+
+	for idx := range decoders {
+		dec, err := NewDecoder(info)
+		if err != nil {
+			return nil, err
+		}
+
+		decoders[idx] = dec
+	}
+
+	return decoders, nil
+}
@@ -0,0 +1,127 @@
+package flac
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// bitReader reads a frame MSB first, through a 64-bit cache refilled a word at a time. Reads past
+// the end of the data return zero bits; overrun tells whether that happened.
+type bitReader struct {
+	data  []byte
+	pos   int    // next byte of data to load into the cache
+	cache uint64 // unread bits, left-aligned
+	count uint   // number of unread bits in the cache
+}
+
+func newBitReader(data []byte) bitReader {
+	return bitReader{data: data}
+}
+
+// refill tops the cache up to at least 57 bits.
+func (br *bitReader) refill() {
+	if br.pos+8 <= len(br.data) {
+		take := (64 - br.count) >> 3
+		word := binary.BigEndian.Uint64(br.data[br.pos:])
+		word &= ^uint64(0) << (64 - take*8)
+
+		br.cache |= word >> br.count
+		br.count += take * 8
+		br.pos += int(take)
+
+		return
+	}
+
+	for br.count <= 56 {
+		var next byte
+		if br.pos < len(br.data) {
+			next = br.data[br.pos]
+		}
+
+		br.cache |= uint64(next) << (56 - br.count)
+		br.count += 8
+		br.pos++
+	}
+}
+
+// read reads n bits, n up to 57, as an unsigned value.
+func (br *bitReader) read(n uint) uint64 {
+	if br.count < n {
+		br.refill()
+	}
+
+	value := br.cache >> (64 - n)
+	br.cache <<= n
+	br.count -= n
+
+	return value
+}
+
+// readSigned reads n bits, n up to 57, as a two's complement value.
+func (br *bitReader) readSigned(n uint) int64 {
+	if n == 0 {
+		return 0
+	}
+
+	return int64(br.read(n)<<(64-n)) >> (64 - n) //nolint:gosec // sign extension.
+}
+
+// readUnary reads a unary coded value: the count of zero bits before a one bit.
+func (br *bitReader) readUnary() uint64 {
+	var zeros uint64
+
+	for {
+		if br.count == 0 {
+			br.refill()
+		}
+
+		if lead := uint(bits.LeadingZeros64(br.cache)); lead < br.count {
+			br.cache <<= lead + 1
+			br.count -= lead + 1
+
+			return zeros + uint64(lead)
+		}
+
+		zeros += uint64(br.count)
+		br.cache, br.count = 0, 0
+
+		// Past the end of the data, only zero bits follow.
+		if br.pos > len(br.data) {
+			return zeros
+		}
+	}
+}
+
+// readRice reads a Rice coded signed value with parameter k: a unary quotient, k low bits, and
+// the sign folded into the lowest bit.
+func (br *bitReader) readRice(k uint) int64 {
+	var folded uint64
+
+	// The whole code usually lies in the cache: read it at once.
+	if lead := uint(bits.LeadingZeros64(br.cache)); lead+1+k <= br.count {
+		rest := br.cache << (lead + 1)
+		folded = uint64(lead)<<k | rest>>(64-k)
+
+		br.cache = rest << k
+		br.count -= lead + 1 + k
+	} else {
+		folded = br.readUnary()<<k | br.read(k)
+	}
+
+	return int64(folded>>1) ^ -int64(folded&1) //nolint:gosec // zigzag decoding.
+}
+
+// alignByte skips the bits up to the next byte boundary.
+func (br *bitReader) alignByte() {
+	br.read(br.count % 8)
+}
+
+// offset returns the number of whole bytes read.
+func (br *bitReader) offset() int {
+	return br.pos - int(br.count/8)
+}
+
+// overrun tells whether reads went past the end of the data.
+func (br *bitReader) overrun() bool {
+	return br.pos*8-int(br.count) > len(br.data)*8
+}
@@ -0,0 +1,108 @@
+package flac
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/farcloser/saprobe"
+)
+
+// maxBlockSize is the largest block size a frame header can code.
+const maxBlockSize = 1 << 16
+
+// Blocks decodes a FLAC stream frame by frame, straight into planar samples, and yields a block per
+// frame (see saprobe.Block and Decoder.DecodeFrameInt32). Decoding stops at the first error, which
+// is yielded as Decode would return it; a stream cut inside a frame ends without error.
+func Blocks(rs io.ReadSeeker) iter.Seq2[saprobe.Block, error] {
+	return func(yield func(saprobe.Block, error) bool) {
+		tracked := saprobe.TrackReader(rs)
+
+		err := decodeBlocks(tracked, func(block saprobe.Block) bool {
+			return yield(block, nil)
+		})
+		if err != nil {
+			yield(saprobe.Block{}, wrapError(tracked, err))
+		}
+	}
+}
+
+// decodeBlocks decodes every frame of rs, handing each to yield until it returns false.
+func decodeBlocks(rs io.ReadSeeker, yield func(saprobe.Block) bool) error {
+	info, err := readStreamInfo(rs)
+	if err != nil {
+		return fmt.Errorf("opening flac: %w", err)
+	}
+
+	dec, err := NewDecoder(info)
+	if err != nil {
+		return err
+	}
+
+	start, err := audioOffset(rs)
+	if err != nil {
+		return err
+	}
+
+	frames, err := newFrameReader(rs, start, &info)
+	if err != nil {
+		return err
+	}
+
+	planes := make([][]int32, info.Channels)
+	for ch := range planes {
+		planes[ch] = make([]int32, info.BlockSizeMax)
+	}
+
+	decodeFrame := func(data []byte) (Frame, error) {
+		frame, err := dec.DecodeFrameInt32(planes, data)
+
+		// Frames may exceed the STREAMINFO maximum: make room for the largest there can be.
+		if errors.Is(err, ErrShortBuffer) {
+			for ch := range planes {
+				planes[ch] = make([]int32, maxBlockSize)
+			}
+
+			frame, err = dec.DecodeFrameInt32(planes, data)
+		}
+
+		return frame, err
+	}
+
+	block := saprobe.Block{Format: dec.Format(), Samples: make([][]int32, len(planes))}
+
+	for frameIdx := 0; ; frameIdx++ {
+		frameStart := frames.offset
+
+		frame, err := frames.decode(decodeFrame)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+
+		if frames.err != nil {
+			return err
+		}
+
+		if err != nil {
+			return &saprobe.DecodeError{
+				Codec:  codecName,
+				Kind:   saprobe.ErrCorrupt,
+				Offset: frameStart,
+				Frame:  frameIdx,
+				Sample: block.Start + int64(block.Len()),
+				Err:    err,
+			}
+		}
+
+		block.Start += int64(block.Len())
+
+		for ch, plane := range planes {
+			block.Samples[ch] = plane[:frame.Samples]
+		}
+
+		if !yield(block) {
+			return nil
+		}
+	}
+}
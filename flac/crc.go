@@ -0,0 +1,84 @@
+package flac
+
+// CRC polynomials of the frame header (CRC-8) and of the whole frame (CRC-16), both MSB first
+// with a zero initial value.
+const (
+	crc8Poly  = 0x07
+	crc16Poly = 0x8005
+)
+
+//nolint:gochecknoglobals // computed constant tables
+var (
+	crc8Table  = makeCRC8Table()
+	crc16Table = makeCRC16Table()
+)
+
+func makeCRC8Table() [256]uint8 {
+	var table [256]uint8
+
+	for idx := range table {
+		crc := uint8(idx)
+		for range 8 {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ crc8Poly
+			} else {
+				crc <<= 1
+			}
+		}
+
+		table[idx] = crc
+	}
+
+	return table
+}
+
+// makeCRC16Table returns the tables of a slicing-by-2 CRC-16: the first one for the last byte
+// fed, the second one for the byte before it.
+func makeCRC16Table() [2][256]uint16 {
+	var table [2][256]uint16
+
+	for idx := range table[0] {
+		crc := uint16(idx) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ crc16Poly
+			} else {
+				crc <<= 1
+			}
+		}
+
+		table[0][idx] = crc
+	}
+
+	for idx := range table[1] {
+		table[1][idx] = table[0][table[0][idx]>>8] ^ table[0][idx]<<8
+	}
+
+	return table
+}
+
+func crc8(data []byte) uint8 {
+	var crc uint8
+
+	for _, b := range data {
+		crc = crc8Table[crc^b]
+	}
+
+	return crc
+}
+
+func crc16(data []byte) uint16 {
+	var crc uint16
+
+	for len(data) >= 2 {
+		crc ^= uint16(data[0])<<8 | uint16(data[1])
+		crc = crc16Table[1][crc>>8] ^ crc16Table[0][crc&0xFF]
+		data = data[2:]
+	}
+
+	for _, b := range data {
+		crc = crc<<8 ^ crc16Table[0][byte(crc>>8)^b]
+	}
+
+	return crc
+}
@@ -1,118 +1,278 @@
 package flac
 
 import (
-	"encoding/binary"
+	"crypto/md5" //nolint:gosec // FLAC STREAMINFO stores an MD5 of the audio; not used for security.
 	"errors"
 	"fmt"
 	"io"
 
-	goflac "github.com/mewkiz/flac"
-	"github.com/mewkiz/flac/frame"
-
 	"github.com/farcloser/saprobe"
 )
 
+const codecName = "flac"
+
 var errBitDepth = errors.New("unsupported bit depth")
 
+// ErrFrameCRC is matched (via errors.Is) by frame header (CRC-8) and frame (CRC-16) checksum failures.
+var ErrFrameCRC = errors.New("frame CRC mismatch")
+
+// ErrShortBuffer is returned by DecodeFrameInt32 for a destination too small for a frame.
+var ErrShortBuffer = errors.New("flac: destination buffer too small for a frame")
+
+// MD5Status is the outcome of checking the decoded audio against the STREAMINFO MD5.
+type MD5Status uint8
+
+const (
+	// MD5NotChecked means verification was not requested.
+	MD5NotChecked MD5Status = iota
+	// MD5Match means the decoded audio hashes to the stored MD5.
+	MD5Match
+	// MD5Mismatch means the decoded audio does not hash to the stored MD5.
+	MD5Mismatch
+	// MD5Absent means the encoder stored no MD5 (all zero bytes), so nothing could be checked.
+	MD5Absent
+)
+
+// String returns a human-readable name for the status.
+func (s MD5Status) String() string {
+	switch s {
+	case MD5NotChecked:
+		return "not checked"
+	case MD5Match:
+		return "match"
+	case MD5Mismatch:
+		return "mismatch"
+	case MD5Absent:
+		return "no MD5 stored"
+	}
+
+	return "unknown"
+}
+
+// Options controls optional work performed by DecodeWithOptions.
+type Options struct {
+	saprobe.Options
+
+	// VerifyMD5 hashes the decoded samples the way the reference encoder does and compares
+	// the result with the MD5 stored in STREAMINFO.
+	VerifyMD5 bool
+}
+
+// Report describes the integrity checks performed while decoding.
+type Report struct {
+	saprobe.Report
+
+	MD5         MD5Status
+	StoredMD5   [md5.Size]byte
+	ComputedMD5 [md5.Size]byte
+}
+
 // Decode reads a FLAC stream and decodes it to interleaved little-endian signed PCM bytes.
 // Native bit depth is preserved (16-bit FLAC produces s16le, 24-bit produces s24le, etc.).
 func Decode(rs io.ReadSeeker) ([]byte, saprobe.PCMFormat, error) {
-	stream, err := goflac.New(rs)
+	pcm, format, _, err := DecodeWithOptions(rs, Options{})
+
+	return pcm, format, err
+}
+
+// DecodeWithOptions is Decode with optional integrity checks, whose outcome is returned in
+// the Report. An MD5 mismatch is reported, not returned as an error.
+// A stream that ends before the sample count declared in STREAMINFO (a partial final frame is
+// dropped) is reported as a Truncation, or fails in strict mode.
+// Frame header (CRC-8) and frame (CRC-16) checksum failures are errors naming the frame index
+// and the sample position at which the damaged frame starts, unless resilient decoding is
+// requested: the frame is then concealed and decoding resumes at the next valid frame header.
+// With opts.Jobs above 1, frames decode concurrently; the output is the same.
+func DecodeWithOptions(rs io.ReadSeeker, opts Options) ([]byte, saprobe.PCMFormat, Report, error) {
+	tracked := saprobe.TrackReader(rs)
+	pcm, format, report, err := decodeStream(tracked, opts, true)
+
+	return pcm, format, report, wrapError(tracked, err)
+}
+
+// wrapError categorizes a decoding error as a *saprobe.DecodeError.
+func wrapError(reader *saprobe.TrackedReader, err error) error {
+	return saprobe.WrapDecodeError(codecName, reader, err, errBitDepth)
+}
+
+// decodeStream decodes every frame of rs. The PCM is only accumulated and returned when keep
+// is set, so that verification can run in constant memory.
+//
+//revive:disable-next-line:flag-parameter
+func decodeStream(rs io.ReadSeeker, opts Options, keep bool) ([]byte, saprobe.PCMFormat, Report, error) {
+	var report Report
+
+	info, err := readStreamInfo(rs)
 	if err != nil {
-		return nil, saprobe.PCMFormat{}, fmt.Errorf("opening flac: %w", err)
+		return nil, saprobe.PCMFormat{}, report, fmt.Errorf("opening flac: %w", err)
 	}
-	defer stream.Close()
 
-	info := stream.Info
-	nChannels := int(info.NChannels)
-
-	bitDepth, err := saprobe.ToBitDepth(info.BitsPerSample)
+	dec, err := NewDecoder(info)
 	if err != nil {
-		return nil, saprobe.PCMFormat{}, fmt.Errorf("%w: %w", errBitDepth, err)
+		return nil, saprobe.PCMFormat{}, report, err
 	}
 
-	bytesPerSample := bitDepth.BytesPerSample()
+	report.StoredMD5 = info.MD5
 
-	format := saprobe.PCMFormat{
-		SampleRate: int(info.SampleRate),
-		BitDepth:   bitDepth,
-		Channels:   uint(nChannels), //nolint:gosec // nChannels comes from uint8, always fits in uint.
+	if opts.VerifyMD5 {
+		dec.md5 = md5.New() //nolint:gosec // integrity check mandated by the format.
 	}
 
+	format := dec.Format()
+	frameSize := int(format.Channels) * format.BitDepth.BytesPerSample() //nolint:gosec // at most 8 channels.
+
 	// Pre-allocate output buffer when total sample count is known.
 	var buf []byte
-	if info.NSamples > 0 {
-		//nolint:gosec // NSamples (uint64) fits in int for any real audio file.
-		buf = make([]byte, 0, int(info.NSamples)*nChannels*bytesPerSample)
+	if keep && info.Samples > 0 {
+		//nolint:gosec // Samples fits in int for any real audio file.
+		buf = make([]byte, 0, int(info.Samples)*frameSize)
 	}
 
-	// Scratch buffer for one interleaved frame (reused across iterations).
-	var scratch []byte
+	start, err := audioOffset(rs)
+	if err != nil {
+		return nil, saprobe.PCMFormat{}, report, err
+	}
 
-	for {
-		audioFrame, parseErr := stream.ParseNext()
-		if errors.Is(parseErr, io.EOF) {
-			break
+	frames, err := newFrameReader(rs, start, &info)
+	if err != nil {
+		return nil, saprobe.PCMFormat{}, report, err
+	}
+
+	// Concurrent decoding runs ahead in batches; the frames a batch leaves out, damaged ones
+	// included, go through the sequential path below.
+	var (
+		batch    *frameBatch
+		decoders []*Decoder
+	)
+
+	if opts.Jobs > 1 {
+		decoders, err = newDecoders(info, opts.Jobs)
+		if err != nil {
+			return nil, saprobe.PCMFormat{}, report, err
+		}
+
+		batch = newFrameBatch(opts.Jobs)
+	}
+
+	// Without keep, every frame is decoded into the same scratch buffer.
+	var (
+		scratch   []byte
+		samplePos uint64
+	)
+
+	for frameIdx := 0; ; frameIdx++ {
+		if batch != nil {
+			for _, result := range batch.decode(frames, decoders, &info) {
+				if keep {
+					buf = append(buf, result.pcm...)
+				}
+
+				if dec.md5 != nil {
+					dec.hash(result.pcm)
+				}
+
+				samplePos += uint64(result.frame.Samples) //nolint:gosec // block sizes are 16-bit.
+				frameIdx++
+			}
 		}
 
-		if parseErr != nil {
-			return nil, saprobe.PCMFormat{}, fmt.Errorf("decoding frame: %w", parseErr)
+		frameStart := frames.offset
+
+		out := scratch[:0]
+		if keep {
+			out = buf
 		}
 
-		blockSize := int(audioFrame.BlockSize)
-		frameBytes := blockSize * nChannels * bytesPerSample
+		out, decoded, decodeErr := frames.next(dec, out)
+		if errors.Is(decodeErr, io.EOF) || errors.Is(decodeErr, io.ErrUnexpectedEOF) {
+			break
+		}
 
-		if cap(scratch) < frameBytes {
-			scratch = make([]byte, frameBytes)
-		} else {
-			scratch = scratch[:frameBytes]
+		if frames.err != nil {
+			return nil, saprobe.PCMFormat{}, report, decodeErr
 		}
 
-		interleave(scratch, audioFrame.Subframes, blockSize, nChannels, bitDepth)
-		buf = append(buf, scratch...)
-	}
+		if decodeErr != nil {
+			damaged := &saprobe.DecodeError{
+				Codec:  codecName,
+				Kind:   saprobe.ErrCorrupt,
+				Offset: frameStart,
+				Frame:  frameIdx,
+				Sample: int64(samplePos), //nolint:gosec // sample counts fit in int64.
+				Err:    decodeErr,
+			}
+			if !opts.Resilient {
+				return nil, saprobe.PCMFormat{}, report, damaged
+			}
 
-	return buf, format, nil
-}
+			// Resume at the next valid frame; the gap runs up to its first sample, or to the
+			// declared end of the stream when no frame follows.
+			header, found := frames.resync(&info)
 
-// interleave writes decoded subframe samples into dst as interleaved little-endian signed PCM.
-func interleave(dst []byte, subframes []*frame.Subframe, blockSize, nChannels int, depth saprobe.BitDepth) {
-	pos := 0
-
-	switch depth {
-	case saprobe.Depth16:
-		for i := range blockSize {
-			for ch := range nChannels {
-				binary.LittleEndian.PutUint16(
-					dst[pos:],
-					uint16(int16(subframes[ch].Samples[i])), //nolint:gosec // Intentional int32-to-int16 truncation.
-				)
-				pos += 2
+			end := info.Samples
+			if found {
+				end = header.firstSample(&info)
 			}
-		}
-	case saprobe.Depth24:
-		for i := range blockSize {
-			for ch := range nChannels {
-				s := subframes[ch].Samples[i]
-				dst[pos] = byte(s)
-				dst[pos+1] = byte(s >> 8)
-				dst[pos+2] = byte(s >> 16)
-				pos += 3
+
+			if end > samplePos {
+				if keep {
+					gap := int(end-samplePos) * frameSize //nolint:gosec // bounded by the stream length.
+					buf = append(buf, make([]byte, gap)...)
+				}
+
+				dec.hashSilence(end - samplePos)
+
+				report.Damaged = append(report.Damaged, saprobe.Damage{
+					Start: int64(samplePos), //nolint:gosec // sample counts fit in int64.
+					End:   int64(end),       //nolint:gosec // sample counts fit in int64.
+					Err:   damaged,
+				})
+				samplePos = end
 			}
-		}
-	case saprobe.Depth32:
-		for i := range blockSize {
-			for ch := range nChannels {
-				binary.LittleEndian.PutUint32(
-					dst[pos:],
-					uint32(subframes[ch].Samples[i]), //nolint:gosec // int32-to-uint32 reinterpretation.
-				)
-				pos += 4
+
+			if !found {
+				break
 			}
+
+			continue
+		}
+
+		if keep {
+			buf = out
+		} else {
+			scratch = out
 		}
-	case saprobe.Depth20:
-		fallthrough
+
+		samplePos += uint64(decoded.Samples) //nolint:gosec // block sizes are 16-bit.
+	}
+
+	//nolint:gosec // sample counts fit in int64.
+	declared, decoded := int64(info.Samples), int64(samplePos)
+
+	err = saprobe.CheckTruncation(opts.Options, &report.Report, declared, decoded, format.SampleRate)
+	if err != nil {
+		return nil, saprobe.PCMFormat{}, report, err
+	}
+
+	if dec.md5 != nil {
+		report.ComputedMD5 = [md5.Size]byte(dec.md5.Sum(nil))
+		report.MD5 = md5Status(report.StoredMD5, report.ComputedMD5)
+	}
+
+	if keep && opts.Conceal != saprobe.ConcealSilence {
+		saprobe.Conceal(buf, format, report.Damaged, opts.Conceal)
+	}
+
+	return buf, format, report, nil
+}
+
+func md5Status(stored, computed [md5.Size]byte) MD5Status {
+	switch {
+	case stored == [md5.Size]byte{}:
+		return MD5Absent
+	case stored == computed:
+		return MD5Match
 	default:
-		panic(fmt.Sprintf("flac: interleave called with unsupported bit depth %d", depth))
+		return MD5Mismatch
 	}
 }
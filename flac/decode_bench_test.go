@@ -0,0 +1,123 @@
+package flac_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+
+	goflac "github.com/mewkiz/flac"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/flac"
+)
+
+// benchStream encodes 10 seconds of a stereo sine sweep at the given bit depth, and returns the
+// stream with the size of its decoded PCM.
+func benchStream(b *testing.B, depth saprobe.BitDepth) ([]byte, int64) {
+	b.Helper()
+
+	format := saprobe.PCMFormat{SampleRate: 44100, BitDepth: depth, Channels: 2}
+	frames := 10 * format.SampleRate
+	bps := depth.BytesPerSample()
+	pcm := make([]byte, frames*2*bps)
+	amplitude := float64(int64(1) << (8*bps - 2))
+
+	for idx := range frames * 2 {
+		value := int64(amplitude * math.Sin(float64(idx*idx)/1e9))
+		for k := range bps {
+			pcm[idx*bps+k] = byte(value >> (8 * k))
+		}
+	}
+
+	return encode(b, pcm, format), int64(len(pcm))
+}
+
+func BenchmarkDecode(b *testing.B) {
+	for _, depth := range []saprobe.BitDepth{saprobe.Depth16, saprobe.Depth24} {
+		for _, jobs := range []int{1, 4} {
+			b.Run(fmt.Sprintf("%d-bit/jobs=%d", depth, jobs), func(b *testing.B) {
+				data, size := benchStream(b, depth)
+				opts := flac.Options{Options: saprobe.Options{Jobs: jobs}}
+
+				b.SetBytes(size)
+				b.ReportAllocs()
+
+				for b.Loop() {
+					if _, _, _, err := flac.DecodeWithOptions(bytes.NewReader(data), opts); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkDecodeMewkiz decodes the same streams with the mewkiz decoder, interleaving its
+// samples into PCM bytes as DecodeWithOptions outputs them, for comparison with BenchmarkDecode.
+func BenchmarkDecodeMewkiz(b *testing.B) {
+	for _, depth := range []saprobe.BitDepth{saprobe.Depth16, saprobe.Depth24} {
+		b.Run(fmt.Sprintf("%d-bit", depth), func(b *testing.B) {
+			data, size := benchStream(b, depth)
+			bps := depth.BytesPerSample()
+			pcm := make([]byte, 0, size)
+
+			b.SetBytes(size)
+			b.ReportAllocs()
+
+			for b.Loop() {
+				stream, err := goflac.New(bytes.NewReader(data))
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				pcm = pcm[:0]
+
+				for {
+					frame, err := stream.ParseNext()
+					if errors.Is(err, io.EOF) {
+						break
+					}
+
+					if err != nil {
+						b.Fatal(err)
+					}
+
+					for idx := range int(frame.BlockSize) {
+						for _, subframe := range frame.Subframes {
+							value := subframe.Samples[idx]
+							for k := range bps {
+								pcm = append(pcm, byte(value>>(8*k)))
+							}
+						}
+					}
+				}
+
+				if int64(len(pcm)) != size {
+					b.Fatalf("decoded %d bytes, want %d", len(pcm), size)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkBlocks(b *testing.B) {
+	for _, depth := range []saprobe.BitDepth{saprobe.Depth16, saprobe.Depth24} {
+		b.Run(fmt.Sprintf("%d-bit", depth), func(b *testing.B) {
+			data, size := benchStream(b, depth)
+
+			b.SetBytes(size)
+			b.ReportAllocs()
+
+			for b.Loop() {
+				for _, err := range flac.Blocks(bytes.NewReader(data)) {
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
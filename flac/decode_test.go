@@ -0,0 +1,214 @@
+package flac_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"testing"
+
+	goflac "github.com/mewkiz/flac"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/detect"
+	"github.com/farcloser/saprobe/flac"
+	"github.com/farcloser/saprobe/tests/testutils"
+)
+
+// TestDecodeID3Prefix decodes a stream behind an ID3v2 tag (testdata/id3.flac, from mewkiz/flac,
+// an excerpt cut short after 16384 samples) and compares it with the mewkiz decoder.
+func TestDecodeID3Prefix(t *testing.T) {
+	t.Parallel()
+
+	file, err := os.Open("testdata/id3.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if codec, err := detect.Identify(file); err != nil || codec != detect.FLAC {
+		t.Errorf("identified as %v (%v), want FLAC", codec, err)
+	}
+
+	pcm, format, report, err := flac.DecodeWithOptions(file, flac.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if format.SampleRate != 44100 || format.BitDepth != saprobe.Depth16 || format.Channels != 2 {
+		t.Errorf("format: %+v, want 44100 Hz 16-bit stereo", format)
+	}
+
+	if report.Truncation == nil {
+		t.Error("the excerpt is not reported truncated")
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := goflac.New(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want []int16
+
+	for {
+		frame, err := stream.ParseNext()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Fatal(err)
+			}
+
+			break
+		}
+
+		for idx := range int(frame.BlockSize) {
+			for _, subframe := range frame.Subframes {
+				want = append(want, int16(subframe.Samples[idx])) //nolint:gosec // 16-bit samples.
+			}
+		}
+	}
+
+	if len(pcm) != 2*len(want) {
+		t.Fatalf("decoded %d samples, mewkiz %d", len(pcm)/2, len(want))
+	}
+
+	for idx, sample := range want {
+		if got := int16(binary.LittleEndian.Uint16(pcm[2*idx:])); got != sample { //nolint:gosec // 16-bit samples.
+			t.Fatalf("sample %d: %d, mewkiz %d", idx, got, sample)
+		}
+	}
+}
+
+// TestDecodeTruncated checks that a stream cut short reports the samples STREAMINFO declares and
+// the samples decoded, and fails with ErrTruncated in strict mode.
+func TestDecodeTruncated(t *testing.T) {
+	t.Parallel()
+
+	format := saprobe.PCMFormat{SampleRate: 44100, BitDepth: saprobe.Depth16, Channels: 2}
+	data := encode(t, testutils.Noise(format, 20000, -6), format)
+
+	// The frames hold 4096 samples: cutting into the third one leaves two.
+	cut := data[:len(data)/2]
+
+	pcm, _, report, err := flac.DecodeWithOptions(bytes.NewReader(cut), flac.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	truncation := report.Truncation
+	if truncation == nil || truncation.Declared != 20000 || truncation.Decoded != 8192 ||
+		truncation.Missing() != 11808 || int64(len(pcm)/4) != truncation.Decoded {
+		t.Fatalf("truncation %+v with %d samples decoded, want 8192 of 20000", truncation, len(pcm)/4)
+	}
+
+	if truncation.MissingDuration().Milliseconds() != 267 {
+		t.Errorf("missing %s, want 267ms", truncation.MissingDuration())
+	}
+
+	_, _, _, err = flac.DecodeWithOptions(bytes.NewReader(cut), flac.Options{Options: saprobe.Options{Strict: true}})
+
+	var decodeErr *saprobe.DecodeError
+	if !errors.Is(err, saprobe.ErrTruncated) || !errors.As(err, &decodeErr) || decodeErr.Sample != 8192 {
+		t.Errorf("strict decode: %v, want a truncation at sample 8192", err)
+	}
+
+	if _, _, report, err := flac.DecodeWithOptions(bytes.NewReader(data), flac.Options{}); err != nil ||
+		report.Truncation != nil {
+		t.Errorf("complete stream: %+v (%v), want no truncation", report.Truncation, err)
+	}
+}
+
+// TestDecodeJobs checks that decoding frames concurrently outputs the same bytes, MD5 and damage,
+// and verifies the same way, as decoding them one after the other.
+func TestDecodeJobs(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []saprobe.PCMFormat{
+		{SampleRate: 44100, BitDepth: saprobe.Depth16, Channels: 2},
+		{SampleRate: 48000, BitDepth: saprobe.Depth24, Channels: 6},
+	} {
+		data := encode(t, testutils.Noise(format, 100000, -6), format)
+
+		// Damage a few frames, for resilient decoding.
+		damaged := slices.Clone(data)
+		for _, at := range []int{len(data) / 4, len(data) / 2, len(data) * 3 / 4} {
+			damaged[at] ^= 0xFF
+		}
+
+		for _, test := range []struct {
+			data []byte
+			opts flac.Options
+		}{
+			{data, flac.Options{VerifyMD5: true}},
+			{damaged, flac.Options{Options: saprobe.Options{Resilient: true}, VerifyMD5: true}},
+		} {
+			name := fmt.Sprintf("%d-bit %d channels, resilient %t",
+				format.BitDepth, format.Channels, test.opts.Resilient)
+
+			_, _, report, err := flac.DecodeWithOptions(bytes.NewReader(test.data), test.opts)
+			if err != nil || test.opts.Resilient != (len(report.Damaged) != 0) {
+				t.Fatalf("%s: damage %v (%v)", name, report.Damaged, err)
+			}
+
+			verification, err := flac.VerifyWithOptions(bytes.NewReader(test.data), test.opts.Options)
+			if err != nil || verification.OK() == test.opts.Resilient {
+				t.Fatalf("%s: verify: %v (%v)", name, verification.Findings, err)
+			}
+
+			testutils.SameWithJobs(t, name, func(jobs int) ([]byte, string, error) {
+				opts := test.opts
+				opts.Jobs = jobs
+
+				pcm, _, report, err := flac.DecodeWithOptions(bytes.NewReader(test.data), opts)
+				if err != nil {
+					return nil, "", err
+				}
+
+				verification, err := flac.VerifyWithOptions(bytes.NewReader(test.data), opts.Options)
+
+				return pcm, fmt.Sprint(report.MD5, report.ComputedMD5, report.Damaged, verification), err
+			})
+		}
+	}
+}
+
+// TestEncodeBits checks that 8 and 12-bit samples, left-aligned in 16-bit PCM, are stored at their
+// size, decode back to the same PCM with a matching MD5, and that wider samples are refused.
+func TestEncodeBits(t *testing.T) {
+	t.Parallel()
+
+	format := saprobe.PCMFormat{SampleRate: 44100, BitDepth: saprobe.Depth16, Channels: 2}
+
+	for _, bits := range []int{8, 12} {
+		pcm := testutils.Noise(format, 10000, -6)
+		for idx := 0; idx < len(pcm); idx += 2 {
+			sample := binary.LittleEndian.Uint16(pcm[idx:])
+			binary.LittleEndian.PutUint16(pcm[idx:], sample&^(1<<(16-bits)-1))
+		}
+
+		var buf bytes.Buffer
+		if err := flac.EncodeBits(&buf, pcm, format, bits, saprobe.Metadata{}); err != nil {
+			t.Fatal(err)
+		}
+
+		if read, err := flac.ReadBitsPerSample(bytes.NewReader(buf.Bytes())); err != nil || read != bits {
+			t.Errorf("%d bits: STREAMINFO declares %d (%v)", bits, read, err)
+		}
+
+		decoded, _, _, err := flac.DecodeWithOptions(bytes.NewReader(buf.Bytes()), flac.Options{VerifyMD5: true})
+		if err != nil || !bytes.Equal(decoded, pcm) {
+			t.Errorf("%d bits: %v, samples differ: %t", bits, err, !bytes.Equal(decoded, pcm))
+		}
+
+		pcm[0] |= 1
+		if err := flac.EncodeBits(&buf, pcm, format, bits, saprobe.Metadata{}); err == nil {
+			t.Errorf("%d bits: a wider sample encodes", bits)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package flac
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"slices"
+
+	"github.com/farcloser/saprobe"
+)
+
+const crc16Size = 2
+
+// Frame describes a decoded frame.
+type Frame struct {
+	Size    int // bytes, header and footer included
+	Samples int // samples per channel
+}
+
+// Decoder decodes FLAC frames into interleaved LE signed PCM.
+type Decoder struct {
+	info     StreamInfo
+	format   saprobe.PCMFormat
+	shift    uint      // left alignment of samples narrower than their output container
+	channels [][]int64 // decoded samples of each channel of the current frame
+	md5      hash.Hash // running MD5 of the decoded audio, when checked
+	native   []byte    // the samples of the frame as the MD5 hashes them, when unlike the output
+}
+
+// NewDecoder creates a decoder for the frames of the stream STREAMINFO describes. Samples of 8 to
+// 16 bits decode to 16-bit PCM, 17 to 20 bits to 20-bit PCM, and so on, left-aligned: 12-bit
+// samples take the top 12 bits of their 16-bit container, as 20-bit samples take the top 20 of
+// their 24-bit one.
+func NewDecoder(info StreamInfo) (*Decoder, error) {
+	depth, err := outputDepth(info.BitsPerSample)
+	if err != nil {
+		return nil, err
+	}
+
+	channels := make([][]int64, info.Channels)
+	for ch := range channels {
+		channels[ch] = make([]int64, info.BlockSizeMax)
+	}
+
+	return &Decoder{
+		info: info,
+		format: saprobe.PCMFormat{
+			SampleRate: info.SampleRate,
+			BitDepth:   depth,
+			Channels:   uint(info.Channels), //nolint:gosec // STREAMINFO codes 1 to 8 channels.
+			Layout:     channelLayouts[info.Channels],
+		},
+		shift:    uint(8*depth.BytesPerSample() - info.BitsPerSample), //nolint:gosec // positive by construction.
+		channels: channels,
+	}, nil
+}
+
+// outputDepth returns the PCM bit depth samples of the given size decode to.
+func outputDepth(bitsPerSample int) (saprobe.BitDepth, error) {
+	for _, depth := range []saprobe.BitDepth{saprobe.Depth16, saprobe.Depth20, saprobe.Depth24, saprobe.Depth32} {
+		if bitsPerSample <= int(depth) {
+			return depth, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %d-bit", errBitDepth, bitsPerSample)
+}
+
+// Format returns the PCM output format.
+func (d *Decoder) Format() saprobe.PCMFormat {
+	return d.format
+}
+
+// AppendFrame decodes the frame at the start of data, checking its CRC-8 and CRC-16, and appends
+// its samples to dst. It returns io.ErrUnexpectedEOF when data ends inside the frame. On error dst
+// is returned unchanged. Once dst has room for the stream, decoding allocates nothing.
+func (d *Decoder) AppendFrame(dst, data []byte) ([]byte, Frame, error) {
+	frame, err := d.decodeFrame(data)
+	if err != nil {
+		return dst, Frame{}, err
+	}
+
+	start := len(dst)
+	dst = d.interleave(dst, frame.Samples)
+
+	if d.md5 != nil {
+		d.hash(dst[start:])
+	}
+
+	return dst, frame, nil
+}
+
+// DecodeFrameInt32 decodes the frame at the start of data, checking its CRC-8 and CRC-16, into
+// dst, one plane per channel. dst must hold Channels planes of BlockSizeMax samples. Samples are
+// right-aligned at the output bit depth: 12-bit samples are scaled to 16 bits, as in the
+// interleaved output, but 20-bit samples range over [-2^19, 2^19). Decoding allocates nothing.
+func (d *Decoder) DecodeFrameInt32(dst [][]int32, data []byte) (Frame, error) {
+	if len(dst) < len(d.channels) {
+		return Frame{}, fmt.Errorf("%w: %d planes, need %d", ErrShortBuffer, len(dst), len(d.channels))
+	}
+
+	frame, err := d.decodeFrame(data)
+	if err != nil {
+		return Frame{}, err
+	}
+
+	shift := uint(d.format.BitDepth) - uint(d.info.BitsPerSample) //nolint:gosec // positive by construction.
+
+	for ch, samples := range d.channels {
+		if len(dst[ch]) < frame.Samples {
+			return Frame{}, fmt.Errorf("%w: %d samples per plane, need %d", ErrShortBuffer, len(dst[ch]), frame.Samples)
+		}
+
+		plane := dst[ch][:frame.Samples]
+		for idx, sample := range samples[:frame.Samples] {
+			plane[idx] = int32(sample << shift) //nolint:gosec // fits the output bit depth.
+		}
+	}
+
+	return frame, nil
+}
+
+// decodeFrame decodes the frame at the start of data into the channel buffers, checking its CRC-8
+// and CRC-16.
+func (d *Decoder) decodeFrame(data []byte) (Frame, error) {
+	header, err := parseFrameHeader(data, &d.info)
+	if err != nil {
+		return Frame{}, err
+	}
+
+	if header.channels != d.info.Channels || header.bitsPerSample != d.info.BitsPerSample {
+		return Frame{}, fmt.Errorf("%w: %d channels of %d bits", errFrameFormat, header.channels, header.bitsPerSample)
+	}
+
+	br := newBitReader(data[header.size:])
+
+	for ch, samples := range d.channels {
+		if len(samples) < header.blockSize {
+			samples = make([]int64, header.blockSize)
+			d.channels[ch] = samples
+		}
+
+		bps := uint(header.bitsPerSample) //nolint:gosec // 4 to 32.
+		if isSide(header.assignment, ch) {
+			bps++
+		}
+
+		if err := decodeSubframe(&br, samples[:header.blockSize], bps); err != nil {
+			if br.overrun() {
+				err = io.ErrUnexpectedEOF
+			}
+
+			return Frame{}, err
+		}
+	}
+
+	br.alignByte()
+
+	size := header.size + br.offset()
+	stored := br.read(16)
+
+	if br.overrun() {
+		return Frame{}, io.ErrUnexpectedEOF
+	}
+
+	if computed := crc16(data[:size]); uint64(computed) != stored {
+		return Frame{}, fmt.Errorf("%w: stored 0x%04X, computed 0x%04X", errFrameCRC, stored, computed)
+	}
+
+	d.decorrelate(header.assignment, header.blockSize)
+
+	return Frame{Size: size + crc16Size, Samples: header.blockSize}, nil
+}
+
+// isSide tells whether channel ch carries the difference of a stereo pair, one bit wider than
+// the samples.
+func isSide(assignment, ch int) bool {
+	switch assignment {
+	case channelsLeftSide, channelsMidSide:
+		return ch == 1
+	case channelsSideRight:
+		return ch == 0
+	default:
+		return false
+	}
+}
+
+// decorrelate restores the left and right channels of a stereo pair.
+func (d *Decoder) decorrelate(assignment, blockSize int) {
+	if assignment < channelsLeftSide {
+		return
+	}
+
+	first, second := d.channels[0][:blockSize], d.channels[1][:blockSize]
+
+	switch assignment {
+	case channelsLeftSide:
+		for idx, side := range second {
+			second[idx] = first[idx] - side
+		}
+	case channelsSideRight:
+		for idx, right := range second {
+			first[idx] += right
+		}
+	case channelsMidSide:
+		for idx, side := range second {
+			mid := first[idx]<<1 | side&1
+			first[idx] = (mid + side) >> 1
+			second[idx] = (mid - side) >> 1
+		}
+	default:
+	}
+}
+
+// interleave appends the samples of the frame to dst as interleaved little-endian PCM.
+func (d *Decoder) interleave(dst []byte, blockSize int) []byte {
+	bytesPerSample := d.format.BitDepth.BytesPerSample()
+	stride := len(d.channels) * bytesPerSample
+	size := blockSize * stride
+
+	dst = slices.Grow(dst, size)
+	out := dst[len(dst) : len(dst)+size]
+	shift := d.shift
+
+	for ch, samples := range d.channels {
+		samples = samples[:blockSize]
+		pos := ch * bytesPerSample
+
+		switch bytesPerSample {
+		case 2:
+			for _, sample := range samples {
+				//nolint:gosec // truncation to the container.
+				binary.LittleEndian.PutUint16(out[pos:], uint16(sample<<shift))
+				pos += stride
+			}
+		case 3:
+			for _, sample := range samples {
+				sample <<= shift
+				out[pos] = byte(sample)
+				out[pos+1] = byte(sample >> 8)
+				out[pos+2] = byte(sample >> 16)
+				pos += stride
+			}
+		default:
+			for _, sample := range samples {
+				//nolint:gosec // truncation to the container.
+				binary.LittleEndian.PutUint32(out[pos:], uint32(sample<<shift))
+				pos += stride
+			}
+		}
+	}
+
+	return dst[:len(dst)+size]
+}
+
+// hash feeds one frame, as interleaved output, to the running MD5 the way the reference encoder
+// does: samples interleaved, little-endian, in (bps+7)/8 bytes. That is exactly the interleaved
+// output, unless the samples are left-aligned in a wider container.
+func (d *Decoder) hash(interleaved []byte) {
+	bytesPerSample := (d.info.BitsPerSample + 7) / 8
+	container := d.format.BitDepth.BytesPerSample()
+
+	if d.shift == 0 && bytesPerSample == container {
+		_, _ = d.md5.Write(interleaved)
+
+		return
+	}
+
+	d.native = d.native[:0]
+	top := 64 - 8*uint(container) //nolint:gosec // 2 to 4 bytes.
+
+	for pos := 0; pos < len(interleaved); pos += container {
+		var sample uint64
+		for b := range container {
+			sample |= uint64(interleaved[pos+b]) << (8 * b)
+		}
+
+		native := int64(sample<<top) >> (top + d.shift) //nolint:gosec // sign extension.
+		for b := range bytesPerSample {
+			d.native = append(d.native, byte(native>>(8*b)))
+		}
+	}
+
+	_, _ = d.md5.Write(d.native)
+}
+
+// hashSilence feeds silence standing for samples missing samples per channel to the running MD5.
+func (d *Decoder) hashSilence(samples uint64) {
+	if d.md5 == nil {
+		return
+	}
+
+	//nolint:gosec // bounded by the stream length.
+	size := int(samples) * len(d.channels) * ((d.info.BitsPerSample + 7) / 8)
+	_, _ = d.md5.Write(make([]byte, size))
+}
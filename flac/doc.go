@@ -1,2 +1,7 @@
 // Package flac decodes FLAC audio streams to raw interleaved PCM at native bit depth.
+//
+// Frames are decoded natively, straight into the interleaved output, with their CRC-8 and CRC-16
+// checked. Every sample size from 4 to 32 bits decodes: sizes without a PCM container of their own
+// (8 and 12 bits, say) are left-aligned in the next wider one. Metadata and encoding rely on
+// github.com/mewkiz/flac.
 package flac
@@ -0,0 +1,151 @@
+package flac
+
+import (
+	"crypto/md5" //nolint:gosec // FLAC mandates MD5 for the STREAMINFO audio signature.
+	"errors"
+	"fmt"
+	"io"
+
+	goflac "github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/internal/pcmio"
+)
+
+var (
+	errEncodeBitDepth = errors.New("encoding is only supported for 16, 20 and 24-bit audio")
+	errEncodeChannels = errors.New("encoding supports 1 to 8 channels")
+	errEncodeBits     = errors.New("samples do not fit the bits per sample")
+)
+
+const (
+	// encodeBlockSize is the number of samples per channel in each encoded frame.
+	encodeBlockSize = 4096
+	maxChannels     = 8
+	minBits         = 4
+
+	vendorString = "saprobe"
+)
+
+// writerOnly hides io.Seeker from the mewkiz encoder, which would otherwise rewrite
+// STREAMINFO on Close with a minimum block size that includes the final, shorter frame.
+type writerOnly struct {
+	io.Writer
+}
+
+// Encode writes pcm (interleaved little-endian signed, as produced by Decode) as a FLAC stream.
+// Tags are stored as Vorbis comments and pictures as PICTURE blocks.
+// STREAMINFO carries the exact sample count and the MD5 signature of the audio.
+func Encode(writer io.Writer, pcm []byte, format saprobe.PCMFormat, metadata saprobe.Metadata) error {
+	return EncodeBits(writer, pcm, format, int(format.BitDepth), metadata) //nolint:gosec // small.
+}
+
+// EncodeBits is Encode for samples of bitsPerSample bits, left-aligned in the PCM bit depth as
+// Decode outputs them: 8-bit samples in 16-bit PCM are stored as 8-bit samples again. The bits
+// below must be zero.
+func EncodeBits(
+	writer io.Writer, pcm []byte, format saprobe.PCMFormat, bitsPerSample int, metadata saprobe.Metadata,
+) error {
+	if format.BitDepth != saprobe.Depth16 && format.BitDepth != saprobe.Depth20 && format.BitDepth != saprobe.Depth24 {
+		return fmt.Errorf("%w: got %d-bit", errEncodeBitDepth, format.BitDepth)
+	}
+
+	if format.Channels == 0 || format.Channels > maxChannels {
+		return fmt.Errorf("%w: got %d", errEncodeChannels, format.Channels)
+	}
+
+	if bitsPerSample < minBits || bitsPerSample > int(format.BitDepth) || //nolint:gosec // small.
+		!pcmio.FitsBits(pcm, format.BitDepth.BytesPerSample(), bitsPerSample) {
+		return fmt.Errorf("%w: %d bits in %d-bit PCM", errEncodeBits, bitsPerSample, format.BitDepth)
+	}
+
+	nChannels := int(format.Channels) //nolint:gosec // bounded above.
+	bytesPerSample := format.BitDepth.BytesPerSample()
+	totalSamples := len(pcm) / (nChannels * bytesPerSample)
+
+	blockSize := uint16(min(encodeBlockSize, max(totalSamples, 16))) //nolint:gosec // bounded by encodeBlockSize.
+
+	info := &meta.StreamInfo{
+		BlockSizeMin:  blockSize,
+		BlockSizeMax:  blockSize,
+		SampleRate:    uint32(format.SampleRate), //nolint:gosec // sample rates are small.
+		NChannels:     uint8(nChannels),          //nolint:gosec // bounded above.
+		BitsPerSample: uint8(bitsPerSample),      //nolint:gosec // bounded above.
+		NSamples:      uint64(totalSamples),      //nolint:gosec // non-negative.
+	}
+
+	frames := splitFrames(pcm, format, bitsPerSample, totalSamples)
+
+	// STREAMINFO precedes the audio, so the MD5 signature is computed up front.
+	sum := md5.New() //nolint:gosec // see import.
+	for _, audioFrame := range frames {
+		audioFrame.Hash(sum)
+	}
+
+	copy(info.MD5sum[:], sum.Sum(nil))
+
+	enc, err := goflac.NewEncoder(writerOnly{writer}, info, metadataBlocks(metadata)...)
+	if err != nil {
+		return fmt.Errorf("creating flac encoder: %w", err)
+	}
+
+	for idx, audioFrame := range frames {
+		if err := enc.WriteFrame(audioFrame); err != nil {
+			return fmt.Errorf("encoding frame %d: %w", idx, err)
+		}
+	}
+
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("closing flac encoder: %w", err)
+	}
+
+	return nil
+}
+
+// splitFrames de-interleaves pcm into fixed-size FLAC frames with verbatim subframes.
+// The encoder's prediction analysis later picks constant/fixed predictors where smaller.
+// Samples of fewer bits, such as 20-bit ones, are stored left-aligned and are shifted back down.
+func splitFrames(pcm []byte, format saprobe.PCMFormat, bitsPerSample, totalSamples int) []*frame.Frame {
+	nChannels := int(format.Channels) //nolint:gosec // channel count is small.
+	bytesPerSample := format.BitDepth.BytesPerSample()
+	shift := uint(8*bytesPerSample - bitsPerSample) //nolint:gosec // positive, checked by EncodeBits.
+	frames := make([]*frame.Frame, 0, (totalSamples+encodeBlockSize-1)/encodeBlockSize)
+
+	for start := 0; start < totalSamples; start += encodeBlockSize {
+		blockSize := min(encodeBlockSize, totalSamples-start)
+		subframes := make([]*frame.Subframe, nChannels)
+
+		for ch := range subframes {
+			subframes[ch] = &frame.Subframe{
+				SubHeader: frame.SubHeader{Pred: frame.PredVerbatim},
+				Samples:   make([]int32, blockSize),
+				NSamples:  blockSize,
+			}
+		}
+
+		pos := start * nChannels * bytesPerSample
+
+		for i := range blockSize {
+			for ch := range nChannels {
+				//nolint:gosec // fits the bit depth.
+				subframes[ch].Samples[i] = int32(pcmio.ReadSample(pcm[pos:], bytesPerSample) >> shift)
+				pos += bytesPerSample
+			}
+		}
+
+		frames = append(frames, &frame.Frame{
+			Header: frame.Header{
+				HasFixedBlockSize: true,
+				BlockSize:         uint16(blockSize),         //nolint:gosec // bounded by encodeBlockSize.
+				SampleRate:        uint32(format.SampleRate), //nolint:gosec // sample rates are small.
+				Channels:          frame.Channels(nChannels - 1),
+				BitsPerSample:     uint8(bitsPerSample), //nolint:gosec // checked by EncodeBits.
+			},
+			Subframes: subframes,
+		})
+	}
+
+	return frames
+}
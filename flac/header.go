@@ -0,0 +1,313 @@
+package flac
+
+import (
+	"crypto/md5" //nolint:gosec // FLAC STREAMINFO stores an MD5 of the audio; not used for security.
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+
+	"github.com/farcloser/saprobe"
+)
+
+const (
+	streamInfoSize = 34 // STREAMINFO block body
+	frameSync      = 0xFFF8 >> 2
+	frameSyncBits  = 14
+
+	blockSizeUncommon8  = 6 // block size code for an 8-bit block size - 1 after the coded number
+	blockSizeUncommon16 = 7 // block size code for a 16-bit block size - 1
+	sampleRateKHz       = 12
+	sampleRateHz        = 13
+	sampleRateTensHz    = 14
+	sampleRateInvalid   = 15
+	sampleSizeReserved  = 3
+
+	maxCodedNumberBytes = 7
+)
+
+// Channel assignments beyond the independent ones, coded as the channel count - 1.
+const (
+	channelsLeftSide  = 8
+	channelsSideRight = 9
+	channelsMidSide   = 10
+)
+
+// channelLayouts holds the speaker assignments the format defines for 1-8 channels. They follow
+// the WAVE defaults but for 7 channels, whose surrounds are back center and sides.
+//
+//nolint:gochecknoglobals // constant table
+var channelLayouts = [...]saprobe.ChannelLayout{
+	1: saprobe.LayoutMono,
+	2: saprobe.LayoutStereo,
+	3: saprobe.DefaultLayout(3),
+	4: saprobe.DefaultLayout(4),
+	5: saprobe.DefaultLayout(5),
+	6: saprobe.DefaultLayout(6),
+	7: {
+		saprobe.SpeakerFrontLeft, saprobe.SpeakerFrontRight, saprobe.SpeakerFrontCenter, saprobe.SpeakerLowFrequency,
+		saprobe.SpeakerBackCenter, saprobe.SpeakerSideLeft, saprobe.SpeakerSideRight,
+	},
+	8: saprobe.DefaultLayout(8),
+}
+
+// Layout returns the speakers of the channels of a FLAC stream, in the order Decode returns and
+// Encode takes them, or the unknown layout beyond 8 channels.
+func Layout(channels uint) saprobe.ChannelLayout {
+	if channels >= uint(len(channelLayouts)) {
+		return saprobe.ChannelLayout{}
+	}
+
+	return channelLayouts[channels]
+}
+
+var (
+	errMarker        = errors.New("flac: missing fLaC stream marker")
+	errStreamInfo    = errors.New("flac: first metadata block is not STREAMINFO")
+	errFrameSync     = errors.New("flac: missing frame sync code")
+	errFrameReserved = errors.New("flac: reserved value in frame header")
+	errCodedNumber   = errors.New("flac: invalid coded frame number")
+	errHeaderCRC     = fmt.Errorf("%w: header CRC-8", ErrFrameCRC)
+	errFrameCRC      = fmt.Errorf("%w: CRC-16", ErrFrameCRC)
+	errFrameFormat   = errors.New("flac: frame channels or sample size differ from STREAMINFO")
+)
+
+//nolint:gochecknoglobals // constant tables
+var (
+	// frameSampleRates maps the sample rate codes 1 to 11 of a frame header to their rate in Hz.
+	frameSampleRates = [...]int{0, 88200, 176400, 192000, 8000, 16000, 22050, 24000, 32000, 44100, 48000, 96000}
+
+	// frameSampleSizes maps the sample size codes of a frame header to bits per sample.
+	frameSampleSizes = [...]int{0, 8, 12, 0, 16, 20, 24, 32}
+)
+
+// StreamInfo is the STREAMINFO metadata block: the properties every frame of the stream shares.
+type StreamInfo struct {
+	BlockSizeMin  int // samples per channel
+	BlockSizeMax  int
+	FrameSizeMin  int // bytes; 0 when unknown
+	FrameSizeMax  int
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+	Samples       uint64 // samples per channel; 0 when unknown
+	MD5           [md5.Size]byte
+}
+
+// streamStart returns the offset of the stream marker: past the ID3v2 tag some taggers put before
+// it, or 0.
+func streamStart(rs io.ReadSeeker) (int64, error) {
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("seeking to start: %w", err)
+	}
+
+	var header [id3v2HeaderSize]byte
+	if _, err := io.ReadFull(rs, header[:]); err != nil || string(header[:3]) != "ID3" {
+		return 0, nil //nolint:nilerr // too short for a tag, readStreamInfo reports it.
+	}
+
+	size := int64(header[6]&0x7F)<<21 | int64(header[7]&0x7F)<<14 | int64(header[8]&0x7F)<<7 | int64(header[9]&0x7F)
+
+	start := id3v2HeaderSize + size
+	if header[5]&id3v2FooterFlag != 0 {
+		start += id3v2HeaderSize
+	}
+
+	return start, nil
+}
+
+// readStreamInfo reads the stream marker and the STREAMINFO block at the start of rs, past any
+// ID3v2 tag.
+func readStreamInfo(rs io.ReadSeeker) (StreamInfo, error) {
+	start, err := streamStart(rs)
+	if err != nil {
+		return StreamInfo{}, err
+	}
+
+	if _, err := rs.Seek(start, io.SeekStart); err != nil {
+		return StreamInfo{}, fmt.Errorf("seeking to stream marker: %w", err)
+	}
+
+	var head [streamMarkerSize + blockHeaderSize + streamInfoSize]byte
+	if _, err := io.ReadFull(rs, head[:]); err != nil {
+		return StreamInfo{}, fmt.Errorf("reading STREAMINFO: %w", err)
+	}
+
+	if string(head[:streamMarkerSize]) != "fLaC" {
+		return StreamInfo{}, errMarker
+	}
+
+	block := head[streamMarkerSize:]
+	if block[0]&0x7F != 0 || binary.BigEndian.Uint32(block)&0xFFFFFF < streamInfoSize {
+		return StreamInfo{}, errStreamInfo
+	}
+
+	body := block[blockHeaderSize:]
+	packed := binary.BigEndian.Uint64(body[10:18])
+
+	info := StreamInfo{
+		BlockSizeMin:  int(binary.BigEndian.Uint16(body[0:2])),
+		BlockSizeMax:  int(binary.BigEndian.Uint16(body[2:4])),
+		FrameSizeMin:  int(binary.BigEndian.Uint32(body[3:7]) & 0xFFFFFF),
+		FrameSizeMax:  int(binary.BigEndian.Uint32(body[6:10]) & 0xFFFFFF),
+		SampleRate:    int(packed >> 44),
+		Channels:      int(packed>>41&0x7) + 1,
+		BitsPerSample: int(packed>>36&0x1F) + 1,
+		Samples:       packed & 0xFFFFFFFFF,
+		MD5:           [md5.Size]byte(body[18:]),
+	}
+
+	return info, nil
+}
+
+// frameHeader is the header of an audio frame, with the values it defers to STREAMINFO filled in.
+type frameHeader struct {
+	variable      bool   // variable block size: number is the first sample, not the frame number
+	number        uint64 // frame number, or first sample number
+	blockSize     int
+	sampleRate    int
+	channels      int
+	assignment    int // channel assignment: channels - 1 for independent channels, or a stereo pairing
+	bitsPerSample int
+	size          int // header length in bytes, CRC-8 included
+}
+
+// parseFrameHeader parses the frame header at the start of data and checks its CRC-8. Values the
+// header defers to STREAMINFO are taken from info.
+func parseFrameHeader(data []byte, info *StreamInfo) (frameHeader, error) {
+	br := newBitReader(data)
+
+	if br.read(frameSyncBits) != frameSync {
+		return frameHeader{}, errFrameSync
+	}
+
+	if br.read(1) != 0 {
+		return frameHeader{}, errFrameReserved
+	}
+
+	header := frameHeader{variable: br.read(1) == 1}
+
+	blockSizeCode := br.read(4)
+	sampleRateCode := br.read(4)
+	header.assignment = int(br.read(4))
+	sampleSizeCode := br.read(3)
+
+	if br.read(1) != 0 || blockSizeCode == 0 || sampleRateCode == sampleRateInvalid ||
+		header.assignment > channelsMidSide || sampleSizeCode == sampleSizeReserved {
+		return frameHeader{}, errFrameReserved
+	}
+
+	number, err := readCodedNumber(&br)
+	if err != nil {
+		return frameHeader{}, err
+	}
+
+	header.number = number
+
+	switch {
+	case blockSizeCode == 1:
+		header.blockSize = 192
+	case blockSizeCode <= 5:
+		header.blockSize = 576 << (blockSizeCode - 2)
+	case blockSizeCode == blockSizeUncommon8:
+		header.blockSize = int(br.read(8)) + 1
+	case blockSizeCode == blockSizeUncommon16:
+		header.blockSize = int(br.read(16)) + 1
+	default:
+		header.blockSize = 256 << (blockSizeCode - 8)
+	}
+
+	switch sampleRateCode {
+	case 0:
+		header.sampleRate = info.SampleRate
+	case sampleRateKHz:
+		header.sampleRate = int(br.read(8)) * 1000
+	case sampleRateHz:
+		header.sampleRate = int(br.read(16))
+	case sampleRateTensHz:
+		header.sampleRate = int(br.read(16)) * 10
+	default:
+		header.sampleRate = frameSampleRates[sampleRateCode]
+	}
+
+	header.channels = header.assignment + 1
+	if header.assignment >= channelsLeftSide {
+		header.channels = 2
+	}
+
+	header.bitsPerSample = frameSampleSizes[sampleSizeCode]
+	if sampleSizeCode == 0 {
+		header.bitsPerSample = info.BitsPerSample
+	}
+
+	header.size = br.offset() + 1
+
+	stored := br.read(8)
+	if br.overrun() {
+		return frameHeader{}, io.ErrUnexpectedEOF
+	}
+
+	if computed := crc8(data[:header.size-1]); uint64(computed) != stored {
+		return frameHeader{}, fmt.Errorf("%w: stored 0x%02X, computed 0x%02X", errHeaderCRC, stored, computed)
+	}
+
+	return header, nil
+}
+
+// readCodedNumber reads the frame or sample number of a frame header, coded like UTF-8 on up to
+// seven bytes.
+func readCodedNumber(br *bitReader) (uint64, error) {
+	first := br.read(8)
+	length := bits.LeadingZeros8(^uint8(first))
+
+	switch {
+	case length == 0:
+		return first, nil
+	case length == 1 || length > maxCodedNumberBytes: // a lone continuation byte, or 0xFF
+		return 0, errCodedNumber
+	}
+
+	number := first & (0xFF >> (length + 1))
+
+	for range length - 1 {
+		next := br.read(8)
+		if next&0xC0 != 0x80 {
+			return 0, errCodedNumber
+		}
+
+		number = number<<6 | next&0x3F
+	}
+
+	return number, nil
+}
+
+// matches tells whether the header agrees with STREAMINFO. Resynchronization relies on it to
+// reject sync code lookalikes.
+func (h *frameHeader) matches(info *StreamInfo) bool {
+	return h.channels == info.Channels && h.bitsPerSample == info.BitsPerSample &&
+		h.sampleRate == info.SampleRate && h.blockSize <= info.BlockSizeMax
+}
+
+// firstSample returns the position of the first sample of the frame. Fixed-blocksize streams
+// number frames rather than samples, and only the last frame may be shorter than the maximum.
+func (h *frameHeader) firstSample(info *StreamInfo) uint64 {
+	if !h.variable {
+		return h.number * uint64(info.BlockSizeMax) //nolint:gosec // block sizes are 16-bit.
+	}
+
+	return h.number
+}
+
+// follows tells whether the header numbers the frame right after the one prev heads.
+func (h *frameHeader) follows(prev *frameHeader) bool {
+	if h.variable != prev.variable {
+		return false
+	}
+
+	if !h.variable {
+		return h.number == prev.number+1
+	}
+
+	return h.number == prev.number+uint64(prev.blockSize) //nolint:gosec // block sizes are 16-bit.
+}
@@ -0,0 +1,113 @@
+package flac
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	goflac "github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/meta"
+
+	"github.com/farcloser/saprobe"
+)
+
+// ReadMetadata returns the Vorbis comments and embedded pictures of a FLAC stream. When a metadata
+// block is malformed, the tags and pictures of the blocks before it are returned with the error.
+func ReadMetadata(rs io.ReadSeeker) (saprobe.Metadata, error) {
+	var metadata saprobe.Metadata
+
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return metadata, fmt.Errorf("seeking to start: %w", err)
+	}
+
+	stream, err := goflac.Parse(rs)
+	if err != nil {
+		err = fmt.Errorf("parsing flac metadata: %w", err)
+	}
+
+	if stream == nil {
+		return metadata, err
+	}
+
+	for _, block := range stream.Blocks {
+		switch body := block.Body.(type) {
+		case *meta.VorbisComment:
+			for _, tag := range body.Tags {
+				metadata.Add(tag[0], tag[1])
+			}
+		case *meta.Picture:
+			metadata.Pictures = append(metadata.Pictures, saprobe.Picture{
+				Type:        body.Type,
+				MIME:        body.MIME,
+				Description: body.Desc,
+				Data:        body.Data,
+			})
+		}
+	}
+
+	return metadata, err
+}
+
+// ReadFormat returns the format of the PCM Decode outputs and the samples per channel STREAMINFO
+// declares, 0 when it does not. Only STREAMINFO is read.
+func ReadFormat(rs io.ReadSeeker) (saprobe.PCMFormat, int64, error) {
+	info, err := readStreamInfo(rs)
+	if err != nil {
+		return saprobe.PCMFormat{}, 0, fmt.Errorf("opening flac: %w", err)
+	}
+
+	dec, err := NewDecoder(info)
+	if err != nil {
+		return saprobe.PCMFormat{}, 0, err
+	}
+
+	return dec.Format(), int64(info.Samples), nil //nolint:gosec // 36-bit field.
+}
+
+// ReadBitsPerSample returns the bits per sample STREAMINFO declares. Decode left-aligns samples of
+// fewer bits than its PCM bit depth, 8 or 12 say, in 16-bit PCM. Only STREAMINFO is read.
+func ReadBitsPerSample(rs io.ReadSeeker) (int, error) {
+	info, err := readStreamInfo(rs)
+	if err != nil {
+		return 0, fmt.Errorf("opening flac: %w", err)
+	}
+
+	return info.BitsPerSample, nil
+}
+
+// metadataBlocks converts saprobe metadata into FLAC VORBIS_COMMENT and PICTURE blocks.
+func metadataBlocks(metadata saprobe.Metadata) []*meta.Block {
+	var blocks []*meta.Block
+
+	if len(metadata.Tags) > 0 {
+		comment := &meta.VorbisComment{Vendor: vendorString}
+		length := 4 + len(comment.Vendor) + 4
+
+		for _, tag := range metadata.Tags {
+			comment.Tags = append(comment.Tags, [2]string{strings.ToUpper(tag.Key), tag.Value})
+			length += 4 + len(tag.Key) + 1 + len(tag.Value)
+		}
+
+		blocks = append(blocks, &meta.Block{
+			Header: meta.Header{Type: meta.TypeVorbisComment, Length: int64(length)},
+			Body:   comment,
+		})
+	}
+
+	for _, pic := range metadata.Pictures {
+		// Type, MIME length, description length, width, height, depth, colors, data length: 8 x 32 bits.
+		length := 8*4 + len(pic.MIME) + len(pic.Description) + len(pic.Data)
+
+		blocks = append(blocks, &meta.Block{
+			Header: meta.Header{Type: meta.TypePicture, Length: int64(length)},
+			Body: &meta.Picture{
+				Type: pic.Type,
+				MIME: pic.MIME,
+				Desc: pic.Description,
+				Data: pic.Data,
+			},
+		})
+	}
+
+	return blocks
+}
@@ -0,0 +1,70 @@
+package flac_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/flac"
+	"github.com/farcloser/saprobe/tests/testutils"
+)
+
+// TestReadMetadataDamaged checks that the tags read before a malformed PICTURE block are returned
+// with the error.
+func TestReadMetadataDamaged(t *testing.T) {
+	t.Parallel()
+
+	format := saprobe.PCMFormat{SampleRate: 44100, BitDepth: saprobe.Depth16, Channels: 2}
+	metadata := saprobe.Metadata{
+		Tags:     []saprobe.Tag{{Key: "TITLE", Value: "Saprobe"}, {Key: "ARTIST", Value: "Mycota"}},
+		Pictures: []saprobe.Picture{{Type: 3, MIME: "image/png", Data: []byte("not really a png")}},
+	}
+
+	var buf bytes.Buffer
+	if err := flac.Encode(&buf, testutils.Noise(format, 1000, -6), format, metadata); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+
+	got, err := flac.ReadMetadata(bytes.NewReader(data))
+	if err != nil || len(got.Tags) != 2 || len(got.Pictures) != 1 {
+		t.Fatalf("read %d tags and %d pictures (%v), want 2 and 1", len(got.Tags), len(got.Pictures), err)
+	}
+
+	// Make the MIME type of the picture run past the end of its block.
+	mime := bytes.Index(data, []byte("image/png"))
+	binary.BigEndian.PutUint32(data[mime-4:], 1000)
+
+	got, err = flac.ReadMetadata(bytes.NewReader(data))
+	if err == nil {
+		t.Error("a malformed PICTURE block is not reported")
+	}
+
+	if title, _ := got.Get("TITLE"); title != "Saprobe" || len(got.Tags) != 2 || len(got.Pictures) != 0 {
+		t.Errorf("read %v, want the two tags and no picture", got)
+	}
+}
+
+// TestReadFormat checks that the format and sample count read from STREAMINFO match the decode.
+func TestReadFormat(t *testing.T) {
+	t.Parallel()
+
+	format := saprobe.PCMFormat{SampleRate: 48000, BitDepth: saprobe.Depth24, Channels: 2}
+	data := encode(t, testutils.Noise(format, 30000, -6), format)
+
+	_, decoded, err := flac.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read, samples, err := flac.ReadFormat(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if read != decoded || samples != 30000 {
+		t.Errorf("read %+v, %d samples, want %+v, 30000", read, samples, decoded)
+	}
+}
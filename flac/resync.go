@@ -0,0 +1,204 @@
+package flac
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+const (
+	streamMarkerSize   = 4  // "fLaC"
+	id3v2HeaderSize    = 10 // "ID3", version (2), flags (1), syncsafe size (4)
+	id3v2FooterFlag    = 0x10
+	blockHeaderSize    = 4  // last flag (1 bit), type (7 bits), length (24 bits)
+	maxFrameHeaderSize = 16 // sync (2) + fields (2) + UTF-8 number (<=7) + block size (2) + rate (2) + CRC-8 (1)
+	syncByte           = 0xFF
+	syncMask           = 0xFE // second sync byte: 1111100x, the low bit being the blocking strategy
+	syncLow            = 0xF8
+)
+
+var errNoAudio = errors.New("flac: metadata blocks extend past the end of the stream")
+
+// audioOffset returns the byte offset of the first audio frame, right after the last
+// metadata block.
+func audioOffset(rs io.ReadSeeker) (int64, error) {
+	start, err := streamStart(rs)
+	if err != nil {
+		return 0, err
+	}
+
+	offset := start + streamMarkerSize
+
+	if _, err := rs.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("seeking past stream marker: %w", err)
+	}
+
+	var header [blockHeaderSize]byte
+
+	for {
+		if _, err := io.ReadFull(rs, header[:]); err != nil {
+			return 0, fmt.Errorf("%w: %w", errNoAudio, err)
+		}
+
+		length := int64(binary.BigEndian.Uint32(header[:]) & 0xFFFFFF)
+		offset += blockHeaderSize + length
+
+		if header[0]&0x80 != 0 {
+			return offset, nil
+		}
+
+		if _, err := rs.Seek(length, io.SeekCurrent); err != nil {
+			return 0, fmt.Errorf("skipping metadata block: %w", err)
+		}
+	}
+}
+
+// readAhead is the least amount of the stream frameReader reads at once.
+const readAhead = 1 << 20
+
+// frameReader buffers the audio frames of a stream, so that the decoder sees whole frames, and
+// tracks the byte offset, so that decoding can resynchronize after a damaged frame.
+type frameReader struct {
+	reader io.Reader
+	buf    []byte // buffered stream bytes, from buf[pos] at offset on
+	pos    int
+	offset int64
+	bound  int   // the most a frame of the stream is expected to take
+	eof    bool  // the stream is fully buffered
+	err    error // the read error that ended the stream, if not io.EOF
+}
+
+func newFrameReader(rs io.ReadSeeker, offset int64, info *StreamInfo) (*frameReader, error) {
+	if _, err := rs.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seeking to first frame: %w", err)
+	}
+
+	// STREAMINFO may not know the largest frame; a verbatim one bounds any sane encoding.
+	bound := info.FrameSizeMax
+	if bound == 0 {
+		verbatim := info.BlockSizeMax * info.Channels * (info.BitsPerSample + 1) / 8
+		bound = maxFrameHeaderSize + verbatim + info.Channels + crc16Size
+	}
+
+	return &frameReader{reader: rs, offset: offset, bound: bound}, nil
+}
+
+// window returns the buffered stream from the read position on, holding at least size bytes
+// unless the stream ends first.
+func (r *frameReader) window(size int) []byte {
+	for len(r.buf)-r.pos < size && !r.eof {
+		// Move the unread bytes to the front, and make room for size bytes, or the read-ahead.
+		kept := copy(r.buf, r.buf[r.pos:])
+		r.buf, r.pos = r.buf[:kept], 0
+
+		if room := max(size, readAhead); cap(r.buf) < room {
+			r.buf = slices.Grow(r.buf, room-kept)
+		}
+
+		n, err := r.reader.Read(r.buf[kept:cap(r.buf)])
+		r.buf = r.buf[:kept+n]
+
+		if err != nil {
+			r.eof = true
+
+			if !errors.Is(err, io.EOF) {
+				r.err = err
+			}
+		}
+	}
+
+	return r.buf[r.pos:]
+}
+
+// advance moves the read position n bytes forward.
+func (r *frameReader) advance(n int) {
+	r.pos += n
+	r.offset += int64(n)
+}
+
+// next decodes the frame at the read position, appending its samples to dst, and moves past it.
+// It returns io.EOF at the end of the stream, and io.ErrUnexpectedEOF when the stream ends inside
+// the frame.
+func (r *frameReader) next(dec *Decoder, dst []byte) ([]byte, Frame, error) {
+	frame, err := r.decode(func(data []byte) (Frame, error) {
+		var (
+			frame Frame
+			err   error
+		)
+
+		dst, frame, err = dec.AppendFrame(dst, data)
+
+		return frame, err
+	})
+
+	return dst, frame, err
+}
+
+// decode runs decodeFrame on the buffered stream from the read position on, until the window
+// holds the whole frame, and moves past the frame it decoded. It returns io.EOF at the end of the
+// stream, and io.ErrUnexpectedEOF when the stream ends inside the frame.
+func (r *frameReader) decode(decodeFrame func(data []byte) (Frame, error)) (Frame, error) {
+	size := r.bound
+
+	for {
+		data := r.window(size)
+		if r.err != nil {
+			return Frame{}, fmt.Errorf("reading frame: %w", r.err)
+		}
+
+		if len(data) == 0 {
+			return Frame{}, io.EOF
+		}
+
+		frame, err := decodeFrame(data)
+
+		// The frame runs past the buffered stream: buffer more, and decode it again.
+		if errors.Is(err, io.ErrUnexpectedEOF) && !r.eof {
+			size = 2 * len(data)
+
+			continue
+		}
+
+		if err == nil {
+			r.advance(frame.Size)
+		}
+
+		return frame, err
+	}
+}
+
+// resync moves the read position past the damaged frame it is at, to the next frame whose header
+// parses with a valid CRC-8 and matches the stream parameters, and returns that header. It
+// returns false when no such frame remains.
+func (r *frameReader) resync(info *StreamInfo) (frameHeader, bool) {
+	r.advance(1)
+
+	for {
+		data := r.window(maxFrameHeaderSize)
+		if len(data) < 2 {
+			return frameHeader{}, false
+		}
+
+		found := bytes.IndexByte(data[:len(data)-1], syncByte)
+		if found < 0 {
+			r.advance(len(data) - 1)
+
+			continue
+		}
+
+		r.advance(found)
+		data = r.window(maxFrameHeaderSize)
+
+		if data[1]&syncMask == syncLow {
+			header, err := parseFrameHeader(data, info)
+			if err == nil && header.matches(info) {
+				return header, true
+			}
+		}
+
+		r.advance(1)
+	}
+}
@@ -0,0 +1,218 @@
+package flac
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Subframe types, from the 6-bit type code of the subframe header.
+const (
+	subframeConstant = 0
+	subframeVerbatim = 1
+	subframeFixed    = 8  // 001xxx: fixed predictor of order xxx, up to 4
+	subframeLPC      = 32 // 1xxxxx: linear predictor of order xxxxx+1
+
+	maxFixedOrder      = 4
+	maxLPCOrder        = 32
+	lpcPrecisionBits   = 4
+	lpcPrecisionEscape = 15
+	lpcShiftBits       = 5
+	riceParamBits      = 4
+	rice2ParamBits     = 5
+	partitionOrderBits = 4
+	escapeSizeBits     = 5
+)
+
+// Residual coding methods.
+const (
+	residualRice  = 0
+	residualRice2 = 1
+)
+
+var (
+	errSubframePadding = errors.New("flac: non-zero subframe padding")
+	errSubframeType    = errors.New("flac: reserved subframe type")
+	errWastedBits      = errors.New("flac: wasted bits exceed the sample size")
+	errLPCPrecision    = errors.New("flac: invalid LPC coefficient precision")
+	errLPCShift        = errors.New("flac: negative LPC shift")
+	errResidualCoding  = errors.New("flac: reserved residual coding method")
+	errPartitionOrder  = errors.New("flac: partition order does not fit the block size")
+)
+
+// decodeSubframe decodes one channel of a frame, coded with bps bits per sample, into samples,
+// which holds the block.
+func decodeSubframe(br *bitReader, samples []int64, bps uint) error {
+	if br.read(1) != 0 {
+		return errSubframePadding
+	}
+
+	kind := br.read(6)
+
+	var wasted uint
+	if br.read(1) == 1 {
+		wasted = uint(br.readUnary()) + 1
+	}
+
+	if wasted >= bps {
+		return fmt.Errorf("%w: %d of %d bits", errWastedBits, wasted, bps)
+	}
+
+	bps -= wasted
+
+	switch {
+	case kind == subframeConstant:
+		value := br.readSigned(bps)
+		for idx := range samples {
+			samples[idx] = value
+		}
+	case kind == subframeVerbatim:
+		for idx := range samples {
+			samples[idx] = br.readSigned(bps)
+		}
+	case kind >= subframeFixed && kind <= subframeFixed+maxFixedOrder:
+		if err := decodeFixed(br, samples, int(kind-subframeFixed), bps); err != nil {
+			return err
+		}
+	case kind >= subframeLPC:
+		if err := decodeLPC(br, samples, int(kind-subframeLPC)+1, bps); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: %06b", errSubframeType, kind)
+	}
+
+	if wasted > 0 {
+		for idx := range samples {
+			samples[idx] <<= wasted
+		}
+	}
+
+	return nil
+}
+
+// decodeFixed decodes a subframe coded with one of the fixed polynomial predictors.
+func decodeFixed(br *bitReader, samples []int64, order int, bps uint) error {
+	if order > len(samples) {
+		return errPartitionOrder
+	}
+
+	for idx := range order {
+		samples[idx] = br.readSigned(bps)
+	}
+
+	if err := decodeResidual(br, samples, order); err != nil {
+		return err
+	}
+
+	switch order {
+	case 1:
+		for idx := 1; idx < len(samples); idx++ {
+			samples[idx] += samples[idx-1]
+		}
+	case 2:
+		for idx := 2; idx < len(samples); idx++ {
+			samples[idx] += 2*samples[idx-1] - samples[idx-2]
+		}
+	case 3:
+		for idx := 3; idx < len(samples); idx++ {
+			samples[idx] += 3*(samples[idx-1]-samples[idx-2]) + samples[idx-3]
+		}
+	case 4:
+		for idx := 4; idx < len(samples); idx++ {
+			samples[idx] += 4*(samples[idx-1]+samples[idx-3]) - 6*samples[idx-2] - samples[idx-4]
+		}
+	default:
+	}
+
+	return nil
+}
+
+// decodeLPC decodes a subframe coded with a linear predictor whose quantized coefficients the
+// subframe carries.
+func decodeLPC(br *bitReader, samples []int64, order int, bps uint) error {
+	if order > len(samples) {
+		return errPartitionOrder
+	}
+
+	for idx := range order {
+		samples[idx] = br.readSigned(bps)
+	}
+
+	precision := br.read(lpcPrecisionBits)
+	if precision == lpcPrecisionEscape {
+		return errLPCPrecision
+	}
+
+	shift := br.readSigned(lpcShiftBits)
+	if shift < 0 {
+		return fmt.Errorf("%w: %d", errLPCShift, shift)
+	}
+
+	// Coefficients in reverse order, oldest sample first, to run over the history in order.
+	var coefs [maxLPCOrder]int64
+
+	history := coefs[maxLPCOrder-order:]
+	for idx := range order {
+		history[order-1-idx] = br.readSigned(uint(precision) + 1)
+	}
+
+	if err := decodeResidual(br, samples, order); err != nil {
+		return err
+	}
+
+	for idx := order; idx < len(samples); idx++ {
+		window := samples[idx-order : idx]
+
+		var sum int64
+		for k, coef := range history[:len(window)] {
+			sum += coef * window[k]
+		}
+
+		samples[idx] += sum >> shift
+	}
+
+	return nil
+}
+
+// decodeResidual decodes the Rice coded residual of a predicted subframe into samples, after the
+// order warm-up samples.
+func decodeResidual(br *bitReader, samples []int64, order int) error {
+	paramBits := uint(riceParamBits)
+
+	switch br.read(2) {
+	case residualRice:
+	case residualRice2:
+		paramBits = rice2ParamBits
+	default:
+		return errResidualCoding
+	}
+
+	escape := uint64(1)<<paramBits - 1
+	partitionOrder := br.read(partitionOrderBits)
+	partitionSize := len(samples) >> partitionOrder
+
+	if partitionSize<<partitionOrder != len(samples) || partitionSize < order {
+		return fmt.Errorf("%w: order %d, %d samples", errPartitionOrder, partitionOrder, len(samples))
+	}
+
+	pos := order
+
+	for end := partitionSize; end <= len(samples); end += partitionSize {
+		param := br.read(paramBits)
+
+		if param == escape {
+			size := uint(br.read(escapeSizeBits))
+			for ; pos < end; pos++ {
+				samples[pos] = br.readSigned(size)
+			}
+
+			continue
+		}
+
+		for ; pos < end; pos++ {
+			samples[pos] = br.readRice(uint(param))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package flac
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/farcloser/saprobe"
+)
+
+// Verify decodes the whole stream without keeping the PCM, checking frame CRC-8/CRC-16
+// checksums, the STREAMINFO sample count and MD5. Integrity failures are returned as findings; the error
+// is reserved for streams that cannot be read at all.
+func Verify(rs io.ReadSeeker) (saprobe.Verification, error) {
+	return VerifyWithOptions(rs, saprobe.Options{})
+}
+
+// VerifyWithOptions is Verify decoding opts.Jobs frames concurrently. The other options do not
+// apply: verification is strict and never conceals.
+func VerifyWithOptions(rs io.ReadSeeker, opts saprobe.Options) (saprobe.Verification, error) {
+	var verification saprobe.Verification
+
+	tracked := saprobe.TrackReader(rs)
+	strict := Options{Options: saprobe.Options{Jobs: opts.Jobs}, VerifyMD5: true}
+	_, _, report, err := decodeStream(tracked, strict, false)
+	err = wrapError(tracked, err)
+
+	var decodeErr *saprobe.DecodeError
+
+	switch {
+	case errors.As(err, &decodeErr) && decodeErr.Frame >= 0 && errors.Is(decodeErr.Kind, saprobe.ErrCorrupt):
+		check := saprobe.CheckBitstream
+		if errors.Is(err, ErrFrameCRC) {
+			check = saprobe.CheckFrameCRC
+		}
+
+		verification.Fail(decodeErr.Finding(check))
+
+		// Decoding stopped at the damaged frame, so the MD5 cannot be checked.
+		return verification, nil
+	case err != nil:
+		return verification, err
+	}
+
+	verification.Examined(saprobe.CheckFrameCRC)
+	verification.Examined(saprobe.CheckSampleCount)
+
+	if report.Truncation != nil {
+		verification.Fail(report.Truncation.Finding())
+	}
+
+	switch report.MD5 {
+	case MD5Match:
+		verification.Examined(saprobe.CheckMD5)
+	case MD5Mismatch:
+		verification.Fail(saprobe.Finding{
+			Check:  saprobe.CheckMD5,
+			Frame:  -1,
+			Offset: -1,
+			Sample: -1,
+			Detail: fmt.Sprintf("stored %x, decoded audio hashes to %x", report.StoredMD5, report.ComputedMD5),
+		})
+	case MD5NotChecked, MD5Absent:
+	}
+
+	return verification, nil
+}
@@ -0,0 +1,88 @@
+package flac_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/flac"
+	"github.com/farcloser/saprobe/tests/testutils"
+)
+
+// encode returns pcm encoded as a FLAC stream.
+func encode(t testing.TB, pcm []byte, format saprobe.PCMFormat) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := flac.Encode(&buf, pcm, format, saprobe.Metadata{}); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// TestVerifyFrameCRC checks that a damaged frame checksum is reported as a frame CRC failure.
+func TestVerifyFrameCRC(t *testing.T) {
+	t.Parallel()
+
+	format := saprobe.PCMFormat{SampleRate: 44100, BitDepth: saprobe.Depth16, Channels: 2}
+	data := encode(t, testutils.Noise(format, 20000, -6), format)
+
+	verification, err := flac.Verify(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !verification.OK() || !slices.Contains(verification.Checked, saprobe.CheckFrameCRC) {
+		t.Fatalf("checked %v, findings %v, want clean frame CRCs", verification.Checked, verification.Findings)
+	}
+
+	// The stream ends with the CRC-16 of the last frame.
+	data[len(data)-1] ^= 0x01
+
+	verification, err = flac.Verify(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(verification.Findings) != 1 || verification.Findings[0].Check != saprobe.CheckFrameCRC ||
+		verification.Findings[0].Sample != 16384 {
+		t.Errorf("findings %v, want a CRC failure in the frame at sample 16384", verification.Findings)
+	}
+}
+
+// TestVerifyMD5 checks the STREAMINFO MD5 of 16 and 24-bit streams against the decoded audio, and
+// reports a stored signature that no longer matches.
+func TestVerifyMD5(t *testing.T) {
+	t.Parallel()
+
+	// "fLaC", the metadata block header, then 18 bytes of STREAMINFO before the MD5.
+	const md5Offset = 4 + 4 + 18
+
+	for _, depth := range []saprobe.BitDepth{saprobe.Depth16, saprobe.Depth24} {
+		format := saprobe.PCMFormat{SampleRate: 48000, BitDepth: depth, Channels: 2}
+		data := encode(t, testutils.Noise(format, 10000, -6), format)
+
+		_, _, report, err := flac.DecodeWithOptions(bytes.NewReader(data), flac.Options{VerifyMD5: true})
+		if err != nil || report.MD5 != flac.MD5Match {
+			t.Errorf("%d-bit: %v (%v), want a match", depth, report.MD5, err)
+		}
+
+		data[md5Offset+7] ^= 0x80
+
+		_, _, report, err = flac.DecodeWithOptions(bytes.NewReader(data), flac.Options{VerifyMD5: true})
+		if err != nil || report.MD5 != flac.MD5Mismatch || report.StoredMD5 == report.ComputedMD5 {
+			t.Errorf("%d-bit: %v (%v), want a mismatch", depth, report.MD5, err)
+		}
+
+		verification, err := flac.Verify(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(verification.Findings) != 1 || verification.Findings[0].Check != saprobe.CheckMD5 {
+			t.Errorf("%d-bit: findings %v, want an MD5 mismatch", depth, verification.Findings)
+		}
+	}
+}
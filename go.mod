@@ -7,8 +7,7 @@ require (
 	github.com/containerd/nerdctl/mod/tigron v0.0.0-20260121031139-a630881afd01
 	github.com/farcloser/agar v0.0.0-20260129015059-fcda423fe291
 	github.com/farcloser/primordium v0.0.0-20260129020312-51a7a6cb1992
-	github.com/hajimehoshi/go-mp3 v0.3.4
-	github.com/jfreymuth/oggvorbis v1.0.5
+	github.com/jfreymuth/vorbis v1.0.2
 	github.com/mewkiz/flac v1.0.13
 	github.com/urfave/cli/v3 v3.6.2
 )
@@ -17,7 +16,6 @@ require (
 	github.com/creack/pty v1.1.24 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/icza/bitio v1.1.0 // indirect
-	github.com/jfreymuth/vorbis v1.0.2 // indirect
 	github.com/mattn/go-colorable v0.1.14 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/mewkiz/pkg v0.0.0-20250417130911-3f050ff8c56d // indirect
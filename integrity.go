@@ -0,0 +1,90 @@
+package saprobe
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Check names an integrity signal that a verifier can examine.
+type Check string
+
+// Integrity signals. Which ones are available depends on the codec and on what the encoder stored.
+const (
+	// CheckMD5 compares decoded audio with the MD5 stored in FLAC STREAMINFO.
+	CheckMD5 Check = "md5"
+	// CheckFrameCRC validates FLAC frame CRC-8/CRC-16 and MPEG frame CRC-16 checksums.
+	CheckFrameCRC Check = "frame-crc"
+	// CheckLAMETagCRC validates the CRC-16 of the LAME/Info tag and of the music data it covers.
+	CheckLAMETagCRC Check = "lame-tag-crc"
+	// CheckPageCRC validates Ogg page CRC32 checksums.
+	CheckPageCRC Check = "page-crc"
+	// CheckBitstream reports bitstream errors raised by the decoder itself (overruns, invalid headers).
+	CheckBitstream Check = "bitstream"
+	// CheckSampleCount compares the decoded sample count with the count declared by the container.
+	CheckSampleCount Check = "sample-count"
+)
+
+// Finding is a single integrity failure, located as precisely as the format allows.
+// Frame, Offset and Sample are -1 when unknown.
+type Finding struct {
+	Check Check
+	// Frame is the index of the frame, packet or page.
+	Frame int
+	// Offset is the byte offset of the frame, packet or page in the file.
+	Offset int64
+	// Sample is the position (in samples per channel) at which the damaged data starts.
+	Sample int64
+	Detail string
+}
+
+// String formats the finding with its location.
+func (f Finding) String() string {
+	var location []string
+
+	if f.Frame >= 0 {
+		location = append(location, fmt.Sprintf("frame %d", f.Frame))
+	}
+
+	if f.Offset >= 0 {
+		location = append(location, fmt.Sprintf("offset %d", f.Offset))
+	}
+
+	if f.Sample >= 0 {
+		location = append(location, fmt.Sprintf("sample %d", f.Sample))
+	}
+
+	if len(location) == 0 {
+		return fmt.Sprintf("%s: %s", f.Check, f.Detail)
+	}
+
+	return fmt.Sprintf("%s: %s: %s", f.Check, strings.Join(location, ", "), f.Detail)
+}
+
+// Verification lists the integrity signals examined in a stream and the failures found.
+type Verification struct {
+	// Checked lists the signals that were present and examined.
+	Checked  []Check
+	Findings []Finding
+}
+
+// OK reports whether no integrity failure was found.
+func (v *Verification) OK() bool {
+	return len(v.Findings) == 0
+}
+
+// Examined records that check was performed.
+func (v *Verification) Examined(check Check) {
+	for _, c := range v.Checked {
+		if c == check {
+			return
+		}
+	}
+
+	v.Checked = append(v.Checked, check)
+}
+
+// Fail records a finding, also marking its check as examined.
+func (v *Verification) Fail(finding Finding) {
+	v.Examined(finding.Check)
+	v.Findings = append(v.Findings, finding)
+}
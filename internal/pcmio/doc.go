@@ -0,0 +1,3 @@
+// Package pcmio reads and writes the samples of the interleaved little-endian signed PCM the
+// decoders produce, for the packages that process it.
+package pcmio
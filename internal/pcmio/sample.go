@@ -0,0 +1,35 @@
+package pcmio
+
+// ReadSample reads a little-endian signed sample of bps bytes, sign-extending it.
+func ReadSample(data []byte, bps int) int64 {
+	var value int64
+
+	for i := bps - 1; i >= 0; i-- {
+		value = value<<8 | int64(data[i])
+	}
+
+	shift := 64 - 8*bps
+
+	return value << shift >> shift
+}
+
+// WriteSample writes the low bps bytes of value as a little-endian sample.
+func WriteSample(data []byte, bps int, value int64) {
+	for i := range bps {
+		data[i] = byte(value >> (8 * i))
+	}
+}
+
+// FitsBits reports whether every sample of pcm, of bps bytes each, holds no more than its top bits
+// bits: the bits below are zero, as in samples widened from that size.
+func FitsBits(pcm []byte, bps, bits int) bool {
+	mask := int64(1)<<(8*bps-bits) - 1
+
+	for pos := 0; pos+bps <= len(pcm); pos += bps {
+		if ReadSample(pcm[pos:], bps)&mask != 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,153 @@
+package saprobe
+
+import "strings"
+
+// Speaker is a loudspeaker position. Positions are numbered after the bits of the
+// WAVEFORMATEXTENSIBLE channel mask: speaker n is bit n-1, and 0 is no speaker.
+type Speaker uint8
+
+// Speaker positions.
+const (
+	SpeakerNone Speaker = iota
+	SpeakerFrontLeft
+	SpeakerFrontRight
+	SpeakerFrontCenter
+	SpeakerLowFrequency
+	SpeakerBackLeft
+	SpeakerBackRight
+	SpeakerFrontLeftOfCenter
+	SpeakerFrontRightOfCenter
+	SpeakerBackCenter
+	SpeakerSideLeft
+	SpeakerSideRight
+	SpeakerTopCenter
+	SpeakerTopFrontLeft
+	SpeakerTopFrontCenter
+	SpeakerTopFrontRight
+	SpeakerTopBackLeft
+	SpeakerTopBackCenter
+	SpeakerTopBackRight
+)
+
+//nolint:gochecknoglobals // constant table
+var speakerNames = [...]string{
+	SpeakerFrontLeft:          "FL",
+	SpeakerFrontRight:         "FR",
+	SpeakerFrontCenter:        "FC",
+	SpeakerLowFrequency:       "LFE",
+	SpeakerBackLeft:           "BL",
+	SpeakerBackRight:          "BR",
+	SpeakerFrontLeftOfCenter:  "FLC",
+	SpeakerFrontRightOfCenter: "FRC",
+	SpeakerBackCenter:         "BC",
+	SpeakerSideLeft:           "SL",
+	SpeakerSideRight:          "SR",
+	SpeakerTopCenter:          "TC",
+	SpeakerTopFrontLeft:       "TFL",
+	SpeakerTopFrontCenter:     "TFC",
+	SpeakerTopFrontRight:      "TFR",
+	SpeakerTopBackLeft:        "TBL",
+	SpeakerTopBackCenter:      "TBC",
+	SpeakerTopBackRight:       "TBR",
+}
+
+// String returns the usual abbreviation of the speaker position, such as FL or LFE.
+func (s Speaker) String() string {
+	if s == SpeakerNone || int(s) >= len(speakerNames) {
+		return "none"
+	}
+
+	return speakerNames[s]
+}
+
+// MaxLayoutChannels is the largest channel count a ChannelLayout describes. It covers every
+// layout the codecs define.
+const MaxLayoutChannels = 8
+
+// ChannelLayout assigns a speaker position to each channel, in the order the channels are
+// interleaved. Positions past the last channel are SpeakerNone. The zero value is an unknown
+// layout, which streams of more channels than the codec assigns positions to have.
+type ChannelLayout [MaxLayoutChannels]Speaker
+
+// Common layouts.
+//
+//nolint:gochecknoglobals // constant tables
+var (
+	LayoutMono   = ChannelLayout{SpeakerFrontCenter}
+	LayoutStereo = ChannelLayout{SpeakerFrontLeft, SpeakerFrontRight}
+)
+
+// defaultLayouts holds the WAVEFORMATEXTENSIBLE default speaker assignments for 1-8 channels.
+//
+//nolint:gochecknoglobals // constant table
+var defaultLayouts = [...]ChannelLayout{
+	1: LayoutMono,
+	2: LayoutStereo,
+	3: {SpeakerFrontLeft, SpeakerFrontRight, SpeakerFrontCenter},
+	4: {SpeakerFrontLeft, SpeakerFrontRight, SpeakerBackLeft, SpeakerBackRight},
+	5: {SpeakerFrontLeft, SpeakerFrontRight, SpeakerFrontCenter, SpeakerBackLeft, SpeakerBackRight},
+	6: {
+		SpeakerFrontLeft, SpeakerFrontRight, SpeakerFrontCenter, SpeakerLowFrequency,
+		SpeakerBackLeft, SpeakerBackRight,
+	},
+	7: {
+		SpeakerFrontLeft, SpeakerFrontRight, SpeakerFrontCenter, SpeakerLowFrequency,
+		SpeakerBackLeft, SpeakerBackRight, SpeakerBackCenter,
+	},
+	8: {
+		SpeakerFrontLeft, SpeakerFrontRight, SpeakerFrontCenter, SpeakerLowFrequency,
+		SpeakerBackLeft, SpeakerBackRight, SpeakerSideLeft, SpeakerSideRight,
+	},
+}
+
+// DefaultLayout returns the layout WAVE assumes for a channel count, or the unknown layout
+// beyond 8 channels.
+func DefaultLayout(channels uint) ChannelLayout {
+	if channels == 0 || channels >= uint(len(defaultLayouts)) {
+		return ChannelLayout{}
+	}
+
+	return defaultLayouts[channels]
+}
+
+// Channels returns the number of channels the layout assigns a position to.
+func (l ChannelLayout) Channels() int {
+	for ch, speaker := range l {
+		if speaker == SpeakerNone {
+			return ch
+		}
+	}
+
+	return len(l)
+}
+
+// Speakers returns the speaker position of each channel.
+func (l ChannelLayout) Speakers() []Speaker {
+	return l[:l.Channels()]
+}
+
+// Index returns the channel at speaker, or -1 when the layout has none there.
+func (l ChannelLayout) Index(speaker Speaker) int {
+	for ch, position := range l.Speakers() {
+		if position == speaker {
+			return ch
+		}
+	}
+
+	return -1
+}
+
+// String lists the speaker positions, such as "FL FR FC LFE BL BR", or returns "unknown".
+func (l ChannelLayout) String() string {
+	speakers := l.Speakers()
+	if len(speakers) == 0 {
+		return "unknown"
+	}
+
+	names := make([]string, len(speakers))
+	for ch, speaker := range speakers {
+		names[ch] = speaker.String()
+	}
+
+	return strings.Join(names, " ")
+}
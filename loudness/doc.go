@@ -0,0 +1,4 @@
+// Package loudness measures loudness after ITU-R BS.1770-4 and EBU R 128: integrated loudness,
+// loudness range (EBU Tech 3342), maximum momentary and short-term loudness, sample peak and true
+// peak. It works on the planar blocks the codec Blocks iterators yield.
+package loudness
@@ -0,0 +1,67 @@
+package loudness
+
+import "math"
+
+// K-weighting stages, as analog prototypes that the bilinear transform maps onto any sample rate:
+// a high shelf modelling the acoustic effect of the head, then the RLB high-pass. At 48 kHz, they
+// give the coefficients of BS.1770.
+const (
+	shelfFrequency = 1681.974450955533
+	shelfGain      = 3.999843853973347 // dB
+	shelfQ         = 0.7071752369554196
+	shelfSlope     = 0.4996667741545416
+	highPassFreq   = 38.13547087602444
+	highPassQ      = 0.5003270373238773
+)
+
+// biquad is a second-order IIR section, in transposed direct form II.
+type biquad struct {
+	b0, b1, b2 float64
+	a1, a2     float64
+	z1, z2     float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.z1
+	f.z1 = f.b1*x - f.a1*y + f.z2
+	f.z2 = f.b2*x - f.a2*y
+
+	return y
+}
+
+// kWeighting is the K-weighting filter of one channel.
+type kWeighting struct {
+	shelf, highPass biquad
+}
+
+func newKWeighting(rate int) kWeighting {
+	k := math.Tan(math.Pi * shelfFrequency / float64(rate))
+	vh := math.Pow(10, shelfGain/20)
+	vb := math.Pow(vh, shelfSlope)
+	a0 := 1 + k/shelfQ + k*k
+
+	shelf := biquad{
+		b0: (vh + vb*k/shelfQ + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/shelfQ + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/shelfQ + k*k) / a0,
+	}
+
+	k = math.Tan(math.Pi * highPassFreq / float64(rate))
+	a0 = 1 + k/highPassQ + k*k
+
+	highPass := biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/highPassQ + k*k) / a0,
+	}
+
+	return kWeighting{shelf: shelf, highPass: highPass}
+}
+
+func (k *kWeighting) process(x float64) float64 {
+	return k.highPass.process(k.shelf.process(x))
+}
@@ -0,0 +1,253 @@
+package loudness
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/farcloser/saprobe"
+)
+
+// Gating and windows of BS.1770-4 and EBU Tech 3342. Loudness is measured every 100 ms, over
+// the last 400 ms (momentary, the gating blocks of the integrated loudness) and the last 3 s
+// (short-term, the values of the loudness range).
+const (
+	stepsPerSecond  = 10
+	momentarySteps  = 4
+	shortTermSteps  = 30
+	absoluteGate    = -70 // LUFS
+	relativeGate    = -10 // LU, integrated loudness
+	rangeGate       = -20 // LU, loudness range
+	rangeLow        = 0.10
+	rangeHigh       = 0.95
+	surroundWeight  = 1.41
+	loudnessOffset  = -0.691
+	replayGainLevel = -18 // LUFS, the ReplayGain 2.0 reference
+)
+
+var errFormatChange = errors.New("loudness: the format changes within the stream")
+
+// Meter measures the loudness of a stream, block after block.
+type Meter struct {
+	format    saprobe.PCMFormat
+	weights   []float64 // of each channel in the sum
+	filters   []kWeighting
+	peaks     []truePeak
+	scale     float64 // from samples to full scale
+	step      int     // samples per 100 ms
+	count     int     // samples in the current step
+	energy    float64 // weighted sum of the squares of the current step
+	recent    []float64
+	momentary []float64 // mean squares of the 400 ms windows
+	shortTerm []float64 // mean squares of the 3 s windows
+	peak      float64
+}
+
+// Result is the loudness of a stream, or of an album. Levels are -Inf when the audio is too short
+// or too quiet for them.
+type Result struct {
+	Integrated   float64 // LUFS
+	Range        float64 // LU
+	MomentaryMax float64 // LUFS
+	ShortTermMax float64 // LUFS
+	SamplePeak   float64 // dBFS
+	TruePeak     float64 // dBTP
+}
+
+// New creates a meter for audio in format. Channels are weighed after their speakers: surrounds by
+// +1.5 dB, and the LFE left out. An unknown layout weighs every channel alike.
+func New(format saprobe.PCMFormat) *Meter {
+	meter := &Meter{
+		format:  format,
+		weights: make([]float64, format.Channels),
+		filters: make([]kWeighting, format.Channels),
+		peaks:   make([]truePeak, format.Channels),
+		scale:   1 / float64(int64(1)<<(format.BitDepth-1)),
+		step:    max(format.SampleRate/stepsPerSecond, 1),
+	}
+
+	for ch := range meter.weights {
+		meter.weights[ch] = 1
+		meter.filters[ch] = newKWeighting(format.SampleRate)
+
+		var speaker saprobe.Speaker
+		if ch < saprobe.MaxLayoutChannels {
+			speaker = format.Layout[ch]
+		}
+
+		switch speaker {
+		case saprobe.SpeakerLowFrequency:
+			meter.weights[ch] = 0
+		case saprobe.SpeakerBackLeft, saprobe.SpeakerBackRight, saprobe.SpeakerSideLeft, saprobe.SpeakerSideRight:
+			meter.weights[ch] = surroundWeight
+		default:
+		}
+	}
+
+	return meter
+}
+
+// Add measures a block of the stream, which must keep the format the meter was created for.
+func (m *Meter) Add(block saprobe.Block) error {
+	if block.Format != m.format {
+		return fmt.Errorf("%w: %d Hz %d channels, then %d Hz %d channels", errFormatChange,
+			m.format.SampleRate, m.format.Channels, block.Format.SampleRate, block.Format.Channels)
+	}
+
+	for idx := range block.Len() {
+		for ch, samples := range block.Samples {
+			x := float64(samples[idx]) * m.scale
+
+			m.peak = max(m.peak, math.Abs(x))
+			m.peaks[ch].process(x)
+
+			y := m.filters[ch].process(x)
+			m.energy += m.weights[ch] * y * y
+		}
+
+		if m.count++; m.count == m.step {
+			m.advance()
+		}
+	}
+
+	return nil
+}
+
+// advance closes a step of 100 ms, and the windows ending with it.
+func (m *Meter) advance() {
+	m.recent = append(m.recent, m.energy/float64(m.step))
+	if len(m.recent) > shortTermSteps {
+		m.recent = m.recent[1:]
+	}
+
+	m.energy, m.count = 0, 0
+
+	if len(m.recent) >= momentarySteps {
+		m.momentary = append(m.momentary, mean(m.recent[len(m.recent)-momentarySteps:]))
+	}
+
+	if len(m.recent) == shortTermSteps {
+		m.shortTerm = append(m.shortTerm, mean(m.recent))
+	}
+}
+
+// Result returns the loudness of what the meter measured.
+func (m *Meter) Result() Result {
+	return Album(m)
+}
+
+// Album returns the loudness of the streams of meters taken as one program: the integrated
+// loudness and the loudness range gate the windows of every stream together, and the maxima and
+// peaks are those of the loudest one.
+func Album(meters ...*Meter) Result {
+	var momentary, shortTerm []float64
+
+	result := Result{MomentaryMax: math.Inf(-1), ShortTermMax: math.Inf(-1)}
+	samplePeak, truePeak := 0.0, 0.0
+
+	for _, meter := range meters {
+		momentary = append(momentary, meter.momentary...)
+		shortTerm = append(shortTerm, meter.shortTerm...)
+
+		for _, energy := range meter.momentary {
+			result.MomentaryMax = max(result.MomentaryMax, loudness(energy))
+		}
+
+		for _, energy := range meter.shortTerm {
+			result.ShortTermMax = max(result.ShortTermMax, loudness(energy))
+		}
+
+		samplePeak = max(samplePeak, meter.peak)
+
+		for _, peak := range meter.peaks {
+			truePeak = max(truePeak, peak.peak)
+		}
+	}
+
+	result.Integrated = integrated(momentary)
+	result.Range = loudnessRange(shortTerm)
+	result.SamplePeak = decibels(samplePeak)
+	result.TruePeak = decibels(max(truePeak, samplePeak))
+
+	return result
+}
+
+// ReplayGain returns the ReplayGain 2.0 values of the result: the gain bringing the integrated
+// loudness to -18 LUFS, and the true peak.
+func (r Result) ReplayGain() saprobe.Gain {
+	if math.IsInf(r.Integrated, -1) {
+		return saprobe.Gain{}
+	}
+
+	return saprobe.Gain{
+		Set:  true,
+		Gain: replayGainLevel - r.Integrated,
+		Peak: math.Pow(10, r.TruePeak/20),
+	}
+}
+
+// integrated returns the gated loudness of the 400 ms windows: those above the absolute gate, then
+// those above the relative gate under their mean.
+func integrated(windows []float64) float64 {
+	gated := gate(windows, absoluteGate)
+	if len(gated) == 0 {
+		return math.Inf(-1)
+	}
+
+	return loudness(mean(gate(gated, loudness(mean(gated))+relativeGate)))
+}
+
+// loudnessRange returns the spread of the short-term loudness, between its 10th and 95th
+// percentiles, once gated like the integrated loudness, 20 LU under.
+func loudnessRange(windows []float64) float64 {
+	gated := gate(windows, absoluteGate)
+	if len(gated) == 0 {
+		return 0
+	}
+
+	gated = gate(gated, loudness(mean(gated))+rangeGate)
+
+	levels := make([]float64, len(gated))
+	for idx, energy := range gated {
+		levels[idx] = loudness(energy)
+	}
+
+	slices.Sort(levels)
+
+	percentile := func(p float64) float64 {
+		return levels[int(math.Round(p*float64(len(levels)-1)))]
+	}
+
+	return percentile(rangeHigh) - percentile(rangeLow)
+}
+
+// gate returns the windows louder than the threshold, in LUFS.
+func gate(windows []float64, threshold float64) []float64 {
+	var kept []float64
+
+	for _, energy := range windows {
+		if loudness(energy) > threshold {
+			kept = append(kept, energy)
+		}
+	}
+
+	return kept
+}
+
+func loudness(energy float64) float64 {
+	return loudnessOffset + 10*math.Log10(energy)
+}
+
+func decibels(ratio float64) float64 {
+	return 20 * math.Log10(ratio)
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+
+	return sum / float64(len(values))
+}
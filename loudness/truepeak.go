@@ -0,0 +1,47 @@
+package loudness
+
+// The 4x oversampling interpolator of BS.1770-4 Annex 2: 48 taps in 4 phases of 12.
+const (
+	oversampling = 4
+	phaseTaps    = 12
+)
+
+//nolint:gochecknoglobals // constant table
+var interpolator = [oversampling][phaseTaps]float64{
+	{
+		0.0017089843750, 0.0109863281250, -0.0196533203125, 0.0332031250000, -0.0594482421875, 0.1373291015625,
+		0.9721679687500, -0.1022949218750, 0.0476074218750, -0.0266113281250, 0.0148925781250, -0.0083007812500,
+	},
+	{
+		-0.0291748046875, 0.0292968750000, -0.0517578125000, 0.0891113281250, -0.1665039062500, 0.4650878906250,
+		0.7797851562500, -0.2003173828125, 0.1015625000000, -0.0582275390625, 0.0330810546875, -0.0189208984375,
+	},
+	{
+		-0.0189208984375, 0.0330810546875, -0.0582275390625, 0.1015625000000, -0.2003173828125, 0.7797851562500,
+		0.4650878906250, -0.1665039062500, 0.0891113281250, -0.0517578125000, 0.0292968750000, -0.0291748046875,
+	},
+	{
+		-0.0083007812500, 0.0148925781250, -0.0266113281250, 0.0476074218750, -0.1022949218750, 0.9721679687500,
+		0.1373291015625, -0.0594482421875, 0.0332031250000, -0.0196533203125, 0.0109863281250, 0.0017089843750,
+	},
+}
+
+// truePeak tracks the highest magnitude of one channel, oversampled 4 times.
+type truePeak struct {
+	history [phaseTaps]float64 // the last samples, the latest first
+	peak    float64
+}
+
+func (t *truePeak) process(x float64) {
+	copy(t.history[1:], t.history[:phaseTaps-1])
+	t.history[0] = x
+
+	for _, phase := range interpolator {
+		var sum float64
+		for tap, coef := range phase {
+			sum += coef * t.history[tap]
+		}
+
+		t.peak = max(t.peak, sum, -sum)
+	}
+}
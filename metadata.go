@@ -0,0 +1,68 @@
+package saprobe
+
+import "strings"
+
+// Well-known tag keys. Keys follow Vorbis comment conventions (upper-case ASCII),
+// and every codec package maps its native fields onto them.
+const (
+	TagTitle       = "TITLE"
+	TagArtist      = "ARTIST"
+	TagAlbum       = "ALBUM"
+	TagAlbumArtist = "ALBUMARTIST"
+	TagDate        = "DATE"
+	TagGenre       = "GENRE"
+	TagComment     = "COMMENT"
+	TagComposer    = "COMPOSER"
+	TagTrackNumber = "TRACKNUMBER"
+	TagTrackTotal  = "TRACKTOTAL"
+	TagDiscNumber  = "DISCNUMBER"
+	TagDiscTotal   = "DISCTOTAL"
+	TagCopyright   = "COPYRIGHT"
+	TagEncoder     = "ENCODER"
+	TagGrouping    = "GROUPING"
+	TagLyrics      = "LYRICS"
+)
+
+// PictureFrontCover is the ID3v2 APIC picture type for the front cover.
+const PictureFrontCover = 3
+
+// Tag is a single textual metadata field.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// Picture is an embedded image, typically cover art.
+type Picture struct {
+	// Type is the ID3v2 APIC picture type (3 is the front cover).
+	Type        uint32
+	MIME        string
+	Description string
+	Data        []byte
+}
+
+// Metadata holds the descriptive metadata carried alongside the audio of a file.
+type Metadata struct {
+	Tags     []Tag
+	Pictures []Picture
+}
+
+// Get returns the first value stored under key (case-insensitive).
+func (m *Metadata) Get(key string) (string, bool) {
+	for _, tag := range m.Tags {
+		if strings.EqualFold(tag.Key, key) {
+			return tag.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// Add appends a tag, normalizing the key to upper case. Empty values are ignored.
+func (m *Metadata) Add(key, value string) {
+	if value == "" {
+		return
+	}
+
+	m.Tags = append(m.Tags, Tag{Key: strings.ToUpper(key), Value: value})
+}
@@ -0,0 +1,41 @@
+package mp3
+
+// bitReader reads MSB-first bit fields from a byte slice. Reading past the end yields zero bits;
+// callers compare pos with the data length to detect it.
+type bitReader struct {
+	data []byte
+	pos  int // position in bits
+}
+
+// bit reads one bit.
+func (b *bitReader) bit() int {
+	idx, shift := b.pos>>3, 7-b.pos&7
+	b.pos++
+
+	if idx >= len(b.data) {
+		return 0
+	}
+
+	return int(b.data[idx]>>shift) & 1
+}
+
+// read reads an unsigned field of up to 32 bits.
+func (b *bitReader) read(numBits int) int {
+	value := 0
+
+	for range numBits {
+		value = value<<1 | b.bit()
+	}
+
+	return value
+}
+
+// flag reads one bit as a boolean.
+func (b *bitReader) flag() bool {
+	return b.bit() == 1
+}
+
+// bits returns the length of the data in bits.
+func (b *bitReader) bits() int {
+	return len(b.data) * 8
+}
@@ -0,0 +1,70 @@
+package mp3
+
+import (
+	"io"
+	"iter"
+
+	"github.com/farcloser/saprobe"
+)
+
+// Blocks decodes an MP3 stream frame by frame and yields a block of planar 24-bit samples per
+// frame (see saprobe.Block), gapless trimming included, as Decode returns them. Decoding stops at
+// the first frame the decoder rejects, whose error is yielded as Decode would return it.
+func Blocks(reader io.ReadSeeker) iter.Seq2[saprobe.Block, error] {
+	return func(yield func(saprobe.Block, error) bool) {
+		tracked := saprobe.TrackReader(reader)
+
+		err := decodeBlocks(tracked, func(block saprobe.Block) bool {
+			return yield(block, nil)
+		})
+		if err != nil {
+			yield(saprobe.Block{}, wrapError(tracked, err))
+		}
+	}
+}
+
+// decodeBlocks decodes every frame of the stream, handing the samples gapless trimming keeps of
+// each to yield until it returns false.
+func decodeBlocks(reader io.ReadSeeker, yield func(saprobe.Block) bool) error {
+	str, err := readStream(reader)
+	if err != nil {
+		return err
+	}
+
+	frameSamples := str.frameBytes / str.sampleBytes
+	total := str.wholeFrames() * frameSamples
+
+	gapless := parseGaplessInfo(reader, str.firstFrame())
+	gapless.fitLength(total)
+	keepStart, keepEnd := gapless.bounds(total)
+
+	block := saprobe.Block{
+		Format: saprobe.PCMFormat{
+			SampleRate: str.first.sampleRate,
+			BitDepth:   saprobe.Depth24,
+			Channels:   uint(str.first.channels()), //nolint:gosec // one or two channels.
+			Layout:     str.first.layout(),
+		},
+		Samples: make([][]int32, str.first.channels()),
+	}
+
+	return decodeFrames(str, func(idx int, pcm framePCM) bool {
+		// Keep the part of the frame within the trimmed output.
+		position := idx * frameSamples
+		first := max(keepStart, position)
+		last := min(keepEnd, position+frameSamples)
+
+		if first >= last {
+			return true
+		}
+
+		for ch := range block.Samples {
+			block.Samples[ch] = block.Samples[ch][:0]
+		}
+
+		block.Start = int64(first - keepStart)
+		block.Samples = pcm.appendPlanar(block.Samples, first-position, last-position)
+
+		return yield(block)
+	})
+}
@@ -0,0 +1,43 @@
+package mp3
+
+const (
+	crc16PolyMPEG = 0x8005 // CRC-16 polynomial, MSB-first (ISO 11172-3 frame CRC).
+	crc16PolyLAME = 0xA001 // The same polynomial, bit-reflected (LAME/Info tag CRCs).
+	crc16InitMPEG = 0xFFFF
+)
+
+// crc16MPEG updates an ISO 11172-3 frame CRC with data.
+func crc16MPEG(crc uint16, data []byte) uint16 {
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ crc16PolyMPEG
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
+
+// crc16LAME computes the reflected CRC-16 LAME uses for the tag and music CRCs (initial value 0).
+func crc16LAME(data []byte) uint16 {
+	var crc uint16
+
+	for _, b := range data {
+		crc ^= uint16(b)
+
+		for range 8 {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ crc16PolyLAME
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+
+	return crc
+}
@@ -7,23 +7,17 @@ import (
 	"fmt"
 	"io"
 
-	gomp3 "github.com/hajimehoshi/go-mp3"
-
 	"github.com/farcloser/saprobe"
 )
 
 const (
-	channels       = 2 // go-mp3 always decodes to stereo
-	bytesPerSample = 2 // 16-bit
-	bytesPerFrame  = channels * bytesPerSample
-
-	// MP3 frame contains 1152 samples for MPEG1 Layer III.
-	samplesPerFrame = 1152
+	bytesPerSample = 3 // 24-bit
 
-	// go-mp3 decoder has additional delay from synthesis filterbank priming.
-	// This is the difference between go-mp3's output and what LAME header expects.
-	// Empirically measured: 529 samples.
-	decoderDelay = 529
+	// synthesisDelay is the delay, in samples, between the encoder input and the decoder output
+	// that the hybrid filterbank adds on top of the encoder delay: 528 samples of IMDCT overlap
+	// and polyphase filtering, plus one for the alignment of the synthesis window. It is the value
+	// LAME's gapless fields assume, and the decoder in this package matches it by construction.
+	synthesisDelay = 529
 )
 
 // MPEG version identifiers (2-bit field in frame header).
@@ -56,6 +50,11 @@ const (
 // LAME tag layout.
 const (
 	lameTagMinSize     = 24    // Minimum bytes for a valid LAME tag.
+	lameMethodOffset   = 9     // Offset of the tag revision (high nibble) and VBR method (low nibble).
+	lameMethodMask     = 0x0F  // Mask of the VBR method.
+	lamePeakOffset     = 11    // Offset of the peak signal amplitude, 9.23 fixed point.
+	lameTrackGain      = 15    // Offset of the radio ReplayGain field.
+	lameAlbumGain      = 17    // Offset of the audiophile ReplayGain field.
 	lameGaplessOffset  = 21    // Offset of gapless info within LAME tag.
 	gaplessFieldBits   = 12    // Each gapless field (delay/padding) is 12 bits.
 	gaplessPaddingMask = 0xFFF // 12-bit mask for gapless padding field.
@@ -68,10 +67,12 @@ const (
 
 // Parsing thresholds.
 const (
-	headerBufSize   = 4096 // Buffer size for reading first MPEG frame.
-	minHeaderBytes  = 256  // Minimum bytes needed to find a valid frame header.
-	minXINGPlusLAME = 120  // Minimum bytes from XING offset for XING+LAME headers.
-	encoderTagLen   = 9    // Length to check for printable encoder tag.
+	encoderTagLen = 9 // Length to check for printable encoder tag.
+
+	// maxFreeFormatSize bounds the length of the free-format frames measureFreeFormat looks for:
+	// 640 kbit/s, the highest bitrate encoders offer, at every sample rate.
+	maxFreeFormatSize = 8192
+	freeFormatChecks  = 4
 )
 
 // Printable ASCII range.
@@ -80,156 +81,394 @@ const (
 	printableASCIIMax = 0x7E
 )
 
-// gaplessInfo contains encoder delay and padding from LAME header.
+// gaplessInfo contains encoder delay and padding, from the LAME tag, iTunSMPB or the VBRI header.
 type gaplessInfo struct {
-	delay      int  // samples to skip at start (LAME encoder delay)
-	padding    int  // samples to skip at end (LAME padding)
-	hasXINGTag bool // true if XING/Info frame present (adds samplesPerFrame to output)
+	source     GaplessSource
+	delay      int                // samples to skip at start (encoder delay)
+	padding    int                // samples to skip at end (encoder padding)
+	hasXINGTag bool               // true if a XING/Info or VBRI frame is present (adds frameSize to output)
+	frames     int                // audio frame count from the XING header (0 if absent), excluding the XING frame
+	frameSize  int                // samples per frame, from the first frame header: 1152, or 576 for MPEG-2/2.5
+	length     int64              // original sample count from iTunSMPB (0 if absent)
+	vbrMethod  byte               // VBR method of the LAME tag (lameMethodCBR...), 0 if absent
+	replayGain saprobe.ReplayGain // radio and audiophile gains of the LAME tag
 }
 
-// Decode reads an MP3 stream and decodes it to interleaved little-endian signed 16-bit PCM bytes.
-// The output is always stereo (2 channels) at the source sample rate.
-// If the file contains LAME gapless metadata, encoder delay and padding are trimmed automatically.
+const codecName = "mp3"
+
+var (
+	errNoFrames = errors.New("mp3: no decodable frame")
+)
+
+// Report describes how the stream was decoded.
+type Report struct {
+	saprobe.Report
+
+	// Gapless is the metadata that supplied Delay and Padding, the encoder samples trimmed from
+	// the start and the end of the output.
+	Gapless GaplessSource
+	Delay   int
+	Padding int
+}
+
+// Decode reads an MP3 stream and decodes it to interleaved little-endian signed 24-bit PCM bytes,
+// at the channel count and sample rate of the source.
+// If the file contains gapless metadata (LAME tag, iTunSMPB or VBRI header), encoder delay and
+// padding are trimmed automatically.
 func Decode(reader io.ReadSeeker) ([]byte, saprobe.PCMFormat, error) {
+	pcm, format, _, err := DecodeWithOptions(reader, saprobe.Options{})
+
+	return pcm, format, err
+}
+
+// DecodeWithOptions is Decode with shared decoder options. When the XING header declares a frame
+// count, a stream that decodes fewer frames is reported as a Truncation, or fails in strict mode.
+// In resilient mode a frame the decoder rejects is concealed, and decoding restarts at the next one.
+func DecodeWithOptions(reader io.ReadSeeker, opts saprobe.Options) ([]byte, saprobe.PCMFormat, Report, error) {
+	tracked := saprobe.TrackReader(reader)
+	pcm, format, report, err := decode(tracked, opts)
+
+	return pcm, format, report, wrapError(tracked, err)
+}
+
+// wrapError categorizes a decoding error as a *saprobe.DecodeError.
+func wrapError(reader *saprobe.TrackedReader, err error) error {
+	return saprobe.WrapDecodeError(codecName, reader, err)
+}
+
+func decode(reader io.ReadSeeker, opts saprobe.Options) ([]byte, saprobe.PCMFormat, Report, error) {
+	var report Report
+
+	stream, err := readStream(reader)
+	if err != nil {
+		return nil, saprobe.PCMFormat{}, report, err
+	}
+
 	// Parse gapless info before decoding.
-	gapless := parseGaplessInfo(reader)
+	gapless := parseGaplessInfo(reader, stream.firstFrame())
+	report.Gapless, report.Delay, report.Padding = gapless.source, gapless.delay, gapless.padding
 
-	// Seek back to start for decoding.
-	if _, err := reader.Seek(0, io.SeekStart); err != nil {
-		return nil, saprobe.PCMFormat{}, fmt.Errorf("seeking to start: %w", err)
+	var buf []byte
+
+	if opts.Resilient {
+		buf, report.Damaged = decodeResilient(stream)
+	} else {
+		buf, err = decodeAll(stream)
 	}
 
-	decoder, err := gomp3.NewDecoder(reader)
 	if err != nil {
-		return nil, saprobe.PCMFormat{}, fmt.Errorf("creating mp3 decoder: %w", err)
+		return nil, saprobe.PCMFormat{}, report, err
 	}
 
 	format := saprobe.PCMFormat{
-		SampleRate: decoder.SampleRate(),
-		BitDepth:   saprobe.Depth16,
-		Channels:   channels,
+		SampleRate: stream.first.sampleRate,
+		BitDepth:   saprobe.Depth24,
+		Channels:   uint(stream.first.channels()), //nolint:gosec // one or two channels.
+		Layout:     stream.first.layout(),
 	}
 
-	// Pre-allocate output buffer when total length is known.
-	var buf []byte
-	if length := decoder.Length(); length > 0 {
-		buf = make([]byte, 0, length)
+	// The decoder emits every frame, XING frame included, before trimming.
+	missing := 0
+	if gapless.frames > 0 {
+		missing = max((gapless.frames+1)*gapless.frameSize-len(buf)/stream.sampleBytes, 0)
 	}
 
-	chunk := make([]byte, 32*1024)
+	gapless.fitLength(len(buf) / stream.sampleBytes)
+	report.Padding = gapless.padding
 
-	for {
-		readN, readErr := decoder.Read(chunk)
-		if readN > 0 {
-			buf = append(buf, chunk[:readN]...)
-		}
+	// Apply gapless trimming if we have valid info.
+	buf, trimmed := applyGaplessTrimming(buf, gapless, stream.sampleBytes)
 
-		if errors.Is(readErr, io.EOF) {
-			break
-		}
+	decoded := int64(len(buf) / stream.sampleBytes)
+	report.Damaged = shiftDamage(report.Damaged, int64(trimmed), decoded)
 
-		if readErr != nil {
-			return nil, saprobe.PCMFormat{}, fmt.Errorf("decoding mp3: %w", readErr)
-		}
+	// iTunSMPB declares the samples left after trimming.
+	declared := max(decoded+int64(missing), gapless.length)
+
+	err = saprobe.CheckTruncation(opts, &report.Report, declared, decoded, format.SampleRate)
+	if err != nil {
+		return nil, saprobe.PCMFormat{}, report, err
 	}
 
-	// Apply gapless trimming if we have valid info.
-	buf = applyGaplessTrimming(buf, gapless)
+	if opts.Conceal != saprobe.ConcealSilence {
+		saprobe.Conceal(buf, format, report.Damaged, opts.Conceal)
+	}
 
-	return buf, format, nil
+	return buf, format, report, nil
 }
 
-// applyGaplessTrimming removes encoder delay from start and padding from end.
-// For go-mp3 decoder, we need to account for:
-// 1. XING/Info frame being decoded as audio (1152 samples) if present
-// 2. go-mp3's synthesis filterbank priming delay (529 samples).
-func applyGaplessTrimming(buf []byte, info gaplessInfo) []byte {
-	if info.delay == 0 && info.padding == 0 && !info.hasXINGTag {
-		return buf
+// stream is the frame data of an MP3 file, between any ID3v2 tag and any trailing tags, with the
+// location of its frames.
+type stream struct {
+	data        []byte
+	base        int64       // file offset of data[0]
+	offsets     []int       // frame offsets within data
+	gaps        []syncGap   // junk skipped between frames, in order
+	first       frameHeader // header of the first frame, which sets the output format
+	freeBase    int         // length of the unpadded frames of a free-format stream, 0 otherwise
+	sampleBytes int         // decoded bytes per sample, all channels
+	frameBytes  int         // decoded bytes per frame
+}
+
+// readStream reads the frame data and locates its frames. Streams the decoder cannot handle are
+// rejected up front, from their first frame header, so that decoding errors further on can be
+// attributed to corruption.
+func readStream(reader io.ReadSeeker) (stream, error) {
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return stream{}, fmt.Errorf("seeking to start: %w", err)
 	}
 
-	// Calculate start trim: LAME delay + decoder delay + XING frame (if present).
-	startSamples := info.delay + decoderDelay
-	if info.hasXINGTag {
-		startSamples += samplesPerFrame
+	base := skipID3v2(reader)
+	if base < 0 {
+		return stream{}, errID3Skip
 	}
 
-	// Calculate end trim: LAME padding - decoder delay (decoder delay shifts from end to start).
-	endSamples := max(info.padding-decoderDelay, 0)
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return stream{}, fmt.Errorf("reading mp3 stream: %w", err)
+	}
+
+	// ID3v1, APE and Lyrics3 tags after the last frame are not audio.
+	end, _ := splitTrailers(data)
+	data = data[:end]
+
+	first, freeBase := findFirstFrame(data)
+	if first < 0 {
+		return stream{}, errNoFrames
+	}
+
+	hdr, _ := parseFrameHeader(data[first:])
+	sampleBytes := hdr.channels() * bytesPerSample
+	offsets, gaps := frameOffsets(data, first, freeBase)
+
+	return stream{
+		data:        data,
+		base:        int64(base),
+		offsets:     offsets,
+		gaps:        gaps,
+		first:       hdr,
+		freeBase:    freeBase,
+		sampleBytes: sampleBytes,
+		frameBytes:  hdr.samples() * sampleBytes,
+	}, nil
+}
+
+// frame returns frame idx, or false when the data ends before it does.
+func (s stream) frame(idx int) ([]byte, bool) {
+	start := s.offsets[idx]
+
+	hdr, _ := parseFrameHeader(s.data[start:])
+	if end := start + hdr.length(s.freeBase); end <= len(s.data) {
+		return s.data[start:end], true
+	}
+
+	return nil, false
+}
+
+// firstFrame returns the first frame, or nil when the data ends before it does.
+func (s stream) firstFrame() []byte {
+	frame, _ := s.frame(0)
+
+	return frame
+}
+
+// wholeFrames returns the number of frames the data holds entirely: only the last can be cut short.
+func (s stream) wholeFrames() int {
+	if _, ok := s.frame(len(s.offsets) - 1); !ok {
+		return len(s.offsets) - 1
+	}
+
+	return len(s.offsets)
+}
+
+// frameError locates a decoding failure at frame idx, which started at sample.
+func (s stream) frameError(idx int, sample int64, err error) *saprobe.DecodeError {
+	return &saprobe.DecodeError{
+		Codec:  codecName,
+		Kind:   saprobe.ErrCorrupt,
+		Offset: s.base + int64(s.offsets[idx]),
+		Frame:  idx,
+		Sample: sample,
+		Err:    err,
+	}
+}
+
+// decodeAll decodes every frame of the stream, failing on the first frame the decoder rejects. A
+// last frame cut short by the end of the data is dropped.
+func decodeAll(str stream) ([]byte, error) {
+	buf := make([]byte, 0, len(str.offsets)*str.frameBytes)
+
+	err := decodeFrames(str, func(_ int, pcm framePCM) bool {
+		buf = pcm.appendPCM(buf, 0, pcm.length)
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// decodeFrames decodes the whole frames of the stream in order, handing the samples of each to
+// emit with its index until it returns false. It fails on the first frame the decoder rejects.
+func decodeFrames(str stream, emit func(idx int, pcm framePCM) bool) error {
+	decoder := newFrameDecoder(str.first)
+	frameSamples := str.frameBytes / str.sampleBytes
+
+	for idx := range str.wholeFrames() {
+		frame, _ := str.frame(idx)
+
+		pcm, err := decoder.decode(frame)
+		if err != nil {
+			return str.frameError(idx, int64(idx*frameSamples), err)
+		}
+
+		if !emit(idx, pcm) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// shiftDamage moves damaged ranges by the samples trimmed from the start of the output, clamping
+// them to the trimmed length and dropping those that fell entirely in the trimmed parts.
+func shiftDamage(damaged []saprobe.Damage, trimmed, length int64) []saprobe.Damage {
+	kept := damaged[:0]
+
+	for _, damage := range damaged {
+		damage.Start = max(damage.Start-trimmed, 0)
+		damage.End = min(damage.End-trimmed, length)
+
+		if damage.Start < damage.End {
+			kept = append(kept, damage)
+		}
+	}
+
+	return kept
+}
+
+// applyGaplessTrimming removes encoder delay from start and padding from end, returning the
+// trimmed buffer and the number of samples removed from the start.
+// Every source counts encoder samples, like the LAME fields. On top of them, we need to account for:
+// 1. XING/Info frame being decoded as audio (one frame: 1152 samples, 576 for MPEG-2/2.5) if present
+// 2. the synthesis delay of the decoder (529 samples).
+func applyGaplessTrimming(buf []byte, info gaplessInfo, sampleBytes int) ([]byte, int) {
+	start, end := info.bounds(len(buf) / sampleBytes)
+
+	return buf[start*sampleBytes : end*sampleBytes], start
+}
 
-	startBytes := startSamples * bytesPerFrame
-	endBytes := endSamples * bytesPerFrame
-	totalTrim := startBytes + endBytes
+// bounds returns the range of the total decoded samples, end-exclusive, that gapless trimming keeps.
+func (info gaplessInfo) bounds(total int) (int, int) {
+	startSamples, endSamples := info.trim()
+	if info.hasXINGTag {
+		startSamples += info.frameSize
+	}
 
 	// Sanity check: don't trim more than we have.
-	if totalTrim >= len(buf) {
-		return buf
+	if startSamples+endSamples >= total {
+		return 0, total
 	}
 
-	return buf[startBytes : len(buf)-endBytes]
+	return startSamples, total - endSamples
 }
 
-// parseGaplessInfo attempts to extract LAME encoder delay and padding from the MP3 file.
-// Returns zero values if no LAME header is found.
-func parseGaplessInfo(reader io.ReadSeeker) gaplessInfo {
-	// Skip ID3v2 tag if present (can be very large, 100KB+).
-	id3Size := skipID3v2(reader)
-	if id3Size < 0 {
-		return gaplessInfo{}
+// trim returns the samples to remove from the start of the audio frames, after any XING/Info
+// frame, and from their end: the encoder delay plus the decoder delay, and the encoder padding
+// less the decoder delay, which shifts from end to start.
+func (info gaplessInfo) trim() (int, int) {
+	if info.delay == 0 && info.padding == 0 && !info.hasXINGTag {
+		return 0, 0
 	}
 
-	// Read enough bytes to find XING/LAME header (first frame is typically < 2KB).
-	header := make([]byte, headerBufSize)
-	bytesRead, err := reader.Read(header)
+	return info.delay + synthesisDelay, max(info.padding-synthesisDelay, 0)
+}
 
-	if err != nil || bytesRead < minHeaderBytes {
-		return gaplessInfo{}
+// fitLength sets the padding from the original sample count of iTunSMPB, given the total decoded
+// samples, when they hold that many after the delay: the count is what the encoder was given, the
+// padding a figure some encoders get wrong. A count the stream falls short of is left for
+// CheckTruncation to report.
+func (info *gaplessInfo) fitLength(total int) {
+	if info.hasXINGTag {
+		total -= info.frameSize
 	}
 
-	header = header[:bytesRead]
+	if info.length > 0 && int64(info.delay)+info.length <= int64(total) {
+		info.padding = total - info.delay - int(info.length)
+	}
+}
 
-	// Find first MPEG sync word.
-	syncPos := findSyncWord(header)
-	if syncPos < 0 || syncPos+4 > len(header) {
-		return gaplessInfo{}
+// parseGaplessInfo extracts the encoder delay and padding of the MP3 file, given its first frame,
+// from the first source found in priority order: the LAME tag, iTunSMPB, then the VBRI header.
+// Returns zero values if none is found.
+func parseGaplessInfo(reader io.ReadSeeker, first []byte) gaplessInfo {
+	info := parseInfoFrame(first)
+
+	if info.source == GaplessLAME {
+		return info
 	}
 
-	// Parse frame header to get side info size.
-	frameHeader := header[syncPos : syncPos+4]
-	sideInfoSize := getSideInfoSize(frameHeader)
+	if smpb, ok := parseITunSMPB(reader); ok {
+		info.source, info.delay, info.padding, info.length = smpb.source, smpb.delay, smpb.padding, smpb.length
+	}
 
-	if sideInfoSize < 0 {
+	return info
+}
+
+// parseInfoFrame reads the XING/Info header of the first frame and the LAME tag that follows it,
+// or a VBRI header.
+func parseInfoFrame(frame []byte) gaplessInfo {
+	// Parse frame header to get side info size.
+	hdr, ok := parseFrameHeader(frame)
+	if !ok || hdr.layer != layerIII {
 		return gaplessInfo{}
 	}
 
-	// XING header starts after frame header (4 bytes) + side info.
-	xingOffset := syncPos + 4 + sideInfoSize
-	if xingOffset+minXINGPlusLAME > len(header) {
+	// XING header starts after frame header (4 bytes), CRC (if protected) and side info.
+	xingOffset := hdr.xingOffset()
+	if xingOffset+xingPreambleSize > len(frame) {
 		return gaplessInfo{}
 	}
 
 	// Look for XING or Info tag.
-	xingData := header[xingOffset:]
+	xingData := frame[xingOffset:]
 	if !bytes.HasPrefix(xingData, []byte("Xing")) && !bytes.HasPrefix(xingData, []byte("Info")) {
-		return gaplessInfo{}
+		// A VBRI frame also decodes as a frame of silence.
+		vbri, found := parseVBRI(frame)
+		if found {
+			vbri.hasXINGTag, vbri.frameSize = true, hdr.samples()
+		}
+
+		return vbri
 	}
 
-	// XING/Info frame found - go-mp3 will decode this as audio.
+	// XING/Info frame found - it decodes as a frame of silence.
 	hasXING := true
 
+	var frames int
+
+	frameSize := hdr.samples()
+
+	flags := binary.BigEndian.Uint32(xingData[4:xingPreambleSize])
+	if flags&xingFlagFrames != 0 && len(xingData) >= xingPreambleSize+4 {
+		frames = int(binary.BigEndian.Uint32(xingData[xingPreambleSize:]))
+	}
+
 	// Parse XING header to find LAME tag offset.
 	// XING structure: "Xing" (4) + flags (4) + optional fields based on flags.
 	lameOffset := findLAMETag(xingData)
 	if lameOffset < 0 || lameOffset+lameTagMinSize > len(xingData) {
 		// XING present but no LAME tag - still need to skip the XING frame.
-		return gaplessInfo{hasXINGTag: hasXING}
+		return gaplessInfo{hasXINGTag: hasXING, frames: frames, frameSize: frameSize}
 	}
 
 	// LAME tag structure: "LAME" (4) + version (5) + ... + gapless info at offset 21-23.
 	// Gapless info: 24 bits = 12-bit delay + 12-bit padding.
 	lameData := xingData[lameOffset:]
 	if len(lameData) < lameTagMinSize {
-		return gaplessInfo{hasXINGTag: hasXING}
+		return gaplessInfo{hasXINGTag: hasXING, frames: frames, frameSize: frameSize}
 	}
 
 	// Gapless bytes are at offset 21 from LAME tag start.
@@ -240,9 +479,14 @@ func parseGaplessInfo(reader io.ReadSeeker) gaplessInfo {
 	padding := int(gapless24 & gaplessPaddingMask)
 
 	return gaplessInfo{
+		source:     GaplessLAME,
 		delay:      delay,
 		padding:    padding,
 		hasXINGTag: hasXING,
+		frames:     frames,
+		frameSize:  frameSize,
+		vbrMethod:  lameData[lameMethodOffset] & lameMethodMask,
+		replayGain: lameReplayGain(lameData),
 	}
 }
 
@@ -276,8 +520,11 @@ func skipID3v2(reader io.ReadSeeker) int {
 		header[9],
 	)
 
-	// Total tag size = header + size.
+	// Total tag size = header + size, plus the ID3v2.4 footer when present.
 	totalSize := id3v2HeaderSize + size
+	if header[5]&id3FlagFooter != 0 {
+		totalSize += id3v2HeaderSize
+	}
 
 	// Seek past the ID3v2 tag.
 	if _, err := reader.Seek(int64(totalSize), io.SeekStart); err != nil {
@@ -287,6 +534,102 @@ func skipID3v2(reader io.ReadSeeker) int {
 	return totalSize
 }
 
+// findFirstFrame locates the first frame of data and, for free-format streams, measures the
+// length of their unpadded frames (0 otherwise). Junk between the ID3v2 tag and the audio may
+// hold stray sync words, so a candidate only counts when another frame of the same stream
+// follows it, or when the data ends there.
+func findFirstFrame(data []byte) (int, int) {
+	for pos := 0; pos < len(data); {
+		next := findSyncWord(data[pos:])
+		if next < 0 {
+			return -1, 0
+		}
+
+		pos += next
+
+		if hdr, ok := parseFrameHeader(data[pos:]); ok {
+			if hdr.bitrate != bitrateFree && chains(hdr, data[pos:]) {
+				return pos, 0
+			}
+
+			if freeBase, found := measureFreeFormat(hdr, data[pos:]); found {
+				return pos, freeBase
+			}
+		}
+
+		pos++
+	}
+
+	return -1, 0
+}
+
+// chains reports whether the frame hdr, at the start of data, is followed by a frame of the same
+// stream, by trailing tags, or by the end of the data.
+func chains(hdr frameHeader, data []byte) bool {
+	size := hdr.size()
+	if size+frameHeaderSize > len(data) || isTrailer(data[size:]) {
+		return true
+	}
+
+	next, ok := parseFrameHeader(data[size:])
+
+	return ok && sameStream(hdr, next)
+}
+
+// sameStream reports whether two frame headers can belong to the same stream.
+func sameStream(a, b frameHeader) bool {
+	return a.version == b.version && a.layer == b.layer && a.sampleRate == b.sampleRate &&
+		(a.bitrate == bitrateFree) == (b.bitrate == bitrateFree)
+}
+
+// measureFreeFormat measures the length of the unpadded frames of a free-format stream, whose
+// first frame hdr starts data. The headers carry no bitrate, so the length is the distance to the
+// next frame, less the padding of the first one. A candidate sync word only counts when the
+// freeFormatChecks frames that follow, or those up to the end of the data, chain at the lengths
+// it implies.
+func measureFreeFormat(hdr frameHeader, data []byte) (int, bool) {
+	if hdr.bitrate != bitrateFree {
+		return 0, false
+	}
+
+	window := data[:min(len(data), maxFreeFormatSize+frameHeaderSize)]
+
+	for pos := frameHeaderSize; pos < len(window); pos++ {
+		next := findSyncWord(window[pos:])
+		if next < 0 {
+			break
+		}
+
+		pos += next
+
+		if freeBase := pos - hdr.padSize(); freeChains(hdr, freeBase, data) {
+			return freeBase, true
+		}
+	}
+
+	return 0, false
+}
+
+// freeChains reports whether the frames of a free-format stream with unpadded length freeBase
+// follow each other from the start of data.
+func freeChains(first frameHeader, freeBase int, data []byte) bool {
+	pos := 0
+
+	for range freeFormatChecks {
+		hdr, ok := parseFrameHeader(data[pos:])
+		if !ok || !sameStream(first, hdr) {
+			return false
+		}
+
+		pos += hdr.length(freeBase)
+		if pos+frameHeaderSize > len(data) || isTrailer(data[pos:]) {
+			return true
+		}
+	}
+
+	return true
+}
+
 // findSyncWord locates the first MPEG audio sync word (0xFF followed by 0xE0+).
 func findSyncWord(data []byte) int {
 	for i := range len(data) - 1 {
@@ -335,37 +678,6 @@ func isValidFrameHeader(header []byte) bool {
 	return true
 }
 
-// getSideInfoSize returns the side information size based on MPEG version and channel mode.
-// Returns -1 if the header is invalid.
-func getSideInfoSize(header []byte) int {
-	if len(header) < 4 {
-		return -1
-	}
-
-	versionBits := (header[1] >> 3) & 0x03
-	channelBits := (header[3] >> 6) & 0x03
-
-	isMono := channelBits == channelModeMono
-
-	// MPEG version: 00=2.5, 01=reserved, 10=2, 11=1.
-	switch versionBits {
-	case mpegVersion1:
-		if isMono {
-			return sideInfoMPEG1Mono
-		}
-
-		return sideInfoMPEG1Stereo
-	case mpegVersion2, mpegVersion25:
-		if isMono {
-			return sideInfoMPEG2Mono
-		}
-
-		return sideInfoMPEG2Stereo
-	default:
-		return -1
-	}
-}
-
 // findLAMETag locates the LAME tag within XING header data.
 // Returns offset from start of xingData, or -1 if not found.
 func findLAMETag(xingData []byte) int {
@@ -0,0 +1,125 @@
+package mp3_test
+
+import (
+	"bytes"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/mp3"
+)
+
+// TestDecodeTruncated checks that a stream holding fewer frames than its Xing header counts
+// reports the missing samples.
+func TestDecodeTruncated(t *testing.T) {
+	t.Parallel()
+
+	music := mp3.SilentFrames(6)
+	data := append(mp3.InfoFrame(mp3.LAMEFields{Frames: 10}, music, false), music...)
+
+	_, _, report, err := mp3.DecodeWithOptions(bytes.NewReader(data), saprobe.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Truncation == nil || report.Truncation.Missing() != 4*mp3.SilentFrameSamples {
+		t.Fatalf("truncation %+v, want %d samples missing", report.Truncation, 4*mp3.SilentFrameSamples)
+	}
+
+	_, _, _, err = mp3.DecodeWithOptions(bytes.NewReader(data), saprobe.Options{Strict: true})
+	if !errors.Is(err, saprobe.ErrTruncated) {
+		t.Errorf("strict decode: %v, want ErrTruncated", err)
+	}
+
+	complete := append(mp3.InfoFrame(mp3.LAMEFields{Frames: 6}, music, false), music...)
+
+	if _, _, report, err := mp3.DecodeWithOptions(bytes.NewReader(complete), saprobe.Options{}); err != nil ||
+		report.Truncation != nil {
+		t.Errorf("complete stream: %+v (%v), want no truncation", report.Truncation, err)
+	}
+}
+
+// TestDecodeFreeFormat rewrites the headers of CBR streams to the free-format bitrate index, and
+// checks that the frame length is measured from the distance between frames, padding aside, and
+// that the stream decodes to the same PCM.
+func TestDecodeFreeFormat(t *testing.T) {
+	t.Parallel()
+
+	random := rand.New(rand.NewPCG(1, 2)) //nolint:gosec // test signal.
+
+	// MPEG-1 Layer II, 256 kbit/s stereo at 48 kHz: unpadded frames of 768 bytes, whose first two
+	// subbands of both channels are allocated 3-bit samples of random scalefactors and values.
+	layerII := make([][]byte, 20)
+	for idx := range layerII {
+		layerII[idx] = make([]byte, 768)
+		copy(layerII[idx], []byte{0xFF, 0xFD, 0xC4, 0x00, 0x33, 0x33})
+
+		for pos := 4 + 24; pos < len(layerII[idx]); pos++ {
+			layerII[idx][pos] = byte(random.Uint32())
+		}
+	}
+
+	// Silent MPEG-1 Layer III frames at 128 kbit/s and 44.1 kHz, one in three padded to 418 bytes.
+	layerIII := make([][]byte, 20)
+	for idx := range layerIII {
+		layerIII[idx] = mp3.SilentFrames(1)
+
+		if idx%3 == 1 {
+			layerIII[idx] = append(layerIII[idx], 0)
+			layerIII[idx][2] |= 0x02
+		}
+	}
+
+	for _, test := range []struct {
+		name   string
+		frames [][]byte
+		length int
+	}{
+		{"Layer II", layerII, 768},
+		{"Layer III", layerIII, mp3.SilentFrameSize},
+	} {
+		cbr := bytes.Join(test.frames, nil)
+
+		free := make([][]byte, len(test.frames))
+		for idx, frame := range test.frames {
+			free[idx] = slices.Clone(frame)
+			free[idx][2] &= 0x0F
+		}
+
+		data := bytes.Join(free, nil)
+
+		if length, ok := mp3.MeasureFreeFormat(data); !ok || length != test.length {
+			t.Errorf("%s: measured %d (%t), want %d", test.name, length, ok, test.length)
+		}
+
+		want, _, err := mp3.Decode(bytes.NewReader(cbr))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pcm, _, err := mp3.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if !bytes.Equal(pcm, want) {
+			t.Errorf("%s: decoded %d bytes, unlike the CBR stream's %d", test.name, len(pcm), len(want))
+		}
+
+		cbrIndex, err := mp3.Index(bytes.NewReader(cbr))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		index, err := mp3.Index(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !slices.Equal(index.Frames, cbrIndex.Frames) {
+			t.Errorf("%s: indexed %v, want %v", test.name, index.Frames, cbrIndex.Frames)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package mp3
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Internals of the package the tests use.
+const (
+	FrameHeaderSize    = frameHeaderSize
+	FrameCRCSize       = frameCRCSize
+	SynthesisDelay     = synthesisDelay
+	VBRIOffset         = vbriOffset
+	VBRIDelayOffset    = vbriDelayOffset
+	VBRIFramesField    = vbriFramesField
+	LAMEMethodABR      = lameMethodABR
+	LAMEMethodABR2Pass = lameMethodABR2Pass
+	ID3EncodingLatin1  = id3EncodingLatin1
+	ID3EncodingUTF16   = id3EncodingUTF16
+	ID3v1Size          = id3v1Size
+	APEFooterSize      = apeFooterSize
+	APEFlagHasHeader   = apeFlagHasHeader
+	APEItemTypeShift   = apeItemTypeShift
+	APEItemBinary      = apeItemBinary
+)
+
+//nolint:gochecknoglobals // test exports
+var (
+	ErrSyncLost   = errSyncLost
+	BitrateModeOf = bitrateMode
+)
+
+// MeasureFreeFormat returns the length of the unpadded frames of the free-format stream data
+// starts with, and whether it could measure it.
+func MeasureFreeFormat(data []byte) (int, bool) {
+	hdr, _ := parseFrameHeader(data)
+
+	return measureFreeFormat(hdr, data)
+}
+
+// ParseInfoFrame returns the frame count and the samples per frame of the XING/Info or VBRI header
+// of frame, and whether it has one.
+func ParseInfoFrame(frame []byte) (int, int, bool) {
+	info := parseInfoFrame(frame)
+
+	return info.frames, info.frameSize, info.hasXINGTag
+}
+
+// SplitTrailers returns the length of data before its trailing tags, whether they include an
+// ID3v1 tag, and the number of APE items.
+func SplitTrailers(data []byte) (int, bool, int) {
+	end, found := splitTrailers(data)
+
+	return end, found.id3v1 != nil, found.apeItems
+}
+
+// Silent MPEG-1 Layer III frames: 128 kbit/s at 44.1 kHz, mono, without CRC. Zeroed side information
+// and main data decode to silence.
+const (
+	SilentFrameSize    = 417
+	SilentFrameSamples = 1152
+)
+
+//nolint:gochecknoglobals // constant table
+var SilentHeader = []byte{0xFF, 0xFB, 0x90, 0xC0}
+
+// SilentFrames returns count silent frames, back to back.
+func SilentFrames(count int) []byte {
+	frame := make([]byte, SilentFrameSize)
+	copy(frame, SilentHeader)
+
+	return bytes.Repeat(frame, count)
+}
+
+// Protect clears the protection bit of every frame in frames (silent frames, back to back) and
+// stores their CRC-16 after the header.
+func Protect(frames []byte) []byte {
+	for pos := 0; pos+SilentFrameSize <= len(frames); pos += SilentFrameSize {
+		frame := frames[pos : pos+SilentFrameSize]
+		frame[1] &^= 0x01
+
+		hdr, _ := parseFrameHeader(frame)
+		crc := crc16MPEG(crc16InitMPEG, frame[2:frameHeaderSize])
+		crc = crc16MPEG(crc, frame[frameHeaderSize+frameCRCSize:frameHeaderSize+frameCRCSize+hdr.sideInfoSize()])
+		binary.BigEndian.PutUint16(frame[frameHeaderSize:], crc)
+	}
+
+	return frames
+}
+
+// LAMEFields are the LAME tag fields InfoFrame fills in.
+type LAMEFields struct {
+	Frames         int    // Xing frame count, the Info frame aside
+	Delay, Padding int    // Gapless delay and padding, in samples
+	Peak           uint32 // 9.23 fixed point
+	TrackGain      uint16 // Radio ReplayGain field
+	AlbumGain      uint16 // Audiophile ReplayGain field
+}
+
+// InfoFrame returns a silent frame carrying an Info header with a frame count and a LAME tag
+// describing music, the frames that follow it. The frame has a CRC-16 when protected is set.
+func InfoFrame(fields LAMEFields, music []byte, protected bool) []byte {
+	frame := SilentFrames(1)
+	if protected {
+		Protect(frame)
+	}
+
+	hdr, _ := parseFrameHeader(frame)
+	xing := frame[hdr.xingOffset():]
+	copy(xing, "Info")
+	binary.BigEndian.PutUint32(xing[4:], xingFlagFrames)
+	binary.BigEndian.PutUint32(xing[xingPreambleSize:], uint32(fields.Frames)) //nolint:gosec // small.
+
+	lameStart := hdr.xingOffset() + xingPreambleSize + 4
+	lame := frame[lameStart:]
+	copy(lame, "LAME3.100")
+	binary.BigEndian.PutUint32(lame[lamePeakOffset:], fields.Peak)
+	binary.BigEndian.PutUint16(lame[lameTrackGain:], fields.TrackGain)
+	binary.BigEndian.PutUint16(lame[lameAlbumGain:], fields.AlbumGain)
+	lame[lameGaplessOffset] = byte(fields.Delay >> 4)
+	lame[lameGaplessOffset+1] = byte(fields.Delay<<4 | fields.Padding>>8)
+	lame[lameGaplessOffset+2] = byte(fields.Padding)
+	binary.BigEndian.PutUint32(lame[lameMusicLengthOffset:], uint32(len(frame)+len(music))) //nolint:gosec // small.
+	binary.BigEndian.PutUint16(lame[lameMusicCRCOffset:], crc16LAME(music))
+	binary.BigEndian.PutUint16(lame[lameTagCRCOffset:], crc16LAME(frame[:lameStart+lameTagCRCOffset]))
+
+	return frame
+}
@@ -0,0 +1,213 @@
+package mp3
+
+import "github.com/farcloser/saprobe"
+
+// MPEG audio layers, as stored in the 2-bit layer field.
+const (
+	layerIII = 0x01
+	layerII  = 0x02
+	layerI   = 0x03
+)
+
+// Joint stereo mode extension bits.
+const (
+	channelModeJoint = 0x01
+	modeExtIntensity = 0x01
+	modeExtMS        = 0x02
+)
+
+// Frame header layout.
+const (
+	frameHeaderSize  = 4
+	frameCRCSize     = 2
+	bitrateFree      = 0x00
+	sampleRateRsvd   = 0x03
+	samplesLayerI    = 384
+	samplesLayerII   = 1152
+	samplesMPEG2LIII = 576
+	slotBytesLayerI  = 4
+)
+
+// Bitrates in kbit/s, indexed by [MPEG-1 ? 0 : 1][layer index (I=0, II=1, III=2)][bitrate index].
+//
+//nolint:gochecknoglobals // constant table
+var bitrates = [2][3][15]int{
+	{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	},
+	{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	},
+}
+
+// Sample rates in Hz, indexed by the 2-bit version field and the sample rate index.
+//
+//nolint:gochecknoglobals // constant table
+var sampleRates = [4][3]int{
+	mpegVersion25: {11025, 12000, 8000},
+	mpegVersion2:  {22050, 24000, 16000},
+	mpegVersion1:  {44100, 48000, 32000},
+}
+
+// frameHeader is a decoded 4-byte MPEG audio frame header.
+type frameHeader struct {
+	version     byte // mpegVersion1, mpegVersion2 or mpegVersion25
+	layer       byte // layerI, layerII or layerIII
+	protected   bool // a CRC-16 follows the header
+	bitrate     int  // kbit/s; 0 for free format
+	sampleRate  int
+	rateIndex   int // sample rate index within the version
+	padding     bool
+	channelMode byte
+	modeExt     byte // joint stereo mode extension: modeExtIntensity, modeExtMS
+}
+
+// parseFrameHeader decodes the frame header at the start of data.
+func parseFrameHeader(data []byte) (frameHeader, bool) {
+	if len(data) < frameHeaderSize || !isValidFrameHeader(data[:frameHeaderSize]) {
+		return frameHeader{}, false
+	}
+
+	hdr := frameHeader{
+		version:     (data[1] >> 3) & 0x03,
+		layer:       (data[1] >> 1) & 0x03,
+		protected:   data[1]&0x01 == 0,
+		padding:     (data[2]>>1)&0x01 == 1,
+		channelMode: (data[3] >> 6) & 0x03,
+		modeExt:     (data[3] >> 4) & 0x03,
+	}
+
+	rateIdx := (data[2] >> 2) & 0x03
+	if rateIdx == sampleRateRsvd {
+		return frameHeader{}, false
+	}
+
+	hdr.rateIndex = int(rateIdx)
+	hdr.sampleRate = sampleRates[hdr.version][rateIdx]
+	hdr.bitrate = bitrates[hdr.tableVersion()][hdr.tableLayer()][(data[2]>>4)&0x0F]
+
+	return hdr, true
+}
+
+func (h frameHeader) tableVersion() int {
+	if h.version == mpegVersion1 {
+		return 0
+	}
+
+	return 1
+}
+
+func (h frameHeader) tableLayer() int {
+	return int(layerI - h.layer)
+}
+
+// channels returns the number of channels coded in the frame.
+func (h frameHeader) channels() int {
+	if h.channelMode == channelModeMono {
+		return 1
+	}
+
+	return 2
+}
+
+// layout returns the speaker assignment of the channels.
+func (h frameHeader) layout() saprobe.ChannelLayout {
+	if h.channelMode == channelModeMono {
+		return saprobe.LayoutMono
+	}
+
+	return saprobe.LayoutStereo
+}
+
+// samples returns the number of samples per channel carried by one frame.
+func (h frameHeader) samples() int {
+	switch {
+	case h.layer == layerI:
+		return samplesLayerI
+	case h.layer == layerIII && h.version != mpegVersion1:
+		return samplesMPEG2LIII
+	default:
+		return samplesLayerII
+	}
+}
+
+// size returns the total frame length in bytes, header included, or 0 for free-format frames.
+func (h frameHeader) size() int {
+	if h.bitrate == bitrateFree {
+		return 0
+	}
+
+	if h.layer == layerI {
+		return 12*h.bitrate*1000/h.sampleRate*slotBytesLayerI + h.padSize()
+	}
+
+	// Bytes per frame is samples / 8 * bitrate / sampleRate.
+	return h.samples()/8*h.bitrate*1000/h.sampleRate + h.padSize()
+}
+
+// length returns the frame length in bytes, header included. Free-format headers carry no
+// bitrate: their frames take freeBase, the length of the unpadded frames of the stream as
+// measureFreeFormat found it, plus their padding. Without it, length is 0 like size.
+func (h frameHeader) length(freeBase int) int {
+	if h.bitrate != bitrateFree || freeBase == 0 {
+		return h.size()
+	}
+
+	return freeBase + h.padSize()
+}
+
+// padSize returns the length of the padding of the frame: one 4-byte slot for Layer I, one byte
+// otherwise.
+func (h frameHeader) padSize() int {
+	switch {
+	case !h.padding:
+		return 0
+	case h.layer == layerI:
+		return slotBytesLayerI
+	default:
+		return 1
+	}
+}
+
+// impliedBitrate returns the bitrate, in kbit/s and rounded, that a frame of the given length
+// codes at: the bitrate of a free-format frame.
+func (h frameHeader) impliedBitrate(length int) int {
+	// A frame is bitrate * perKbps / sampleRate bytes long, padding aside.
+	perKbps := h.samples() / 8 * 1000
+	if h.layer == layerI {
+		perKbps = 12 * slotBytesLayerI * 1000
+	}
+
+	return ((length-h.padSize())*h.sampleRate + perKbps/2) / perKbps
+}
+
+// xingOffset returns the offset of a Xing or Info header in the frame: after the header, the CRC
+// when the frame is protected, and the side information.
+func (h frameHeader) xingOffset() int {
+	offset := frameHeaderSize + h.sideInfoSize()
+	if h.protected {
+		offset += frameCRCSize
+	}
+
+	return offset
+}
+
+// sideInfoSize returns the Layer III side information length in bytes.
+func (h frameHeader) sideInfoSize() int {
+	mono := h.channelMode == channelModeMono
+
+	switch {
+	case h.version == mpegVersion1 && mono:
+		return sideInfoMPEG1Mono
+	case h.version == mpegVersion1:
+		return sideInfoMPEG1Stereo
+	case mono:
+		return sideInfoMPEG2Mono
+	default:
+		return sideInfoMPEG2Stereo
+	}
+}
@@ -0,0 +1,118 @@
+package mp3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// GaplessSource identifies the metadata that supplied the encoder delay and padding.
+type GaplessSource uint8
+
+// Gapless metadata sources, in priority order. The LAME tag is written by the encoder that
+// produced the frames and counts samples exactly; iTunSMPB is written by iTunes, possibly after
+// the fact; the VBRI header only carries a delay.
+const (
+	// GaplessNone means no gapless metadata was found, and nothing is trimmed.
+	GaplessNone GaplessSource = iota
+	// GaplessLAME is the LAME tag following a Xing/Info header.
+	GaplessLAME
+	// GaplessITunSMPB is the iTunSMPB comment of an ID3v2 tag.
+	GaplessITunSMPB
+	// GaplessVBRI is the Fraunhofer VBRI header.
+	GaplessVBRI
+)
+
+// String returns a human-readable name for the source.
+func (s GaplessSource) String() string {
+	switch s {
+	case GaplessNone:
+		return "none"
+	case GaplessLAME:
+		return "LAME tag"
+	case GaplessITunSMPB:
+		return "iTunSMPB"
+	case GaplessVBRI:
+		return "VBRI header"
+	}
+
+	return "unknown"
+}
+
+// VBRI header layout. The header sits at a fixed offset from the frame start, whatever the
+// MPEG version and channel mode.
+const (
+	vbriOffset      = frameHeaderSize + 32
+	vbriSize        = 26
+	vbriDelayOffset = 6
+	vbriFramesField = 14
+)
+
+// iTunSMPB holds a zero, the delay and the padding, then the original sample count.
+const (
+	itunSMPBFields      = 3
+	itunSMPBLengthField = 3
+)
+
+// parseVBRI reads the encoder delay and frame count of a VBRI header in frame.
+func parseVBRI(frame []byte) (gaplessInfo, bool) {
+	if len(frame) < vbriOffset+vbriSize || !bytes.HasPrefix(frame[vbriOffset:], []byte("VBRI")) {
+		return gaplessInfo{}, false
+	}
+
+	vbri := frame[vbriOffset:]
+
+	return gaplessInfo{
+		source: GaplessVBRI,
+		delay:  int(binary.BigEndian.Uint16(vbri[vbriDelayOffset:])),
+		frames: int(binary.BigEndian.Uint32(vbri[vbriFramesField:])),
+	}, true
+}
+
+// parseITunSMPB reads the encoder delay and padding from the iTunSMPB comment of the ID3v2 tag
+// at the start of the file, with the original sample count when the comment has it. The comment
+// holds space-separated hexadecimal fields.
+func parseITunSMPB(reader io.ReadSeeker) (gaplessInfo, bool) {
+	tag, ok := readID3v2(reader)
+	if !ok {
+		return gaplessInfo{}, false
+	}
+
+	for id, body := range tag.frames() {
+		if id != "COMM" && id != "COM" {
+			continue
+		}
+
+		desc, text, ok := parseComment(body)
+		if !ok || desc != "iTunSMPB" {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) < itunSMPBFields {
+			return gaplessInfo{}, false
+		}
+
+		delay, errDelay := strconv.ParseUint(fields[1], 16, 32)
+		padding, errPadding := strconv.ParseUint(fields[2], 16, 32)
+
+		if errDelay != nil || errPadding != nil {
+			return gaplessInfo{}, false
+		}
+
+		info := gaplessInfo{source: GaplessITunSMPB, delay: int(delay), padding: int(padding)}
+
+		// The count is only a cross-check: a comment without it still trims.
+		if len(fields) > itunSMPBLengthField {
+			if length, err := strconv.ParseUint(fields[itunSMPBLengthField], 16, 63); err == nil {
+				info.length = int64(length) //nolint:gosec // 63 bits.
+			}
+		}
+
+		return info, true
+	}
+
+	return gaplessInfo{}, false
+}
@@ -0,0 +1,135 @@
+package mp3_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/mp3"
+)
+
+// smpb is an iTunSMPB comment: a delay of 0x210 samples, a padding of 0x3C0 and 0x2730 samples,
+// what remains of 10 frames.
+const smpb = " 00000000 00000210 000003C0 0000000000002730 00000000 00000000"
+
+// decodeGapless decodes data, returning its length in samples per channel and the report.
+func decodeGapless(t *testing.T, data []byte) (int, mp3.Report) {
+	t.Helper()
+
+	pcm, format, report, err := mp3.DecodeWithOptions(bytes.NewReader(data), saprobe.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return len(pcm) / int(format.Channels) / format.BitDepth.BytesPerSample(), report
+}
+
+// TestGaplessITunSMPB checks that the delay and padding of an iTunSMPB comment are trimmed,
+// whatever the ID3v2 version and text encoding that carry it, and that a LAME tag has priority.
+func TestGaplessITunSMPB(t *testing.T) {
+	t.Parallel()
+
+	music := mp3.SilentFrames(10)
+
+	for _, test := range []struct {
+		name string
+		tag  []byte
+	}{
+		{"ID3v2.2 Latin-1", id3v2(2, id3Frame{"COM", comm(latin1Text("iTunSMPB", smpb))})},
+		{"ID3v2.3 UTF-16", id3v2(3,
+			id3Frame{"TIT2", latin1Text("Saprobe")},
+			id3Frame{"COMM", comm(latin1Text("", "a comment"))},
+			id3Frame{"COMM", comm(utf16Text("iTunSMPB", smpb))},
+		)},
+		{"ID3v2.4 Latin-1", id3v2(4, id3Frame{"COMM", comm(latin1Text("iTunSMPB", smpb))})},
+	} {
+		samples, report := decodeGapless(t, append(slices.Clone(test.tag), music...))
+
+		// 10 frames of 1152 samples, less a delay of 0x210 and a padding of 0x3C0.
+		if report.Gapless != mp3.GaplessITunSMPB || report.Delay != 528 || report.Padding != 960 ||
+			samples != 10*mp3.SilentFrameSamples-528-960 {
+			t.Errorf("%s: %s, delay %d, padding %d, %d samples, want iTunSMPB, 528, 960 and %d",
+				test.name, report.Gapless, report.Delay, report.Padding, samples, 10*mp3.SilentFrameSamples-528-960)
+		}
+	}
+
+	tag := id3v2(3, id3Frame{"COMM", comm(latin1Text("iTunSMPB", smpb))})
+	stream := append(mp3.InfoFrame(mp3.LAMEFields{Frames: 10, Delay: 576, Padding: 1000}, music, false), music...)
+
+	_, report := decodeGapless(t, append(tag, stream...))
+	if report.Gapless != mp3.GaplessLAME || report.Delay != 576 || report.Padding != 1000 {
+		t.Errorf("%s, delay %d, padding %d, want the LAME tag", report.Gapless, report.Delay, report.Padding)
+	}
+}
+
+// TestGaplessITunSMPBLength checks that the original sample count of iTunSMPB overrides a padding
+// it disagrees with, and that a count the stream falls short of is reported as a truncation.
+func TestGaplessITunSMPBLength(t *testing.T) {
+	t.Parallel()
+
+	music := mp3.SilentFrames(10)
+
+	for _, test := range []struct {
+		name      string
+		smpb      string
+		samples   int
+		padding   int
+		truncated bool
+	}{
+		// 10 frames of 1152 samples hold 10000 after a delay of 0x210: the padding is 992.
+		{"shorter", " 00000000 00000210 000003C0 0000000000002710", 10000, 992, false},
+		// 0x2D00 samples do not fit: the padding of 0x3C0 is trimmed, and the rest is missing.
+		{"longer", " 00000000 00000210 000003C0 0000000000002D00", 10032, 960, true},
+	} {
+		data := append(id3v2(3, id3Frame{"COMM", comm(latin1Text("iTunSMPB", test.smpb))}), music...)
+
+		samples, report := decodeGapless(t, data)
+		if samples != test.samples || report.Padding != test.padding || (report.Truncation != nil) != test.truncated {
+			t.Errorf("%s: %d samples, padding %d, truncation %v, want %d samples, padding %d",
+				test.name, samples, report.Padding, report.Truncation, test.samples, test.padding)
+		}
+
+		_, _, _, err := mp3.DecodeWithOptions(bytes.NewReader(data), saprobe.Options{Strict: true})
+		if (err != nil) != test.truncated {
+			t.Errorf("%s: strict decode: %v", test.name, err)
+		}
+
+		index, err := mp3.Index(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if index.Samples() != int64(test.samples) || index.Padding != test.padding {
+			t.Errorf("%s: index counts %d samples, padding %d, want %d and %d",
+				test.name, index.Samples(), index.Padding, test.samples, test.padding)
+		}
+	}
+}
+
+// TestGaplessVBRI checks that a VBRI frame is dropped from the output with its encoder delay, and
+// that its frame count is read.
+func TestGaplessVBRI(t *testing.T) {
+	t.Parallel()
+
+	vbri := mp3.SilentFrames(1)
+	copy(vbri[mp3.VBRIOffset:], "VBRI")
+	binary.BigEndian.PutUint16(vbri[mp3.VBRIOffset+4:], 1) // version
+	binary.BigEndian.PutUint16(vbri[mp3.VBRIOffset+mp3.VBRIDelayOffset:], 1105)
+	binary.BigEndian.PutUint32(vbri[mp3.VBRIOffset+mp3.VBRIFramesField:], 10)
+
+	samples, report := decodeGapless(t, append(vbri, mp3.SilentFrames(10)...))
+
+	// The VBRI frame, then the encoder delay and the decoder delay, are trimmed from the start.
+	want := 10*mp3.SilentFrameSamples - 1105 - mp3.SynthesisDelay
+	if report.Gapless != mp3.GaplessVBRI || report.Delay != 1105 || samples != want {
+		t.Errorf("%s, delay %d, %d samples, want VBRI, 1105 and %d", report.Gapless, report.Delay, samples, want)
+	}
+
+	frames, frameSize, found := mp3.ParseInfoFrame(vbri)
+	if frames != 10 || !found || frameSize != mp3.SilentFrameSamples {
+		t.Errorf("parsed %d frames of %d samples (%t), want 10 frames of %d samples",
+			frames, frameSize, found, mp3.SilentFrameSamples)
+	}
+}
@@ -0,0 +1,179 @@
+package mp3
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	errHuffmanTable = errors.New("mp3: reserved Huffman table")
+	errHuffmanCode  = errors.New("mp3: invalid Huffman code")
+)
+
+// huffCode is a Huffman codeword: its length in bits and its bits.
+type huffCode struct {
+	len  uint8
+	code uint32
+}
+
+// huffTree decodes the codewords of one table. Each node holds its two children: a negative child
+// is a leaf carrying the value -child-1, and zero marks a bit sequence no codeword starts with.
+type huffTree [][2]int32
+
+// newHuffTree builds the decoding tree of codes, the value of each code being its index.
+func newHuffTree(codes []huffCode) huffTree {
+	tree := huffTree{{}}
+
+	for value, code := range codes {
+		node := 0
+
+		for bit := int(code.len) - 1; bit > 0; bit-- {
+			branch := (code.code >> bit) & 1
+			if tree[node][branch] == 0 {
+				tree = append(tree, [2]int32{})
+				tree[node][branch] = int32(len(tree) - 1) //nolint:gosec // trees have at most 512 nodes.
+			}
+
+			node = int(tree[node][branch])
+		}
+
+		tree[node][code.code&1] = int32(-value - 1) //nolint:gosec // values are below 256.
+	}
+
+	return tree
+}
+
+// decode reads one codeword and returns its value.
+func (t huffTree) decode(br *bitReader) (int, error) {
+	var node int32
+
+	for {
+		child := t[node][br.bit()]
+
+		switch {
+		case child < 0:
+			return int(-child - 1), nil
+		case child == 0:
+			return 0, errHuffmanCode
+		}
+
+		node = child
+	}
+}
+
+// pairTable is a big-values Huffman table. Its values code the pair (x, y) as x*ylen+y, and
+// magnitudes of 15 are extended by linbits raw bits.
+type pairTable struct {
+	tree    huffTree
+	ylen    int
+	linbits int
+}
+
+//nolint:gochecknoglobals // computed constant tables
+var (
+	tree16 = newHuffTree(huffCodes16)
+	tree24 = newHuffTree(huffCodes24)
+
+	// pairTables is indexed by table_select. Table 0 codes nothing; tables 4 and 14 are reserved.
+	pairTables = [32]pairTable{
+		1:  {newHuffTree(huffCodes1), 2, 0},
+		2:  {newHuffTree(huffCodes2), 3, 0},
+		3:  {newHuffTree(huffCodes3), 3, 0},
+		5:  {newHuffTree(huffCodes5), 4, 0},
+		6:  {newHuffTree(huffCodes6), 4, 0},
+		7:  {newHuffTree(huffCodes7), 6, 0},
+		8:  {newHuffTree(huffCodes8), 6, 0},
+		9:  {newHuffTree(huffCodes9), 6, 0},
+		10: {newHuffTree(huffCodes10), 8, 0},
+		11: {newHuffTree(huffCodes11), 8, 0},
+		12: {newHuffTree(huffCodes12), 8, 0},
+		13: {newHuffTree(huffCodes13), 16, 0},
+		15: {newHuffTree(huffCodes15), 16, 0},
+		16: {tree16, 16, 1},
+		17: {tree16, 16, 2},
+		18: {tree16, 16, 3},
+		19: {tree16, 16, 4},
+		20: {tree16, 16, 6},
+		21: {tree16, 16, 8},
+		22: {tree16, 16, 10},
+		23: {tree16, 16, 13},
+		24: {tree24, 16, 4},
+		25: {tree24, 16, 5},
+		26: {tree24, 16, 6},
+		27: {tree24, 16, 7},
+		28: {tree24, 16, 8},
+		29: {tree24, 16, 9},
+		30: {tree24, 16, 11},
+		31: {tree24, 16, 13},
+	}
+
+	// quadTrees is indexed by count1table_select.
+	quadTrees = [2]huffTree{newHuffTree(huffCodesA), newHuffTree(huffCodesB)}
+)
+
+// readPairs decodes big-values pairs with table until values is full.
+func readPairs(br *bitReader, table int, values []int) error {
+	if table == 0 {
+		clear(values)
+
+		return nil
+	}
+
+	spec := pairTables[table]
+	if spec.tree == nil {
+		return fmt.Errorf("%w %d", errHuffmanTable, table)
+	}
+
+	for i := 0; i+1 < len(values); i += 2 {
+		pair, err := spec.tree.decode(br)
+		if err != nil {
+			return err
+		}
+
+		values[i] = readMagnitude(br, pair/spec.ylen, spec.linbits)
+		values[i+1] = readMagnitude(br, pair%spec.ylen, spec.linbits)
+	}
+
+	return nil
+}
+
+// readQuads decodes count1 quadruples with table until the granule data ends at bit end or values
+// is full, and returns the number of lines decoded. The remaining lines are zeroed.
+func readQuads(br *bitReader, table int, values []int, end int) (int, error) {
+	count := 0
+
+	for count+4 <= len(values) && br.pos < end {
+		quad, err := quadTrees[table].decode(br)
+		if err != nil {
+			return 0, err
+		}
+
+		for i := range 4 {
+			values[count+i] = readMagnitude(br, quad>>(3-i)&1, 0)
+		}
+
+		// A codeword running past the granule data belongs to no line.
+		if br.pos > end {
+			break
+		}
+
+		count += 4
+	}
+
+	clear(values[count:])
+
+	return count, nil
+}
+
+// readMagnitude completes a Huffman-coded magnitude with its linbits extension and sign bit.
+func readMagnitude(br *bitReader, value, linbits int) int {
+	if linbits > 0 && value == 15 {
+		value += br.read(linbits)
+	}
+
+	if value != 0 && br.flag() {
+		return -value
+	}
+
+	return value
+}
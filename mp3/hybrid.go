@@ -0,0 +1,287 @@
+package mp3
+
+import "math"
+
+const (
+	subbands       = 32
+	subbandLines   = 18
+	shortLines     = 6
+	aliasButterfly = 8
+	maxMagnitude   = 15 + 1<<13 // largest big value: 15 plus 13 linbits
+	gainBias       = 210
+)
+
+//nolint:gochecknoglobals // computed constant tables
+var (
+	// pow43 maps a Huffman magnitude to magnitude^4/3.
+	pow43 = func() []float64 {
+		table := make([]float64, maxMagnitude)
+		for value := range table {
+			table[value] = math.Pow(float64(value), 4.0/3.0)
+		}
+
+		return table
+	}()
+
+	// aliasCS and aliasCA are the anti-aliasing butterfly coefficients (ISO/IEC 11172-3 Table B.9).
+	aliasCS, aliasCA = func() ([aliasButterfly]float64, [aliasButterfly]float64) {
+		var cs, ca [aliasButterfly]float64
+
+		for i, coef := range [aliasButterfly]float64{-0.6, -0.535, -0.33, -0.185, -0.095, -0.041, -0.0142, -0.0037} {
+			norm := math.Sqrt(1 + coef*coef)
+			cs[i], ca[i] = 1/norm, coef/norm
+		}
+
+		return cs, ca
+	}()
+
+	// imdctWindows holds the 36-sample windows of block types 0, 1 and 3, and in slot 2 the
+	// 12-sample window of short blocks.
+	imdctWindows = func() [4][36]float64 {
+		var windows [4][36]float64
+
+		for i := range 36 {
+			windows[blockNormal][i] = math.Sin(math.Pi / 36 * (float64(i) + 0.5))
+		}
+
+		for i := range 18 {
+			windows[blockStart][i] = windows[blockNormal][i]
+			windows[blockStop][i+18] = windows[blockNormal][i+18]
+		}
+
+		for i := range 6 {
+			windows[blockStart][18+i] = 1
+			windows[blockStart][24+i] = math.Sin(math.Pi / 12 * (float64(i) + 6.5))
+			windows[blockStop][6+i] = math.Sin(math.Pi / 12 * (float64(i) + 0.5))
+			windows[blockStop][12+i] = 1
+		}
+
+		for i := range 12 {
+			windows[blockShort][i] = math.Sin(math.Pi / 12 * (float64(i) + 0.5))
+		}
+
+		return windows
+	}()
+
+	// imdctLong and imdctShort are the IMDCT kernels of 18 and 6 coefficients.
+	imdctLong = func() [36][subbandLines]float64 {
+		var kernel [36][subbandLines]float64
+
+		for i := range 36 {
+			for k := range subbandLines {
+				kernel[i][k] = math.Cos(math.Pi / 72 * float64((2*i+1+18)*(2*k+1)))
+			}
+		}
+
+		return kernel
+	}()
+
+	imdctShort = func() [12][shortLines]float64 {
+		var kernel [12][shortLines]float64
+
+		for i := range 12 {
+			for k := range shortLines {
+				kernel[i][k] = math.Cos(math.Pi / 24 * float64((2*i+1+6)*(2*k+1)))
+			}
+		}
+
+		return kernel
+	}()
+)
+
+// requantize scales the Huffman values of a granule into spectral lines, by the global gain, the
+// subblock gains and the scalefactors. Short windows keep their coded layout: each band holds its
+// three windows one after the other. Only the first nonZero lines can be non-zero.
+func requantize(
+	hdr frameHeader,
+	gran *granule,
+	sf *scalefactors,
+	values *[granuleLines]int,
+	nonZero int,
+	lines *[granuleLines]float64,
+) {
+	bands := hdr.bands()
+
+	// Scalefactors step by 2^-1/2, or 2^-1 with scalefac_scale; exponents are in quarters.
+	sfStep := 2
+	if gran.scalefacScale {
+		sfStep = 4
+	}
+
+	gain := gran.globalGain - gainBias
+	longEnd, shortStart := longBands, shortBands
+
+	if gran.shortBlocks() {
+		longEnd, shortStart = 0, 0
+
+		if gran.mixed {
+			longEnd, shortStart = hdr.mixedLongEnd(), mixedShortStart
+		}
+	}
+
+	clear(lines[:])
+
+	for sfb := 0; sfb < longEnd && bands.long[sfb] < nonZero; sfb++ {
+		factor := sf.long[sfb]
+		if gran.preflag {
+			factor += pretab[sfb]
+		}
+
+		scale := math.Exp2(float64(gain-sfStep*factor) / 4)
+
+		for line := bands.long[sfb]; line < bands.long[sfb+1]; line++ {
+			lines[line] = dequantize(values[line], scale)
+		}
+	}
+
+	for sfb := shortStart; sfb < shortBands && bands.short[sfb]*shortWindows < nonZero; sfb++ {
+		width := bands.short[sfb+1] - bands.short[sfb]
+		base := bands.short[sfb] * shortWindows
+
+		for window := range shortWindows {
+			scale := math.Exp2(float64(gain-8*gran.subblockGain[window]-sfStep*sf.short[sfb][window]) / 4)
+
+			for line := base + window*width; line < base+(window+1)*width; line++ {
+				lines[line] = dequantize(values[line], scale)
+			}
+		}
+	}
+}
+
+func dequantize(value int, scale float64) float64 {
+	if value < 0 {
+		return -pow43[-value] * scale
+	}
+
+	return pow43[value] * scale
+}
+
+// reorder interleaves the short windows of each band, so that every subband holds its six
+// frequency lines window by window, as the short IMDCT reads them.
+func reorder(hdr frameHeader, gran *granule, lines *[granuleLines]float64) {
+	if !gran.shortBlocks() {
+		return
+	}
+
+	bands := hdr.bands()
+
+	shortStart := 0
+	if gran.mixed {
+		shortStart = mixedShortStart
+	}
+
+	var reordered [granuleLines]float64
+
+	for sfb := shortStart; sfb < shortBands; sfb++ {
+		width := bands.short[sfb+1] - bands.short[sfb]
+		base := bands.short[sfb] * shortWindows
+
+		for window := range shortWindows {
+			for i := range width {
+				reordered[base+i*shortWindows+window] = lines[base+window*width+i]
+			}
+		}
+	}
+
+	start := bands.short[shortStart] * shortWindows
+	copy(lines[start:], reordered[start:])
+}
+
+// antialias applies the alias reduction butterflies between long-block subbands. Short blocks
+// have none, and mixed blocks only between their two long subbands.
+func antialias(gran *granule, lines *[granuleLines]float64) {
+	limit := subbands
+
+	if gran.shortBlocks() {
+		if !gran.mixed {
+			return
+		}
+
+		limit = 2
+	}
+
+	for sb := 1; sb < limit; sb++ {
+		for i := range aliasButterfly {
+			lower, upper := sb*subbandLines-1-i, sb*subbandLines+i
+			below, above := lines[lower], lines[upper]
+			lines[lower] = below*aliasCS[i] - above*aliasCA[i]
+			lines[upper] = above*aliasCS[i] + below*aliasCA[i]
+		}
+	}
+}
+
+// hybridSynthesis runs the IMDCT of every subband, overlapping each block with the second half of
+// the previous one, and stores the subband samples by time slot. Odd time slots of odd subbands
+// are negated to undo the frequency inversion of the analysis filterbank.
+func hybridSynthesis(
+	gran *granule,
+	lines *[granuleLines]float64,
+	overlap *[subbands][subbandLines]float64,
+	out *[subbandLines][subbands]float64,
+) {
+	for sb := range subbands {
+		blockType := blockNormal
+		if gran.windowSwitching && !(gran.mixed && sb < 2) {
+			blockType = gran.blockType
+		}
+
+		var block [36]float64
+
+		coefs := lines[sb*subbandLines : (sb+1)*subbandLines]
+
+		if nonZero(coefs) {
+			if blockType == blockShort {
+				imdctShortBlock(coefs, &block)
+			} else {
+				imdctLongBlock(coefs, blockType, &block)
+			}
+		}
+
+		for i := range subbandLines {
+			sample := block[i] + overlap[sb][i]
+			overlap[sb][i] = block[subbandLines+i]
+
+			if sb&1 == 1 && i&1 == 1 {
+				sample = -sample
+			}
+
+			out[i][sb] = sample
+		}
+	}
+}
+
+// imdctLongBlock computes the windowed 36-sample IMDCT of 18 coefficients. The first half of the
+// output is odd-symmetric and the second half even-symmetric, so only half of it is computed.
+func imdctLongBlock(coefs []float64, blockType int, block *[36]float64) {
+	for i := range 9 {
+		var first, second float64
+
+		for k, coef := range coefs {
+			first += coef * imdctLong[i][k]
+			second += coef * imdctLong[18+i][k]
+		}
+
+		block[i], block[17-i] = first, -first
+		block[18+i], block[35-i] = second, second
+	}
+
+	for i := range block {
+		block[i] *= imdctWindows[blockType][i]
+	}
+}
+
+// imdctShortBlock computes the IMDCT of the three short windows of a subband and overlaps them,
+// six samples apart, in the middle of the block.
+func imdctShortBlock(coefs []float64, block *[36]float64) {
+	for window := range shortWindows {
+		for i := range 12 {
+			var sum float64
+
+			for k := range shortLines {
+				sum += coefs[k*shortWindows+window] * imdctShort[i][k]
+			}
+
+			block[6+6*window+i] += sum * imdctWindows[blockShort][i]
+		}
+	}
+}
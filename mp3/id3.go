@@ -0,0 +1,182 @@
+package mp3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"iter"
+	"strings"
+	"unicode/utf16"
+)
+
+// ID3v2 layout.
+const (
+	id3FlagUnsync    = 0x80
+	id3FlagExtended  = 0x40
+	id3FlagFooter    = 0x10
+	id3v22FrameSize  = 6 // ID (3) + size (3)
+	id3v23FrameSize  = 10
+	id3CommentHeader = 4 // encoding (1) + language (3)
+)
+
+// ID3v2 text encodings.
+const (
+	id3EncodingLatin1 = 0
+	id3EncodingUTF16  = 1
+	id3EncodingBE     = 2
+	id3EncodingUTF8   = 3
+)
+
+// id3Tag is the body of an ID3v2 tag, after its 10-byte header.
+type id3Tag struct {
+	version byte // major version: 2, 3 or 4
+	flags   byte
+	body    []byte
+}
+
+// readID3v2 reads the ID3v2 tag at the start of the file, if any.
+func readID3v2(reader io.ReadSeeker) (id3Tag, bool) {
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return id3Tag{}, false
+	}
+
+	header := make([]byte, id3v2HeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil || !bytes.HasPrefix(header, []byte("ID3")) {
+		return id3Tag{}, false
+	}
+
+	body := make([]byte, syncsafe(header[6:id3v2HeaderSize]))
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return id3Tag{}, false
+	}
+
+	return id3Tag{version: header[3], flags: header[5], body: body}, true
+}
+
+// frames iterates over the frames of the tag, yielding each frame ID and body. ID3v2.2 IDs have
+// three characters, later versions four.
+func (t id3Tag) frames() iter.Seq2[string, []byte] {
+	return func(yield func(string, []byte) bool) {
+		data := t.body
+
+		// ID3v2.3 unsynchronisation applies to the whole tag; ID3v2.4 flags it per frame, which
+		// taggers practically never do.
+		if t.flags&id3FlagUnsync != 0 && t.version < 4 {
+			data = bytes.ReplaceAll(data, []byte{0xFF, 0x00}, []byte{0xFF})
+		}
+
+		pos := 0
+
+		if t.flags&id3FlagExtended != 0 && t.version >= 3 && len(data) >= 4 {
+			if t.version == 3 {
+				pos = 4 + int(binary.BigEndian.Uint32(data))
+			} else {
+				pos = syncsafe(data[:4])
+			}
+		}
+
+		headerSize, idLen := id3v23FrameSize, 4
+		if t.version == 2 {
+			headerSize, idLen = id3v22FrameSize, 3
+		}
+
+		for pos+headerSize <= len(data) && data[pos] != 0 {
+			var size int
+
+			switch t.version {
+			case 2:
+				size = int(data[pos+3])<<16 | int(data[pos+4])<<8 | int(data[pos+5])
+			case 3:
+				size = int(binary.BigEndian.Uint32(data[pos+4:]))
+			default:
+				size = syncsafe(data[pos+4 : pos+8])
+			}
+
+			start := pos + headerSize
+			if size < 0 || start+size > len(data) {
+				return
+			}
+
+			if !yield(string(data[pos:pos+idLen]), data[start:start+size]) {
+				return
+			}
+
+			pos = start + size
+		}
+	}
+}
+
+// parseComment splits the body of a COMM (or USLT) frame into its description and text.
+func parseComment(body []byte) (string, string, bool) {
+	if len(body) < id3CommentHeader {
+		return "", "", false
+	}
+
+	encoding := body[0]
+
+	desc, text, ok := cutText(encoding, body[id3CommentHeader:])
+	if !ok {
+		return "", "", false
+	}
+
+	return decodeText(encoding, desc), decodeText(encoding, text), true
+}
+
+// cutText splits data at the first string terminator of the encoding: a zero byte, or a zero
+// 16-bit code unit for UTF-16.
+func cutText(encoding byte, data []byte) ([]byte, []byte, bool) {
+	if encoding != id3EncodingUTF16 && encoding != id3EncodingBE {
+		return bytes.Cut(data, []byte{0})
+	}
+
+	for idx := 0; idx+1 < len(data); idx += 2 {
+		if data[idx] == 0 && data[idx+1] == 0 {
+			return data[:idx], data[idx+2:], true
+		}
+	}
+
+	return data, nil, false
+}
+
+// decodeText decodes an ID3v2 string, dropping trailing terminators. UTF-16 honors its byte
+// order mark; the BOM-less encoding is big-endian.
+func decodeText(encoding byte, data []byte) string {
+	switch encoding {
+	case id3EncodingUTF16, id3EncodingBE:
+		var order binary.ByteOrder = binary.BigEndian
+
+		if encoding == id3EncodingUTF16 && len(data) >= 2 {
+			if data[0] == 0xFF && data[1] == 0xFE {
+				order = binary.LittleEndian
+			}
+
+			data = data[2:]
+		}
+
+		units := make([]uint16, 0, len(data)/2)
+		for idx := 0; idx+1 < len(data); idx += 2 {
+			units = append(units, order.Uint16(data[idx:]))
+		}
+
+		return strings.TrimRight(string(utf16.Decode(units)), "\x00")
+	case id3EncodingUTF8:
+		return strings.TrimRight(string(data), "\x00")
+	default:
+		return latin1(bytes.TrimRight(data, "\x00"))
+	}
+}
+
+// latin1 decodes ISO-8859-1 text, as ID3v1 and ID3v2 encoding 0 store it.
+func latin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for idx, char := range data {
+		runes[idx] = rune(char)
+	}
+
+	return string(runes)
+}
+
+// syncsafe decodes a 4-byte ID3v2 syncsafe integer (7 bits per byte).
+func syncsafe(data []byte) int {
+	return int(data[0]&0x7F)<<21 | int(data[1]&0x7F)<<14 | int(data[2]&0x7F)<<7 | int(data[3]&0x7F)
+}
@@ -0,0 +1,87 @@
+package mp3_test
+
+import (
+	"encoding/binary"
+	"unicode/utf16"
+
+	"github.com/farcloser/saprobe/mp3"
+)
+
+// id3Frame is a frame of a tag built by id3v2.
+type id3Frame struct {
+	id   string
+	body []byte
+}
+
+// id3v2 returns an ID3v2 tag of the given major version holding frames, which take three
+// character IDs in version 2.
+func id3v2(version byte, frames ...id3Frame) []byte {
+	var body []byte
+
+	for _, frame := range frames {
+		size := len(frame.body)
+		body = append(body, frame.id...)
+
+		switch version {
+		case 2:
+			body = append(body, byte(size>>16), byte(size>>8), byte(size))
+		case 3:
+			body = binary.BigEndian.AppendUint32(body, uint32(size)) //nolint:gosec // small test frames.
+		default:
+			body = append(body, syncsafeBytes(size)...)
+		}
+
+		if version > 2 {
+			body = append(body, 0, 0) // flags
+		}
+
+		body = append(body, frame.body...)
+	}
+
+	header := append([]byte{'I', 'D', '3', version, 0, 0}, syncsafeBytes(len(body))...)
+
+	return append(header, body...)
+}
+
+// syncsafeBytes encodes value as a 4-byte ID3v2 syncsafe integer.
+func syncsafeBytes(value int) []byte {
+	return []byte{byte(value >> 21 & 0x7F), byte(value >> 14 & 0x7F), byte(value >> 7 & 0x7F), byte(value & 0x7F)}
+}
+
+// latin1Text returns the body of a TXXX frame holding strs in Latin-1, each terminated but the
+// last.
+func latin1Text(strs ...string) []byte {
+	body := []byte{mp3.ID3EncodingLatin1}
+
+	for idx, str := range strs {
+		body = append(body, str...)
+		if idx < len(strs)-1 {
+			body = append(body, 0)
+		}
+	}
+
+	return body
+}
+
+// utf16Text is latin1Text in little-endian UTF-16, each string with its byte order mark.
+func utf16Text(strs ...string) []byte {
+	body := []byte{mp3.ID3EncodingUTF16}
+
+	for idx, str := range strs {
+		body = append(body, 0xFF, 0xFE)
+		for _, unit := range utf16.Encode([]rune(str)) {
+			body = binary.LittleEndian.AppendUint16(body, unit)
+		}
+
+		if idx < len(strs)-1 {
+			body = append(body, 0, 0)
+		}
+	}
+
+	return body
+}
+
+// comm turns the body of a TXXX frame into that of a COMM frame, in English.
+func comm(text []byte) []byte {
+	return append([]byte{text[0], 'e', 'n', 'g'}, text[1:]...)
+}
@@ -0,0 +1,454 @@
+package mp3
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/farcloser/saprobe"
+)
+
+// LAME tag VBR methods that target an average bitrate.
+const (
+	lameMethodABR      = 2
+	lameMethodABR2Pass = 9
+)
+
+// indexScanSize is the length of the reads that look for the first frame, the trailing tags and
+// the sync word after junk: Index reads nothing else but frame headers and the first frame.
+const indexScanSize = 64 << 10
+
+var errSeekRange = errors.New("mp3: seek beyond the stream")
+
+// BitrateMode classifies how the bitrate of a stream varies from frame to frame.
+type BitrateMode uint8
+
+const (
+	// CBR streams code every frame at the same bitrate.
+	CBR BitrateMode = iota
+	// ABR streams vary their bitrate around an average target. Only the LAME tag tells them from
+	// VBR streams.
+	ABR
+	// VBR streams vary their bitrate with the content.
+	VBR
+)
+
+// String returns the usual abbreviation of the mode.
+func (m BitrateMode) String() string {
+	switch m {
+	case CBR:
+		return "CBR"
+	case ABR:
+		return "ABR"
+	case VBR:
+		return "VBR"
+	}
+
+	return "unknown"
+}
+
+// Frame locates one audio frame of a stream.
+type Frame struct {
+	Offset  int64 // file offset of the frame header
+	Size    int   // length in bytes, header included
+	Samples int   // samples per channel
+	Bitrate int   // kbit/s; free-format frames report the bitrate their length implies
+	CRC     bool  // a CRC-16 protects the frame
+}
+
+// FrameIndex lists the audio frames of an MPEG audio stream, with the stream properties that follow
+// from their headers, as Index reads them without decoding.
+type FrameIndex struct {
+	Frames     []Frame // audio frames, without the Xing/Info or VBRI frame
+	SampleRate int
+	Channels   int
+	Layer      int // 1, 2 or 3
+
+	// Declared is the frame count of the Xing or VBRI header, 0 when there is none. A count that
+	// differs from len(Frames) flags a truncated or damaged stream, or a wrong header.
+	Declared int
+
+	// DeclaredSamples is the original sample count of iTunSMPB, 0 when there is none. It sets
+	// Padding when the frames hold that many samples; a count above Samples flags a truncated
+	// stream.
+	DeclaredSamples int64
+
+	// Gapless is the metadata that supplied Delay and Padding, the encoder samples a decoder trims
+	// from the start and the end of the stream.
+	Gapless GaplessSource
+	Delay   int
+	Padding int
+
+	// Mode is the bitrate mode: VBR or ABR, as the LAME tag tells them apart, when the bitrate
+	// of the frames varies, CBR otherwise.
+	Mode BitrateMode
+
+	trimStart int // samples gapless trimming removes before the first output sample
+	trimEnd   int // samples gapless trimming removes after the last one
+}
+
+// Index reads the frame headers of an MPEG audio stream, and the Xing, LAME, VBRI and iTunSMPB
+// metadata, without decoding any audio: it seeks from header to header rather than reading the
+// frames. A last frame cut short by the end of the stream is left
+// out, as decoders drop it.
+func Index(reader io.ReadSeeker) (*FrameIndex, error) {
+	tracked := saprobe.TrackReader(reader)
+	index, err := buildIndex(tracked)
+
+	return index, wrapError(tracked, err)
+}
+
+func buildIndex(reader io.ReadSeeker) (*FrameIndex, error) {
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seeking to start: %w", err)
+	}
+
+	base := int64(skipID3v2(reader))
+	if base < 0 {
+		return nil, errID3Skip
+	}
+
+	size, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("seeking to end: %w", err)
+	}
+
+	end, err := audioEnd(reader, base, size)
+	if err != nil {
+		return nil, err
+	}
+
+	first, freeBase, err := locateFirstFrame(reader, base, end)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := readAt(reader, first, frameHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+
+	hdr, _ := parseFrameHeader(header)
+
+	// Only the first frame is read whole, for its Xing/Info, LAME or VBRI header.
+	var firstFrame []byte
+	if length := int64(hdr.length(freeBase)); first+length <= end {
+		if firstFrame, err = readAt(reader, first, length); err != nil {
+			return nil, err
+		}
+	}
+
+	gapless := parseGaplessInfo(reader, firstFrame)
+
+	index := &FrameIndex{
+		SampleRate:      hdr.sampleRate,
+		Channels:        hdr.channels(),
+		Layer:           int(layerI - hdr.layer + 1),
+		Declared:        gapless.frames,
+		DeclaredSamples: gapless.length,
+		Gapless:         gapless.source,
+		Delay:           gapless.delay,
+	}
+
+	if err := index.scanFrames(reader, first, end, freeBase, gapless.hasXINGTag); err != nil {
+		return nil, err
+	}
+
+	if len(index.Frames) == 0 {
+		return nil, errNoFrames
+	}
+
+	index.Mode = bitrateMode(index.Frames, gapless.vbrMethod)
+
+	// The decoder also outputs the Xing/Info or VBRI frame, which the index leaves out.
+	decoded := len(index.Frames) * index.Frames[0].Samples
+	if gapless.hasXINGTag {
+		decoded += gapless.frameSize
+	}
+
+	gapless.fitLength(decoded)
+	index.Padding = gapless.padding
+
+	// Like applyGaplessTrimming, which leaves streams too short for their trimming untouched.
+	if start, end := gapless.trim(); start+end < len(index.Frames)*index.Frames[0].Samples {
+		index.trimStart, index.trimEnd = start, end
+	}
+
+	return index, nil
+}
+
+// scanFrames follows the frame chain from the first frame to the end of the audio like
+// frameOffsets, reading each header and seeking past the frame, and resynchronizing on the next
+// frame of the stream after junk. The Xing/Info or VBRI frame, when skipFirst, and a last frame cut
+// short are left out.
+//
+//revive:disable-next-line:flag-parameter
+func (x *FrameIndex) scanFrames(reader io.ReadSeeker, pos, end int64, freeBase int, skipFirst bool) error {
+	header, err := readAt(reader, pos, frameHeaderSize)
+	if err != nil {
+		return err
+	}
+
+	stream, _ := parseFrameHeader(header)
+
+	for pos+frameHeaderSize <= end {
+		hdr, ok, err := chainedFrameAt(reader, pos, end, stream, freeBase)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			header, err := readAt(reader, pos, min(end-pos, int64(len("LYRICSBEGIN"))))
+			if err != nil || isTrailer(header) {
+				return err
+			}
+
+			next, err := resyncAt(reader, pos+1, end, stream, freeBase)
+			if err != nil || next < 0 {
+				return err
+			}
+
+			pos = next
+
+			continue
+		}
+
+		size := hdr.length(freeBase)
+		if size == 0 || pos+int64(size) > end {
+			return nil
+		}
+
+		bitrate := hdr.bitrate
+		if bitrate == bitrateFree {
+			bitrate = hdr.impliedBitrate(size)
+		}
+
+		if !skipFirst {
+			x.Frames = append(x.Frames, Frame{
+				Offset:  pos,
+				Size:    size,
+				Samples: hdr.samples(),
+				Bitrate: bitrate,
+				CRC:     hdr.protected,
+			})
+		}
+
+		skipFirst = false
+		pos += int64(size)
+	}
+
+	return nil
+}
+
+// streamFrameAt is streamFrame at a file offset, returning the header.
+func streamFrameAt(reader io.ReadSeeker, pos int64, first frameHeader) (frameHeader, bool, error) {
+	header, err := readAt(reader, pos, frameHeaderSize)
+	if err != nil {
+		return frameHeader{}, false, err
+	}
+
+	hdr, ok := parseFrameHeader(header)
+
+	return hdr, ok && hdr.sameStream(first), nil
+}
+
+// continuesAt is continues at a file offset, the audio ending at end.
+func continuesAt(reader io.ReadSeeker, pos, end int64, first frameHeader) (bool, error) {
+	if pos+frameHeaderSize > end {
+		return true, nil
+	}
+
+	header, err := readAt(reader, pos, min(end-pos, int64(len("LYRICSBEGIN"))))
+	if err != nil || isTrailer(header) {
+		return err == nil, err
+	}
+
+	_, ok, err := streamFrameAt(reader, pos, first)
+
+	return ok, err
+}
+
+// chainedFrameAt is chainedFrame at a file offset, returning the header.
+func chainedFrameAt(reader io.ReadSeeker, pos, end int64, first frameHeader, freeBase int) (frameHeader, bool, error) {
+	hdr, ok, err := streamFrameAt(reader, pos, first)
+	if err != nil || !ok || hdr.length(freeBase) == 0 {
+		return hdr, ok, err
+	}
+
+	next := pos + int64(hdr.length(freeBase))
+	if cont, err := continuesAt(reader, next, end, first); err != nil || cont {
+		return hdr, ok, err
+	}
+
+	resynced, err := resyncAt(reader, pos+1, end, first, freeBase)
+
+	return hdr, resynced < 0 || resynced >= next, err
+}
+
+// resyncAt is resync at a file offset, reading the file a window at a time.
+func resyncAt(reader io.ReadSeeker, pos, end int64, first frameHeader, freeBase int) (int64, error) {
+	for {
+		next, err := nextSyncWord(reader, pos, end)
+		if err != nil || next < 0 {
+			return next, err
+		}
+
+		hdr, ok, err := streamFrameAt(reader, next, first)
+		if err != nil {
+			return 0, err
+		}
+
+		if size := int64(hdr.length(freeBase)); ok {
+			cont, err := continuesAt(reader, next+size, end, first)
+			if err != nil {
+				return 0, err
+			}
+
+			if size == 0 || cont {
+				return next, nil
+			}
+		}
+
+		pos = next + 1
+	}
+}
+
+// audioEnd returns the file offset where the trailing ID3v1, APE and Lyrics3 tags start, size if
+// there are none. It reads the end of the file, more of it when a tag found there reaches further
+// back.
+func audioEnd(reader io.ReadSeeker, base, size int64) (int64, error) {
+	for window := min(size-base, indexScanSize); ; window = min(2*window, size-base) {
+		tail, err := readAt(reader, size-window, window)
+		if err != nil {
+			return 0, err
+		}
+
+		cut, _ := splitTrailers(tail)
+		rest := tail[:cut]
+
+		if window == size-base || (!isAPEFooter(rest) && !bytes.HasSuffix(rest, []byte("LYRICS200")) &&
+			!bytes.HasSuffix(rest, []byte("LYRICSEND"))) {
+			return size - window + int64(cut), nil
+		}
+	}
+}
+
+// locateFirstFrame returns the file offset of the first frame between base and end, as
+// findFirstFrame finds it, with the length of the unpadded frames of a free-format stream. It
+// reads more of the file while the frame it finds lies too close to the end of what it read to
+// check the frames that follow.
+func locateFirstFrame(reader io.ReadSeeker, base, end int64) (int64, int, error) {
+	const margin = (freeFormatChecks + 1) * (maxFreeFormatSize + frameHeaderSize)
+
+	for window := min(end-base, indexScanSize); ; window = min(2*window, end-base) {
+		head, err := readAt(reader, base, window)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		first, freeBase := findFirstFrame(head)
+
+		switch {
+		case window == end-base && first < 0:
+			return 0, 0, errNoFrames
+		case window == end-base || (first >= 0 && first+margin <= len(head)):
+			return base + int64(first), freeBase, nil
+		default:
+		}
+	}
+}
+
+// nextSyncWord returns the file offset of the next sync word between pos and end, or -1 if there
+// is none, reading the file a window at a time.
+func nextSyncWord(reader io.ReadSeeker, pos, end int64) (int64, error) {
+	for pos+frameHeaderSize <= end {
+		window, err := readAt(reader, pos, min(end-pos, indexScanSize))
+		if err != nil {
+			return 0, err
+		}
+
+		if next := findSyncWord(window); next >= 0 {
+			return pos + int64(next), nil
+		}
+
+		// A sync word may straddle two windows.
+		pos += int64(len(window)) - frameHeaderSize + 1
+	}
+
+	return -1, nil
+}
+
+// readAt reads length bytes at the file offset.
+func readAt(reader io.ReadSeeker, offset, length int64) ([]byte, error) {
+	if _, err := reader.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seeking to offset %d: %w", offset, err)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, fmt.Errorf("reading %d bytes at offset %d: %w", length, offset, err)
+	}
+
+	return data, nil
+}
+
+// bitrateMode classifies the bitrate of the frames, given the VBR method of the LAME tag.
+func bitrateMode(frames []Frame, method byte) BitrateMode {
+	lowest, highest := frames[0].Bitrate, frames[0].Bitrate
+
+	for _, frame := range frames[1:] {
+		lowest, highest = min(lowest, frame.Bitrate), max(highest, frame.Bitrate)
+	}
+
+	switch {
+	case lowest == highest:
+		return CBR
+	case method == lameMethodABR || method == lameMethodABR2Pass:
+		return ABR
+	default:
+		return VBR
+	}
+}
+
+// Samples returns the number of samples per channel Decode outputs: those of every frame, less
+// what gapless trimming removes.
+func (x *FrameIndex) Samples() int64 {
+	return int64(len(x.Frames)*x.Frames[0].Samples - x.trimStart - x.trimEnd)
+}
+
+// Duration returns the playing time of the decoded stream.
+func (x *FrameIndex) Duration() time.Duration {
+	return time.Duration(x.Samples()) * time.Second / time.Duration(x.SampleRate)
+}
+
+// Bitrate returns the average bitrate of the audio frames, in kbit/s.
+func (x *FrameIndex) Bitrate() int {
+	var size int64
+	for _, frame := range x.Frames {
+		size += int64(frame.Size)
+	}
+
+	samples := int64(len(x.Frames) * x.Frames[0].Samples)
+
+	return int((size*8*int64(x.SampleRate) + samples*500) / (samples * 1000))
+}
+
+// FrameAt returns the index of the frame holding the given output sample, counted like Samples, and
+// the position of the sample among the samples that frame decodes to. Every frame of a stream
+// carries the same number of samples, so seeking takes constant time.
+//
+// Decoders do not output a frame on its own: the polyphase filterbank, and for Layer III the
+// hybrid filterbank and the bit reservoir, carry state from the frames before it. Decoding from
+// a few frames earlier and discarding their output gives exact samples.
+func (x *FrameIndex) FrameAt(sample int64) (int, int, error) {
+	if sample < 0 || sample >= x.Samples() {
+		return 0, 0, fmt.Errorf("%w: sample %d of %d", errSeekRange, sample, x.Samples())
+	}
+
+	position := sample + int64(x.trimStart)
+	perFrame := int64(x.Frames[0].Samples)
+
+	return int(position / perFrame), int(position % perFrame), nil
+}
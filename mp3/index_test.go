@@ -0,0 +1,129 @@
+package mp3_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/mp3"
+)
+
+// TestIndexDuration checks that the index counts the samples Decode outputs, gapless trimming
+// included, without decoding, and locates frames behind an ID3v2 tag.
+func TestIndexDuration(t *testing.T) {
+	t.Parallel()
+
+	music := mp3.SilentFrames(100)
+	tag := id3v2(3, id3Frame{"TIT2", latin1Text("Saprobe")})
+	info := mp3.InfoFrame(mp3.LAMEFields{Frames: 100, Delay: 576, Padding: 1000}, music, false)
+
+	// A last frame cut short is left out, as decoders drop it.
+	data := slices.Concat(tag, info, music, mp3.SilentFrames(1)[:200])
+
+	index, err := mp3.Index(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pcm, format, _, err := mp3.DecodeWithOptions(bytes.NewReader(data), saprobe.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := int64(len(pcm) / format.BitDepth.BytesPerSample())
+
+	// 100 frames, less the encoder delay and padding: the decoder delay shifts from end to start.
+	const want = 100*mp3.SilentFrameSamples - 576 - 1000
+
+	if index.Samples() != want || decoded != want {
+		t.Errorf("index counts %d samples, decoder outputs %d, want %d", index.Samples(), decoded, want)
+	}
+
+	if duration := index.Duration(); duration != time.Duration(want)*time.Second/44100 {
+		t.Errorf("duration %s, want %s", duration, time.Duration(want)*time.Second/44100)
+	}
+
+	if len(index.Frames) != 100 || index.Declared != 100 || index.Gapless != mp3.GaplessLAME ||
+		index.SampleRate != 44100 || index.Channels != 1 || index.Layer != 3 {
+		t.Errorf("index %+v", index)
+	}
+
+	// The audio frames follow the tag and the Info frame.
+	if first := index.Frames[0].Offset; first != int64(len(tag)+mp3.SilentFrameSize) {
+		t.Errorf("first frame at %d, want %d", first, len(tag)+mp3.SilentFrameSize)
+	}
+
+	if index.Bitrate() != 128 || index.Mode != mp3.CBR {
+		t.Errorf("%d kbit/s %s, want 128 kbit/s CBR", index.Bitrate(), index.Mode)
+	}
+
+	// The first output sample is the last of the trimmed start.
+	if frame, offset, err := index.FrameAt(0); err != nil || frame != 0 || offset != 576+mp3.SynthesisDelay {
+		t.Errorf("sample 0 in frame %d at %d (%v), want frame 0 at %d", frame, offset, err, 576+mp3.SynthesisDelay)
+	}
+
+	if _, _, err := index.FrameAt(want); err == nil {
+		t.Error("seeking past the last sample succeeds")
+	}
+}
+
+// TestBitrateMode checks that only the LAME tag tells ABR streams from VBR ones.
+func TestBitrateMode(t *testing.T) {
+	t.Parallel()
+
+	constant := []mp3.Frame{{Bitrate: 128}, {Bitrate: 128}}
+	varying := []mp3.Frame{{Bitrate: 128}, {Bitrate: 192}, {Bitrate: 96}}
+
+	for _, test := range []struct {
+		frames []mp3.Frame
+		method byte
+		want   mp3.BitrateMode
+	}{
+		{constant, 0, mp3.CBR},
+		{constant, mp3.LAMEMethodABR, mp3.CBR},
+		{varying, 0, mp3.VBR},
+		{varying, mp3.LAMEMethodABR, mp3.ABR},
+		{varying, mp3.LAMEMethodABR2Pass, mp3.ABR},
+	} {
+		if mode := mp3.BitrateModeOf(test.frames, test.method); mode != test.want {
+			t.Errorf("%v with method %d: %s, want %s", test.frames, test.method, mode, test.want)
+		}
+	}
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	*bytes.Reader
+	read int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += n
+
+	return n, err
+}
+
+// TestIndexSeeks checks that the index reads frame headers, not frames.
+func TestIndexSeeks(t *testing.T) {
+	t.Parallel()
+
+	music := mp3.SilentFrames(5000)
+	data := slices.Concat(mp3.InfoFrame(mp3.LAMEFields{Frames: 5000}, music, false), music)
+	reader := &countingReader{Reader: bytes.NewReader(data)}
+
+	index, err := mp3.Index(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(index.Frames) != 5000 {
+		t.Fatalf("indexed %d frames, want 5000", len(index.Frames))
+	}
+
+	if reader.read > len(data)/4 {
+		t.Errorf("read %d bytes of %d", reader.read, len(data))
+	}
+}
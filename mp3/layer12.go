@@ -0,0 +1,299 @@
+package mp3
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	allocBitsLayerI    = 4
+	forbiddenAllocI    = 15 // the Layer I allocation 1111 is forbidden
+	scalefactorBits    = 6
+	scfsiBits          = 2
+	layerISlots        = 12 // time slots per Layer I frame
+	layerIIGranules    = 12 // granules of three time slots per Layer II frame
+	layerIIParts       = 3  // a scalefactor per part of four granules
+	granulesPerPart    = layerIIGranules / layerIIParts
+	intensityBoundStep = 4
+)
+
+// Scalefactor selection information: which of the three Layer II scalefactors are transmitted.
+const (
+	scfsiAll       = 0 // three scalefactors
+	scfsiFirstTwo  = 1 // the first one applies to the first two parts
+	scfsiOne       = 2 // one for the whole frame
+	scfsiSecondTwo = 3 // the second one applies to the last two parts
+)
+
+var (
+	errForbiddenAlloc = errors.New("mp3: forbidden Layer I bit allocation")
+	errSubbandOverrun = errors.New("mp3: subband data exceeds the frame")
+)
+
+// layer12Scalefactors maps a Layer I or II scalefactor index to its multiplier, 2^(1-index/3)
+// (ISO/IEC 11172-3 Table B.1). Index 63 is reserved, and silences the subband.
+//
+//nolint:gochecknoglobals // computed constant tables
+var layer12Scalefactors = func() [1 << scalefactorBits]float64 {
+	var table [1 << scalefactorBits]float64
+
+	for idx := range len(table) - 1 {
+		table[idx] = math.Exp2(1 - float64(idx)/3)
+	}
+
+	return table
+}()
+
+// subbandDecoder decodes the Layer I or Layer II frames of one stream to 24-bit PCM. These layers
+// code the subband samples directly, without the hybrid filterbank and the bit reservoir of
+// Layer III: frames decode independently, and only the synthesis filterbank carries state.
+type subbandDecoder struct {
+	format   frameHeader // the first frame, which every frame must match
+	alloc    [maxChannels][subbands]int
+	scfsi    [maxChannels][subbands]int
+	scale    [maxChannels][subbands][layerIIParts]float64
+	samples  [maxChannels][3][subbands]float64 // subband samples of up to three time slots
+	synth    [maxChannels]polyphase
+	pcm      [maxChannels][samplesLayerII]float64
+	channels int
+}
+
+func newSubbandDecoder(first frameHeader) *subbandDecoder {
+	return &subbandDecoder{format: first, channels: first.channels()}
+}
+
+// decode decodes one frame, header included, and returns its samples.
+func (d *subbandDecoder) decode(frame []byte) (framePCM, error) {
+	hdr, ok := parseFrameHeader(frame)
+	if !ok || hdr.layer != d.format.layer || hdr.version != d.format.version ||
+		hdr.sampleRate != d.format.sampleRate || hdr.channels() != d.channels {
+		return framePCM{}, errFormatChange
+	}
+
+	// Free-format Layer II frames pick their bit allocation table from the bitrate they code at.
+	if hdr.bitrate == bitrateFree {
+		hdr.bitrate = hdr.impliedBitrate(len(frame))
+	}
+
+	start := frameHeaderSize
+	if hdr.protected {
+		start += frameCRCSize
+	}
+
+	br := bitReader{data: frame[start:]}
+
+	if hdr.layer == layerI {
+		if err := d.decodeLayerI(&br, hdr); err != nil {
+			return framePCM{}, err
+		}
+	} else {
+		d.decodeLayerII(&br, hdr)
+	}
+
+	if br.pos > br.bits() {
+		return framePCM{}, errSubbandOverrun
+	}
+
+	return framePCM{samples: &d.pcm, channels: d.channels, length: hdr.samples()}, nil
+}
+
+// decodeLayerI decodes the bit allocations, scalefactors and samples of a Layer I frame. Each
+// allocated subband has one scalefactor, and twelve samples of 2 to 15 bits.
+func (d *subbandDecoder) decodeLayerI(br *bitReader, hdr frameHeader) error {
+	bound := hdr.intensityBound()
+
+	for sb := range subbands {
+		for ch := range d.channels {
+			if ch > 0 && sb >= bound {
+				d.alloc[ch][sb] = d.alloc[0][sb]
+
+				continue
+			}
+
+			d.alloc[ch][sb] = br.read(allocBitsLayerI)
+			if d.alloc[ch][sb] == forbiddenAllocI {
+				return errForbiddenAlloc
+			}
+		}
+	}
+
+	for sb := range subbands {
+		for ch := range d.channels {
+			if d.alloc[ch][sb] != 0 {
+				d.scale[ch][sb][0] = layer12Scalefactors[br.read(scalefactorBits)]
+			}
+		}
+	}
+
+	for slot := range layerISlots {
+		for sb := range subbands {
+			var fraction float64
+
+			for ch := range d.channels {
+				alloc := d.alloc[ch][sb]
+				if alloc == 0 {
+					d.samples[ch][0][sb] = 0
+
+					continue
+				}
+
+				// Beyond the intensity bound, both channels share the samples of the first one.
+				if ch == 0 || sb < bound {
+					bits := alloc + 1
+					fraction = fractionOf(br.read(bits), 1<<bits-1)
+				}
+
+				d.samples[ch][0][sb] = fraction * d.scale[ch][sb][0]
+			}
+		}
+
+		d.synthesize(1, slot*subbands)
+	}
+
+	return nil
+}
+
+// decodeLayerII decodes the bit allocations, scalefactors and samples of a Layer II frame. Each
+// allocated subband has up to three scalefactors, one per part of the frame, and twelve granules
+// of three samples, quantized in the class its allocation selects.
+func (d *subbandDecoder) decodeLayerII(br *bitReader, hdr frameHeader) {
+	table := allocTable(hdr)
+	limit := len(table)
+	bound := min(hdr.intensityBound(), limit)
+
+	for sb := range limit {
+		for ch := range d.channels {
+			if ch > 0 && sb >= bound {
+				d.alloc[ch][sb] = d.alloc[0][sb]
+			} else {
+				d.alloc[ch][sb] = br.read(table[sb].bits)
+			}
+		}
+	}
+
+	for sb := range limit {
+		for ch := range d.channels {
+			if d.alloc[ch][sb] != 0 {
+				d.scfsi[ch][sb] = br.read(scfsiBits)
+			}
+		}
+	}
+
+	for sb := range limit {
+		for ch := range d.channels {
+			if d.alloc[ch][sb] != 0 {
+				readScalefactors(br, d.scfsi[ch][sb], &d.scale[ch][sb])
+			}
+		}
+	}
+
+	for gr := range layerIIGranules {
+		part := gr / granulesPerPart
+
+		for sb := range limit {
+			var fractions [3]float64
+
+			for ch := range d.channels {
+				alloc := d.alloc[ch][sb]
+				if alloc == 0 {
+					for slot := range 3 {
+						d.samples[ch][slot][sb] = 0
+					}
+
+					continue
+				}
+
+				// Beyond the intensity bound, both channels share the samples of the first one.
+				if ch == 0 || sb < bound {
+					fractions = readTriplet(br, quantClasses[table[sb].classes[alloc-1]])
+				}
+
+				for slot := range 3 {
+					d.samples[ch][slot][sb] = fractions[slot] * d.scale[ch][sb][part]
+				}
+			}
+		}
+
+		for ch := range d.channels {
+			for slot := range 3 {
+				clear(d.samples[ch][slot][limit:])
+			}
+		}
+
+		d.synthesize(3, gr*3*subbands)
+	}
+}
+
+// readScalefactors reads the scalefactors of a Layer II subband, as its selection information
+// lays them out over the three parts of the frame.
+func readScalefactors(br *bitReader, scfsi int, scale *[layerIIParts]float64) {
+	read := func() float64 {
+		return layer12Scalefactors[br.read(scalefactorBits)]
+	}
+
+	switch scfsi {
+	case scfsiAll:
+		scale[0], scale[1], scale[2] = read(), read(), read()
+	case scfsiFirstTwo:
+		scale[0] = read()
+		scale[1], scale[2] = scale[0], read()
+	case scfsiOne:
+		scale[0] = read()
+		scale[1], scale[2] = scale[0], scale[0]
+	case scfsiSecondTwo:
+		scale[0], scale[1] = read(), read()
+		scale[2] = scale[1]
+	default:
+	}
+}
+
+// readTriplet reads the three consecutive samples of a Layer II subband granule, as fractions.
+// Grouped classes pack them in one codeword, least significant first.
+func readTriplet(br *bitReader, class quantClass) [3]float64 {
+	var fractions [3]float64
+
+	if !class.grouped {
+		for idx := range fractions {
+			fractions[idx] = fractionOf(br.read(class.bits), class.steps)
+		}
+
+		return fractions
+	}
+
+	code := br.read(class.bits)
+
+	for idx := range fractions {
+		fractions[idx] = fractionOf(code%class.steps, class.steps)
+		code /= class.steps
+	}
+
+	return fractions
+}
+
+// fractionOf maps a sample code of a quantizer with the given number of steps to its fraction,
+// evenly spread over ]-1, 1[.
+func fractionOf(code, steps int) float64 {
+	return float64(2*code-steps+1) / float64(steps)
+}
+
+// synthesize runs the first slots time slots of subband samples through the synthesis filterbank,
+// into the frame's PCM from sample offset on.
+func (d *subbandDecoder) synthesize(slots, offset int) {
+	for ch := range d.channels {
+		for slot := range slots {
+			start := offset + slot*subbands
+			d.synth[ch].synthesize(&d.samples[ch][slot], d.pcm[ch][start:start+subbands])
+		}
+	}
+}
+
+// intensityBound returns the first Layer I or II subband whose samples the channels share: in
+// joint stereo, the mode extension sets it at 4, 8, 12 or 16; otherwise every subband is coded
+// per channel.
+func (h frameHeader) intensityBound() int {
+	if h.channelMode != channelModeJoint {
+		return subbands
+	}
+
+	return (int(h.modeExt) + 1) * intensityBoundStep
+}
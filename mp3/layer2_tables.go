@@ -0,0 +1,108 @@
+package mp3
+
+// quantClass is a Layer II quantization class (ISO/IEC 11172-3 Table B.4): the number of steps
+// and the codeword length. Grouped classes code three samples in one codeword.
+type quantClass struct {
+	steps   int
+	bits    int
+	grouped bool
+}
+
+// quantClasses are the quantization classes, indexed by the classes of allocBand.
+//
+//nolint:gochecknoglobals // constant table
+var quantClasses = [...]quantClass{
+	{3, 5, true}, {5, 7, true}, {7, 3, false}, {9, 10, true}, {15, 4, false}, {31, 5, false},
+	{63, 6, false}, {127, 7, false}, {255, 8, false}, {511, 9, false}, {1023, 10, false},
+	{2047, 11, false}, {4095, 12, false}, {8191, 13, false}, {16383, 14, false}, {32767, 15, false},
+	{65535, 16, false},
+}
+
+// allocBand is the bit allocation of one Layer II subband: the length of its allocation field,
+// and the quantization class of every non-zero allocation, from 1 up.
+type allocBand struct {
+	bits    int
+	classes []int
+}
+
+// allocRun repeats one subband allocation over count subbands.
+type allocRun struct {
+	count int
+	band  allocBand
+}
+
+//nolint:gochecknoglobals // constant table
+var (
+	// Quantization classes of the MPEG-1 tables (ISO/IEC 11172-3 Table B.2): the high- and
+	// low-rate tables give the lowest subbands 4 bits, and the higher ones fewer and coarser
+	// classes.
+	allocHighLow  = allocBand{4, []int{0, 2, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}}
+	allocHighMid  = allocBand{4, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 16}}
+	allocHighTop  = allocBand{3, []int{0, 1, 2, 3, 4, 5, 16}}
+	allocHighLast = allocBand{2, []int{0, 1, 16}}
+	allocLowLow   = allocBand{4, []int{0, 1, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}}
+	allocLowTop   = allocBand{3, []int{0, 1, 3, 4, 5, 6, 7}}
+
+	// Quantization classes of the MPEG-2 low sampling frequency table (ISO/IEC 13818-3 Table B.1).
+	allocLSFLow  = allocBand{4, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}}
+	allocLSFMid  = allocBand{3, []int{0, 1, 3, 4, 5, 6, 7}}
+	allocLSFHigh = allocBand{2, []int{0, 1, 3}}
+)
+
+// allocTables are the Layer II bit allocation tables, one band per coded subband: MPEG-1 Tables
+// B.2a to B.2d, then the MPEG-2 table.
+//
+//nolint:gochecknoglobals // computed constant tables
+var allocTables = [...][]allocBand{
+	allocBands(
+		allocRun{3, allocHighLow}, allocRun{8, allocHighMid}, allocRun{12, allocHighTop}, allocRun{4, allocHighLast},
+	),
+	allocBands(
+		allocRun{3, allocHighLow}, allocRun{8, allocHighMid}, allocRun{12, allocHighTop}, allocRun{7, allocHighLast},
+	),
+	allocBands(allocRun{2, allocLowLow}, allocRun{6, allocLowTop}),
+	allocBands(allocRun{2, allocLowLow}, allocRun{10, allocLowTop}),
+	allocBands(allocRun{4, allocLSFLow}, allocRun{7, allocLSFMid}, allocRun{19, allocLSFHigh}),
+}
+
+// Indexes in allocTables.
+const (
+	allocTableA = iota
+	allocTableB
+	allocTableC
+	allocTableD
+	allocTableLSF
+)
+
+func allocBands(runs ...allocRun) []allocBand {
+	var bands []allocBand
+
+	for _, run := range runs {
+		for range run.count {
+			bands = append(bands, run.band)
+		}
+	}
+
+	return bands
+}
+
+// allocTable returns the bit allocation table of a Layer II frame. MPEG-1 picks it from the
+// bitrate per channel and the sample rate (ISO/IEC 11172-3 Annex B.2); MPEG-2 has a single one.
+func allocTable(hdr frameHeader) []allocBand {
+	if hdr.version != mpegVersion1 {
+		return allocTables[allocTableLSF]
+	}
+
+	perChannel := hdr.bitrate / hdr.channels()
+
+	switch {
+	case perChannel >= 56 && (perChannel <= 80 || hdr.sampleRate == 48000):
+		return allocTables[allocTableA]
+	case perChannel >= 96 && hdr.sampleRate != 48000:
+		return allocTables[allocTableB]
+	case perChannel <= 48 && hdr.sampleRate != 32000:
+		return allocTables[allocTableC]
+	default:
+		return allocTables[allocTableD]
+	}
+}
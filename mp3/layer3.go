@@ -0,0 +1,265 @@
+package mp3
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	maxReservoir = 511 // main_data_begin is at most 9 bits
+	pcmScale     = 1 << 23
+	pcmMax       = pcmScale - 1
+)
+
+var (
+	errFormatChange     = errors.New("mp3: frame format differs from the first frame")
+	errShortFrame       = errors.New("mp3: frame shorter than its side information")
+	errMainDataOverrun  = errors.New("mp3: granule data exceeds the main data")
+	errPart23Overrun    = errors.New("mp3: Huffman data exceeds part2_3_length")
+	errScalefacsOverrun = errors.New("mp3: scalefactors exceed part2_3_length")
+)
+
+// frameDecoder decodes the frames of one stream to 24-bit PCM, in order.
+type frameDecoder interface {
+	// decode decodes one frame, header included, and returns its samples, valid until the next
+	// call. On error the decoder can go on with the next frame.
+	decode(frame []byte) (framePCM, error)
+}
+
+// framePCM holds the samples of a decoded frame, per channel, at a full scale of 1.
+type framePCM struct {
+	samples  *[maxChannels][samplesLayerII]float64
+	channels int
+	length   int // samples per channel
+}
+
+// newFrameDecoder returns the decoder for the layer of the first frame.
+func newFrameDecoder(first frameHeader) frameDecoder {
+	if first.layer == layerIII {
+		return newLayer3Decoder(first)
+	}
+
+	return newSubbandDecoder(first)
+}
+
+// layer3Decoder decodes the Layer III frames of one stream to 24-bit PCM. It carries the bit
+// reservoir, the IMDCT overlap and the synthesis filterbank state from frame to frame, so frames
+// must be fed in order.
+type layer3Decoder struct {
+	format    frameHeader // the first frame, which every frame must match
+	reservoir []byte      // main data of the previous frames, up to maxReservoir bytes
+	mainData  []byte
+
+	values   [granuleLines]int
+	sf       [maxChannels]scalefactors
+	spectra  [maxChannels][granuleLines]float64
+	overlap  [maxChannels][subbands][subbandLines]float64
+	slots    [subbandLines][subbands]float64
+	synth    [maxChannels]polyphase
+	pcm      [maxChannels][maxGranules * granuleLines]float64
+	channels int
+}
+
+func newLayer3Decoder(first frameHeader) *layer3Decoder {
+	return &layer3Decoder{format: first, channels: first.channels()}
+}
+
+// decode decodes one frame, header included, and returns its samples. On error the decoder can go
+// on with the next frame: the frame's main data still feeds the bit reservoir.
+//
+// Granules whose main data starts before the reservoir (the first frames of a stream cut from a
+// longer one) decode as silence.
+func (d *layer3Decoder) decode(frame []byte) (framePCM, error) {
+	hdr, ok := parseFrameHeader(frame)
+	if !ok || hdr.layer != layerIII || hdr.version != d.format.version ||
+		hdr.sampleRate != d.format.sampleRate || hdr.channels() != d.channels {
+		return framePCM{}, errFormatChange
+	}
+
+	sideStart := frameHeaderSize
+	if hdr.protected {
+		sideStart += frameCRCSize
+	}
+
+	sideEnd := sideStart + hdr.sideInfoSize()
+	if len(frame) < sideEnd {
+		return framePCM{}, errShortFrame
+	}
+
+	info, err := readSideInfo(hdr, frame[sideStart:sideEnd])
+
+	// Bit position of the first granule in d.mainData, negative when the reservoir lacks data.
+	start := d.fillMainData(info.mainDataBegin, frame[sideEnd:])
+
+	if err != nil {
+		return framePCM{}, err
+	}
+
+	for gr := range hdr.granules() {
+		if start, err = d.decodeGranule(hdr, &info, gr, start); err != nil {
+			return framePCM{}, fmt.Errorf("granule %d: %w", gr, err)
+		}
+	}
+
+	return framePCM{samples: &d.pcm, channels: d.channels, length: hdr.samples()}, nil
+}
+
+// fillMainData assembles the main data of a frame from the reservoir and the frame's own bytes,
+// then adds the latter to the reservoir. It returns the bit position at which the frame's main
+// data begins.
+func (d *layer3Decoder) fillMainData(begin int, data []byte) int {
+	available := min(begin, len(d.reservoir))
+
+	d.mainData = append(d.mainData[:0], d.reservoir[len(d.reservoir)-available:]...)
+	d.mainData = append(d.mainData, data...)
+
+	d.reservoir = append(d.reservoir, data...)
+	if excess := len(d.reservoir) - maxReservoir; excess > 0 {
+		d.reservoir = append(d.reservoir[:0], d.reservoir[excess:]...)
+	}
+
+	return (available - begin) * 8
+}
+
+// decodeGranule decodes granule gr of every channel, whose main data starts at bit start, into
+// PCM, and returns the position of the next granule.
+func (d *layer3Decoder) decodeGranule(hdr frameHeader, info *sideInfo, gr, start int) (int, error) {
+	br := bitReader{data: d.mainData}
+
+	for ch := range d.channels {
+		gran := &info.granules[gr][ch]
+		end := start + gran.part23Length
+
+		switch {
+		case end > br.bits():
+			return 0, fmt.Errorf("channel %d: %w", ch, errMainDataOverrun)
+		case start < 0:
+			clear(d.spectra[ch][:])
+		default:
+			br.pos = start
+
+			nonZero, err := d.readGranule(&br, hdr, info, gr, ch, end)
+			if err != nil {
+				return 0, fmt.Errorf("channel %d: %w", ch, err)
+			}
+
+			requantize(hdr, gran, &d.sf[ch], &d.values, nonZero, &d.spectra[ch])
+		}
+
+		start = end
+	}
+
+	if hdr.channelMode == channelModeJoint {
+		jointStereo(hdr, &info.granules[gr][1], &d.sf[1], &d.spectra)
+	}
+
+	for ch := range d.channels {
+		gran := &info.granules[gr][ch]
+
+		reorder(hdr, gran, &d.spectra[ch])
+		antialias(gran, &d.spectra[ch])
+		hybridSynthesis(gran, &d.spectra[ch], &d.overlap[ch], &d.slots)
+
+		for slot := range subbandLines {
+			offset := gr*granuleLines + slot*subbands
+			d.synth[ch].synthesize(&d.slots[slot], d.pcm[ch][offset:offset+subbands])
+		}
+	}
+
+	return start, nil
+}
+
+// readGranule reads the scalefactors and Huffman values of one granule and channel, and returns
+// the number of lines that may be non-zero.
+func (d *layer3Decoder) readGranule(br *bitReader, hdr frameHeader, info *sideInfo, gr, ch, end int) (int, error) {
+	gran := &info.granules[gr][ch]
+
+	if hdr.lsf() {
+		intensityRight := ch == 1 && hdr.channelMode == channelModeJoint && hdr.modeExt&modeExtIntensity != 0
+		readScalefactorsLSF(br, gran, intensityRight, &d.sf[ch])
+	} else {
+		readScalefactorsMPEG1(br, gran, info.scfsi[ch], gr, &d.sf[ch])
+	}
+
+	if br.pos > end {
+		return 0, errScalefacsOverrun
+	}
+
+	bigEnd := gran.bigValues * 2
+	bounds := regionBounds(hdr, gran)
+
+	for region := range bigValueRegions {
+		if err := readPairs(br, gran.tableSelect[region], d.values[bounds[region]:bounds[region+1]]); err != nil {
+			return 0, err
+		}
+	}
+
+	if br.pos > end {
+		return 0, errPart23Overrun
+	}
+
+	count, err := readQuads(br, gran.count1Table, d.values[bigEnd:], end)
+	if err != nil {
+		return 0, err
+	}
+
+	return bigEnd + count, nil
+}
+
+// regionBounds returns the line boundaries of the three big-values regions, each coded with its
+// own Huffman table. With window switching, the first region covers 36 lines for short blocks and
+// eight long bands otherwise, and the second one the rest.
+func regionBounds(hdr frameHeader, gran *granule) [bigValueRegions + 1]int {
+	bands := hdr.bands()
+	bigEnd := gran.bigValues * 2
+
+	var region1, region2 int
+
+	switch {
+	case gran.shortBlocks():
+		region1, region2 = 36, granuleLines
+	case gran.windowSwitching:
+		region1, region2 = bands.long[8], granuleLines
+	default:
+		region1 = bands.long[min(gran.region0Count+1, longBands)]
+		region2 = bands.long[min(gran.region0Count+gran.region1Count+2, longBands)]
+	}
+
+	region1 = min(region1, bigEnd)
+	region2 = min(max(region2, region1), bigEnd)
+
+	return [bigValueRegions + 1]int{0, region1, region2, bigEnd}
+}
+
+// appendPCM appends the samples from first to last of every channel to buf, interleaved, as
+// little-endian signed 24-bit integers.
+func (p framePCM) appendPCM(buf []byte, first, last int) []byte {
+	for idx := first; idx < last; idx++ {
+		for ch := range p.channels {
+			value := pcm24(p.samples[ch][idx])
+			buf = append(buf, byte(value), byte(value>>8), byte(value>>16))
+		}
+	}
+
+	return buf
+}
+
+// appendPlanar appends the samples from first to last of every channel to its plane, as signed
+// 24-bit integers, and returns the planes.
+func (p framePCM) appendPlanar(planes [][]int32, first, last int) [][]int32 {
+	for ch, plane := range planes[:p.channels] {
+		for _, value := range p.samples[ch][first:last] {
+			plane = append(plane, pcm24(value))
+		}
+
+		planes[ch] = plane
+	}
+
+	return planes
+}
+
+// pcm24 converts a sample to 24 bits, clipping it to full scale.
+func pcm24(value float64) int32 {
+	return int32(max(min(math.Round(value*pcmScale), pcmMax), -pcmScale))
+}
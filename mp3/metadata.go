@@ -0,0 +1,193 @@
+package mp3
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/farcloser/saprobe"
+)
+
+// id3Keys maps ID3v2 text frames, by their ID3v2.2 and ID3v2.3/2.4 identifiers, to saprobe tag keys.
+//
+//nolint:gochecknoglobals // constant table
+var id3Keys = []struct {
+	v22 string
+	v23 string
+	key string
+}{
+	{"TT2", "TIT2", saprobe.TagTitle},
+	{"TP1", "TPE1", saprobe.TagArtist},
+	{"TAL", "TALB", saprobe.TagAlbum},
+	{"TP2", "TPE2", saprobe.TagAlbumArtist},
+	{"TYE", "TYER", saprobe.TagDate},
+	{"", "TDRC", saprobe.TagDate},
+	{"TCO", "TCON", saprobe.TagGenre},
+	{"TCM", "TCOM", saprobe.TagComposer},
+	{"TCR", "TCOP", saprobe.TagCopyright},
+	{"TSS", "TSSE", saprobe.TagEncoder},
+	{"TT1", "TIT1", saprobe.TagGrouping},
+}
+
+// ReadMetadata returns the tags and pictures of an MP3 stream: those of its ID3v2 tag, completed
+// by the APE, Lyrics3 and ID3v1 blocks trailing the last frame for the keys it lacks.
+func ReadMetadata(rs io.ReadSeeker) (saprobe.Metadata, error) {
+	var metadata saprobe.Metadata
+
+	if tag, ok := readID3v2(rs); ok {
+		addID3v2(&metadata, tag)
+	}
+
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return metadata, fmt.Errorf("seeking to start: %w", err)
+	}
+
+	data, err := io.ReadAll(rs)
+	if err != nil {
+		return metadata, fmt.Errorf("reading mp3 stream: %w", err)
+	}
+
+	_, found := splitTrailers(data)
+	found.addTo(&metadata)
+
+	return metadata, nil
+}
+
+// addID3v2 adds the text, comment, lyrics and picture frames of an ID3v2 tag.
+func addID3v2(metadata *saprobe.Metadata, tag id3Tag) {
+	for id, body := range tag.frames() {
+		if len(body) == 0 {
+			continue
+		}
+
+		switch id {
+		case "TRK", "TRCK":
+			addNumberPair(metadata, decodeText(body[0], body[1:]), saprobe.TagTrackNumber, saprobe.TagTrackTotal)
+		case "TPA", "TPOS":
+			addNumberPair(metadata, decodeText(body[0], body[1:]), saprobe.TagDiscNumber, saprobe.TagDiscTotal)
+		case "TXX", "TXXX":
+			if desc, value, ok := cutText(body[0], body[1:]); ok {
+				metadata.Add(decodeText(body[0], desc), decodeText(body[0], value))
+			}
+		case "COM", "COMM":
+			// iTunes stores its own data (iTunSMPB, iTunNORM...) in described comments. Sound Check
+			// levels are kept, as MP4 freeform items keep them.
+			desc, text, ok := parseComment(body)
+
+			switch {
+			case !ok:
+			case desc == "iTunNORM":
+				metadata.Add(saprobe.TagITunNORM, text)
+			case !strings.HasPrefix(desc, "iTun"):
+				metadata.Add(saprobe.TagComment, text)
+			default:
+			}
+		case "ULT", "USLT":
+			if _, text, ok := parseComment(body); ok {
+				metadata.Add(saprobe.TagLyrics, text)
+			}
+		case "PIC", "APIC":
+			addID3Picture(metadata, id == "PIC", body)
+		default:
+			addID3Text(metadata, id, body)
+		}
+	}
+}
+
+// addID3Text adds a text frame that maps to a saprobe tag, one tag per value: ID3v2.4 separates
+// multiple values with string terminators.
+func addID3Text(metadata *saprobe.Metadata, id string, body []byte) {
+	for _, k := range id3Keys {
+		if k.v22 != id && k.v23 != id {
+			continue
+		}
+
+		encoding, text := body[0], body[1:]
+
+		for len(text) > 0 {
+			value, rest, _ := cutText(encoding, text)
+			decoded := decodeText(encoding, value)
+
+			if k.key == saprobe.TagGenre {
+				decoded = genreName(decoded)
+			}
+
+			metadata.Add(k.key, decoded)
+
+			text = rest
+		}
+
+		return
+	}
+}
+
+// addID3Picture adds an attached picture. ID3v2.2 names the image format with three characters
+// where later versions store a MIME type.
+//
+//revive:disable-next-line:flag-parameter
+func addID3Picture(metadata *saprobe.Metadata, v22 bool, body []byte) {
+	encoding := body[0]
+	rest := body[1:]
+
+	var mime string
+
+	if v22 {
+		if len(rest) < 3 {
+			return
+		}
+
+		mime, rest = "image/"+strings.ToLower(string(rest[:3])), rest[3:]
+		if mime == "image/jpg" {
+			mime = "image/jpeg"
+		}
+	} else {
+		value, after, ok := bytes.Cut(rest, []byte{0})
+		if !ok {
+			return
+		}
+
+		mime, rest = string(value), after
+	}
+
+	if len(rest) < 1 {
+		return
+	}
+
+	pictureType := rest[0]
+
+	desc, data, ok := cutText(encoding, rest[1:])
+	if !ok {
+		return
+	}
+
+	if mime == "" {
+		mime = sniffImage(data)
+	}
+
+	metadata.Pictures = append(metadata.Pictures, saprobe.Picture{
+		Type:        uint32(pictureType),
+		MIME:        mime,
+		Description: decodeText(encoding, desc),
+		Data:        data,
+	})
+}
+
+// genreName resolves the ID3v1 genre references of a content type: "17", "(17)" or "(17)Rock"
+// become "Rock".
+func genreName(value string) string {
+	if ref, rest, ok := strings.Cut(strings.TrimPrefix(value, "("), ")"); ok && strings.HasPrefix(value, "(") {
+		if rest != "" {
+			return rest
+		}
+
+		value = ref
+	}
+
+	if index, err := strconv.Atoi(value); err == nil && index >= 0 && index < len(id3v1Genres) {
+		return id3v1Genres[index]
+	}
+
+	return value
+}
@@ -0,0 +1,65 @@
+package mp3
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/farcloser/saprobe"
+)
+
+// LAME tag ReplayGain fields: a 3-bit name code, a 3-bit originator code, a sign bit and a 9-bit
+// magnitude in tenths of a dB.
+const (
+	lameGainNameShift  = 13
+	lameGainRadio      = 1
+	lameGainAudiophile = 2
+	lameGainSign       = 0x200
+	lameGainMagnitude  = 0x1FF
+	lamePeakScale      = 1 << 23
+)
+
+// ReadReplayGain returns the ReplayGain of an MP3 stream: that of its tags (ID3v2 TXXX frames, APE
+// items, the iTunNORM comment), completed by the radio (track) and audiophile (album) gains of its
+// LAME tag.
+func ReadReplayGain(rs io.ReadSeeker) (saprobe.ReplayGain, error) {
+	metadata, err := ReadMetadata(rs)
+	if err != nil {
+		return saprobe.ReplayGain{}, err
+	}
+
+	str, err := readStream(rs)
+	if err != nil {
+		return saprobe.ReplayGain{}, err
+	}
+
+	return metadata.ReplayGain().Or(parseGaplessInfo(rs, str.firstFrame()).replayGain), nil
+}
+
+// lameReplayGain reads the ReplayGain fields of a LAME tag. The peak is that of the track: it only
+// goes with the radio gain.
+func lameReplayGain(lame []byte) saprobe.ReplayGain {
+	gains := saprobe.ReplayGain{
+		Track: lameGain(binary.BigEndian.Uint16(lame[lameTrackGain:]), lameGainRadio),
+		Album: lameGain(binary.BigEndian.Uint16(lame[lameAlbumGain:]), lameGainAudiophile),
+	}
+
+	if gains.Track.Set {
+		gains.Track.Peak = float64(binary.BigEndian.Uint32(lame[lamePeakOffset:])) / lamePeakScale
+	}
+
+	return gains
+}
+
+// lameGain decodes a ReplayGain field, which is set when it carries the expected name code.
+func lameGain(field, name uint16) saprobe.Gain {
+	if field>>lameGainNameShift != name {
+		return saprobe.Gain{}
+	}
+
+	gain := float64(field&lameGainMagnitude) / 10
+	if field&lameGainSign != 0 {
+		gain = -gain
+	}
+
+	return saprobe.Gain{Set: true, Gain: gain}
+}
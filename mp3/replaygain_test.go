@@ -0,0 +1,82 @@
+package mp3_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/mp3"
+)
+
+// TestReadReplayGain checks that the radio and audiophile gains of a LAME tag are read with the track
+// peak, that a gain field of the wrong name is ignored, and that ID3v2 tags have priority.
+func TestReadReplayGain(t *testing.T) {
+	t.Parallel()
+
+	music := mp3.SilentFrames(10)
+
+	// Name code, originator code (3, automatic), sign and magnitude in tenths of a dB.
+	const (
+		radioMinus64     = 1<<13 | 3<<10 | 0x200 | 64
+		audiophilePlus25 = 2<<13 | 3<<10 | 25
+	)
+
+	gains := mp3.LAMEFields{Frames: 10, Peak: 1 << 22, TrackGain: radioMinus64, AlbumGain: audiophilePlus25}
+	swapped := mp3.LAMEFields{Frames: 10, Peak: 1 << 22, TrackGain: audiophilePlus25, AlbumGain: radioMinus64}
+
+	for _, test := range []struct {
+		name   string
+		tag    []byte
+		fields mp3.LAMEFields
+		want   saprobe.ReplayGain
+	}{
+		{"LAME", nil, gains, saprobe.ReplayGain{
+			Track: saprobe.Gain{Set: true, Gain: -6.4, Peak: 0.5},
+			Album: saprobe.Gain{Set: true, Gain: 2.5},
+		}},
+		{"wrong names", nil, swapped, saprobe.ReplayGain{}},
+		{"ID3v2 and LAME", id3v2(3,
+			id3Frame{"TXXX", latin1Text(saprobe.TagReplayGainAlbumGain, "-1.25 dB")},
+			id3Frame{"TXXX", latin1Text(saprobe.TagReplayGainAlbumPeak, "0.75")},
+		), gains, saprobe.ReplayGain{
+			Track: saprobe.Gain{Set: true, Gain: -6.4, Peak: 0.5},
+			Album: saprobe.Gain{Set: true, Gain: -1.25, Peak: 0.75},
+		}},
+		{"none", nil, mp3.LAMEFields{Frames: 10}, saprobe.ReplayGain{}},
+	} {
+		data := append(append(test.tag, mp3.InfoFrame(test.fields, music, false)...), music...)
+
+		got, err := mp3.ReadReplayGain(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if got != test.want {
+			t.Errorf("%s: %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+// TestReadReplayGainITunNORM checks that the Sound Check level of an iTunNORM comment is kept as a
+// tag, and has priority over the LAME radio gain.
+func TestReadReplayGainITunNORM(t *testing.T) {
+	t.Parallel()
+
+	// A level of 2000 of 1/1000 W, -3.01 dB, and a peak of 0x4000 of 32768.
+	const norm = " 000007D0 000007D0 00000000 00000000 00000000 00000000 00004000 00004000 00000000 00000000"
+
+	music := mp3.SilentFrames(10)
+	tag := id3v2(3, id3Frame{"COMM", comm(latin1Text("iTunNORM", norm))})
+	fields := mp3.LAMEFields{Frames: 10, Peak: 1 << 22, TrackGain: 1<<13 | 3<<10 | 0x200 | 64}
+	stream := mp3.InfoFrame(fields, music, false)
+
+	got, err := mp3.ReadReplayGain(bytes.NewReader(append(append(tag, stream...), music...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !got.Track.Set || math.Abs(got.Track.Gain+10*math.Log10(2)) > 1e-9 || got.Track.Peak != 0.5 || got.Album.Set {
+		t.Errorf("%+v, want a track gain of -3.01 dB peaking at 0.5", got)
+	}
+}
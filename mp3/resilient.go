@@ -0,0 +1,223 @@
+package mp3
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/farcloser/saprobe"
+)
+
+var errSyncLost = errors.New("mp3: lost frame sync")
+
+// syncGap is junk the frame chain skipped to resynchronize: most often frames whose header was
+// damaged.
+type syncGap struct {
+	before int // index of the frame that follows the gap
+	offset int // of the gap within the data
+	size   int // in bytes
+	span   int // bytes from the start of the frame before the gap, if any, to the frame after it
+	frames int // frames lost in the gap
+}
+
+// frameOffsets returns the offset of every frame in data from the first one, following the frame
+// chain and resynchronizing on the next frame of the stream after junk, and the gaps it skipped to
+// do so. Free-format frames are freeBase bytes long plus their padding. It stops at trailing tags
+// and at free-format frames of a stream whose frame length was not measured.
+func frameOffsets(data []byte, first, freeBase int) ([]int, []syncGap) {
+	var (
+		offsets []int
+		gaps    []syncGap
+		total   int
+	)
+
+	stream, _ := parseFrameHeader(data[first:])
+	pos := first
+
+	for pos+frameHeaderSize <= len(data) {
+		size, ok := chainedFrame(data, pos, stream, freeBase)
+		if ok && size == 0 {
+			break
+		}
+
+		if ok {
+			offsets = append(offsets, pos)
+			total += size
+			pos += size
+
+			continue
+		}
+
+		if isTrailer(data[pos:]) {
+			break
+		}
+
+		next := resync(data, pos+1, stream, freeBase)
+		if next < 0 {
+			break
+		}
+
+		span := next - pos
+		if len(offsets) > 0 {
+			span = next - offsets[len(offsets)-1]
+		}
+
+		gaps = append(gaps, syncGap{before: len(offsets), offset: pos, size: next - pos, span: span})
+		pos = next
+	}
+
+	// The frame before a gap may end anywhere in it, as its header may have been damaged: count the
+	// frames of average length from its start, less itself.
+	average := float64(total) / float64(max(len(offsets), 1))
+
+	for idx, gap := range gaps {
+		frames := lostFrames(gap.span, average)
+		if gap.before > 0 {
+			frames = max(frames-1, 0)
+		}
+
+		gaps[idx].frames = frames
+	}
+
+	return offsets, gaps
+}
+
+// sameStream reports whether the frame of header h can belong to the stream the frame of header
+// first starts: it has the same version, layer, sample rate and number of channels. Stereo and
+// joint stereo frames alternate in some streams.
+func (h frameHeader) sameStream(first frameHeader) bool {
+	return h.version == first.version && h.layer == first.layer && h.rateIndex == first.rateIndex &&
+		h.channels() == first.channels()
+}
+
+// streamFrame returns the length of the frame at pos in data, and whether its header belongs to the
+// stream of header first. The length is 0 for a free-format frame of a stream whose frame length
+// was not measured.
+func streamFrame(data []byte, pos int, first frameHeader, freeBase int) (int, bool) {
+	hdr, ok := parseFrameHeader(data[pos:])
+	if !ok || !hdr.sameStream(first) {
+		return 0, false
+	}
+
+	return hdr.length(freeBase), true
+}
+
+// continues reports whether the stream of header first goes on at pos in data: a frame of the
+// stream starts there, or the audio ends.
+func continues(data []byte, pos int, first frameHeader, freeBase int) bool {
+	if pos+frameHeaderSize > len(data) || isTrailer(data[pos:]) {
+		return true
+	}
+
+	_, ok := streamFrame(data, pos, first, freeBase)
+
+	return ok
+}
+
+// chainedFrame is streamFrame for a frame the chain leads to. A damaged header may still parse,
+// and claim any length: a frame that runs past the start of the next frame of the stream is junk.
+func chainedFrame(data []byte, pos int, first frameHeader, freeBase int) (int, bool) {
+	size, ok := streamFrame(data, pos, first, freeBase)
+	if !ok || size == 0 || continues(data, pos+size, first, freeBase) {
+		return size, ok
+	}
+
+	next := resync(data, pos+1, first, freeBase)
+
+	return size, next < 0 || next >= pos+size
+}
+
+// resync returns the offset of the next frame of the stream of header first from pos on, or -1 if
+// there is none. Damage may leave sync words and valid headers behind: a frame only counts if the
+// stream goes on after it.
+func resync(data []byte, pos int, first frameHeader, freeBase int) int {
+	for pos+frameHeaderSize <= len(data) {
+		next := findSyncWord(data[pos:])
+		if next < 0 {
+			return -1
+		}
+
+		if size, ok := streamFrame(data, pos+next, first, freeBase); ok &&
+			(size == 0 || continues(data, pos+next+size, first, freeBase)) {
+			return pos + next
+		}
+
+		pos += next + 1
+	}
+
+	return -1
+}
+
+// lostFrames estimates the number of frames of frameSize bytes in a gap. Gaps under half a frame
+// are taken for stray bytes between frames.
+func lostFrames(gapSize int, frameSize float64) int {
+	if frameSize == 0 {
+		return 0
+	}
+
+	return int(math.Round(float64(gapSize) / frameSize))
+}
+
+// decodeResilient decodes the stream like decodeAll, but replaces every frame the decoder rejects
+// with one frame of silence, and the frames lost to a sync loss with as many, so the timeline keeps
+// its length. The decoder state survives the failure: the frames that follow decode normally,
+// unless their main data starts in the bit reservoir of a damaged frame.
+func decodeResilient(str stream) ([]byte, []saprobe.Damage) {
+	var damaged []saprobe.Damage
+
+	decoder := newFrameDecoder(str.first)
+	buf := make([]byte, 0, len(str.offsets)*str.frameBytes)
+	gaps := str.gaps
+
+	for idx := range str.offsets {
+		for len(gaps) > 0 && gaps[0].before == idx {
+			if gaps[0].frames > 0 {
+				start := int64(len(buf) / str.sampleBytes)
+				buf = append(buf, make([]byte, gaps[0].frames*str.frameBytes)...)
+
+				damaged = append(damaged, saprobe.Damage{
+					Start: start,
+					End:   int64(len(buf) / str.sampleBytes),
+					Err:   str.gapError(gaps[0], start),
+				})
+			}
+
+			gaps = gaps[1:]
+		}
+
+		frame, ok := str.frame(idx)
+		if !ok {
+			break
+		}
+
+		pcm, err := decoder.decode(frame)
+		if err == nil {
+			buf = pcm.appendPCM(buf, 0, pcm.length)
+
+			continue
+		}
+
+		start := int64(len(buf) / str.sampleBytes)
+		buf = append(buf, make([]byte, str.frameBytes)...)
+
+		damaged = append(damaged, saprobe.Damage{
+			Start: start,
+			End:   int64(len(buf) / str.sampleBytes),
+			Err:   str.frameError(idx, start, err),
+		})
+	}
+
+	return buf, damaged
+}
+
+// gapError locates a sync loss, which started at sample.
+func (s stream) gapError(gap syncGap, sample int64) *saprobe.DecodeError {
+	return &saprobe.DecodeError{
+		Codec:  codecName,
+		Kind:   saprobe.ErrCorrupt,
+		Offset: s.base + int64(gap.offset),
+		Frame:  gap.before,
+		Sample: sample,
+		Err:    fmt.Errorf("%w: %d bytes skipped", errSyncLost, gap.size),
+	}
+}
@@ -0,0 +1,125 @@
+package mp3_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/mp3"
+)
+
+// TestResilientSyncLoss checks that frames lost to a sync loss are concealed, so that the output
+// keeps its length, and reported as damage.
+func TestResilientSyncLoss(t *testing.T) {
+	t.Parallel()
+
+	const frames = 20
+
+	data := mp3.SilentFrames(frames)
+
+	// Wipe the header of frame 5, and the sync word of frames 9 and 10.
+	copy(data[5*mp3.SilentFrameSize:], []byte{0, 0, 0, 0})
+	copy(data[9*mp3.SilentFrameSize:], []byte{0, 0})
+	copy(data[10*mp3.SilentFrameSize:], []byte{0, 0})
+
+	pcm, format, report, err := mp3.DecodeWithOptions(bytes.NewReader(data), saprobe.Options{Resilient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sampleBytes := int(format.Channels) * format.BitDepth.BytesPerSample()
+	if got := len(pcm) / sampleBytes; got != frames*mp3.SilentFrameSamples {
+		t.Fatalf("decoded %d samples, want %d", got, frames*mp3.SilentFrameSamples)
+	}
+
+	want := []saprobe.Damage{
+		{Start: 5 * mp3.SilentFrameSamples, End: 6 * mp3.SilentFrameSamples},
+		{Start: 9 * mp3.SilentFrameSamples, End: 11 * mp3.SilentFrameSamples},
+	}
+
+	if len(report.Damaged) != len(want) {
+		t.Fatalf("damaged ranges: %v, want %v", report.Damaged, want)
+	}
+
+	for idx, damage := range report.Damaged {
+		if damage.Start != want[idx].Start || damage.End != want[idx].End {
+			t.Errorf("damaged range %d: %d-%d, want %d-%d",
+				idx, damage.Start, damage.End, want[idx].Start, want[idx].End)
+		}
+
+		if !errors.Is(damage.Err, saprobe.ErrCorrupt) || !errors.Is(damage.Err, mp3.ErrSyncLost) {
+			t.Errorf("damaged range %d: %v, want a corrupt stream sync loss", idx, damage.Err)
+		}
+	}
+
+	// An intact stream reports no damage.
+	intact := mp3.SilentFrames(frames)
+
+	_, _, report, err = mp3.DecodeWithOptions(bytes.NewReader(intact), saprobe.Options{Resilient: true})
+	if err != nil || len(report.Damaged) != 0 {
+		t.Errorf("intact stream: %v, damaged %v", err, report.Damaged)
+	}
+}
+
+// TestResilientHeaderDamage checks that a header damaged into another valid header, of another
+// format or claiming another length, neither shifts nor stretches the output.
+func TestResilientHeaderDamage(t *testing.T) {
+	t.Parallel()
+
+	const frames = 20
+
+	cases := []struct {
+		name    string
+		header  []byte
+		damaged bool
+	}{
+		{"other sample rate", []byte{0xFF, 0xFB, 0xE8, 0xC0}, true},
+		{"other channel mode", []byte{0xFF, 0xFB, 0x90, 0x00}, true},
+		{"longer frame", []byte{0xFF, 0xFB, 0xE0, 0xC0}, true},
+		// The frame stays in the chain and still decodes to a full frame: only stray bytes follow it.
+		{"shorter frame", []byte{0xFF, 0xFB, 0x10, 0xC0}, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			data := mp3.SilentFrames(frames)
+			copy(data[5*mp3.SilentFrameSize:], tc.header)
+
+			pcm, format, report, err := mp3.DecodeWithOptions(bytes.NewReader(data), saprobe.Options{Resilient: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sampleBytes := int(format.Channels) * format.BitDepth.BytesPerSample()
+			if got := len(pcm) / sampleBytes; got != frames*mp3.SilentFrameSamples {
+				t.Errorf("decoded %d samples, want %d", got, frames*mp3.SilentFrameSamples)
+			}
+
+			// The index follows the same chain, leaving the junk out.
+			index, err := mp3.Index(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := frames - len(report.Damaged); len(index.Frames) != want {
+				t.Errorf("indexed %d frames, want %d", len(index.Frames), want)
+			}
+
+			if !tc.damaged {
+				if len(report.Damaged) != 0 {
+					t.Errorf("damaged ranges: %v, want none", report.Damaged)
+				}
+
+				return
+			}
+
+			want := saprobe.Damage{Start: 5 * mp3.SilentFrameSamples, End: 6 * mp3.SilentFrameSamples}
+			if len(report.Damaged) != 1 || report.Damaged[0].Start != want.Start || report.Damaged[0].End != want.End {
+				t.Errorf("damaged ranges: %v, want %d-%d", report.Damaged, want.Start, want.End)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package mp3
+
+// Mixed blocks code the lowest two subbands as long blocks, then switch to short blocks from short
+// band 3. At MPEG-1 rates that boundary is long band 8, at lower rates long band 6.
+const (
+	mixedShortStart    = 3
+	mixedLongEndMPEG1  = 8
+	mixedLongEndLSF    = 6
+	lastLongFactorBand = longBands - 1
+	lastShortFactors   = shortBands - 1
+	maxLSFScalefactors = 36
+)
+
+// scalefactors are the scalefactors of one channel, kept across granules for scfsi reuse.
+type scalefactors struct {
+	long  [longBands]int
+	short [shortBands][shortWindows]int
+}
+
+// MPEG-1 scalefactor bit lengths, indexed by scalefac_compress (slen1, slen2).
+//
+//nolint:gochecknoglobals // constant table
+var slenMPEG1 = [2][16]int{
+	{0, 0, 0, 0, 3, 1, 1, 1, 2, 2, 2, 3, 3, 3, 4, 4},
+	{0, 1, 2, 3, 0, 1, 2, 3, 1, 2, 3, 1, 2, 3, 2, 3},
+}
+
+// scfsiGroups are the long band groups whose scalefactors scfsi lets granule 1 reuse.
+//
+//nolint:gochecknoglobals // constant table
+var scfsiGroups = [scfsiBands + 1]int{0, 6, 11, 16, 21}
+
+// Number of scalefactors per partition of the MPEG-2 LSF syntax (ISO/IEC 13818-3 Table B.1),
+// indexed by table, block kind (long, short, mixed) and partition.
+//
+//nolint:gochecknoglobals // constant table
+var lsfPartitions = [6][3][4]int{
+	{{6, 5, 5, 5}, {9, 9, 9, 9}, {6, 9, 9, 9}},
+	{{6, 5, 7, 3}, {9, 9, 12, 6}, {6, 9, 12, 6}},
+	{{11, 10, 0, 0}, {18, 18, 0, 0}, {15, 18, 0, 0}},
+	{{7, 7, 7, 0}, {12, 12, 12, 0}, {6, 15, 12, 0}},
+	{{6, 6, 6, 3}, {12, 9, 9, 6}, {6, 12, 9, 6}},
+	{{8, 8, 5, 0}, {15, 12, 9, 0}, {6, 18, 9, 0}},
+}
+
+// shortBlocks reports whether the granule uses short windows, in whole or mixed.
+func (g *granule) shortBlocks() bool {
+	return g.windowSwitching && g.blockType == blockShort
+}
+
+// mixedLongEnd returns the long band at which mixed blocks switch to short windows.
+func (h frameHeader) mixedLongEnd() int {
+	if h.lsf() {
+		return mixedLongEndLSF
+	}
+
+	return mixedLongEndMPEG1
+}
+
+// readScalefactorsMPEG1 reads the scalefactors of granule gr, reusing those of granule 0 for the
+// band groups scfsi marks.
+func readScalefactorsMPEG1(br *bitReader, gran *granule, scfsi [scfsiBands]bool, gr int, sf *scalefactors) {
+	slen1, slen2 := slenMPEG1[0][gran.scalefacCompress], slenMPEG1[1][gran.scalefacCompress]
+
+	if gran.shortBlocks() {
+		sfb := 0
+
+		if gran.mixed {
+			for ; sfb < mixedLongEndMPEG1; sfb++ {
+				sf.long[sfb] = br.read(slen1)
+			}
+
+			sfb = mixedShortStart
+		}
+
+		for ; sfb < lastShortFactors; sfb++ {
+			slen := slen1
+			if sfb >= 6 {
+				slen = slen2
+			}
+
+			for window := range shortWindows {
+				sf.short[sfb][window] = br.read(slen)
+			}
+		}
+
+		sf.short[lastShortFactors] = [shortWindows]int{}
+
+		return
+	}
+
+	for group := range scfsiBands {
+		if gr == 1 && scfsi[group] {
+			continue
+		}
+
+		slen := slen1
+		if group >= 2 {
+			slen = slen2
+		}
+
+		for sfb := scfsiGroups[group]; sfb < scfsiGroups[group+1]; sfb++ {
+			sf.long[sfb] = br.read(slen)
+		}
+	}
+
+	sf.long[lastLongFactorBand] = 0
+}
+
+// readScalefactorsLSF reads MPEG-2 LSF scalefactors. The right channel of an intensity stereo
+// frame codes them differently. A scalefac_compress of 500 or more implies preflag, which is set
+// on gran.
+//
+//revive:disable-next-line:flag-parameter
+func readScalefactorsLSF(br *bitReader, gran *granule, intensityRight bool, sf *scalefactors) {
+	var (
+		slen  [4]int
+		table int
+	)
+
+	sfc := gran.scalefacCompress
+
+	switch {
+	case intensityRight:
+		sfc >>= 1
+
+		switch {
+		case sfc < 180:
+			slen, table = [4]int{sfc / 36, (sfc % 36) / 6, (sfc % 36) % 6, 0}, 3
+		case sfc < 244:
+			sfc -= 180
+			slen, table = [4]int{(sfc & 63) >> 4, (sfc & 15) >> 2, sfc & 3, 0}, 4
+		default:
+			sfc -= 244
+			slen, table = [4]int{sfc / 3, sfc % 3, 0, 0}, 5
+		}
+	case sfc < 400:
+		slen = [4]int{(sfc >> 4) / 5, (sfc >> 4) % 5, (sfc & 15) >> 2, sfc & 3}
+	case sfc < 500:
+		sfc -= 400
+		slen, table = [4]int{(sfc >> 2) / 5, (sfc >> 2) % 5, sfc & 3, 0}, 1
+	default:
+		sfc -= 500
+		slen, table = [4]int{sfc / 3, sfc % 3, 0, 0}, 2
+		gran.preflag = true
+	}
+
+	kind := 0
+	if gran.shortBlocks() {
+		kind = 1
+		if gran.mixed {
+			kind = 2
+		}
+	}
+
+	var (
+		values [maxLSFScalefactors]int
+		count  int
+	)
+
+	for part, bands := range lsfPartitions[table][kind] {
+		for range bands {
+			values[count] = br.read(slen[part])
+			count++
+		}
+	}
+
+	switch kind {
+	case 0:
+		copy(sf.long[:], values[:lastLongFactorBand])
+		sf.long[lastLongFactorBand] = 0
+	case 1:
+		for idx := range count {
+			sf.short[idx/shortWindows][idx%shortWindows] = values[idx]
+		}
+
+		sf.short[lastShortFactors] = [shortWindows]int{}
+	default:
+		copy(sf.long[:mixedLongEndLSF], values[:mixedLongEndLSF])
+
+		for idx := range count - mixedLongEndLSF {
+			sf.short[mixedShortStart+idx/shortWindows][idx%shortWindows] = values[mixedLongEndLSF+idx]
+		}
+
+		sf.short[lastShortFactors] = [shortWindows]int{}
+	}
+}
@@ -0,0 +1,160 @@
+package mp3
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	errBigValues = errors.New("mp3: big_values exceeds 288")
+	errBlockType = errors.New("mp3: window switching with a normal block type")
+)
+
+// Block types of a granule.
+const (
+	blockNormal = 0
+	blockStart  = 1
+	blockShort  = 2
+	blockStop   = 3
+)
+
+const (
+	granuleLines    = 576
+	maxBigValues    = granuleLines / 2
+	scfsiBands      = 4
+	maxGranules     = 2
+	maxChannels     = 2
+	shortWindows    = 3
+	bigValueRegions = 3
+)
+
+// granule is the side information of one granule of one channel.
+type granule struct {
+	part23Length     int // bits of scalefactors and Huffman data
+	bigValues        int
+	globalGain       int
+	scalefacCompress int
+	windowSwitching  bool
+	blockType        int
+	mixed            bool
+	tableSelect      [bigValueRegions]int
+	subblockGain     [shortWindows]int
+	region0Count     int
+	region1Count     int
+	preflag          bool
+	scalefacScale    bool
+	count1Table      int
+}
+
+// sideInfo is the Layer III side information of a frame.
+type sideInfo struct {
+	mainDataBegin int
+	scfsi         [maxChannels][scfsiBands]bool
+	granules      [maxGranules][maxChannels]granule
+}
+
+// lsf reports whether the frame uses the MPEG-2 low sampling frequency syntax: one granule per
+// frame and 9-bit scalefac_compress.
+func (h frameHeader) lsf() bool {
+	return h.version != mpegVersion1
+}
+
+// granules returns the number of granules per frame.
+func (h frameHeader) granules() int {
+	if h.lsf() {
+		return 1
+	}
+
+	return maxGranules
+}
+
+// readSideInfo parses the side information that follows the header and optional CRC.
+func readSideInfo(hdr frameHeader, data []byte) (sideInfo, error) {
+	var (
+		info sideInfo
+		br   = bitReader{data: data}
+	)
+
+	nch := hdr.channels()
+
+	if hdr.lsf() {
+		info.mainDataBegin = br.read(8)
+		br.read(nch) // private bits
+	} else {
+		info.mainDataBegin = br.read(9)
+
+		// Private bits.
+		if nch == 1 {
+			br.read(5)
+		} else {
+			br.read(3)
+		}
+
+		for ch := range nch {
+			for band := range scfsiBands {
+				info.scfsi[ch][band] = br.flag()
+			}
+		}
+	}
+
+	for gr := range hdr.granules() {
+		for ch := range nch {
+			if err := readGranule(&br, hdr, &info.granules[gr][ch]); err != nil {
+				return info, fmt.Errorf("granule %d, channel %d: %w", gr, ch, err)
+			}
+		}
+	}
+
+	return info, nil
+}
+
+func readGranule(br *bitReader, hdr frameHeader, gr *granule) error {
+	gr.part23Length = br.read(12)
+	gr.bigValues = br.read(9)
+	gr.globalGain = br.read(8)
+
+	if hdr.lsf() {
+		gr.scalefacCompress = br.read(9)
+	} else {
+		gr.scalefacCompress = br.read(4)
+	}
+
+	if gr.bigValues > maxBigValues {
+		return errBigValues
+	}
+
+	gr.windowSwitching = br.flag()
+
+	if gr.windowSwitching {
+		gr.blockType = br.read(2)
+		gr.mixed = br.flag()
+
+		for region := range 2 {
+			gr.tableSelect[region] = br.read(5)
+		}
+
+		for window := range shortWindows {
+			gr.subblockGain[window] = br.read(3)
+		}
+
+		if gr.blockType == blockNormal {
+			return errBlockType
+		}
+	} else {
+		for region := range bigValueRegions {
+			gr.tableSelect[region] = br.read(5)
+		}
+
+		gr.region0Count = br.read(4)
+		gr.region1Count = br.read(3)
+	}
+
+	if !hdr.lsf() {
+		gr.preflag = br.flag()
+	}
+
+	gr.scalefacScale = br.flag()
+	gr.count1Table = br.read(1)
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package mp3
+
+import "math"
+
+// Intensity stereo positions: MPEG-1 codes seven positions and treats the eighth as illegal.
+const (
+	intensityPositionsMPEG1 = 7
+	intensityFullLeft       = 6
+)
+
+// jointStereo undoes joint stereo coding on the spectra of both channels, laid out as they were
+// decoded (short windows not yet reordered). Above the highest non-zero band of the right channel,
+// intensity stereo rebuilds both channels from the left one; elsewhere mid/side stereo applies
+// when enabled. As in libavcodec, the last band of each block kind takes the intensity position
+// of the band below it.
+func jointStereo(hdr frameHeader, right *granule, rightSF *scalefactors, spectra *[maxChannels][granuleLines]float64) {
+	ms := hdr.modeExt&modeExtMS != 0
+
+	if hdr.modeExt&modeExtIntensity == 0 {
+		if ms {
+			midSide(spectra, 0, granuleLines)
+		}
+
+		return
+	}
+
+	bands := hdr.bands()
+	longEnd := longBands
+	found := false // a non-zero right channel line was found in the bands scanned so far
+
+	if right.shortBlocks() {
+		shortStart := 0
+		longEnd = 0
+
+		if right.mixed {
+			shortStart, longEnd = mixedShortStart, hdr.mixedLongEnd()
+		}
+
+		var foundWindow [shortWindows]bool
+
+		pos := granuleLines
+
+		for sfb := shortBands - 1; sfb >= shortStart; sfb-- {
+			width := bands.short[sfb+1] - bands.short[sfb]
+
+			for window := shortWindows - 1; window >= 0; window-- {
+				pos -= width
+
+				if !foundWindow[window] {
+					if nonZero(spectra[1][pos : pos+width]) {
+						foundWindow[window] = true
+					} else if intensity(hdr, right, rightSF.short[min(sfb, lastShortFactors-1)][window],
+						spectra, pos, width) {
+						continue
+					}
+				}
+
+				if ms {
+					midSide(spectra, pos, width)
+				}
+			}
+		}
+
+		found = foundWindow[0] || foundWindow[1] || foundWindow[2]
+	}
+
+	pos := bands.long[longEnd]
+
+	for sfb := longEnd - 1; sfb >= 0; sfb-- {
+		width := bands.long[sfb+1] - bands.long[sfb]
+		pos -= width
+
+		if !found {
+			if nonZero(spectra[1][pos : pos+width]) {
+				found = true
+			} else if intensity(hdr, right, rightSF.long[min(sfb, lastLongFactorBand-1)], spectra, pos, width) {
+				continue
+			}
+		}
+
+		if ms {
+			midSide(spectra, pos, width)
+		}
+	}
+}
+
+// intensity rebuilds both channels of lines [pos, pos+width) from the left channel, at intensity
+// position position. It reports false, leaving the lines alone, for an illegal position.
+func intensity(
+	hdr frameHeader,
+	right *granule,
+	position int,
+	spectra *[maxChannels][granuleLines]float64,
+	pos, width int,
+) bool {
+	var leftGain, rightGain float64
+
+	switch {
+	case hdr.lsf():
+		// Positions alternate between attenuating the right and the left channel, by steps of
+		// 2^-1/4 or 2^-1/2 depending on intensity_scale.
+		gain := math.Exp2(-float64((right.scalefacCompress&1+1)*((position+1)>>1)) / 4)
+		leftGain, rightGain = 1, gain
+
+		if position&1 == 1 {
+			leftGain, rightGain = gain, 1
+		}
+	case position >= intensityPositionsMPEG1:
+		return false
+	case position == intensityFullLeft:
+		leftGain, rightGain = 1, 0
+	default:
+		ratio := math.Tan(float64(position) * math.Pi / 12)
+		leftGain, rightGain = ratio/(1+ratio), 1/(1+ratio)
+	}
+
+	for line := pos; line < pos+width; line++ {
+		value := spectra[0][line]
+		spectra[0][line] = value * leftGain
+		spectra[1][line] = value * rightGain
+	}
+
+	return true
+}
+
+// midSide turns mid/side lines [pos, pos+width) back into left/right.
+func midSide(spectra *[maxChannels][granuleLines]float64, pos, width int) {
+	for line := pos; line < pos+width; line++ {
+		mid, side := spectra[0][line], spectra[1][line]
+		spectra[0][line] = (mid + side) * math.Sqrt2 / 2
+		spectra[1][line] = (mid - side) * math.Sqrt2 / 2
+	}
+}
+
+func nonZero(lines []float64) bool {
+	for _, value := range lines {
+		if value != 0 {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,150 @@
+package mp3
+
+import (
+	"math"
+	"math/bits"
+)
+
+const (
+	synthesisWindow = 512
+	synthesisBuffer = 1024
+)
+
+// synthesisHalfWindow is the first half, plus the midpoint, of the polyphase synthesis window of
+// ISO/IEC 11172-3 Table B.3, in units of 2^-16. The second half mirrors it, negated except at
+// multiples of 64.
+//
+//nolint:gochecknoglobals // constant table
+var synthesisHalfWindow = [synthesisWindow/2 + 1]int32{
+	0, -1, -1, -1, -1, -1, -1, -2, -2, -2,
+	-2, -3, -3, -4, -4, -5, -5, -6, -7, -7,
+	-8, -9, -10, -11, -13, -14, -16, -17, -19, -21,
+	-24, -26, -29, -31, -35, -38, -41, -45, -49, -53,
+	-58, -63, -68, -73, -79, -85, -91, -97, -104, -111,
+	-117, -125, -132, -139, -147, -154, -161, -169, -176, -183,
+	-190, -196, -202, -208, 213, 218, 222, 225, 227, 228,
+	228, 227, 224, 221, 215, 208, 200, 189, 177, 163,
+	146, 127, 106, 83, 57, 29, -2, -36, -72, -111,
+	-153, -197, -244, -294, -347, -401, -459, -519, -581, -645,
+	-711, -779, -848, -919, -991, -1064, -1137, -1210, -1283, -1356,
+	-1428, -1498, -1567, -1634, -1698, -1759, -1817, -1870, -1919, -1962,
+	-2001, -2032, -2057, -2075, -2085, -2087, -2080, -2063, 2037, 2000,
+	1952, 1893, 1822, 1739, 1644, 1535, 1414, 1280, 1131, 970,
+	794, 605, 402, 185, -45, -288, -545, -814, -1095, -1388,
+	-1692, -2006, -2330, -2663, -3004, -3351, -3705, -4063, -4425, -4788,
+	-5153, -5517, -5879, -6237, -6589, -6935, -7271, -7597, -7910, -8209,
+	-8491, -8755, -8998, -9219, -9416, -9585, -9727, -9838, -9916, -9959,
+	-9966, -9935, -9863, -9750, -9592, -9389, -9139, -8840, -8492, -8092,
+	-7640, -7134, 6574, 5959, 5288, 4561, 3776, 2935, 2037, 1082,
+	70, -998, -2122, -3300, -4533, -5818, -7154, -8540, -9975, -11455,
+	-12980, -14548, -16155, -17799, -19478, -21189, -22929, -24694, -26482, -28289,
+	-30112, -31947, -33791, -35640, -37489, -39336, -41176, -43006, -44821, -46617,
+	-48390, -50137, -51853, -53534, -55178, -56778, -58333, -59838, -61289, -62684,
+	-64019, -65290, -66494, -67629, -68692, -69679, -70590, -71420, -72169, -72835,
+	-73415, -73908, -74313, -74630, -74856, -74992, 75038,
+}
+
+//nolint:gochecknoglobals // computed constant tables
+var (
+	synthesisD = func() [synthesisWindow]float64 {
+		var window [synthesisWindow]float64
+
+		for i, value := range synthesisHalfWindow {
+			window[i] = float64(value) / (1 << 16)
+
+			if i > 0 && i < synthesisWindow/2 {
+				mirrored := -window[i]
+				if i%64 == 0 {
+					mirrored = window[i]
+				}
+
+				window[synthesisWindow-i] = mirrored
+			}
+		}
+
+		return window
+	}()
+
+	// dctScales holds, for each DCT size n, the odd-part factors 1/(2cos((2k+1)π/2n)), k < n/2,
+	// indexed by the bit length of n.
+	dctScales = func() [7][]float64 {
+		var scales [7][]float64
+
+		for size := 2; size <= subbands; size *= 2 {
+			factors := make([]float64, size/2)
+			for k := range factors {
+				factors[k] = 1 / (2 * math.Cos(float64(2*k+1)*math.Pi/float64(2*size)))
+			}
+
+			scales[bits.Len(uint(size))] = factors
+		}
+
+		return scales
+	}()
+)
+
+// polyphase is the synthesis filterbank state of one channel.
+type polyphase struct {
+	v       [synthesisBuffer]float64
+	scratch [subbands]float64
+}
+
+// synthesize turns one time slot of 32 subband samples into 32 PCM samples. The matrixing
+// V[i] = Σ cos((16+i)(2k+1)π/64)·S[k] is computed from a 32-point DCT-II of the subband samples,
+// whose symmetries give all 64 values.
+func (p *polyphase) synthesize(samples *[subbands]float64, out []float64) {
+	copy(p.v[64:], p.v[:synthesisBuffer-64])
+
+	dct := *samples
+	dctII(dct[:], p.scratch[:])
+
+	for i := range 16 {
+		p.v[i] = dct[16+i]
+		p.v[48+i] = -dct[i]
+	}
+
+	p.v[16] = 0
+
+	for i := 17; i < 48; i++ {
+		p.v[i] = -dct[48-i]
+	}
+
+	for j := range subbands {
+		var sum float64
+
+		for i := 0; i < synthesisWindow; i += 64 {
+			sum += p.v[2*i+j]*synthesisD[i+j] + p.v[2*i+96+j]*synthesisD[i+32+j]
+		}
+
+		out[j] = sum
+	}
+}
+
+// dctII computes in place X[m] = Σ x[k]·cos((2k+1)mπ/2n) for a power-of-two n, with Lee's
+// recursive decomposition. scratch must be as long as x.
+func dctII(x, scratch []float64) {
+	size := len(x)
+	if size == 1 {
+		return
+	}
+
+	half := size / 2
+	scales := dctScales[bits.Len(uint(size))]
+
+	for k := range half {
+		low, high := x[k], x[size-1-k]
+		scratch[k] = low + high
+		scratch[half+k] = (low - high) * scales[k]
+	}
+
+	dctII(scratch[:half], x[:half])
+	dctII(scratch[half:], x[half:])
+
+	for m := range half - 1 {
+		x[2*m] = scratch[m]
+		x[2*m+1] = scratch[half+m] + scratch[half+m+1]
+	}
+
+	x[size-2] = scratch[half-1]
+	x[size-1] = scratch[size-1]
+}
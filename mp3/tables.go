@@ -0,0 +1,94 @@
+package mp3
+
+const (
+	longBands  = 22
+	shortBands = 13
+)
+
+// scalefactorBands holds the line boundaries of the scalefactor bands at one sample rate: long
+// bands over the 576 lines of a granule, short bands over the 192 lines of a short window.
+type scalefactorBands struct {
+	long  [longBands + 1]int
+	short [shortBands + 1]int
+}
+
+// sfBands is indexed by version group (MPEG-1, MPEG-2, MPEG-2.5) times three plus the sample rate
+// index (ISO/IEC 11172-3 Table B.8, ISO/IEC 13818-3 Table B.2; MPEG-2.5 as decoded by libavcodec).
+//
+//nolint:gochecknoglobals // constant table
+var sfBands = [9]scalefactorBands{
+	{ // 44100 Hz
+		long: [...]int{
+			0, 4, 8, 12, 16, 20, 24, 30, 36, 44, 52, 62, 74, 90, 110, 134, 162, 196, 238, 288, 342, 418, 576,
+		},
+		short: [...]int{0, 4, 8, 12, 16, 22, 30, 40, 52, 66, 84, 106, 136, 192},
+	},
+	{ // 48000 Hz
+		long: [...]int{
+			0, 4, 8, 12, 16, 20, 24, 30, 36, 42, 50, 60, 72, 88, 106, 128, 156, 190, 230, 276, 330, 384, 576,
+		},
+		short: [...]int{0, 4, 8, 12, 16, 22, 28, 38, 50, 64, 80, 100, 126, 192},
+	},
+	{ // 32000 Hz
+		long: [...]int{
+			0, 4, 8, 12, 16, 20, 24, 30, 36, 44, 54, 66, 82, 102, 126, 156, 194, 240, 296, 364, 448, 550, 576,
+		},
+		short: [...]int{0, 4, 8, 12, 16, 22, 30, 42, 58, 78, 104, 138, 180, 192},
+	},
+	{ // 22050 Hz
+		long: [...]int{
+			0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 116, 140, 168, 200, 238, 284, 336, 396, 464, 522, 576,
+		},
+		short: [...]int{0, 4, 8, 12, 18, 24, 32, 42, 56, 74, 100, 132, 174, 192},
+	},
+	{ // 24000 Hz
+		long: [...]int{
+			0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 114, 136, 162, 194, 232, 278, 332, 394, 464, 540, 576,
+		},
+		short: [...]int{0, 4, 8, 12, 18, 26, 36, 48, 62, 80, 104, 136, 180, 192},
+	},
+	{ // 16000 Hz
+		long: [...]int{
+			0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 116, 140, 168, 200, 238, 284, 336, 396, 464, 522, 576,
+		},
+		short: [...]int{0, 4, 8, 12, 18, 26, 36, 48, 62, 80, 104, 134, 174, 192},
+	},
+	{ // 11025 Hz
+		long: [...]int{
+			0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 116, 140, 168, 200, 238, 284, 336, 396, 464, 522, 576,
+		},
+		short: [...]int{0, 4, 8, 12, 18, 26, 36, 48, 62, 80, 104, 134, 174, 192},
+	},
+	{ // 12000 Hz
+		long: [...]int{
+			0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 116, 140, 168, 200, 238, 284, 336, 396, 464, 522, 576,
+		},
+		short: [...]int{0, 4, 8, 12, 18, 26, 36, 48, 62, 80, 104, 134, 174, 192},
+	},
+	{ // 8000 Hz
+		long: [...]int{
+			0, 12, 24, 36, 48, 60, 72, 88, 108, 132, 160, 192, 232, 280, 336, 400, 476, 566, 568, 570, 572, 574, 576,
+		},
+		short: [...]int{0, 8, 16, 24, 36, 52, 72, 96, 124, 160, 162, 164, 166, 192},
+	},
+}
+
+// bands returns the scalefactor bands of the frame's sample rate.
+func (h frameHeader) bands() *scalefactorBands {
+	group := 0
+
+	switch h.version {
+	case mpegVersion2:
+		group = 1
+	case mpegVersion25:
+		group = 2
+	default:
+	}
+
+	return &sfBands[group*3+h.rateIndex]
+}
+
+// pretab is the long-block scalefactor boost applied when preflag is set.
+//
+//nolint:gochecknoglobals // constant table
+var pretab = [longBands]int{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 3, 3, 3, 2, 0}
@@ -0,0 +1,331 @@
+package mp3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"strings"
+
+	"github.com/farcloser/saprobe"
+)
+
+// Trailing tag layouts.
+const (
+	id3v1Size = 128
+
+	apeFooterSize     = 32
+	apeSizeOffset     = 12
+	apeCountOffset    = 16
+	apeFlagsOffset    = 20
+	apeFlagHasHeader  = 1 << 31
+	apeItemHeaderSize = 8 // value size (4) + flags (4)
+	apeItemTypeShift  = 1
+	apeItemTypeMask   = 0x03
+	apeItemBinary     = 1
+
+	lyrics3v2EndSize   = 15 // 6-digit size + "LYRICS200"
+	lyrics3v2SizeLen   = 6
+	lyrics3v1MaxSize   = 5100 + 11 + 9 // lyrics, "LYRICSBEGIN" and "LYRICSEND"
+	lyrics3FieldHeader = 8             // ID (3) + 5-digit size
+)
+
+// trailers are the metadata blocks found after the last frame.
+type trailers struct {
+	id3v1    []byte // the 128-byte ID3v1 tag
+	ape      []byte // APEv1/APEv2 items, without header and footer
+	apeItems int
+	lyrics   []byte // Lyrics3 content after "LYRICSBEGIN"
+	lyricsV2 bool   // lyrics holds Lyrics3v2 fields rather than bare Lyrics3v1 text
+}
+
+// splitTrailers peels the ID3v1, APE and Lyrics3 blocks off the end of data, in any order, and
+// returns the length of what precedes them along with their content.
+func splitTrailers(data []byte) (int, trailers) {
+	var found trailers
+
+	end := len(data)
+
+	for {
+		tail := data[:end]
+
+		switch {
+		case found.id3v1 == nil && len(tail) >= id3v1Size && bytes.HasPrefix(tail[end-id3v1Size:], []byte("TAG")):
+			found.id3v1 = tail[end-id3v1Size:]
+			end -= id3v1Size
+		case found.ape == nil && isAPEFooter(tail):
+			size, ok := apeTagSize(tail)
+			if !ok {
+				return end, found
+			}
+
+			footer := tail[end-apeFooterSize:]
+			found.ape = tail[end-size : end-apeFooterSize]
+			found.apeItems = int(binary.LittleEndian.Uint32(footer[apeCountOffset:]))
+
+			if binary.LittleEndian.Uint32(footer[apeFlagsOffset:])&apeFlagHasHeader != 0 {
+				found.ape = found.ape[apeFooterSize:]
+			}
+
+			end -= size
+		case found.lyrics == nil && len(tail) >= lyrics3v2EndSize && bytes.HasSuffix(tail, []byte("LYRICS200")):
+			start, ok := lyrics3v2Start(tail)
+			if !ok {
+				return end, found
+			}
+
+			found.lyrics, found.lyricsV2 = tail[start+len("LYRICSBEGIN"):end-lyrics3v2EndSize], true
+			end = start
+		case found.lyrics == nil && bytes.HasSuffix(tail, []byte("LYRICSEND")):
+			window := tail[max(end-lyrics3v1MaxSize, 0):]
+
+			start := bytes.LastIndex(window, []byte("LYRICSBEGIN"))
+			if start < 0 {
+				return end, found
+			}
+
+			start += end - len(window)
+			found.lyrics = tail[start+len("LYRICSBEGIN") : end-len("LYRICSEND")]
+			end = start
+		default:
+			return end, found
+		}
+	}
+}
+
+func isAPEFooter(data []byte) bool {
+	return len(data) >= apeFooterSize && bytes.HasPrefix(data[len(data)-apeFooterSize:], []byte("APETAGEX"))
+}
+
+// apeTagSize returns the length of the APE tag ending data, header included.
+func apeTagSize(data []byte) (int, bool) {
+	footer := data[len(data)-apeFooterSize:]
+	size := int(binary.LittleEndian.Uint32(footer[apeSizeOffset:]))
+
+	if binary.LittleEndian.Uint32(footer[apeFlagsOffset:])&apeFlagHasHeader != 0 {
+		size += apeFooterSize
+	}
+
+	if size < apeFooterSize || size > len(data) {
+		return 0, false
+	}
+
+	return size, true
+}
+
+// lyrics3v2Start returns the offset of the Lyrics3v2 block ending data.
+func lyrics3v2Start(data []byte) (int, bool) {
+	sizeField := data[len(data)-lyrics3v2EndSize : len(data)-lyrics3v2EndSize+lyrics3v2SizeLen]
+
+	size, err := strconv.Atoi(string(sizeField))
+	if err != nil {
+		return 0, false
+	}
+
+	start := len(data) - lyrics3v2EndSize - size
+	if start < 0 || !bytes.HasPrefix(data[start:], []byte("LYRICSBEGIN")) {
+		return 0, false
+	}
+
+	return start, true
+}
+
+// addTo adds the tags of the trailers to metadata: APE first, then Lyrics3, then ID3v1, the
+// order of their expressiveness. Each block only supplies the keys and pictures that metadata
+// lacks so far.
+func (t trailers) addTo(metadata *saprobe.Metadata) {
+	var ape, lyrics, id3v1 saprobe.Metadata
+
+	if t.ape != nil {
+		addAPE(&ape, t.ape, t.apeItems)
+	}
+
+	if t.lyrics != nil {
+		addLyrics3(&lyrics, t.lyrics, t.lyricsV2)
+	}
+
+	if t.id3v1 != nil {
+		addID3v1(&id3v1, t.id3v1)
+	}
+
+	for _, block := range []saprobe.Metadata{ape, lyrics, id3v1} {
+		mergeMissing(metadata, block)
+	}
+}
+
+// mergeMissing adds the tags of src whose key metadata lacks, and the pictures of src when
+// metadata has none.
+func mergeMissing(metadata *saprobe.Metadata, src saprobe.Metadata) {
+	present := map[string]bool{}
+	for _, tag := range metadata.Tags {
+		present[tag.Key] = true
+	}
+
+	for _, tag := range src.Tags {
+		if !present[tag.Key] {
+			metadata.Tags = append(metadata.Tags, tag)
+		}
+	}
+
+	if len(metadata.Pictures) == 0 {
+		metadata.Pictures = src.Pictures
+	}
+}
+
+// apeKeys maps APE item keys, matched case-insensitively, to saprobe tag keys. Other keys are
+// kept as they are.
+//
+//nolint:gochecknoglobals // constant table
+var apeKeys = map[string]string{
+	"YEAR":         saprobe.TagDate,
+	"ALBUM ARTIST": saprobe.TagAlbumArtist,
+}
+
+// addAPE adds the items of an APE tag. Text items may hold several NUL-separated values;
+// "Track" and "Disc" hold "number/total"; "Cover Art (Front)" holds a file name, a NUL, then
+// the image.
+func addAPE(metadata *saprobe.Metadata, items []byte, count int) {
+	for range count {
+		if len(items) < apeItemHeaderSize {
+			return
+		}
+
+		size := int(binary.LittleEndian.Uint32(items))
+		flags := binary.LittleEndian.Uint32(items[4:])
+		items = items[apeItemHeaderSize:]
+
+		key, rest, ok := bytes.Cut(items, []byte{0})
+		if !ok || size > len(rest) {
+			return
+		}
+
+		value := rest[:size]
+		items = rest[size:]
+		name := strings.ToUpper(string(key))
+
+		switch {
+		case (flags>>apeItemTypeShift)&apeItemTypeMask == apeItemBinary:
+			if name == "COVER ART (FRONT)" {
+				if _, image, found := bytes.Cut(value, []byte{0}); found {
+					metadata.Pictures = append(metadata.Pictures, saprobe.Picture{
+						Type: saprobe.PictureFrontCover,
+						MIME: sniffImage(image),
+						Data: image,
+					})
+				}
+			}
+		case name == "TRACK":
+			addNumberPair(metadata, string(value), saprobe.TagTrackNumber, saprobe.TagTrackTotal)
+		case name == "DISC":
+			addNumberPair(metadata, string(value), saprobe.TagDiscNumber, saprobe.TagDiscTotal)
+		default:
+			if mapped, known := apeKeys[name]; known {
+				name = mapped
+			}
+
+			for part := range strings.SplitSeq(string(value), "\x00") {
+				metadata.Add(name, part)
+			}
+		}
+	}
+}
+
+// addLyrics3 adds the content of a Lyrics3 block: bare lyrics for version 1, fields for
+// version 2.
+//
+//revive:disable-next-line:flag-parameter
+func addLyrics3(metadata *saprobe.Metadata, content []byte, fields bool) {
+	if !fields {
+		metadata.Add(saprobe.TagLyrics, latin1(content))
+
+		return
+	}
+
+	for len(content) >= lyrics3FieldHeader {
+		size, err := strconv.Atoi(string(content[3:lyrics3FieldHeader]))
+		if err != nil || lyrics3FieldHeader+size > len(content) {
+			return
+		}
+
+		value := latin1(content[lyrics3FieldHeader : lyrics3FieldHeader+size])
+
+		switch string(content[:3]) {
+		case "LYR":
+			metadata.Add(saprobe.TagLyrics, value)
+		case "ETT":
+			metadata.Add(saprobe.TagTitle, value)
+		case "EAR":
+			metadata.Add(saprobe.TagArtist, value)
+		case "EAL":
+			metadata.Add(saprobe.TagAlbum, value)
+		case "INF":
+			metadata.Add(saprobe.TagComment, value)
+		default:
+		}
+
+		content = content[lyrics3FieldHeader+size:]
+	}
+}
+
+// addID3v1 adds the fixed fields of an ID3v1 tag. ID3v1.1 stores the track number in the last
+// byte of the comment, after a zero.
+func addID3v1(metadata *saprobe.Metadata, tag []byte) {
+	field := func(start, length int) string {
+		value, _, _ := bytes.Cut(tag[start:start+length], []byte{0})
+
+		return strings.TrimSpace(latin1(value))
+	}
+
+	metadata.Add(saprobe.TagTitle, field(3, 30))
+	metadata.Add(saprobe.TagArtist, field(33, 30))
+	metadata.Add(saprobe.TagAlbum, field(63, 30))
+	metadata.Add(saprobe.TagDate, field(93, 4))
+	metadata.Add(saprobe.TagComment, field(97, 30))
+
+	if tag[125] == 0 && tag[126] != 0 {
+		metadata.Add(saprobe.TagTrackNumber, strconv.Itoa(int(tag[126])))
+	}
+
+	if genre := int(tag[127]); genre < len(id3v1Genres) {
+		metadata.Add(saprobe.TagGenre, id3v1Genres[genre])
+	}
+}
+
+// addNumberPair adds a "number/total" value as two tags.
+func addNumberPair(metadata *saprobe.Metadata, value, numberKey, totalKey string) {
+	number, total, _ := strings.Cut(strings.TrimSpace(value), "/")
+
+	metadata.Add(numberKey, strings.TrimSpace(number))
+	metadata.Add(totalKey, strings.TrimSpace(total))
+}
+
+// sniffImage returns the MIME type of JPEG, PNG, GIF and BMP data, or an empty string.
+func sniffImage(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return "image/jpeg"
+	case bytes.HasPrefix(data, []byte("\x89PNG")):
+		return "image/png"
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		return "image/gif"
+	case bytes.HasPrefix(data, []byte("BM")):
+		return "image/bmp"
+	default:
+		return ""
+	}
+}
+
+// id3v1Genres are the genre names of ID3v1, by index.
+//
+//nolint:gochecknoglobals // constant table
+var id3v1Genres = [...]string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge", "Hip-Hop", "Jazz", "Metal",
+	"New Age", "Oldies", "Other", "Pop", "R&B", "Rap", "Reggae", "Rock", "Techno", "Industrial",
+	"Alternative", "Ska", "Death Metal", "Pranks", "Soundtrack", "Euro-Techno", "Ambient", "Trip-Hop",
+	"Vocal", "Jazz+Funk", "Fusion", "Trance", "Classical", "Instrumental", "Acid", "House", "Game",
+	"Sound Clip", "Gospel", "Noise", "AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative",
+	"Instrumental Pop", "Instrumental Rock", "Ethnic", "Gothic", "Darkwave", "Techno-Industrial",
+	"Electronic", "Pop-Folk", "Eurodance", "Dream", "Southern Rock", "Comedy", "Cult", "Gangsta",
+	"Top 40", "Christian Rap", "Pop/Funk", "Jungle", "Native American", "Cabaret", "New Wave",
+	"Psychadelic", "Rave", "Showtunes", "Trailer", "Lo-Fi", "Tribal", "Acid Punk", "Acid Jazz", "Polka",
+	"Retro", "Musical", "Rock & Roll", "Hard Rock",
+}
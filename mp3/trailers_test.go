@@ -0,0 +1,167 @@
+package mp3_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/mp3"
+)
+
+// id3v1Tag returns an ID3v1.1 tag. Its comment holds a frame header, which a decoder scanning
+// for sync would take for a frame.
+func id3v1Tag(title, year string, track, genre byte) []byte {
+	tag := make([]byte, mp3.ID3v1Size)
+	copy(tag, "TAG")
+	copy(tag[3:], title)
+	copy(tag[93:], year)
+	copy(tag[97:], mp3.SilentHeader)
+	tag[126], tag[127] = track, genre
+
+	return tag
+}
+
+// apeItem is an item of a tag built by apeTag.
+type apeItem struct {
+	key, value string
+	binary     bool
+}
+
+// apeTag returns an APEv2 tag ending with a footer, and starting with a header if header is set.
+//
+//revive:disable-next-line:flag-parameter
+func apeTag(header bool, items ...apeItem) []byte {
+	var body []byte
+
+	for _, item := range items {
+		var flags uint32
+		if item.binary {
+			flags = mp3.APEItemBinary << mp3.APEItemTypeShift
+		}
+
+		body = binary.LittleEndian.AppendUint32(body, uint32(len(item.value))) //nolint:gosec // small test items.
+		body = binary.LittleEndian.AppendUint32(body, flags)
+		body = append(body, item.key+"\x00"+item.value...)
+	}
+
+	block := func(flags uint32) []byte {
+		block := []byte("APETAGEX")
+		block = binary.LittleEndian.AppendUint32(block, 2000)
+		block = binary.LittleEndian.AppendUint32(block, uint32(len(body)+mp3.APEFooterSize)) //nolint:gosec // small.
+		block = binary.LittleEndian.AppendUint32(block, uint32(len(items)))                  //nolint:gosec // small.
+		block = binary.LittleEndian.AppendUint32(block, flags)
+
+		return append(block, make([]byte, 8)...)
+	}
+
+	if !header {
+		return append(body, block(0)...)
+	}
+
+	const isHeader = 1 << 29
+
+	return slices.Concat(block(mp3.APEFlagHasHeader|isHeader), body, block(mp3.APEFlagHasHeader))
+}
+
+// lyrics3v2 returns a Lyrics3v2 block holding fields, each an ID and a value.
+func lyrics3v2(fields ...[2]string) []byte {
+	block := []byte("LYRICSBEGIN")
+	for _, field := range fields {
+		block = fmt.Appendf(block, "%s%05d%s", field[0], len(field[1]), field[1])
+	}
+
+	return fmt.Appendf(block, "%06dLYRICS200", len(block))
+}
+
+// TestTrailers checks that APE, Lyrics3 and ID3v1 blocks after the last frame are neither decoded,
+// although they hold what looks like frames, nor reported as damage, and that they complete the
+// metadata in order.
+func TestTrailers(t *testing.T) {
+	t.Parallel()
+
+	music := mp3.SilentFrames(10)
+	// Without a header, the APE tag starts with its first item: no marker tells it from audio.
+	trailers := slices.Concat(
+		apeTag(false,
+			apeItem{key: "Cover Art (Back)", value: "back.mp3\x00" + string(mp3.SilentFrames(2)), binary: true},
+			apeItem{key: "Title", value: "Saprobe"},
+			apeItem{key: "Track", value: "7/12"},
+			apeItem{key: "Year", value: "2026"},
+		),
+		lyrics3v2([2]string{"EAL", "Hyphae"}, [2]string{"LYR", "spores"}, [2]string{"ETT", "Lyrics3 title"}),
+		id3v1Tag("ID3v1 title", "1999", 3, 17),
+	)
+	data := append(slices.Clone(music), trailers...)
+
+	pcm, format, report, err := mp3.DecodeWithOptions(bytes.NewReader(data), saprobe.Options{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if samples := len(pcm) / format.BitDepth.BytesPerSample(); samples != 10*mp3.SilentFrameSamples ||
+		len(report.Damaged) != 0 {
+		t.Errorf("%d samples, damage %v, want %d clean samples", samples, report.Damaged, 10*mp3.SilentFrameSamples)
+	}
+
+	verification, err := mp3.Verify(bytes.NewReader(data))
+	if err != nil || !verification.OK() {
+		t.Errorf("verification: %v (%v), want no finding", verification.Findings, err)
+	}
+
+	metadata, err := mp3.ReadMetadata(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]string{
+		saprobe.TagTitle:       "Saprobe",
+		saprobe.TagTrackNumber: "7",
+		saprobe.TagTrackTotal:  "12",
+		saprobe.TagDate:        "2026",
+		saprobe.TagAlbum:       "Hyphae",
+		saprobe.TagLyrics:      "spores",
+		saprobe.TagGenre:       "Rock",
+	} {
+		if value, _ := metadata.Get(key); value != want {
+			t.Errorf("%s: %q, want %q", key, value, want)
+		}
+	}
+
+	// Each block only supplies the keys the ones before it lack.
+	if len(metadata.Tags) != 8 {
+		t.Errorf("tags %v, want 8", metadata.Tags)
+	}
+
+	// The blocks come off in any order.
+	reordered := slices.Concat(music, id3v1Tag("", "", 0, 0xFF), apeTag(true, apeItem{key: "Title", value: "Saprobe"}))
+	if end, id3v1, apeItems := mp3.SplitTrailers(reordered); end != len(music) || !id3v1 || apeItems != 1 {
+		t.Errorf("split at %d, ID3v1 %t, %d APE items, want %d", end, id3v1, apeItems, len(music))
+	}
+}
+
+// TestTrailersShort checks that data too short to hold the blocks its end announces is left as it is,
+// and that a stream made of such an end fails to decode rather than panicking.
+func TestTrailersShort(t *testing.T) {
+	t.Parallel()
+
+	for _, data := range [][]byte{
+		[]byte("LYRICS200"),
+		[]byte("12LYRICS200"),
+		[]byte("LYRICSEND"),
+		[]byte("APETAGEX"),
+		[]byte("TAG"),
+	} {
+		if end, id3v1, apeItems := mp3.SplitTrailers(data); end != len(data) || id3v1 || apeItems != 0 {
+			t.Errorf("%q: split at %d, ID3v1 %t, %d APE items, want nothing split", data, end, id3v1, apeItems)
+		}
+	}
+
+	// An empty ID3v2 tag, then the end of a Lyrics3v2 block.
+	data := append([]byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 0}, "LYRICS200"...)
+	if _, _, err := mp3.Decode(bytes.NewReader(data)); err == nil {
+		t.Error("decoded a stream without frames")
+	}
+}
@@ -0,0 +1,246 @@
+package mp3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/farcloser/saprobe"
+)
+
+// LAME tag fields used for verification, as offsets from the start of the encoder string.
+const (
+	lameMusicLengthOffset = 28
+	lameMusicCRCOffset    = 32
+	lameTagCRCOffset      = 34
+	lameTagFullSize       = 36
+)
+
+var errID3Skip = errors.New("mp3: cannot skip ID3v2 tag")
+
+// Markers of the metadata blocks that commonly trail the last frame.
+//
+//nolint:gochecknoglobals // constant table
+var trailerMarkers = [][]byte{
+	[]byte("TAG"),         // ID3v1
+	[]byte("APETAGEX"),    // APEv1/APEv2 footer or header
+	[]byte("LYRICSBEGIN"), // Lyrics3
+}
+
+// Verify walks every frame of the stream and checks the integrity signals MP3 can carry: the
+// per-frame CRC-16 of protected Layer III frames, and the tag and music CRCs of a LAME/Info
+// tag. It then decodes the stream to surface decoder errors and truncation. Integrity failures
+// are returned as findings; the error is reserved for streams that cannot be read at all.
+func Verify(reader io.ReadSeeker) (saprobe.Verification, error) {
+	tracked := saprobe.TrackReader(reader)
+	verification, err := verify(tracked)
+
+	return verification, wrapError(tracked, err)
+}
+
+func verify(reader io.ReadSeeker) (saprobe.Verification, error) {
+	var verification saprobe.Verification
+
+	base := skipID3v2(reader)
+	if base < 0 {
+		return verification, errID3Skip
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return verification, fmt.Errorf("reading mp3 stream: %w", err)
+	}
+
+	// ID3v1, APE and Lyrics3 tags after the last frame are not audio.
+	end, _ := splitTrailers(data)
+	walkFrames(&verification, data[:end], int64(base))
+
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return verification, fmt.Errorf("seeking to start: %w", err)
+	}
+
+	verification.Examined(saprobe.CheckBitstream)
+
+	_, _, report, err := DecodeWithOptions(reader, saprobe.Options{})
+
+	var decodeErr *saprobe.DecodeError
+
+	switch {
+	case errors.As(err, &decodeErr) && errors.Is(decodeErr.Kind, saprobe.ErrCorrupt):
+		verification.Fail(decodeErr.Finding(saprobe.CheckBitstream))
+	case err != nil:
+		return verification, err
+	}
+
+	if report.Truncation != nil {
+		verification.Fail(report.Truncation.Finding())
+	}
+
+	return verification, nil
+}
+
+// walkFrames follows the frame chain through data (which starts at file offset base), checking
+// CRCs and reporting lost sync and truncation.
+func walkFrames(verification *saprobe.Verification, data []byte, base int64) {
+	// Padding or junk before the first frame is common and harmless: decoders scan for sync.
+	pos, freeBase := findFirstFrame(data)
+	if pos < 0 {
+		return
+	}
+
+	var sample int64
+
+	for frameIdx := 0; pos+frameHeaderSize <= len(data); frameIdx++ {
+		hdr, ok := parseFrameHeader(data[pos:])
+		if !ok {
+			if isTrailer(data[pos:]) {
+				return
+			}
+
+			next := findSyncWord(data[pos+1:])
+			if next < 0 {
+				failAt(verification, saprobe.CheckBitstream, frameIdx, base+int64(pos), sample,
+					fmt.Sprintf("%d bytes of unrecognized data after the last frame", len(data)-pos))
+
+				return
+			}
+
+			failAt(verification, saprobe.CheckBitstream, frameIdx, base+int64(pos), sample,
+				fmt.Sprintf("lost sync, skipped %d bytes", next+1))
+
+			pos += next + 1
+
+			continue
+		}
+
+		size := hdr.length(freeBase)
+		if size == 0 {
+			// A free-format frame in a stream whose frame length is unknown: the walk cannot
+			// continue reliably.
+			return
+		}
+
+		if pos+size > len(data) {
+			failAt(verification, saprobe.CheckBitstream, frameIdx, base+int64(pos), sample,
+				fmt.Sprintf("frame truncated: %d of %d bytes present", len(data)-pos, size))
+
+			return
+		}
+
+		frame := data[pos : pos+size]
+
+		if frameIdx == 0 {
+			checkLAMETag(verification, frame, data[pos:], base+int64(pos))
+		}
+
+		if hdr.protected && hdr.layer == layerIII {
+			checkFrameCRC(verification, hdr, frame, frameIdx, base+int64(pos), sample)
+		}
+
+		pos += size
+		sample += int64(hdr.samples())
+	}
+}
+
+// checkFrameCRC validates the CRC-16 of a protected Layer III frame, which covers the last two
+// header bytes and the side information.
+func checkFrameCRC(
+	verification *saprobe.Verification,
+	hdr frameHeader,
+	frame []byte,
+	frameIdx int,
+	offset, sample int64,
+) {
+	sideEnd := frameHeaderSize + frameCRCSize + hdr.sideInfoSize()
+	if sideEnd > len(frame) {
+		failAt(verification, saprobe.CheckFrameCRC, frameIdx, offset, sample,
+			"frame too short for its side information")
+
+		return
+	}
+
+	verification.Examined(saprobe.CheckFrameCRC)
+
+	crc := crc16MPEG(crc16InitMPEG, frame[2:frameHeaderSize])
+	crc = crc16MPEG(crc, frame[frameHeaderSize+frameCRCSize:sideEnd])
+
+	if stored := binary.BigEndian.Uint16(frame[frameHeaderSize:]); stored != crc {
+		failAt(verification, saprobe.CheckFrameCRC, frameIdx, offset, sample,
+			fmt.Sprintf("CRC-16 mismatch: stored 0x%04X, computed 0x%04X", stored, crc))
+	}
+}
+
+// checkLAMETag validates the tag CRC and the music CRC of a LAME-style Info tag in the first
+// frame. stream holds the first frame and everything after it.
+func checkLAMETag(verification *saprobe.Verification, frame, stream []byte, offset int64) {
+	hdr, _ := parseFrameHeader(frame)
+	xingOffset := hdr.xingOffset()
+
+	if xingOffset+xingPreambleSize > len(frame) {
+		return
+	}
+
+	xingData := frame[xingOffset:]
+	if !bytes.HasPrefix(xingData, []byte("Xing")) && !bytes.HasPrefix(xingData, []byte("Info")) {
+		return
+	}
+
+	lameOffset := findLAMETag(xingData)
+	if lameOffset < 0 {
+		return
+	}
+
+	lameStart := xingOffset + lameOffset
+	encoder := frame[lameStart:]
+
+	// Only LAME and FFmpeg (which writes a LAME-compatible tag) are known to fill in the CRCs.
+	if len(encoder) < lameTagFullSize || !(bytes.HasPrefix(encoder, []byte("LAME")) ||
+		bytes.HasPrefix(encoder, []byte("Lavc")) || bytes.HasPrefix(encoder, []byte("Lavf"))) {
+		return
+	}
+
+	verification.Examined(saprobe.CheckLAMETagCRC)
+
+	tagCRC := crc16LAME(frame[:lameStart+lameTagCRCOffset])
+	if stored := binary.BigEndian.Uint16(encoder[lameTagCRCOffset:]); stored != tagCRC {
+		failAt(verification, saprobe.CheckLAMETagCRC, 0, offset, 0,
+			fmt.Sprintf("tag CRC mismatch: stored 0x%04X, computed 0x%04X", stored, tagCRC))
+
+		// The music length and CRC fields cannot be trusted either.
+		return
+	}
+
+	// The music length counts the Info frame itself; the music CRC covers the frames after it.
+	musicLength := int(binary.BigEndian.Uint32(encoder[lameMusicLengthOffset:]))
+	if musicLength <= len(frame) || musicLength > len(stream) {
+		return
+	}
+
+	musicCRC := crc16LAME(stream[len(frame):musicLength])
+	if stored := binary.BigEndian.Uint16(encoder[lameMusicCRCOffset:]); stored != musicCRC {
+		failAt(verification, saprobe.CheckLAMETagCRC, -1, offset+int64(len(frame)), -1,
+			fmt.Sprintf("music CRC mismatch: stored 0x%04X, computed 0x%04X", stored, musicCRC))
+	}
+}
+
+func isTrailer(data []byte) bool {
+	for _, marker := range trailerMarkers {
+		if bytes.HasPrefix(data, marker) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func failAt(verification *saprobe.Verification, check saprobe.Check, frame int, offset, sample int64, detail string) {
+	verification.Fail(saprobe.Finding{
+		Check:  check,
+		Frame:  frame,
+		Offset: offset,
+		Sample: sample,
+		Detail: detail,
+	})
+}
@@ -0,0 +1,98 @@
+package mp3_test
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/mp3"
+)
+
+// TestVerifyLAMETag checks the LAME tag and music CRCs of an Info frame, with and without frame CRCs,
+// which move the Info header by two bytes.
+func TestVerifyLAMETag(t *testing.T) {
+	t.Parallel()
+
+	for _, protected := range []bool{false, true} {
+		music := mp3.SilentFrames(10)
+		if protected {
+			mp3.Protect(music)
+		}
+
+		data := append(mp3.InfoFrame(mp3.LAMEFields{Frames: 10}, music, protected), music...)
+
+		verification, err := mp3.Verify(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !verification.OK() || !slices.Contains(verification.Checked, saprobe.CheckLAMETagCRC) {
+			t.Errorf("protected %t: checked %v, findings %v, want a clean LAME tag check",
+				protected, verification.Checked, verification.Findings)
+		}
+
+		if protected != slices.Contains(verification.Checked, saprobe.CheckFrameCRC) {
+			t.Errorf("protected %t: checked %v", protected, verification.Checked)
+		}
+
+		// Flip a byte of the main data of the third music frame: only the music CRC covers it.
+		data[3*mp3.SilentFrameSize+100] ^= 0xFF
+
+		verification, err = mp3.Verify(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(verification.Findings) != 1 || verification.Findings[0].Check != saprobe.CheckLAMETagCRC ||
+			!strings.Contains(verification.Findings[0].Detail, "music CRC") {
+			t.Errorf("protected %t: findings %v, want a music CRC mismatch", protected, verification.Findings)
+		}
+	}
+}
+
+// TestVerifyFrameCRC checks that a frame whose side information no longer matches its CRC-16 is
+// reported at its index, offset and first sample.
+func TestVerifyFrameCRC(t *testing.T) {
+	t.Parallel()
+
+	data := mp3.Protect(mp3.SilentFrames(10))
+
+	verification, err := mp3.Verify(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !verification.OK() || !slices.Contains(verification.Checked, saprobe.CheckFrameCRC) {
+		t.Fatalf("checked %v, findings %v, want clean frame CRCs", verification.Checked, verification.Findings)
+	}
+
+	// Damage the stored CRC of frame 4 and the side information of frame 7.
+	data[4*mp3.SilentFrameSize+mp3.FrameHeaderSize] ^= 0x01
+	data[7*mp3.SilentFrameSize+mp3.FrameHeaderSize+mp3.FrameCRCSize+10] ^= 0x01
+
+	verification, err = mp3.Verify(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var frames []int
+
+	for _, finding := range verification.Findings {
+		if finding.Check != saprobe.CheckFrameCRC {
+			continue
+		}
+
+		frames = append(frames, finding.Frame)
+
+		if finding.Offset != int64(finding.Frame*mp3.SilentFrameSize) ||
+			finding.Sample != int64(finding.Frame*mp3.SilentFrameSamples) {
+			t.Errorf("frame %d reported at offset %d, sample %d", finding.Frame, finding.Offset, finding.Sample)
+		}
+	}
+
+	if !slices.Equal(frames, []int{4, 7}) {
+		t.Errorf("CRC failures in frames %v, want [4 7]", frames)
+	}
+}
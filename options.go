@@ -0,0 +1,93 @@
+package saprobe
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTruncated is matched (via errors.Is) by the *Truncation returned in strict mode.
+var ErrTruncated = errors.New("truncated stream")
+
+// Options controls optional decoder behavior shared by every codec.
+type Options struct {
+	// Strict turns completeness warnings (see Report) into errors.
+	Strict bool
+
+	// Resilient conceals undecodable frames or packets instead of failing: decoding resumes at the
+	// next frame that decodes, the gap is filled according to Conceal so that the timeline keeps
+	// its length, and the damaged ranges are listed in the Report.
+	Resilient bool
+
+	// Conceal selects how damaged ranges are filled in resilient mode.
+	Conceal Concealment
+
+	// Jobs is the number of packets or frames decoded concurrently by codecs whose packets or
+	// frames decode independently (ALAC, FLAC). 0 or 1 decodes them one after the other; other
+	// codecs ignore it.
+	Jobs int
+}
+
+// Report carries the non-fatal conditions a decoder noticed. The zero value means nothing to report.
+type Report struct {
+	// Truncation is set when fewer samples were decoded than the stream declares.
+	Truncation *Truncation
+
+	// Damaged lists, in stream order, the ranges concealed in resilient mode.
+	Damaged []Damage
+}
+
+// Truncation describes a stream that decoded fewer samples than its container or headers declare.
+// Sample counts are per channel.
+type Truncation struct {
+	Declared   int64
+	Decoded    int64
+	SampleRate int
+}
+
+// Missing returns the number of samples per channel that could not be decoded.
+func (t *Truncation) Missing() int64 {
+	return t.Declared - t.Decoded
+}
+
+// MissingDuration returns the playing time that could not be decoded.
+func (t *Truncation) MissingDuration() time.Duration {
+	if t.SampleRate <= 0 {
+		return 0
+	}
+
+	return time.Duration(t.Missing()) * time.Second / time.Duration(t.SampleRate)
+}
+
+// Error describes the truncation, so that it can be returned as an error in strict mode.
+func (t *Truncation) Error() string {
+	return fmt.Sprintf("%s: decoded %d of %d declared samples, %s (%d samples) missing",
+		ErrTruncated, t.Decoded, t.Declared, t.MissingDuration().Round(time.Millisecond), t.Missing())
+}
+
+// Is makes errors.Is(err, ErrTruncated) match a *Truncation.
+func (t *Truncation) Is(target error) bool {
+	return target == ErrTruncated //nolint:errorlint // identity comparison with the sentinel is intended.
+}
+
+// Finding expresses the truncation as an integrity finding located at the first missing sample.
+func (t *Truncation) Finding() Finding {
+	return Finding{Check: CheckSampleCount, Frame: -1, Offset: -1, Sample: t.Decoded, Detail: t.Error()}
+}
+
+// CheckTruncation compares the decoded sample count with the declared one (0 or less when the
+// stream declares none), recording a Truncation in report when samples are missing.
+// In strict mode the Truncation is also returned as the error.
+func CheckTruncation(opts Options, report *Report, declared, decoded int64, sampleRate int) error {
+	if declared <= 0 || decoded >= declared {
+		return nil
+	}
+
+	report.Truncation = &Truncation{Declared: declared, Decoded: decoded, SampleRate: sampleRate}
+
+	if opts.Strict {
+		return report.Truncation
+	}
+
+	return nil
+}
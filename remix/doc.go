@@ -0,0 +1,4 @@
+// Package remix maps PCM onto another set of channels through a mixing matrix, on the interleaved
+// little-endian signed PCM the decoders produce: ITU-R BS.775 downmixes of surround to stereo or
+// mono, channel selection, or matrices of the caller's own.
+package remix
@@ -0,0 +1,191 @@
+package remix
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/farcloser/saprobe"
+)
+
+var (
+	errLayout  = errors.New("remix: unknown channel layout")
+	errTarget  = errors.New("remix: downmix targets are mono and stereo")
+	errChannel = errors.New("remix: no such channel")
+	errMatrix  = errors.New("remix: invalid matrix")
+)
+
+// Matrix weighs the input channels into each output channel: row o, column i is the gain of input
+// channel i in output channel o.
+type Matrix [][]float64
+
+// surround is the BS.775 gain of the center and surround channels in a stereo downmix, -3 dB.
+const surround = math.Sqrt2 / 2
+
+// stereoGains holds the gains of each speaker into the left and right channels of a stereo downmix.
+// The LFE is left out, as BS.775 has it; speakers in the middle go to both sides.
+//
+//nolint:gochecknoglobals // constant table
+var stereoGains = map[saprobe.Speaker][2]float64{
+	saprobe.SpeakerFrontLeft:          {1, 0},
+	saprobe.SpeakerFrontRight:         {0, 1},
+	saprobe.SpeakerFrontCenter:        {surround, surround},
+	saprobe.SpeakerLowFrequency:       {0, 0},
+	saprobe.SpeakerBackLeft:           {surround, 0},
+	saprobe.SpeakerBackRight:          {0, surround},
+	saprobe.SpeakerFrontLeftOfCenter:  {1, 0},
+	saprobe.SpeakerFrontRightOfCenter: {0, 1},
+	saprobe.SpeakerBackCenter:         {surround * surround, surround * surround},
+	saprobe.SpeakerSideLeft:           {surround, 0},
+	saprobe.SpeakerSideRight:          {0, surround},
+	saprobe.SpeakerTopCenter:          {surround * surround, surround * surround},
+	saprobe.SpeakerTopFrontLeft:       {surround, 0},
+	saprobe.SpeakerTopFrontCenter:     {surround * surround, surround * surround},
+	saprobe.SpeakerTopFrontRight:      {0, surround},
+	saprobe.SpeakerTopBackLeft:        {surround, 0},
+	saprobe.SpeakerTopBackCenter:      {surround * surround, surround * surround},
+	saprobe.SpeakerTopBackRight:       {0, surround},
+}
+
+// Downmix returns the matrix mixing the channels of layout from down to layout to, which is
+// saprobe.LayoutStereo or saprobe.LayoutMono. Surround and center channels are folded into the
+// sides at -3 dB and the LFE is dropped, after ITU-R BS.775; mono is the average of the stereo
+// downmix. The matrix is scaled down as needed for full-scale input never to clip. Mono mixes up
+// to stereo by going to both sides at full level.
+func Downmix(from, to saprobe.ChannelLayout) (Matrix, error) {
+	speakers := from.Speakers()
+	if len(speakers) == 0 {
+		return nil, errLayout
+	}
+
+	if to != saprobe.LayoutStereo && to != saprobe.LayoutMono {
+		return nil, fmt.Errorf("%w: not %s", errTarget, to)
+	}
+
+	if from == to {
+		return identity(len(speakers)), nil
+	}
+
+	if from == saprobe.LayoutMono {
+		return Matrix{{1}, {1}}, nil
+	}
+
+	left, right := make([]float64, len(speakers)), make([]float64, len(speakers))
+
+	for ch, speaker := range speakers {
+		left[ch], right[ch] = stereoGains[speaker][0], stereoGains[speaker][1]
+	}
+
+	matrix := Matrix{left, right}
+
+	if to == saprobe.LayoutMono {
+		mono := make([]float64, len(speakers))
+		for ch := range mono {
+			mono[ch] = (left[ch] + right[ch]) / 2
+		}
+
+		matrix = Matrix{mono}
+	}
+
+	return matrix.normalized(), nil
+}
+
+// Select returns the matrix picking the given input channels of format, in order, and the layout
+// of the output. A channel may be picked more than once, which leaves the output layout unknown.
+func Select(format saprobe.PCMFormat, channels []int) (Matrix, saprobe.ChannelLayout, error) {
+	if len(channels) == 0 {
+		return nil, saprobe.ChannelLayout{}, fmt.Errorf("%w: no channel selected", errMatrix)
+	}
+
+	matrix := make(Matrix, len(channels))
+	speakers := format.Layout.Speakers()
+
+	var layout saprobe.ChannelLayout
+
+	//nolint:gosec // channel count is small.
+	known := len(speakers) == int(format.Channels) && len(channels) <= saprobe.MaxLayoutChannels
+
+	for out, in := range channels {
+		if in < 0 || in >= int(format.Channels) { //nolint:gosec // channel count is small.
+			return nil, saprobe.ChannelLayout{}, fmt.Errorf("%w: %d of %d", errChannel, in, format.Channels)
+		}
+
+		matrix[out] = make([]float64, format.Channels)
+		matrix[out][in] = 1
+
+		if known {
+			known = layout.Index(speakers[in]) < 0
+			layout[out] = speakers[in]
+		}
+	}
+
+	if !known {
+		layout = saprobe.ChannelLayout{}
+	}
+
+	return matrix, layout, nil
+}
+
+// ParseMatrix reads a matrix written as its rows, separated by semicolons, each a comma-separated
+// list of gains: "0.5,0.5" mixes two channels to mono, "0,1;1,0" swaps them.
+func ParseMatrix(text string) (Matrix, error) {
+	var matrix Matrix
+
+	for row := range strings.SplitSeq(text, ";") {
+		var gains []float64
+
+		for field := range strings.SplitSeq(row, ",") {
+			gain, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", errMatrix, err)
+			}
+
+			gains = append(gains, gain)
+		}
+
+		if len(matrix) > 0 && len(gains) != len(matrix[0]) {
+			return nil, fmt.Errorf("%w: rows of %d and %d gains", errMatrix, len(matrix[0]), len(gains))
+		}
+
+		matrix = append(matrix, gains)
+	}
+
+	return matrix, nil
+}
+
+// normalized returns the matrix scaled down so that no output channel sums to more than full
+// scale.
+func (m Matrix) normalized() Matrix {
+	var peak float64
+
+	for _, row := range m {
+		var sum float64
+		for _, gain := range row {
+			sum += math.Abs(gain)
+		}
+
+		peak = max(peak, sum)
+	}
+
+	if peak > 1 {
+		for _, row := range m {
+			for idx := range row {
+				row[idx] /= peak
+			}
+		}
+	}
+
+	return m
+}
+
+func identity(channels int) Matrix {
+	matrix := make(Matrix, channels)
+	for ch := range matrix {
+		matrix[ch] = make([]float64, channels)
+		matrix[ch][ch] = 1
+	}
+
+	return matrix
+}
@@ -0,0 +1,122 @@
+package remix_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/remix"
+)
+
+// closeTo reports whether two matrices hold the same gains, to rounding.
+func closeTo(got, want remix.Matrix) bool {
+	if len(got) != len(want) {
+		return false
+	}
+
+	for row := range want {
+		if len(got[row]) != len(want[row]) {
+			return false
+		}
+
+		for ch := range want[row] {
+			if math.Abs(got[row][ch]-want[row][ch]) > 1e-9 {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// TestDownmix checks the BS.775 coefficients: center and surrounds at -3 dB, no LFE, scaled down for
+// full-scale input not to clip, and mono as the average of the stereo downmix.
+func TestDownmix(t *testing.T) {
+	t.Parallel()
+
+	const surround = math.Sqrt2 / 2
+
+	// Front left, center and back left add up to 1+2*surround in the left channel.
+	const scale = 1 + 2*surround
+
+	for _, test := range []struct {
+		from, to saprobe.ChannelLayout
+		want     remix.Matrix
+	}{
+		{saprobe.DefaultLayout(6), saprobe.LayoutStereo, remix.Matrix{
+			{1 / scale, 0, surround / scale, 0, surround / scale, 0},
+			{0, 1 / scale, surround / scale, 0, 0, surround / scale},
+		}},
+		{saprobe.DefaultLayout(6), saprobe.LayoutMono, remix.Matrix{
+			{0.5 / scale, 0.5 / scale, surround / scale, 0, 0.5 * surround / scale, 0.5 * surround / scale},
+		}},
+		// Nothing to scale down: the sums stay within full scale.
+		{saprobe.DefaultLayout(3), saprobe.LayoutStereo, remix.Matrix{
+			{1 / (1 + surround), 0, surround / (1 + surround)},
+			{0, 1 / (1 + surround), surround / (1 + surround)},
+		}},
+		{saprobe.LayoutStereo, saprobe.LayoutMono, remix.Matrix{{0.5, 0.5}}},
+		{saprobe.LayoutStereo, saprobe.LayoutStereo, remix.Matrix{{1, 0}, {0, 1}}},
+		{saprobe.LayoutMono, saprobe.LayoutStereo, remix.Matrix{{1}, {1}}},
+	} {
+		matrix, err := remix.Downmix(test.from, test.to)
+		if err != nil {
+			t.Fatalf("%s to %s: %v", test.from, test.to, err)
+		}
+
+		if !closeTo(matrix, test.want) {
+			t.Errorf("%s to %s: %v, want %v", test.from, test.to, matrix, test.want)
+		}
+	}
+
+	if _, err := remix.Downmix(saprobe.LayoutStereo, saprobe.DefaultLayout(6)); err == nil {
+		t.Error("upmix to 5.1: no error")
+	}
+
+	if _, err := remix.Downmix(saprobe.ChannelLayout{}, saprobe.LayoutStereo); err == nil {
+		t.Error("unknown layout: no error")
+	}
+}
+
+// TestSelect checks that picking channels keeps their speakers, unless one is picked twice.
+func TestSelect(t *testing.T) {
+	t.Parallel()
+
+	format := saprobe.PCMFormat{
+		SampleRate: 48000, BitDepth: saprobe.Depth16, Channels: 6, Layout: saprobe.DefaultLayout(6),
+	}
+
+	matrix, layout, err := remix.Select(format, []int{2, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !closeTo(matrix, remix.Matrix{{0, 0, 1, 0, 0, 0}, {1, 0, 0, 0, 0, 0}}) ||
+		layout != (saprobe.ChannelLayout{saprobe.SpeakerFrontCenter, saprobe.SpeakerFrontLeft}) {
+		t.Errorf("channels 2,0: %v laid out %s", matrix, layout)
+	}
+
+	if _, layout, err = remix.Select(format, []int{0, 0}); err != nil || layout != (saprobe.ChannelLayout{}) {
+		t.Errorf("channels 0,0: layout %s (%v), want unknown", layout, err)
+	}
+
+	if _, _, err = remix.Select(format, []int{6}); err == nil {
+		t.Error("channel 6 of 6: no error")
+	}
+}
+
+// TestParseMatrix checks that rows are read in order and must have as many gains.
+func TestParseMatrix(t *testing.T) {
+	t.Parallel()
+
+	matrix, err := remix.ParseMatrix("0, 1; 1, 0")
+	if err != nil || !closeTo(matrix, remix.Matrix{{0, 1}, {1, 0}}) {
+		t.Errorf("swap: %v (%v)", matrix, err)
+	}
+
+	for _, text := range []string{"0.5,0.5;1", "0.5,half", ""} {
+		if matrix, err := remix.ParseMatrix(text); err == nil {
+			t.Errorf("%q: %v, want an error", text, matrix)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package remix
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/internal/pcmio"
+)
+
+// Mixer applies a matrix to interleaved PCM, one sample frame at a time. Samples are rounded to the
+// input bit depth, and clipped to its range.
+type Mixer struct {
+	format saprobe.PCMFormat // of the output
+	in     uint              // input channels
+	matrix Matrix
+	frame  []float64 // the input samples of the frame being mixed
+}
+
+// New creates a mixer applying matrix to PCM in format, its output having the given layout: the
+// unknown layout when no speakers can be told.
+func New(format saprobe.PCMFormat, matrix Matrix, layout saprobe.ChannelLayout) (*Mixer, error) {
+	if len(matrix) == 0 {
+		return nil, fmt.Errorf("%w: no output channel", errMatrix)
+	}
+
+	for _, row := range matrix {
+		if len(row) != int(format.Channels) { //nolint:gosec // channel count is small.
+			return nil, fmt.Errorf("%w: %d gains per row for %d channels", errMatrix, len(row), format.Channels)
+		}
+	}
+
+	if speakers := layout.Channels(); speakers != 0 && speakers != len(matrix) {
+		return nil, fmt.Errorf("%w: layout %s for %d channels", errMatrix, layout, len(matrix))
+	}
+
+	mixer := &Mixer{format: format, in: format.Channels, matrix: matrix, frame: make([]float64, format.Channels)}
+	mixer.format.Channels = uint(len(matrix))
+	mixer.format.Layout = layout
+
+	return mixer, nil
+}
+
+// Format returns the format of the output.
+func (m *Mixer) Format() saprobe.PCMFormat {
+	return m.format
+}
+
+// Append mixes pcm, whole frames of interleaved little-endian signed PCM in the input format, and
+// appends the result to dst. A trailing partial frame is ignored.
+func (m *Mixer) Append(dst, pcm []byte) []byte {
+	bps := m.format.BitDepth.BytesPerSample()
+	shift := 8*uint(bps) - uint(m.format.BitDepth)
+	limit := float64(int64(1) << (m.format.BitDepth - 1))
+	frameSize := bps * int(m.in) //nolint:gosec // channel count is small.
+
+	var sample [4]byte
+
+	for pos := 0; pos+frameSize <= len(pcm); pos += frameSize {
+		for ch := range m.frame {
+			m.frame[ch] = float64(pcmio.ReadSample(pcm[pos+ch*bps:], bps) >> shift)
+		}
+
+		for _, row := range m.matrix {
+			var sum float64
+			for ch, gain := range row {
+				sum += gain * m.frame[ch]
+			}
+
+			value := int64(max(-limit, min(limit-1, math.Round(sum))))
+			pcmio.WriteSample(sample[:], bps, value<<shift)
+			dst = append(dst, sample[:bps]...)
+		}
+	}
+
+	return dst
+}
+
+// Remix applies matrix to pcm, interleaved little-endian signed PCM in format, the output having
+// the given layout (see New).
+func Remix(
+	pcm []byte, format saprobe.PCMFormat, matrix Matrix, layout saprobe.ChannelLayout,
+) ([]byte, saprobe.PCMFormat, error) {
+	mixer, err := New(format, matrix, layout)
+	if err != nil {
+		return nil, saprobe.PCMFormat{}, err
+	}
+
+	bps := format.BitDepth.BytesPerSample()
+	frames := len(pcm) / (bps * int(format.Channels)) //nolint:gosec // channel count is small.
+	out := make([]byte, 0, frames*bps*len(matrix))
+
+	return mixer.Append(out, pcm), mixer.Format(), nil
+}
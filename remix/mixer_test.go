@@ -0,0 +1,100 @@
+package remix_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/remix"
+)
+
+// interleave returns samples as little-endian signed PCM of the given width.
+func interleave(width int, samples ...int32) []byte {
+	pcm := make([]byte, 0, len(samples)*width)
+	for _, sample := range samples {
+		for k := range width {
+			pcm = append(pcm, byte(sample>>(8*k)))
+		}
+	}
+
+	return pcm
+}
+
+// TestRemixDownmix checks that a 5.1 downmix to stereo folds the center in at -3 dB relative to the
+// fronts, ignores the LFE, and does not clip full-scale input.
+func TestRemixDownmix(t *testing.T) {
+	t.Parallel()
+
+	format := saprobe.PCMFormat{
+		SampleRate: 48000, BitDepth: saprobe.Depth16, Channels: 6, Layout: saprobe.DefaultLayout(6),
+	}
+
+	matrix, err := remix.Downmix(format.Layout, saprobe.LayoutStereo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pcm := interleave(2,
+		32767, 32767, 32767, 32767, 32767, 32767,
+		-32768, -32768, -32768, -32768, -32768, -32768,
+		10000, 0, 10000, 32767, 0, 0,
+	)
+
+	got, out, err := remix.Remix(pcm, format, matrix, saprobe.LayoutStereo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 10000 and 10000 at -3 dB over 1+sqrt(2) is 7071.07 on the left, the center alone 2928.93 on
+	// the right.
+	want := interleave(2, 32767, 32767, -32768, -32768, 7071, 2929)
+	if !slices.Equal(got, want) {
+		t.Errorf("downmix %v, want %v", got, want)
+	}
+
+	if out.Channels != 2 || out.Layout != saprobe.LayoutStereo || out.BitDepth != format.BitDepth ||
+		out.SampleRate != format.SampleRate {
+		t.Errorf("output format %+v, want 16-bit stereo at 48000 Hz", out)
+	}
+}
+
+// TestMixer checks that mixed 24-bit samples are rounded, clipped to range, and that a partial
+// frame is ignored.
+func TestMixer(t *testing.T) {
+	t.Parallel()
+
+	format := saprobe.PCMFormat{
+		SampleRate: 44100, BitDepth: saprobe.Depth24, Channels: 2, Layout: saprobe.LayoutStereo,
+	}
+
+	matrix, err := remix.ParseMatrix("2,0;0.5,0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mixer, err := remix.New(format, matrix, saprobe.ChannelLayout{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pcm := interleave(3, 5000000, 3, -5000000, -4, 100, 0)
+	got := mixer.Append(nil, append(pcm, 1, 2, 3))
+
+	// 2500001.5 rounds away from zero.
+	want := interleave(3, 8388607, 2500002, -8388608, -2500002, 200, 50)
+	if !slices.Equal(got, want) {
+		t.Errorf("mix %v, want %v", got, want)
+	}
+
+	if mixer.Format().Channels != 2 || mixer.Format().Layout != (saprobe.ChannelLayout{}) {
+		t.Errorf("output format %+v, want 2 channels of unknown layout", mixer.Format())
+	}
+
+	if _, err := remix.New(format, remix.Matrix{{1, 0, 0}}, saprobe.LayoutMono); err == nil {
+		t.Error("3 gains for 2 channels: no error")
+	}
+
+	if _, err := remix.New(format, remix.Matrix{{1, 0}}, saprobe.LayoutStereo); err == nil {
+		t.Error("stereo layout for 1 channel: no error")
+	}
+}
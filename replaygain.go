@@ -0,0 +1,158 @@
+package saprobe
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/farcloser/saprobe/internal/pcmio"
+)
+
+// ReplayGain tag keys, as Vorbis comments, ID3v2 TXXX frames, APE items and iTunes freeform items
+// name them. iTunNORM is the iTunes Sound Check comment.
+const (
+	TagReplayGainTrackGain = "REPLAYGAIN_TRACK_GAIN"
+	TagReplayGainTrackPeak = "REPLAYGAIN_TRACK_PEAK"
+	TagReplayGainAlbumGain = "REPLAYGAIN_ALBUM_GAIN"
+	TagReplayGainAlbumPeak = "REPLAYGAIN_ALBUM_PEAK"
+	TagITunNORM            = "ITUNNORM"
+)
+
+// iTunNORM holds ten hexadecimal fields: the Sound Check levels of the left and right channels
+// first, relative to 1/1000 W, and their peaks at fields 6 and 7, full scale being 32768.
+const (
+	itunNORMFields    = 10
+	itunNORMReference = 1000
+	itunNORMPeak      = 6
+	itunNORMFullScale = 32768
+)
+
+// Gain is a loudness normalization adjustment.
+type Gain struct {
+	// Set tells whether the source stores the gain at all.
+	Set bool
+	// Gain is the adjustment, in dB.
+	Gain float64
+	// Peak is the highest sample magnitude of the audio, full scale being 1, or 0 when unknown.
+	Peak float64
+}
+
+// Factor returns the linear factor of the gain, reduced as the peak requires for the audio not
+// to clip.
+func (g Gain) Factor() float64 {
+	factor := math.Pow(10, g.Gain/20)
+	if g.Peak > 0 && factor*g.Peak > 1 {
+		factor = 1 / g.Peak
+	}
+
+	return factor
+}
+
+// ReplayGain holds the track and album gains of a file.
+type ReplayGain struct {
+	Track Gain
+	Album Gain
+}
+
+// Or returns the gains of r, completed by those of other where r has none.
+func (r ReplayGain) Or(other ReplayGain) ReplayGain {
+	if !r.Track.Set {
+		r.Track = other.Track
+	}
+
+	if !r.Album.Set {
+		r.Album = other.Album
+	}
+
+	return r
+}
+
+// ReplayGain returns the gains stored in the ReplayGain tags, with the track gain falling back to
+// the iTunNORM Sound Check level.
+func (m *Metadata) ReplayGain() ReplayGain {
+	gains := ReplayGain{
+		Track: m.gain(TagReplayGainTrackGain, TagReplayGainTrackPeak),
+		Album: m.gain(TagReplayGainAlbumGain, TagReplayGainAlbumPeak),
+	}
+
+	if value, ok := m.Get(TagITunNORM); ok {
+		gains = gains.Or(ReplayGain{Track: parseITunNORM(value)})
+	}
+
+	return gains
+}
+
+// gain reads a gain tag, such as "-6.48 dB", and its peak tag.
+func (m *Metadata) gain(gainKey, peakKey string) Gain {
+	value, ok := m.Get(gainKey)
+	if !ok {
+		return Gain{}
+	}
+
+	value = strings.TrimSpace(value)
+	value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(value, "dB"), "db"))
+
+	gain, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(gain) || math.IsInf(gain, 0) {
+		return Gain{}
+	}
+
+	var peak float64
+
+	if value, ok := m.Get(peakKey); ok {
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err == nil && parsed > 0 && !math.IsInf(parsed, 0) {
+			peak = parsed
+		}
+	}
+
+	return Gain{Set: true, Gain: gain, Peak: peak}
+}
+
+// parseITunNORM reads the gain and peak of an iTunNORM comment, from its louder channel.
+func parseITunNORM(value string) Gain {
+	fields := strings.Fields(value)
+	if len(fields) < itunNORMFields {
+		return Gain{}
+	}
+
+	var level, peak uint64
+
+	for idx := range 2 {
+		parsed, err := strconv.ParseUint(fields[idx], 16, 32)
+		if err != nil {
+			return Gain{}
+		}
+
+		level = max(level, parsed)
+
+		if parsed, err = strconv.ParseUint(fields[itunNORMPeak+idx], 16, 32); err == nil {
+			peak = max(peak, parsed)
+		}
+	}
+
+	if level == 0 {
+		return Gain{}
+	}
+
+	return Gain{
+		Set:  true,
+		Gain: -10 * math.Log10(float64(level)/itunNORMReference),
+		Peak: float64(peak) / itunNORMFullScale,
+	}
+}
+
+// ApplyGain multiplies the samples of pcm, interleaved little-endian signed PCM in format, by
+// factor, in place. Samples are rounded, and clipped to the range of the bit depth.
+func ApplyGain(pcm []byte, format PCMFormat, factor float64) {
+	bps := format.BitDepth.BytesPerSample()
+	shift := 8*uint(bps) - uint(format.BitDepth)
+	limit := float64(int64(1) << (format.BitDepth - 1))
+
+	for pos := 0; pos+bps <= len(pcm); pos += bps {
+		// Right-align 20-bit samples.
+		value := pcmio.ReadSample(pcm[pos:], bps) >> shift
+		value = int64(max(-limit, min(limit-1, math.Round(float64(value)*factor))))
+		pcmio.WriteSample(pcm[pos:], bps, value<<shift)
+	}
+}
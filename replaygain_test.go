@@ -0,0 +1,120 @@
+package saprobe_test
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+)
+
+// near reports whether two gains agree, to rounding.
+func near(got, want saprobe.Gain) bool {
+	return got.Set == want.Set && math.Abs(got.Gain-want.Gain) < 1e-4 && math.Abs(got.Peak-want.Peak) < 1e-9
+}
+
+// TestMetadataReplayGain checks that ReplayGain tags are parsed, and that the iTunNORM Sound Check
+// level stands in for a missing track gain.
+func TestMetadataReplayGain(t *testing.T) {
+	t.Parallel()
+
+	// Levels 2000 and 1000 of 1/1000 W, peaks 0x4000 and 0x2000 of 32768.
+	const itunNORM = " 000007D0 000003E8 00000000 00000000 00000000 00000000 00004000 00002000 00000000 00000000"
+
+	for _, test := range []struct {
+		name string
+		tags [][2]string
+		want saprobe.ReplayGain
+	}{
+		{"tags", [][2]string{
+			{"replaygain_track_gain", "-6.48 dB"},
+			{"REPLAYGAIN_TRACK_PEAK", "0.988831"},
+			{"REPLAYGAIN_ALBUM_GAIN", "+1.50dB"},
+		}, saprobe.ReplayGain{
+			Track: saprobe.Gain{Set: true, Gain: -6.48, Peak: 0.988831},
+			Album: saprobe.Gain{Set: true, Gain: 1.5},
+		}},
+		{"invalid", [][2]string{
+			{"REPLAYGAIN_TRACK_GAIN", "loud"},
+			{"REPLAYGAIN_ALBUM_GAIN", "-3 dB"},
+			{"REPLAYGAIN_ALBUM_PEAK", "-1"},
+		}, saprobe.ReplayGain{Album: saprobe.Gain{Set: true, Gain: -3}}},
+		{"iTunNORM", [][2]string{{"ITUNNORM", itunNORM}}, saprobe.ReplayGain{
+			Track: saprobe.Gain{Set: true, Gain: -10 * math.Log10(2), Peak: 0.5},
+		}},
+		{"tags and iTunNORM", [][2]string{
+			{"ITUNNORM", itunNORM},
+			{"REPLAYGAIN_TRACK_GAIN", "-1 dB"},
+		}, saprobe.ReplayGain{Track: saprobe.Gain{Set: true, Gain: -1}}},
+		{"short iTunNORM", [][2]string{{"ITUNNORM", "000007D0 000003E8"}}, saprobe.ReplayGain{}},
+	} {
+		var metadata saprobe.Metadata
+		for _, tag := range test.tags {
+			metadata.Add(tag[0], tag[1])
+		}
+
+		got := metadata.ReplayGain()
+		if !near(got.Track, test.want.Track) || !near(got.Album, test.want.Album) {
+			t.Errorf("%s: %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+// TestGainFactor checks that a gain is held back by its peak, to the level that reaches full scale.
+func TestGainFactor(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		gain saprobe.Gain
+		want float64
+	}{
+		{saprobe.Gain{Set: true, Gain: 20 * math.Log10(2)}, 2},
+		{saprobe.Gain{Set: true, Gain: 20 * math.Log10(2), Peak: 0.8}, 1.25},
+		{saprobe.Gain{Set: true, Gain: -20 * math.Log10(2), Peak: 0.9}, 0.5},
+	} {
+		if got := test.gain.Factor(); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%+v: factor %f, want %f", test.gain, got, test.want)
+		}
+	}
+}
+
+// TestApplyGain checks that scaled samples are rounded and clipped at each bit depth, 20-bit
+// samples staying left-aligned.
+func TestApplyGain(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		depth     saprobe.BitDepth
+		factor    float64
+		in, want  []int32
+		alignment uint
+	}{
+		{saprobe.Depth16, 2, []int32{1000, -1000, 20000, -20000}, []int32{2000, -2000, 32767, -32768}, 0},
+		{saprobe.Depth16, 0.5, []int32{3, -3, 32767}, []int32{2, -2, 16384}, 0},
+		{saprobe.Depth20, 2, []int32{1000, 300000, -300000}, []int32{2000, 524287, -524288}, 4},
+		{saprobe.Depth24, 2, []int32{-7, 5000000}, []int32{-14, 8388607}, 0},
+		{saprobe.Depth32, 0.5, []int32{math.MaxInt32, -5}, []int32{1 << 30, -3}, 0},
+	} {
+		format := saprobe.PCMFormat{SampleRate: 44100, BitDepth: test.depth, Channels: 1}
+		width := test.depth.BytesPerSample()
+
+		pcm := samples(width, test.alignment, test.in)
+		saprobe.ApplyGain(pcm, format, test.factor)
+
+		if want := samples(width, test.alignment, test.want); !slices.Equal(pcm, want) {
+			t.Errorf("%d-bit by %g: %v, want %v", test.depth, test.factor, pcm, want)
+		}
+	}
+}
+
+// samples returns values shifted left by alignment, as little-endian signed PCM of the given width.
+func samples(width int, alignment uint, values []int32) []byte {
+	pcm := make([]byte, 0, len(values)*width)
+	for _, value := range values {
+		for k := range width {
+			pcm = append(pcm, byte(value<<alignment>>(8*k)))
+		}
+	}
+
+	return pcm
+}
@@ -0,0 +1,3 @@
+// Package resample converts PCM from one sample rate to another with a polyphase windowed-sinc
+// filter, on the interleaved little-endian signed PCM the decoders produce.
+package resample
@@ -0,0 +1,100 @@
+package resample
+
+import "math"
+
+// maxPhases bounds the phases of the filter table. Rate pairs whose reduced ratio needs more
+// interpolate linearly between the nearest phases instead.
+const maxPhases = 1024
+
+// filter is a polyphase lowpass: row p holds the taps weighing 2*half consecutive input samples
+// for an output sample that falls p/phases of the way between two of them.
+type filter struct {
+	half   int         // taps on either side of the output sample, in input samples
+	phases int         // rows of the table, the last one excluded
+	table  [][]float64 // phases+1 rows: the last one, one whole sample on, closes interpolation
+}
+
+// newFilter designs the filter converting from rate in to rate out, with a Kaiser window meeting
+// the attenuation of quality over the transition band between its passband edge and the Nyquist
+// frequency of the lower rate. Its cutoff lies in the middle of that band.
+func newFilter(in, out, interpolation int, quality Quality) filter {
+	pass, attenuation := quality.spec()
+
+	// Frequencies in cycles per input sample.
+	nyquist := float64(min(in, out)) / float64(in) / 2
+	transition := (1 - pass) * nyquist
+	cutoff := (1 + pass) / 2 * nyquist
+
+	// Kaiser's estimates of the window length and shape for the attenuation and transition width.
+	length := (attenuation - 7.95) / (2.285 * 2 * math.Pi * transition)
+	half := int(math.Ceil(length / 2))
+	beta := kaiserBeta(attenuation)
+
+	phases := min(interpolation, maxPhases)
+	table := make([][]float64, phases+1)
+
+	for phase := range table {
+		row := make([]float64, 2*half)
+		offset := float64(phase) / float64(phases)
+
+		var sum float64
+
+		for tap := range row {
+			// Distance from the output sample to the input sample the tap weighs.
+			t := offset + float64(half-1-tap)
+			row[tap] = 2 * cutoff * sinc(2*cutoff*t) * kaiser(t/float64(half), beta)
+			sum += row[tap]
+		}
+
+		// Unity gain at DC for every phase.
+		for tap := range row {
+			row[tap] /= sum
+		}
+
+		table[phase] = row
+	}
+
+	return filter{half: half, phases: phases, table: table}
+}
+
+// kaiserBeta returns the Kaiser window shape parameter reaching the attenuation, in dB.
+func kaiserBeta(attenuation float64) float64 {
+	switch {
+	case attenuation > 50:
+		return 0.1102 * (attenuation - 8.7)
+	case attenuation >= 21:
+		return 0.5842*math.Pow(attenuation-21, 0.4) + 0.07886*(attenuation-21)
+	default:
+		return 0
+	}
+}
+
+// kaiser returns the Kaiser window at x, over [-1, 1].
+func kaiser(x, beta float64) float64 {
+	if x <= -1 || x >= 1 {
+		return 0
+	}
+
+	return bessel0(beta*math.Sqrt(1-x*x)) / bessel0(beta)
+}
+
+// bessel0 returns the zeroth order modified Bessel function of the first kind, from its series.
+func bessel0(x float64) float64 {
+	sum, term := 1.0, 1.0
+
+	for k := 1; term > sum*1e-16; k++ {
+		term *= (x / 2 / float64(k)) * (x / 2 / float64(k))
+		sum += term
+	}
+
+	return sum
+}
+
+// sinc returns sin(pi x) / (pi x).
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
@@ -0,0 +1,41 @@
+package resample
+
+// Quality selects the trade-off between the fidelity of the filter and the work per sample.
+type Quality uint8
+
+const (
+	// QualityHigh keeps 95% of the narrower band and rejects images and aliases by 120 dB.
+	QualityHigh Quality = iota
+	// QualityMedium keeps 90% of the narrower band and rejects images and aliases by 90 dB.
+	QualityMedium
+	// QualityLow keeps 80% of the narrower band and rejects images and aliases by 60 dB.
+	QualityLow
+)
+
+// String returns the name of the quality preset.
+func (q Quality) String() string {
+	switch q {
+	case QualityHigh:
+		return "high"
+	case QualityMedium:
+		return "medium"
+	case QualityLow:
+		return "low"
+	}
+
+	return "unknown"
+}
+
+// spec returns the passband edge, as a fraction of the Nyquist frequency of the lower of the two
+// rates, and the stopband attenuation in dB of the preset.
+func (q Quality) spec() (float64, float64) {
+	switch q {
+	case QualityMedium:
+		return 0.90, 90
+	case QualityLow:
+		return 0.80, 60
+	case QualityHigh:
+	}
+
+	return 0.95, 120
+}
@@ -0,0 +1,228 @@
+package resample
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/internal/pcmio"
+)
+
+var errRate = errors.New("resample: sample rates must be positive")
+
+// Resampler converts interleaved PCM to another sample rate incrementally: Append takes the PCM in
+// pieces of any size, and Flush ends the stream. The output is aligned with the input: its first
+// sample falls on the first input sample, and it lasts as long as the input, rounded up to a whole
+// output sample. Samples are rounded to the input bit depth, and clipped to its range.
+type Resampler struct {
+	format  saprobe.PCMFormat // of the input; the output only differs by its rate
+	rate    int
+	up      int // the reduced ratio: up output samples for every down input samples
+	down    int
+	filter  filter
+	row     []float64   // the interpolated taps of the output sample being computed
+	history [][]float64 // input samples per channel, from input index base on
+	base    int64       // negative while the silence before the first input sample is in history
+	input   int64       // input samples per channel appended
+	output  int64       // output samples per channel produced
+	partial []byte      // a trailing partial frame of the last Append
+}
+
+// New creates a resampler from format to rate, with the filter of the quality preset.
+func New(format saprobe.PCMFormat, rate int, quality Quality) (*Resampler, error) {
+	if format.SampleRate <= 0 || rate <= 0 {
+		return nil, fmt.Errorf("%w: %d Hz to %d Hz", errRate, format.SampleRate, rate)
+	}
+
+	divisor := gcd(format.SampleRate, rate)
+	resampler := &Resampler{
+		format: format,
+		rate:   rate,
+		up:     rate / divisor,
+		down:   format.SampleRate / divisor,
+	}
+
+	if resampler.up == resampler.down {
+		return resampler, nil
+	}
+
+	resampler.filter = newFilter(format.SampleRate, rate, resampler.up, quality)
+	resampler.row = make([]float64, 2*resampler.filter.half)
+	resampler.history = make([][]float64, format.Channels)
+	resampler.base = -int64(resampler.filter.half)
+
+	for ch := range resampler.history {
+		resampler.history[ch] = make([]float64, resampler.filter.half)
+	}
+
+	return resampler, nil
+}
+
+// Format returns the format of the output.
+func (r *Resampler) Format() saprobe.PCMFormat {
+	format := r.format
+	format.SampleRate = r.rate
+
+	return format
+}
+
+// Append resamples pcm, interleaved little-endian signed PCM in the input format, and appends to
+// dst the output samples it completes.
+func (r *Resampler) Append(dst, pcm []byte) []byte {
+	if r.up == r.down {
+		return append(dst, pcm...)
+	}
+
+	frameSize := r.frameSize()
+
+	if len(r.partial) > 0 {
+		take := min(frameSize-len(r.partial), len(pcm))
+		r.partial = append(r.partial, pcm[:take]...)
+		pcm = pcm[take:]
+
+		if len(r.partial) < frameSize {
+			return dst
+		}
+
+		r.push(r.partial)
+		r.partial = r.partial[:0]
+	}
+
+	whole := len(pcm) - len(pcm)%frameSize
+	r.push(pcm[:whole])
+	r.partial = append(r.partial, pcm[whole:]...)
+
+	return r.produce(dst, r.input)
+}
+
+// Flush appends to dst the output samples that remain once the input has ended, the input being
+// silent past its end. A trailing partial frame is dropped.
+func (r *Resampler) Flush(dst []byte) []byte {
+	if r.up == r.down {
+		return dst
+	}
+
+	r.partial = r.partial[:0]
+
+	for ch := range r.history {
+		r.history[ch] = append(r.history[ch], make([]float64, r.filter.half+1)...)
+	}
+
+	return r.produce(dst, r.input+int64(r.filter.half)+1)
+}
+
+// push appends whole frames of PCM to the history.
+func (r *Resampler) push(pcm []byte) {
+	bps := r.format.BitDepth.BytesPerSample()
+	shift := 8*uint(bps) - uint(r.format.BitDepth)
+
+	for pos := 0; pos < len(pcm); {
+		for ch := range r.history {
+			r.history[ch] = append(r.history[ch], float64(pcmio.ReadSample(pcm[pos:], bps)>>shift))
+			pos += bps
+		}
+	}
+
+	r.input += int64(len(pcm) / r.frameSize())
+}
+
+// produce computes the output samples whose taps fall before input index available, appending
+// them to dst, and drops the history they no longer need. After Flush, it stops at the end of the
+// stream.
+func (r *Resampler) produce(dst []byte, available int64) []byte {
+	bps := r.format.BitDepth.BytesPerSample()
+	shift := 8*uint(bps) - uint(r.format.BitDepth)
+	limit := float64(int64(1) << (r.format.BitDepth - 1))
+	half := int64(r.filter.half)
+	end := (r.input*int64(r.up) + int64(r.down) - 1) / int64(r.down)
+
+	var sample [4]byte
+
+	for {
+		position := r.output * int64(r.down) // in input samples, times up
+		center := position / int64(r.up)
+
+		if center+half >= available || (available > r.input && r.output >= end) {
+			break
+		}
+
+		row := r.taps(int(position % int64(r.up)))
+
+		first := center - half + 1 - r.base
+
+		for _, samples := range r.history {
+			var sum float64
+			for tap, input := range samples[first : first+2*half] {
+				sum += row[tap] * input
+			}
+
+			value := int64(max(-limit, min(limit-1, math.Round(sum))))
+			pcmio.WriteSample(sample[:], bps, value<<shift)
+			dst = append(dst, sample[:bps]...)
+		}
+
+		r.output++
+	}
+
+	// Keep the history from the first tap of the next output sample on.
+	keep := min(r.output*int64(r.down)/int64(r.up)-half+1, r.input)
+	if drop := int(keep - r.base); drop > 0 {
+		for ch, samples := range r.history {
+			r.history[ch] = samples[:copy(samples, samples[min(drop, len(samples)):])]
+		}
+
+		r.base = keep
+	}
+
+	return dst
+}
+
+// taps returns the taps of an output sample phase/up of the way between two input samples,
+// interpolating between the nearest rows of the table when it has fewer phases than up.
+func (r *Resampler) taps(phase int) []float64 {
+	if r.filter.phases == r.up {
+		return r.filter.table[phase]
+	}
+
+	position := float64(phase) * float64(r.filter.phases) / float64(r.up)
+	index := int(position)
+	weight := position - float64(index)
+	below, above := r.filter.table[index], r.filter.table[index+1]
+
+	for tap := range r.row {
+		r.row[tap] = below[tap] + weight*(above[tap]-below[tap])
+	}
+
+	return r.row
+}
+
+func (r *Resampler) frameSize() int {
+	return r.format.BitDepth.BytesPerSample() * int(r.format.Channels) //nolint:gosec // channel counts are small.
+}
+
+// Resample converts pcm, interleaved little-endian signed PCM in format, to rate.
+func Resample(pcm []byte, format saprobe.PCMFormat, rate int, quality Quality) ([]byte, saprobe.PCMFormat, error) {
+	resampler, err := New(format, rate, quality)
+	if err != nil {
+		return nil, saprobe.PCMFormat{}, err
+	}
+
+	if resampler.up == resampler.down {
+		return pcm, resampler.Format(), nil
+	}
+
+	frames := int64(len(pcm) / resampler.frameSize())
+	out := make([]byte, 0, (frames*int64(rate)/int64(format.SampleRate)+1)*int64(resampler.frameSize()))
+	out = resampler.Append(out, pcm)
+
+	return resampler.Flush(out), resampler.Format(), nil
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	return a
+}
@@ -0,0 +1,254 @@
+package resample_test
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/resample"
+)
+
+// Tones are generated and measured at 32 bits, so that quantization noise stays far below the
+// stopband attenuation of every preset.
+const (
+	amplitude  = 0.5
+	sweepTones = 24
+	toneLength = 1 << 14 // output samples measured per tone
+)
+
+//nolint:gochecknoglobals // constant table
+var conversions = []struct{ in, out int }{
+	{44100, 48000},
+	{48000, 44100},
+	{48000, 16000},
+	{44100, 16000},
+	{16000, 48000},
+	{96000, 44100},
+	{44100, 44101}, // too many phases for the table: interpolated
+}
+
+//nolint:gochecknoglobals // constant table
+var presets = []struct {
+	quality resample.Quality
+	pass    float64 // passband edge, as a fraction of the lower Nyquist frequency
+	ripple  float64 // dB, peak to peak
+	reject  float64 // dB, below the tone
+}{
+	{resample.QualityHigh, 0.95, 0.001, 115},
+	{resample.QualityMedium, 0.90, 0.001, 87},
+	{resample.QualityLow, 0.80, 0.02, 57},
+}
+
+// TestPassbandRipple sweeps tones across the passband and checks that they come out at the same
+// level, and free of images and aliases.
+func TestPassbandRipple(t *testing.T) {
+	for _, preset := range presets {
+		for _, conv := range conversions {
+			t.Run(fmt.Sprintf("%s/%d-%d", preset.quality, conv.in, conv.out), func(t *testing.T) {
+				t.Parallel()
+
+				edge := preset.pass * float64(min(conv.in, conv.out)) / 2
+				lowest, highest := math.Inf(1), math.Inf(-1)
+				worst := math.Inf(-1)
+
+				for _, freq := range sweep(20, edge, sweepTones) {
+					gain, residual := resampleTone(t, conv.in, conv.out, freq, preset.quality)
+					lowest, highest = min(lowest, gain), max(highest, gain)
+					worst = max(worst, residual)
+				}
+
+				if ripple := highest - lowest; ripple > preset.ripple {
+					t.Errorf("passband ripple %.5f dB, want at most %.5f dB", ripple, preset.ripple)
+				}
+
+				if worst > -preset.reject {
+					t.Errorf("images and aliases at %.1f dB, want below -%.0f dB", worst, preset.reject)
+				}
+			})
+		}
+	}
+}
+
+// TestAliasing sweeps tones across the stopband of downsampling conversions, between the output
+// Nyquist frequency and the input one, and checks what folds back into the output.
+func TestAliasing(t *testing.T) {
+	for _, preset := range presets {
+		for _, conv := range conversions {
+			if conv.out >= conv.in {
+				continue
+			}
+
+			t.Run(fmt.Sprintf("%s/%d-%d", preset.quality, conv.in, conv.out), func(t *testing.T) {
+				t.Parallel()
+
+				worst := math.Inf(-1)
+
+				for _, freq := range sweep(float64(conv.out)/2, 0.99*float64(conv.in)/2, sweepTones) {
+					level, _ := resampleTone(t, conv.in, conv.out, freq, preset.quality)
+					worst = max(worst, level)
+				}
+
+				if worst > -preset.reject {
+					t.Errorf("aliases at %.1f dB, want below -%.0f dB", worst, preset.reject)
+				}
+			})
+		}
+	}
+}
+
+// TestStreaming checks that feeding the input in pieces of any size, partial frames included,
+// changes nothing, and that the output lasts as long as the input.
+func TestStreaming(t *testing.T) {
+	format := saprobe.PCMFormat{SampleRate: 44100, BitDepth: saprobe.Depth24, Channels: 2}
+	pcm := tonePCM(format, 1000, 10007)
+
+	whole, outFormat, err := resample.Resample(pcm, format, 48000, resample.QualityMedium)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames := len(whole) / (outFormat.BitDepth.BytesPerSample() * int(outFormat.Channels))
+	if want := (10007*48000 + 44099) / 44100; frames != want {
+		t.Errorf("%d output samples, want %d", frames, want)
+	}
+
+	resampler, err := resample.New(format, 48000, resample.QualityMedium)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pieces []byte
+
+	for start, size := 0, 1; start < len(pcm); start, size = start+size, size*3%1000+1 {
+		pieces = resampler.Append(pieces, pcm[start:min(start+size, len(pcm))])
+	}
+
+	if pieces = resampler.Flush(pieces); !bytes.Equal(pieces, whole) {
+		t.Errorf("streamed output differs from the whole conversion")
+	}
+}
+
+// TestSameRate checks that a conversion to the input rate returns the input.
+func TestSameRate(t *testing.T) {
+	format := saprobe.PCMFormat{SampleRate: 48000, BitDepth: saprobe.Depth20, Channels: 1}
+	pcm := tonePCM(format, 440, 4800)
+
+	out, _, err := resample.Resample(pcm, format, 48000, resample.QualityHigh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(out, pcm) {
+		t.Error("same-rate conversion changed the samples")
+	}
+}
+
+// sweep returns count frequencies spread logarithmically from low to high.
+func sweep(low, high float64, count int) []float64 {
+	freqs := make([]float64, count)
+	for idx := range freqs {
+		freqs[idx] = low * math.Pow(high/low, float64(idx)/float64(count-1))
+	}
+
+	return freqs
+}
+
+// resampleTone converts a tone at freq and returns, in dB relative to the input tone, the level of
+// the tone in the output, and of the rest of the output. A tone beyond the output Nyquist frequency
+// has no place in the output: all of it is the first level.
+func resampleTone(t *testing.T, in, out int, freq float64, quality resample.Quality) (float64, float64) {
+	t.Helper()
+
+	format := saprobe.PCMFormat{SampleRate: in, BitDepth: saprobe.Depth32, Channels: 1}
+
+	// Measure away from the filter transients at either end.
+	margin := out / 10
+	length := (toneLength + 2*margin) * in / out
+
+	pcm, outFormat, err := resample.Resample(tonePCM(format, freq, length), format, out, quality)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := decode(pcm)[margin : margin+toneLength]
+	scale := amplitude * math.MaxInt32
+
+	if freq >= float64(out)/2 {
+		return decibels(rms(samples) * math.Sqrt2 / scale), math.Inf(-1)
+	}
+
+	fitted, residual := fitTone(samples, freq/float64(outFormat.SampleRate))
+
+	return decibels(fitted / scale), decibels(residual * math.Sqrt2 / scale)
+}
+
+// fitTone fits a sinusoid of the given frequency, in cycles per sample, to samples by least
+// squares, and returns its amplitude and the RMS of what it leaves.
+func fitTone(samples []float64, freq float64) (float64, float64) {
+	var cc, ss, cs, xc, xs float64
+
+	for idx, x := range samples {
+		c, s := math.Cos(2*math.Pi*freq*float64(idx)), math.Sin(2*math.Pi*freq*float64(idx))
+		cc, ss, cs = cc+c*c, ss+s*s, cs+c*s
+		xc, xs = xc+x*c, xs+x*s
+	}
+
+	det := cc*ss - cs*cs
+	a := (xc*ss - xs*cs) / det
+	b := (xs*cc - xc*cs) / det
+
+	var residual float64
+
+	for idx, x := range samples {
+		fit := a*math.Cos(2*math.Pi*freq*float64(idx)) + b*math.Sin(2*math.Pi*freq*float64(idx))
+		residual += (x - fit) * (x - fit)
+	}
+
+	return math.Hypot(a, b), math.Sqrt(residual / float64(len(samples)))
+}
+
+// tonePCM returns length samples per channel of a sine at freq, in format.
+func tonePCM(format saprobe.PCMFormat, freq float64, length int) []byte {
+	bps := format.BitDepth.BytesPerSample()
+	scale := amplitude * float64(int64(1)<<(8*bps-1))
+	pcm := make([]byte, 0, length*bps*int(format.Channels))
+
+	for idx := range length {
+		value := int64(math.Round(scale * math.Sin(2*math.Pi*freq*float64(idx)/float64(format.SampleRate))))
+		value &^= int64(1)<<(8*uint(bps)-uint(format.BitDepth)) - 1 // left-aligned 20-bit samples
+
+		for range format.Channels {
+			for b := range bps {
+				pcm = append(pcm, byte(value>>(8*b)))
+			}
+		}
+	}
+
+	return pcm
+}
+
+// decode returns mono 32-bit PCM as samples.
+func decode(pcm []byte) []float64 {
+	samples := make([]float64, len(pcm)/4)
+	for idx := range samples {
+		p := pcm[4*idx:]
+		samples[idx] = float64(int32(uint32(p[0]) | uint32(p[1])<<8 | uint32(p[2])<<16 | uint32(p[3])<<24))
+	}
+
+	return samples
+}
+
+func rms(samples []float64) float64 {
+	var sum float64
+	for _, x := range samples {
+		sum += x * x
+	}
+
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+func decibels(ratio float64) float64 {
+	return 20 * math.Log10(ratio)
+}
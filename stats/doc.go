@@ -0,0 +1,4 @@
+// Package stats gathers the quality-control statistics of a stream, per channel: peak, RMS, crest
+// factor, DC offset, clipping and effective bit depth, along with the silence at its head and tail.
+// It works on the planar blocks the codec Blocks iterators yield, in a single pass.
+package stats
@@ -0,0 +1,208 @@
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+
+	"github.com/farcloser/saprobe"
+)
+
+// minClipRun is the number of consecutive full-scale samples that make a clipped run: a single
+// sample at full scale may be a legitimate peak.
+const minClipRun = 2
+
+var errFormatChange = errors.New("stats: the format changes within the stream")
+
+// Analyzer gathers the statistics of a stream, block after block.
+type Analyzer struct {
+	format   saprobe.PCMFormat
+	silence  int64 // the loudest sample magnitude that counts as silent
+	channels []channel
+	samples  int64
+	head     int64 // silent samples at the head, until the first sound
+	tail     int64 // silent samples since the last sound
+	sounded  bool
+}
+
+// channel holds the running sums of a channel.
+type channel struct {
+	peak    int64
+	sum     int64
+	squares float64
+	bits    uint32 // every sample ORed together
+	run     int    // full-scale samples in a row, up to the current one
+	runs    int
+	clipped int64
+	high    int32 // full scale, positive
+	low     int32 // full scale, negative
+}
+
+// Result holds the statistics of a stream. Levels are fractions of full scale.
+type Result struct {
+	Format saprobe.PCMFormat
+	// Samples is the length of the stream, per channel.
+	Samples int64
+	// HeadSilence and TailSilence are the lengths of the silence at both ends, in samples per
+	// channel: a sample is silent when every channel is under the threshold. A silent stream is
+	// silent at its head and tail alike.
+	HeadSilence int64
+	TailSilence int64
+	// EffectiveBitDepth is the largest of the channels.
+	EffectiveBitDepth uint
+	Channels          []Channel
+}
+
+// Channel holds the statistics of a channel.
+type Channel struct {
+	Peak     float64
+	RMS      float64
+	DCOffset float64 // the mean of the samples
+	// ClippedRuns counts the runs of consecutive samples at full scale, either end, and
+	// ClippedSamples the samples in them.
+	ClippedRuns    int
+	ClippedSamples int64
+	// EffectiveBitDepth is the bit depth less the low bits no sample sets, as in audio padded from a
+	// lower bit depth. It is 0 for a silent channel.
+	EffectiveBitDepth uint
+}
+
+// New creates an analyzer for audio in format. Samples at or under silence, in dBFS, count as
+// silent; -Inf only counts digital silence.
+func New(format saprobe.PCMFormat, silence float64) *Analyzer {
+	fullScale := int64(1) << (format.BitDepth - 1)
+
+	analyzer := &Analyzer{
+		format:   format,
+		silence:  int64(math.Pow(10, silence/20) * float64(fullScale)),
+		channels: make([]channel, format.Channels),
+	}
+
+	for ch := range analyzer.channels {
+		//nolint:gosec // full scale fits 32 bits.
+		analyzer.channels[ch].high, analyzer.channels[ch].low = int32(fullScale-1), int32(-fullScale)
+	}
+
+	return analyzer
+}
+
+// Add gathers the statistics of a block of the stream, which must keep the format the analyzer was
+// created for.
+func (a *Analyzer) Add(block saprobe.Block) error {
+	if block.Format != a.format {
+		return fmt.Errorf("%w: %d Hz %d-bit %d channels, then %d Hz %d-bit %d channels", errFormatChange,
+			a.format.SampleRate, a.format.BitDepth, a.format.Channels,
+			block.Format.SampleRate, block.Format.BitDepth, block.Format.Channels)
+	}
+
+	for idx := range block.Len() {
+		silent := true
+
+		for ch, samples := range block.Samples {
+			sample := samples[idx]
+			magnitude := abs(int64(sample))
+
+			if magnitude > a.silence {
+				silent = false
+			}
+
+			a.channels[ch].add(sample, magnitude)
+		}
+
+		a.samples++
+
+		switch {
+		case !silent:
+			a.sounded, a.tail = true, 0
+		case a.sounded:
+			a.tail++
+		default:
+			a.head++
+		}
+	}
+
+	return nil
+}
+
+func (c *channel) add(sample int32, magnitude int64) {
+	c.peak = max(c.peak, magnitude)
+	c.sum += int64(sample)
+	c.squares += float64(sample) * float64(sample)
+	c.bits |= uint32(sample) //nolint:gosec // the bit pattern is what matters.
+
+	if sample == c.high || sample == c.low {
+		c.run++
+
+		return
+	}
+
+	c.closeRun()
+}
+
+// closeRun counts the run of full-scale samples ending before the current sample.
+func (c *channel) closeRun() {
+	if c.run >= minClipRun {
+		c.runs++
+		c.clipped += int64(c.run)
+	}
+
+	c.run = 0
+}
+
+// Result returns the statistics of what the analyzer gathered.
+func (a *Analyzer) Result() Result {
+	result := Result{
+		Format:      a.format,
+		Samples:     a.samples,
+		HeadSilence: a.head,
+		TailSilence: a.tail,
+		Channels:    make([]Channel, len(a.channels)),
+	}
+
+	if !a.sounded {
+		result.TailSilence = a.samples
+	}
+
+	fullScale := float64(int64(1) << (a.format.BitDepth - 1))
+	length := float64(max(a.samples, 1))
+
+	for ch, state := range a.channels {
+		state.closeRun()
+
+		channel := Channel{
+			Peak:           float64(state.peak) / fullScale,
+			RMS:            math.Sqrt(state.squares/length) / fullScale,
+			DCOffset:       float64(state.sum) / length / fullScale,
+			ClippedRuns:    state.runs,
+			ClippedSamples: state.clipped,
+		}
+
+		if state.bits != 0 {
+			//nolint:gosec // at most 32.
+			channel.EffectiveBitDepth = uint(a.format.BitDepth) - uint(bits.TrailingZeros32(state.bits))
+		}
+
+		result.EffectiveBitDepth = max(result.EffectiveBitDepth, channel.EffectiveBitDepth)
+		result.Channels[ch] = channel
+	}
+
+	return result
+}
+
+// Crest returns the crest factor of the channel, its peak over its RMS level, or 0 when silent.
+func (c Channel) Crest() float64 {
+	if c.RMS == 0 {
+		return 0
+	}
+
+	return c.Peak / c.RMS
+}
+
+func abs(value int64) int64 {
+	if value < 0 {
+		return -value
+	}
+
+	return value
+}
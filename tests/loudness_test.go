@@ -0,0 +1,191 @@
+package tests_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/loudness"
+	"github.com/farcloser/saprobe/tests/testutils"
+)
+
+const toneFrequency = 1000 // Hz, the tone of the EBU conformance signals
+
+//nolint:gochecknoglobals
+var (
+	stereo48 = saprobe.PCMFormat{
+		SampleRate: 48000, BitDepth: saprobe.Depth24, Channels: 2, Layout: saprobe.LayoutStereo,
+	}
+	stereo44 = saprobe.PCMFormat{
+		SampleRate: 44100, BitDepth: saprobe.Depth24, Channels: 2, Layout: saprobe.LayoutStereo,
+	}
+	surround = saprobe.PCMFormat{
+		SampleRate: 48000, BitDepth: saprobe.Depth24, Channels: 5, Layout: saprobe.DefaultLayout(5),
+	}
+)
+
+// measure returns the loudness of a block, fed to the meter in pieces of 1000 samples.
+func measure(t *testing.T, block saprobe.Block) *loudness.Meter {
+	t.Helper()
+
+	meter := loudness.New(block.Format)
+
+	for start := 0; start < block.Len(); start += 1000 {
+		piece := saprobe.Block{Format: block.Format, Samples: make([][]int32, len(block.Samples))}
+		for ch, samples := range block.Samples {
+			piece.Samples[ch] = samples[start:min(start+1000, len(samples))]
+		}
+
+		if err := meter.Add(piece); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return meter
+}
+
+func within(t *testing.T, name string, got, want, below, above float64) {
+	t.Helper()
+
+	if got < want-below || got > want+above || math.IsNaN(got) {
+		t.Errorf("%s: %.2f, want %.1f (-%.1f/+%.1f)", name, got, want, below, above)
+	}
+}
+
+// TestEBU3341 checks the minimum requirements of EBU Tech 3341 with its synthetic test signals:
+// cases 1-6 and 9 on loudness, 15-18 on true peak.
+func TestEBU3341(t *testing.T) {
+	t.Parallel()
+
+	level := testutils.Level
+
+	cases := []struct {
+		name      string
+		format    saprobe.PCMFormat
+		segments  []testutils.Segment
+		momentary float64 // NaN when not checked
+		shortTerm float64
+		integr    float64
+	}{
+		{"case 1", stereo48, []testutils.Segment{level(20, -23)}, -23, -23, -23},
+		{"case 2", stereo48, []testutils.Segment{level(20, -33)}, -33, -33, -33},
+		{"case 3", stereo48, []testutils.Segment{level(10, -36), level(60, -23), level(10, -36)}, -23, -23, -23},
+		{
+			"case 4", stereo48,
+			[]testutils.Segment{level(10, -72), level(10, -36), level(60, -23), level(10, -36), level(10, -72)},
+			-23, -23, -23,
+		},
+		{"case 5", stereo48, []testutils.Segment{level(20, -26), level(20.1, -20), level(20, -26)}, -20, -20, -23},
+		{
+			"case 6", surround,
+			[]testutils.Segment{{Seconds: 20, Levels: []float64{-28, -28, -24, -30, -30}}},
+			math.NaN(), math.NaN(), -23,
+		},
+		{"case 1 at 44.1 kHz", stereo44, []testutils.Segment{level(20, -23)}, -23, -23, -23},
+	}
+
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := measure(t, testutils.Sine(test.format, toneFrequency, 0, test.segments...)).Result()
+
+			within(t, "integrated", result.Integrated, test.integr, 0.1, 0.1)
+
+			if !math.IsNaN(test.momentary) {
+				within(t, "momentary max", result.MomentaryMax, test.momentary, 0.1, 0.1)
+				within(t, "short-term max", result.ShortTermMax, test.shortTerm, 0.1, 0.1)
+			}
+		})
+	}
+
+	t.Run("case 9", func(t *testing.T) {
+		t.Parallel()
+
+		var segments []testutils.Segment
+		for range 20 {
+			segments = append(segments, level(1.34, -20), level(1.66, -30))
+		}
+
+		result := measure(t, testutils.Sine(stereo48, toneFrequency, 0, segments...)).Result()
+		within(t, "short-term max", result.ShortTermMax, -23, 0.1, 0.1)
+	})
+
+	truePeaks := []struct {
+		name      string
+		frequency float64 // in fractions of the sample rate
+		phase     float64
+	}{
+		{"case 15", 1.0 / 4, 0},
+		{"case 16", 1.0 / 4, 45},
+		{"case 17", 1.0 / 6, 60},
+		{"case 18", 1.0 / 8, 67.5},
+	}
+
+	for _, test := range truePeaks {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			block := testutils.FadeIn(testutils.Sine(stereo48, test.frequency*48000, test.phase, level(1, -6)), 0.01)
+			result := measure(t, block).Result()
+
+			within(t, "true peak", result.TruePeak, -6, 0.4, 0.2)
+
+			if result.SamplePeak > result.TruePeak {
+				t.Errorf("sample peak %.2f above the true peak %.2f", result.SamplePeak, result.TruePeak)
+			}
+		})
+	}
+}
+
+// TestEBU3342 checks the loudness range against the EBU Tech 3342 test signals 1-4.
+func TestEBU3342(t *testing.T) {
+	t.Parallel()
+
+	level := testutils.Level
+
+	cases := []struct {
+		name     string
+		segments []testutils.Segment
+		want     float64
+	}{
+		{"case 1", []testutils.Segment{level(20, -20), level(20, -30)}, 10},
+		{"case 2", []testutils.Segment{level(20, -20), level(20, -15)}, 5},
+		{"case 3", []testutils.Segment{level(20, -40), level(20, -20)}, 20},
+		{
+			"case 4",
+			[]testutils.Segment{level(20, -50), level(20, -35), level(20, -20), level(20, -35), level(20, -50)},
+			15,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := measure(t, testutils.Sine(stereo48, toneFrequency, 0, test.segments...)).Result()
+			within(t, "loudness range", result.Range, test.want, 1, 1)
+		})
+	}
+}
+
+// TestAlbumLoudness checks that album mode gates the tracks together, and the ReplayGain 2.0 values.
+func TestAlbumLoudness(t *testing.T) {
+	t.Parallel()
+
+	loud := measure(t, testutils.Sine(stereo48, toneFrequency, 0, testutils.Level(20, -20)))
+	quiet := measure(t, testutils.Sine(stereo48, toneFrequency, 0, testutils.Level(20, -26)))
+
+	album := loudness.Album(loud, quiet)
+	within(t, "album integrated", album.Integrated, -22.04, 0.1, 0.1)
+	within(t, "album true peak", album.TruePeak, -20, 0.4, 0.2)
+
+	gain := quiet.Result().ReplayGain()
+	within(t, "track gain", gain.Gain, 8, 0.1, 0.1)
+	within(t, "album gain", album.ReplayGain().Gain, 4.04, 0.1, 0.1)
+
+	silent := measure(t, testutils.Sine(stereo48, toneFrequency, 0, testutils.Level(5, math.Inf(-1)))).Result()
+	if !math.IsInf(silent.Integrated, -1) || silent.ReplayGain().Set {
+		t.Errorf("silence measures %.2f LUFS, want -Inf and no gain", silent.Integrated)
+	}
+}
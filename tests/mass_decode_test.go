@@ -88,7 +88,7 @@ func makeDecodeTest(af audioFile, index, total int) *test.Case {
 		Expected: func(data test.Data, _ test.Helpers) *test.Expected {
 			return &test.Expected{
 				ExitCode: expect.ExitCodeSuccess,
-				Output:   comparePCMFiles(data, prefix, af.lossy),
+				Output:   comparePCMFiles(data, prefix, af.pcmFormat, af.lossy),
 			}
 		},
 	}
@@ -98,13 +98,15 @@ type audioFile struct {
 	path      string // absolute path to the audio file
 	relPath   string // path relative to the test root (used as test name)
 	pcmFormat string // ffmpeg raw output format: "s16le", "s24le", "s32le"
-	lossy     bool   // true for lossy codecs (mp3, ogg) that allow small sample differences
+	lossy     bool   // true for lossy codecs (mp1, mp2, mp3, ogg) that allow small sample differences
 }
 
 //nolint:gochecknoglobals
 var supportedExts = map[string]bool{
 	".flac": true,
 	".m4a":  true,
+	".mp1":  true,
+	".mp2":  true,
 	".mp3":  true,
 	".ogg":  true,
 }
@@ -150,9 +152,11 @@ func discoverAudioFiles(t *testing.T, root string) []audioFile {
 func probeOutputFormat(t *testing.T, path, ext string) (string, bool) {
 	t.Helper()
 
-	// Lossy codecs: saprobe always decodes to 16-bit.
+	// Lossy codecs: saprobe decodes MPEG audio to 24-bit and Vorbis to 16-bit.
 	switch ext {
-	case ".mp3", ".ogg":
+	case ".mp3", ".mp2", ".mp1":
+		return "s24le", true
+	case ".ogg":
 		return "s16le", true
 	}
 
@@ -183,10 +187,10 @@ func probeOutputFormat(t *testing.T, path, ext string) (string, bool) {
 // comparePCMFiles returns a comparator that reads the saprobe and ffmpeg output
 // files from the test's temp directory and compares them.
 // For lossless codecs, comparison is byte-for-byte.
-// For lossy codecs, small per-sample differences (±2) are tolerated.
+// For lossy codecs, small per-sample differences (±2 at 16-bit) are tolerated.
 //
 //revive:disable:flag-parameter
-func comparePCMFiles(data test.Data, prefix string, lossy bool) test.Comparator {
+func comparePCMFiles(data test.Data, prefix, pcmFormat string, lossy bool) test.Comparator {
 	return func(_ string, t tig.T) {
 		t.Helper()
 
@@ -209,7 +213,7 @@ func comparePCMFiles(data test.Data, prefix string, lossy bool) test.Comparator
 		t.Log(fmt.Sprintf("%s comparing: saprobe=%d bytes, ffmpeg=%d bytes", prefix, len(saprobeData), len(refData)))
 
 		if lossy {
-			compareLossy(t, prefix, saprobeData, refData)
+			compareLossy(t, prefix, pcmFormat, saprobeData, refData)
 		} else {
 			compareLossless(t, prefix, saprobeData, refData)
 		}
@@ -231,9 +235,9 @@ func compareLossless(t tig.T, prefix string, saprobeData, refData []byte) {
 	t.Fail()
 }
 
-// compareLossy allows small per-sample differences (±2) for lossy codecs.
+// compareLossy allows small per-sample differences (±2 at 16-bit, scaled for 24-bit) for lossy codecs.
 // Different decoders have floating-point rounding differences in synthesis filterbanks.
-func compareLossy(t tig.T, prefix string, saprobeData, refData []byte) {
+func compareLossy(t tig.T, prefix, pcmFormat string, saprobeData, refData []byte) {
 	t.Helper()
 
 	// Check length match first.
@@ -244,16 +248,22 @@ func compareLossy(t tig.T, prefix string, saprobeData, refData []byte) {
 		return
 	}
 
-	// Compare 16-bit samples with tolerance.
-	const maxDiffPerSample = 2
+	// Compare samples with a tolerance of ±2 at 16-bit.
+	bytesPerSample := 2
+	maxDiffPerSample := int32(2)
 
-	numSamples := len(saprobeData) / 2
+	if pcmFormat == "s24le" {
+		bytesPerSample = 3
+		maxDiffPerSample <<= 8
+	}
+
+	numSamples := len(saprobeData) / bytesPerSample
 	largeDiffs := 0
-	maxDiff := int16(0)
+	maxDiff := int32(0)
 
 	for i := range numSamples {
-		spSample := int16(binary.LittleEndian.Uint16(saprobeData[i*2:]))
-		refSample := int16(binary.LittleEndian.Uint16(refData[i*2:]))
+		spSample := pcmSample(saprobeData, i, bytesPerSample)
+		refSample := pcmSample(refData, i, bytesPerSample)
 
 		diff := spSample - refSample
 		if diff < 0 {
@@ -282,6 +292,15 @@ func compareLossy(t tig.T, prefix string, saprobeData, refData []byte) {
 	t.Log(fmt.Sprintf("%s MATCH (lossy: max diff=%d, large diffs=%d)", prefix, maxDiff, largeDiffs))
 }
 
+// pcmSample returns sample i of little-endian signed 16-bit or 24-bit PCM.
+func pcmSample(data []byte, i, bytesPerSample int) int32 {
+	if bytesPerSample == 3 {
+		return int32(uint32(data[i*3])<<8|uint32(data[i*3+1])<<16|uint32(data[i*3+2])<<24) >> 8
+	}
+
+	return int32(int16(binary.LittleEndian.Uint16(data[i*2:])))
+}
+
 func reportFirstDifference(t tig.T, prefix string, a, b []byte) {
 	t.Helper()
 
@@ -150,10 +150,13 @@ func probeFileProperties(t *testing.T, path string) fileProperties {
 }
 
 func pcmFormatFromDepth(bitDepth int, ext string) string {
-	// Lossy codecs always decode to 16-bit
-	ext = strings.ToLower(ext)
-	if ext == ".mp3" || ext == ".ogg" {
+	// Lossy codecs decode to fixed depths: 24-bit for MPEG audio, 16-bit for Vorbis
+	switch strings.ToLower(ext) {
+	case ".mp3", ".mp2", ".mp1":
+		return "s24le"
+	case ".ogg":
 		return "s16le"
+	default:
 	}
 
 	switch bitDepth {
@@ -215,7 +218,7 @@ func decodeWithSaprobe(t *testing.T, path string) ([]byte, saprobe.PCMFormat, er
 	switch codec { //revive:disable-line:identical-switch-branches
 	case detect.FLAC:
 		return flac.Decode(file)
-	case detect.MP3:
+	case detect.MP3, detect.MP2, detect.MP1:
 		return mp3.Decode(file)
 	case detect.Vorbis:
 		return vorbis.Decode(file)
@@ -0,0 +1,139 @@
+package tests_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/stats"
+	"github.com/farcloser/saprobe/tests/testutils"
+)
+
+func analyze(t *testing.T, block saprobe.Block, silence float64) stats.Result {
+	t.Helper()
+
+	analyzer := stats.New(block.Format, silence)
+
+	// Cut the block in uneven pieces, so that runs and silences span them.
+	for start := 0; start < block.Len(); start += 777 {
+		piece := saprobe.Block{Format: block.Format, Samples: make([][]int32, len(block.Samples))}
+		for ch, samples := range block.Samples {
+			piece.Samples[ch] = samples[start:min(start+777, len(samples))]
+		}
+
+		if err := analyzer.Add(piece); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return analyzer.Result()
+}
+
+func TestStatsSine(t *testing.T) {
+	t.Parallel()
+
+	// 1 s of silence, 2 s of tone at -6 dBFS left and -12 dBFS right, 0.5 s of silence.
+	block := testutils.Sine(stereo48, toneFrequency, 0,
+		testutils.Level(1, math.Inf(-1)),
+		testutils.Segment{Seconds: 2, Levels: []float64{-6, -12}},
+		testutils.Level(0.5, math.Inf(-1)),
+	)
+
+	result := analyze(t, block, math.Inf(-1))
+
+	if result.Samples != 168000 {
+		t.Errorf("samples: %d, want 168000", result.Samples)
+	}
+
+	// The sine crosses zero at the first and last sample of the tone.
+	if result.HeadSilence < 48000 || result.HeadSilence > 48001 {
+		t.Errorf("head silence: %d samples, want 48000", result.HeadSilence)
+	}
+
+	if result.TailSilence < 24000 || result.TailSilence > 24001 {
+		t.Errorf("tail silence: %d samples, want 24000", result.TailSilence)
+	}
+
+	for ch, level := range []float64{-6, -12} {
+		channel := result.Channels[ch]
+
+		within(t, "peak", 20*math.Log10(channel.Peak), level, 0.01, 0.01)
+		// The tone fills 2 s of 3.5.
+		within(t, "rms", 20*math.Log10(channel.RMS), level-3.01+10*math.Log10(2/3.5), 0.01, 0.01)
+		within(t, "crest", 20*math.Log10(channel.Crest()), 3.01-10*math.Log10(2/3.5), 0.01, 0.01)
+
+		if math.Abs(channel.DCOffset) > 1e-6 {
+			t.Errorf("channel %d DC offset: %g, want 0", ch, channel.DCOffset)
+		}
+
+		if channel.ClippedRuns != 0 || channel.EffectiveBitDepth != 24 {
+			t.Errorf("channel %d: %d clipped runs, %d effective bits, want 0 and 24",
+				ch, channel.ClippedRuns, channel.EffectiveBitDepth)
+		}
+	}
+}
+
+func TestStatsClippingAndBitDepth(t *testing.T) {
+	t.Parallel()
+
+	high, low := int32(1<<23-1), int32(-1<<23)
+
+	// Left: 16-bit audio padded to 24 bits, with a DC offset, never clipping.
+	// Right: a lone full-scale sample, then runs of 3 and 1000 samples, the last crossing pieces.
+	block := saprobe.Block{Format: stereo48, Samples: [][]int32{make([]int32, 5000), make([]int32, 5000)}}
+
+	for idx := range 5000 {
+		block.Samples[0][idx] = int32(idx%7+1000) << 8
+	}
+
+	block.Samples[1][10] = high
+
+	for idx := 100; idx < 103; idx++ {
+		block.Samples[1][idx] = low
+	}
+
+	for idx := 700; idx < 1700; idx++ {
+		block.Samples[1][idx] = high
+	}
+
+	result := analyze(t, block, -60)
+
+	left, right := result.Channels[0], result.Channels[1]
+
+	if left.EffectiveBitDepth != 16 || right.EffectiveBitDepth != 24 || result.EffectiveBitDepth != 24 {
+		t.Errorf("effective bit depth: %d and %d, overall %d, want 16, 24 and 24",
+			left.EffectiveBitDepth, right.EffectiveBitDepth, result.EffectiveBitDepth)
+	}
+
+	if dc := left.DCOffset * (1 << 23); math.Abs(dc-1003*256) > 256 {
+		t.Errorf("DC offset: %.0f, want about %d", dc, 1003*256)
+	}
+
+	if left.ClippedRuns != 0 || right.ClippedRuns != 2 || right.ClippedSamples != 1003 {
+		t.Errorf("clipping: %d runs left, %d runs and %d samples right, want 0, 2 and 1003",
+			left.ClippedRuns, right.ClippedRuns, right.ClippedSamples)
+	}
+
+	if right.Peak < 0.9999 {
+		t.Errorf("peak: %f, want full scale", right.Peak)
+	}
+
+	// The left channel is never under -60 dBFS.
+	if result.HeadSilence != 0 || result.TailSilence != 0 {
+		t.Errorf("silence: %d and %d samples, want none", result.HeadSilence, result.TailSilence)
+	}
+}
+
+func TestStatsSilentStream(t *testing.T) {
+	t.Parallel()
+
+	result := analyze(t, testutils.Sine(stereo48, toneFrequency, 0, testutils.Level(1, -80)), -60)
+
+	if result.HeadSilence != 48000 || result.TailSilence != 48000 {
+		t.Errorf("silence: %d and %d samples, want the whole 48000", result.HeadSilence, result.TailSilence)
+	}
+
+	if result.Channels[0].Crest() == 0 || result.Channels[0].RMS == 0 {
+		t.Errorf("a quiet tone is not digital silence")
+	}
+}
@@ -24,7 +24,9 @@ type codecConfig struct {
 	ext        string
 	sampleRate int
 	bitDepth   int  // input bit depth for encoding (0 = codec decides)
+	channels   int  // source channel count (0 = stereo)
 	lossy      bool // lossy codecs allow small sample differences between decoders
+	gapless    bool // the decoded length must match the source exactly
 	ffmpegArgs []string
 	decoder    func(path string) ([]byte, saprobe.PCMFormat, error)
 }
@@ -105,28 +107,106 @@ var alacConfigs = []codecConfig{
 	},
 }
 
-// Vorbis: lossy, decodes to 16-bit (or float, but we compare as 16-bit).
+// Vorbis: lossy, decodes to 16-bit (or float, but we compare as 16-bit). Granule positions trim the
+// last page to the source length.
 var vorbisConfigs = []codecConfig{
 	{
-		name: "vorbis_44100", ext: "ogg", sampleRate: 44100, bitDepth: 16, lossy: true,
+		name: "vorbis_44100", ext: "ogg", sampleRate: 44100, bitDepth: 16, lossy: true, gapless: true,
 		ffmpegArgs: []string{"-c:a", "libvorbis", "-q:a", "6"}, decoder: decodeVorbis,
 	},
 	{
-		name: "vorbis_48000", ext: "ogg", sampleRate: 48000, bitDepth: 16, lossy: true,
+		name: "vorbis_48000", ext: "ogg", sampleRate: 48000, bitDepth: 16, lossy: true, gapless: true,
 		ffmpegArgs: []string{"-c:a", "libvorbis", "-q:a", "6"}, decoder: decodeVorbis,
 	},
+	{
+		name: "vorbis_22050_mono", ext: "ogg", sampleRate: 22050, bitDepth: 16, channels: 1, lossy: true, gapless: true,
+		ffmpegArgs: []string{"-c:a", "libvorbis", "-q:a", "3"}, decoder: decodeVorbis,
+	},
 }
 
-// MP3: lossy, supports limited sample rates, decodes to 16-bit.
+// MP3: lossy, decodes to 24-bit. MPEG-1 covers 32-48 kHz with 1152-sample frames, MPEG-2 16-24 kHz and
+// MPEG-2.5 8-12 kHz with 576-sample frames; gapless trimming depends on the frame size.
 var mp3Configs = []codecConfig{
+	// MPEG-1
+	{
+		name: "mp3_32000", ext: "mp3", sampleRate: 32000, bitDepth: 24, lossy: true, gapless: true,
+		ffmpegArgs: []string{"-c:a", "libmp3lame", "-b:a", "320k"}, decoder: decodeMp3,
+	},
 	{
-		name: "mp3_44100", ext: "mp3", sampleRate: 44100, bitDepth: 16, lossy: true,
+		name: "mp3_44100", ext: "mp3", sampleRate: 44100, bitDepth: 24, lossy: true, gapless: true,
 		ffmpegArgs: []string{"-c:a", "libmp3lame", "-b:a", "320k"}, decoder: decodeMp3,
 	},
 	{
-		name: "mp3_48000", ext: "mp3", sampleRate: 48000, bitDepth: 16, lossy: true,
+		name: "mp3_48000", ext: "mp3", sampleRate: 48000, bitDepth: 24, lossy: true, gapless: true,
 		ffmpegArgs: []string{"-c:a", "libmp3lame", "-b:a", "320k"}, decoder: decodeMp3,
 	},
+	{
+		name: "mp3_44100_mono", ext: "mp3", sampleRate: 44100, bitDepth: 24, channels: 1, lossy: true, gapless: true,
+		ffmpegArgs: []string{"-c:a", "libmp3lame", "-b:a", "160k"}, decoder: decodeMp3,
+	},
+	// MPEG-2
+	{
+		name: "mp3_16000", ext: "mp3", sampleRate: 16000, bitDepth: 24, lossy: true, gapless: true,
+		ffmpegArgs: []string{"-c:a", "libmp3lame", "-b:a", "160k"}, decoder: decodeMp3,
+	},
+	{
+		name: "mp3_22050", ext: "mp3", sampleRate: 22050, bitDepth: 24, lossy: true, gapless: true,
+		ffmpegArgs: []string{"-c:a", "libmp3lame", "-b:a", "160k"}, decoder: decodeMp3,
+	},
+	{
+		name: "mp3_24000", ext: "mp3", sampleRate: 24000, bitDepth: 24, lossy: true, gapless: true,
+		ffmpegArgs: []string{"-c:a", "libmp3lame", "-b:a", "160k"}, decoder: decodeMp3,
+	},
+	{
+		name: "mp3_22050_mono", ext: "mp3", sampleRate: 22050, bitDepth: 24, channels: 1, lossy: true, gapless: true,
+		ffmpegArgs: []string{"-c:a", "libmp3lame", "-b:a", "64k"}, decoder: decodeMp3,
+	},
+	// MPEG-2.5
+	{
+		name: "mp3_8000", ext: "mp3", sampleRate: 8000, bitDepth: 24, lossy: true, gapless: true,
+		ffmpegArgs: []string{"-c:a", "libmp3lame", "-b:a", "64k"}, decoder: decodeMp3,
+	},
+	{
+		name: "mp3_11025", ext: "mp3", sampleRate: 11025, bitDepth: 24, lossy: true, gapless: true,
+		ffmpegArgs: []string{"-c:a", "libmp3lame", "-b:a", "64k"}, decoder: decodeMp3,
+	},
+	{
+		name: "mp3_12000", ext: "mp3", sampleRate: 12000, bitDepth: 24, lossy: true, gapless: true,
+		ffmpegArgs: []string{"-c:a", "libmp3lame", "-b:a", "64k"}, decoder: decodeMp3,
+	},
+	{
+		name: "mp3_11025_mono", ext: "mp3", sampleRate: 11025, bitDepth: 24, channels: 1, lossy: true, gapless: true,
+		ffmpegArgs: []string{"-c:a", "libmp3lame", "-b:a", "32k"}, decoder: decodeMp3,
+	},
+}
+
+// MP2: lossy, decodes to 24-bit, with nothing trimmed. The bitrates select every Layer II bit allocation
+// table: MPEG-1 tables A to D, then the MPEG-2 one. ffmpeg has no Layer I encoder.
+var mp2Configs = []codecConfig{
+	{
+		name: "mp2_32000", ext: "mp2", sampleRate: 32000, bitDepth: 24, lossy: true,
+		ffmpegArgs: []string{"-c:a", "mp2", "-b:a", "64k"}, decoder: decodeMp3,
+	},
+	{
+		name: "mp2_44100", ext: "mp2", sampleRate: 44100, bitDepth: 24, lossy: true,
+		ffmpegArgs: []string{"-c:a", "mp2", "-b:a", "384k"}, decoder: decodeMp3,
+	},
+	{
+		name: "mp2_48000", ext: "mp2", sampleRate: 48000, bitDepth: 24, lossy: true,
+		ffmpegArgs: []string{"-c:a", "mp2", "-b:a", "192k"}, decoder: decodeMp3,
+	},
+	{
+		name: "mp2_44100_mono", ext: "mp2", sampleRate: 44100, bitDepth: 24, channels: 1, lossy: true,
+		ffmpegArgs: []string{"-c:a", "mp2", "-b:a", "48k"}, decoder: decodeMp3,
+	},
+	{
+		name: "mp2_22050", ext: "mp2", sampleRate: 22050, bitDepth: 24, lossy: true,
+		ffmpegArgs: []string{"-c:a", "mp2", "-b:a", "160k"}, decoder: decodeMp3,
+	},
+	{
+		name: "mp2_16000_mono", ext: "mp2", sampleRate: 16000, bitDepth: 24, channels: 1, lossy: true,
+		ffmpegArgs: []string{"-c:a", "mp2", "-b:a", "48k"}, decoder: decodeMp3,
+	},
 }
 
 func TestFLACDecode(t *testing.T) {
@@ -173,13 +253,239 @@ func TestMP3Decode(t *testing.T) {
 	}
 }
 
+func TestMP2Decode(t *testing.T) {
+	t.Parallel()
+
+	for _, cfg := range mp2Configs {
+		t.Run(cfg.name, func(t *testing.T) {
+			t.Parallel()
+			runSyntheticTest(t, cfg)
+		})
+	}
+}
+
+// Free-format MPEG audio: CBR encodes at 320 kbit/s and above whose frame headers are rewritten with the
+// free-format bitrate index, leaving the frames as they are. ffmpeg cannot decode free format, so the
+// rewritten stream must decode exactly like the CBR one. Without an Info frame, every frame has the same length.
+var freeFormatConfigs = []struct {
+	codecConfig
+
+	kbps int
+}{
+	{
+		codecConfig: codecConfig{
+			name: "mp3_44100_free", ext: "mp3", sampleRate: 44100, bitDepth: 24, lossy: true,
+			ffmpegArgs: []string{"-c:a", "libmp3lame", "-b:a", "320k", "-write_xing", "0"}, decoder: decodeMp3,
+		},
+		kbps: 320,
+	},
+	{
+		codecConfig: codecConfig{
+			name: "mp3_48000_free", ext: "mp3", sampleRate: 48000, bitDepth: 24, lossy: true,
+			ffmpegArgs: []string{"-c:a", "libmp3lame", "-b:a", "320k", "-write_xing", "0"}, decoder: decodeMp3,
+		},
+		kbps: 320,
+	},
+	{
+		codecConfig: codecConfig{
+			name: "mp2_44100_free", ext: "mp2", sampleRate: 44100, bitDepth: 24, lossy: true,
+			ffmpegArgs: []string{"-c:a", "mp2", "-b:a", "384k"}, decoder: decodeMp3,
+		},
+		kbps: 384,
+	},
+	{
+		codecConfig: codecConfig{
+			name: "mp2_48000_free", ext: "mp2", sampleRate: 48000, bitDepth: 24, lossy: true,
+			ffmpegArgs: []string{"-c:a", "mp2", "-b:a", "384k"}, decoder: decodeMp3,
+		},
+		kbps: 384,
+	},
+}
+
+func TestFreeFormatDecode(t *testing.T) {
+	t.Parallel()
+
+	for _, cfg := range freeFormatConfigs {
+		t.Run(cfg.name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := t.TempDir()
+			srcPath := filepath.Join(tmpDir, "source.raw")
+			cbrPath := filepath.Join(tmpDir, "cbr."+cfg.ext)
+			freePath := filepath.Join(tmpDir, "free."+cfg.ext)
+
+			if err := os.WriteFile(srcPath, generateWhiteNoise(cfg.sampleRate, cfg.bitDepth, 2, 1), 0o600); err != nil {
+				t.Fatalf("write source: %v", err)
+			}
+
+			if err := ffmpegEncode(srcPath, cbrPath, cfg.codecConfig); err != nil {
+				t.Fatalf("ffmpeg encode: %v", err)
+			}
+
+			data, err := os.ReadFile(cbrPath)
+			if err != nil {
+				t.Fatalf("read encoded: %v", err)
+			}
+
+			frames := rewriteFreeFormat(data, cfg.kbps, cfg.sampleRate)
+			if frames == 0 {
+				t.Fatal("no frame rewritten")
+			}
+
+			if err := os.WriteFile(freePath, data, 0o600); err != nil {
+				t.Fatalf("write free format: %v", err)
+			}
+
+			cbrPCM, _, err := cfg.decoder(cbrPath)
+			if err != nil {
+				t.Fatalf("decode CBR: %v", err)
+			}
+
+			freePCM, format, err := cfg.decoder(freePath)
+			if err != nil {
+				t.Fatalf("decode free format (%d frames): %v", frames, err)
+			}
+
+			if format.SampleRate != cfg.sampleRate {
+				t.Errorf("sample rate: got %d, want %d", format.SampleRate, cfg.sampleRate)
+			}
+
+			if !bytes.Equal(cbrPCM, freePCM) {
+				t.Errorf("free format decode differs from CBR: %d vs %d bytes", len(freePCM), len(cbrPCM))
+			}
+		})
+	}
+}
+
+// rewriteFreeFormat clears the bitrate index of every frame header of an MPEG-1 Layer II or III CBR
+// stream at kbps, past any ID3v2 tag, and returns the number of frames rewritten. Frames are
+// 144 * bitrate / sample rate bytes long, plus padding.
+func rewriteFreeFormat(data []byte, kbps, sampleRate int) int {
+	pos := 0
+	if bytes.HasPrefix(data, []byte("ID3")) && len(data) >= 10 {
+		pos = 10 + (int(data[6])<<21 | int(data[7])<<14 | int(data[8])<<7 | int(data[9]))
+	}
+
+	frames := 0
+
+	for ; pos+4 <= len(data) && data[pos] == 0xFF && data[pos+1]&0xE0 == 0xE0; frames++ {
+		padding := int(data[pos+2]>>1) & 1
+		data[pos+2] &= 0x0F
+
+		pos += 144*kbps*1000/sampleRate + padding
+	}
+
+	return frames
+}
+
+// TestVorbisStartTrim lowers every granule position of a Vorbis stream, as a stream whose first
+// samples are encoder priming declares them: the decoder must drop that many samples from the start.
+func TestVorbisStartTrim(t *testing.T) {
+	t.Parallel()
+
+	const trimmed = 1000
+
+	cfg := vorbisConfigs[0]
+	tmpDir := t.TempDir()
+	srcPath := filepath.Join(tmpDir, "source.raw")
+	encPath := filepath.Join(tmpDir, "encoded.ogg")
+	trimPath := filepath.Join(tmpDir, "trimmed.ogg")
+
+	if err := os.WriteFile(srcPath, generateWhiteNoise(cfg.sampleRate, cfg.bitDepth, 2, 1), 0o600); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+
+	if err := ffmpegEncode(srcPath, encPath, cfg); err != nil {
+		t.Fatalf("ffmpeg encode: %v", err)
+	}
+
+	data, err := os.ReadFile(encPath)
+	if err != nil {
+		t.Fatalf("read encoded: %v", err)
+	}
+
+	if pages := shiftGranules(data, -trimmed); pages == 0 {
+		t.Fatal("no granule position rewritten")
+	}
+
+	if err := os.WriteFile(trimPath, data, 0o600); err != nil {
+		t.Fatalf("write trimmed: %v", err)
+	}
+
+	fullPCM, _, err := cfg.decoder(encPath)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	trimPCM, _, err := cfg.decoder(trimPath)
+	if err != nil {
+		t.Fatalf("decode trimmed: %v", err)
+	}
+
+	// Stereo 16-bit: 4 bytes per sample.
+	if want := fullPCM[trimmed*4:]; !bytes.Equal(trimPCM, want) {
+		t.Errorf("trimmed decode differs: %d bytes, want the last %d of %d", len(trimPCM), len(want), len(fullPCM))
+	}
+}
+
+// shiftGranules adds delta to the granule position of every audio page of an Ogg stream, updating
+// the page checksums, and returns the number of pages rewritten.
+func shiftGranules(data []byte, delta int64) int {
+	pages := 0
+
+	for pos := 0; pos+27 <= len(data) && bytes.HasPrefix(data[pos:], []byte("OggS")); {
+		segments := int(data[pos+26])
+		size := 27 + segments
+
+		for _, lacing := range data[pos+27 : pos+27+segments] {
+			size += int(lacing)
+		}
+
+		if granule := int64(binary.LittleEndian.Uint64(data[pos+6:])); granule > 0 {
+			binary.LittleEndian.PutUint64(data[pos+6:], uint64(granule+delta))
+			binary.LittleEndian.PutUint32(data[pos+22:], 0)
+			binary.LittleEndian.PutUint32(data[pos+22:], oggChecksum(data[pos:pos+size]))
+
+			pages++
+		}
+
+		pos += size
+	}
+
+	return pages
+}
+
+// oggChecksum computes the CRC32 of an Ogg page (polynomial 0x04C11DB7, no reflection).
+func oggChecksum(page []byte) uint32 {
+	var crc uint32
+
+	for _, b := range page {
+		crc ^= uint32(b) << 24
+
+		for range 8 {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04C11DB7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
+
 func runSyntheticTest(t *testing.T, cfg codecConfig) {
 	t.Helper()
 
 	tmpDir := t.TempDir()
 
-	// Generate source PCM (white noise, 1 second, stereo).
-	srcPCM := generateWhiteNoise(cfg.sampleRate, cfg.bitDepth, 2, 1)
+	channels := cfg.channels
+	if channels == 0 {
+		channels = 2
+	}
+
+	// Generate source PCM (white noise, 1 second).
+	srcPCM := generateWhiteNoise(cfg.sampleRate, cfg.bitDepth, channels, 1)
 	srcPath := filepath.Join(tmpDir, "source.raw")
 
 	if err := os.WriteFile(srcPath, srcPCM, 0o600); err != nil {
@@ -219,8 +525,13 @@ func runSyntheticTest(t *testing.T, cfg codecConfig) {
 		t.Errorf("bit depth: got %d, want %d", format.BitDepth, cfg.bitDepth)
 	}
 
-	if format.Channels != 2 {
-		t.Errorf("channels: got %d, want 2", format.Channels)
+	if int(format.Channels) != channels {
+		t.Errorf("channels: got %d, want %d", format.Channels, channels)
+	}
+
+	// Gapless decoding yields exactly the source samples.
+	if cfg.gapless && len(saprobePCM) != len(srcPCM) {
+		t.Errorf("gapless length: got %d bytes, want %d", len(saprobePCM), len(srcPCM))
 	}
 
 	// Compare PCM data.
@@ -273,27 +584,29 @@ func compareLosslessSamples(t *testing.T, ffmpegPCM, saprobePCM []byte, bitDepth
 
 // compareLossySamples allows small differences between decoders for lossy codecs.
 // Different MP3/Vorbis decoders use different floating-point implementations,
-// resulting in ±1-2 LSB differences per sample.
+// resulting in ±1-2 LSB differences per 16-bit sample, scaled up for 24-bit output.
 func compareLossySamples(t *testing.T, ffmpegPCM, saprobePCM []byte, bitDepth int) {
 	t.Helper()
 
-	// Only 16-bit lossy codecs are currently supported.
-	if bitDepth != 16 {
-		t.Errorf("lossy comparison only supports 16-bit, got %d-bit", bitDepth)
+	// Only 16-bit and 24-bit lossy output is currently supported.
+	if bitDepth != 16 && bitDepth != 24 {
+		t.Errorf("lossy comparison only supports 16-bit and 24-bit, got %d-bit", bitDepth)
 
 		return
 	}
 
-	numSamples := min(len(ffmpegPCM), len(saprobePCM)) / 2
+	bytesPerSample := bitDepth / 8
+	numSamples := min(len(ffmpegPCM), len(saprobePCM)) / bytesPerSample
 
-	const maxDiffPerSample = 2 // Allow ±2 difference per 16-bit sample
+	// Allow ±2 difference per 16-bit sample.
+	maxDiffPerSample := int32(2) << (bitDepth - 16)
 
 	largeDiffs := 0
-	maxDiff := int16(0)
+	maxDiff := int32(0)
 
 	for i := range numSamples {
-		ffSample := int16(binary.LittleEndian.Uint16(ffmpegPCM[i*2:]))
-		spSample := int16(binary.LittleEndian.Uint16(saprobePCM[i*2:]))
+		ffSample := pcmSample(ffmpegPCM, i, bytesPerSample)
+		spSample := pcmSample(saprobePCM, i, bytesPerSample)
 
 		diff := ffSample - spSample
 		if diff < 0 {
@@ -364,6 +677,11 @@ func generateWhiteNoise(sampleRate, bitDepth, channels, durationSec int) []byte
 
 // ffmpegEncode encodes raw PCM to the target format.
 func ffmpegEncode(srcPath, dstPath string, cfg codecConfig) error {
+	channels := "2"
+	if cfg.channels != 0 {
+		channels = fmt.Sprintf("%d", cfg.channels)
+	}
+
 	sampleFmt := "s16le"
 
 	switch cfg.bitDepth {
@@ -379,7 +697,7 @@ func ffmpegEncode(srcPath, dstPath string, cfg codecConfig) error {
 		"-y",
 		"-f", sampleFmt,
 		"-ar", fmt.Sprintf("%d", cfg.sampleRate),
-		"-ac", "2",
+		"-ac", channels,
 		"-i", srcPath,
 	}
 	args = append(args, cfg.ffmpegArgs...)
@@ -0,0 +1,31 @@
+package testutils
+
+import (
+	"bytes"
+	"testing"
+)
+
+// SameWithJobs checks that decode outputs the same PCM, and describes the same report, with 2, 3
+// and 8 jobs as with 0, one after the other. decode returns the PCM and a description of everything
+// else it reports, such as damage: damage carries distinct error values, so tests compare what
+// they say.
+func SameWithJobs(t *testing.T, name string, decode func(jobs int) ([]byte, string, error)) {
+	t.Helper()
+
+	want, wantReport, err := decode(0)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+
+	for _, jobs := range []int{2, 3, 8} {
+		got, report, err := decode(jobs)
+		if err != nil {
+			t.Fatalf("%s, %d jobs: %v", name, jobs, err)
+		}
+
+		if !bytes.Equal(got, want) || report != wantReport {
+			t.Errorf("%s, %d jobs: output or report %s differ from sequential decoding (%s)",
+				name, jobs, report, wantReport)
+		}
+	}
+}
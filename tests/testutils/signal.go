@@ -0,0 +1,84 @@
+package testutils
+
+import (
+	"math"
+	"math/rand/v2"
+
+	"github.com/farcloser/saprobe"
+)
+
+// Segment is a stretch of a test signal.
+type Segment struct {
+	Seconds float64
+	// Levels holds the peak level of each channel, in dBFS. A single level applies to every
+	// channel.
+	Levels []float64
+}
+
+// Level returns a segment at one level on every channel.
+func Level(seconds, dBFS float64) Segment {
+	return Segment{Seconds: seconds, Levels: []float64{dBFS}}
+}
+
+// Sine returns a sine wave at freq Hz in format, starting at phase degrees, made of segments in
+// sequence: the EBU Tech 3341 and 3342 conformance signals are such sequences. The wave runs on
+// across segments, only its level changes.
+func Sine(format saprobe.PCMFormat, freq, phase float64, segments ...Segment) saprobe.Block {
+	block := saprobe.Block{Format: format, Samples: make([][]int32, format.Channels)}
+	fullScale := float64(int64(1)<<(format.BitDepth-1)) - 1
+
+	var idx int
+
+	for _, segment := range segments {
+		end := idx + int(math.Round(segment.Seconds*float64(format.SampleRate)))
+
+		for ch := range block.Samples {
+			level := segment.Levels[min(ch, len(segment.Levels)-1)]
+			amplitude := fullScale * math.Pow(10, level/20)
+
+			for n := idx; n < end; n++ {
+				angle := 2*math.Pi*freq*float64(n)/float64(format.SampleRate) + phase*math.Pi/180
+				block.Samples[ch] = append(block.Samples[ch], int32(math.Round(amplitude*math.Sin(angle))))
+			}
+		}
+
+		idx = end
+	}
+
+	return block
+}
+
+// FadeIn ramps the start of block up over seconds, with a raised cosine. A signal that starts
+// with a step rings in band-limited processing, such as true-peak oversampling.
+func FadeIn(block saprobe.Block, seconds float64) saprobe.Block {
+	length := int(seconds * float64(block.Format.SampleRate))
+
+	for _, samples := range block.Samples {
+		for idx := range min(length, len(samples)) {
+			gain := (1 - math.Cos(math.Pi*float64(idx)/float64(length))) / 2
+			samples[idx] = int32(math.Round(float64(samples[idx]) * gain))
+		}
+	}
+
+	return block
+}
+
+// Noise returns samples samples per channel of white noise in format, peaking at dBFS, as the
+// interleaved PCM the decoders produce. Codecs find little to predict in it, yet the same arguments
+// return the same noise.
+func Noise(format saprobe.PCMFormat, samples int, dBFS float64) []byte {
+	block := saprobe.Block{Format: format, Samples: make([][]int32, format.Channels)}
+	seed := uint64(format.BitDepth)<<8 | uint64(format.Channels)
+	random := rand.New(rand.NewPCG(uint64(samples), seed)) //nolint:gosec // test signal.
+	peak := int32(math.Round((float64(int64(1)<<(format.BitDepth-1)) - 1) * math.Pow(10, dBFS/20)))
+
+	for ch := range block.Samples {
+		block.Samples[ch] = make([]int32, samples)
+
+		for idx := range samples {
+			block.Samples[ch][idx] = random.Int32N(2*peak+1) - peak
+		}
+	}
+
+	return block.AppendPCM(nil)
+}
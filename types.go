@@ -36,6 +36,9 @@ type PCMFormat struct {
 	SampleRate int
 	BitDepth   BitDepth
 	Channels   uint
+
+	// Layout is the speaker position of each channel, as the codec or container defines it.
+	Layout ChannelLayout
 }
 
 var errUnsupportedBitDepth = errors.New("unsupported bit depth")
@@ -0,0 +1,71 @@
+package vorbis
+
+import (
+	"errors"
+	"io"
+	"iter"
+
+	"github.com/farcloser/saprobe"
+)
+
+// Blocks decodes an Ogg Vorbis stream in a single pass, like Stream, and yields blocks of up to
+// saprobe.BlockSize planar 16-bit samples (see saprobe.Block). Unlike Decode, it follows the links
+// of a chained stream across changes of format: each block carries the format of its link.
+// Decoding stops at the first error, which is yielded as Decode would return it; a stream cut
+// inside a page ends without error.
+func Blocks(rs io.ReadSeeker) iter.Seq2[saprobe.Block, error] {
+	return func(yield func(saprobe.Block, error) bool) {
+		tracked := saprobe.TrackReader(rs)
+
+		err := decodeBlocks(tracked, func(block saprobe.Block) bool {
+			return yield(block, nil)
+		})
+		if err != nil {
+			yield(saprobe.Block{}, wrapError(tracked, err))
+		}
+	}
+}
+
+// decodeBlocks reads the stream, handing its samples to yield a block at a time until it returns
+// false.
+func decodeBlocks(reader io.Reader, yield func(saprobe.Block) bool) error {
+	stream, err := NewStream(reader)
+	if err != nil {
+		return err
+	}
+
+	var block saprobe.Block
+
+	for {
+		block.Format = stream.Format()
+		channels := int(block.Format.Channels) //nolint:gosec // channel count is always small positive
+
+		if cap(block.Samples) < channels {
+			block.Samples = make([][]int32, channels)
+		}
+
+		block.Samples = block.Samples[:channels]
+		for ch := range block.Samples {
+			block.Samples[ch] = block.Samples[ch][:0]
+		}
+
+		// Fill the block, unless the format changes or the stream ends first.
+		block.Samples, err = stream.appendPlanar(block.Samples, saprobe.BlockSize)
+
+		if block.Len() > 0 {
+			if !yield(block) {
+				return nil
+			}
+
+			block.Start += int64(block.Len())
+		}
+
+		switch {
+		case err == nil, errors.Is(err, ErrFormatChange):
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			return nil
+		default:
+			return err
+		}
+	}
+}
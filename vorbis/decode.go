@@ -2,36 +2,124 @@ package vorbis
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 
-	"github.com/jfreymuth/oggvorbis"
-
 	"github.com/farcloser/saprobe"
 )
 
-// Decode reads an Ogg Vorbis stream and decodes it to interleaved little-endian signed 16-bit PCM bytes.
+const (
+	codecName = "vorbis"
+
+	// readChunkSamples is the number of samples per channel requested from the decoder at a time.
+	readChunkSamples = 4096
+)
+
+// Decode reads an Ogg Vorbis stream and decodes it to interleaved little-endian signed 16-bit PCM bytes,
+// trimmed to the samples its granule positions declare (see Stream). The links of a chained stream are
+// decoded one after the other; a link that changes the sample rate or the channel count fails with
+// ErrUnsupported, as only a Stream can return it.
 func Decode(rs io.ReadSeeker) ([]byte, saprobe.PCMFormat, error) {
-	samples, format, err := oggvorbis.ReadAll(rs)
+	pcm, format, _, err := DecodeWithOptions(rs, saprobe.Options{})
+
+	return pcm, format, err
+}
+
+// DecodeWithOptions is Decode with shared decoder options. A stream cut inside a page is not an
+// error: the samples before the cut are returned, and the shortfall against the last granule
+// position found in a page header is reported as a Truncation, or fails in strict mode. In
+// resilient mode a packet or page that fails to decode is concealed up to the end of the next
+// readable page, where decoding resumes.
+func DecodeWithOptions(rs io.ReadSeeker, opts saprobe.Options) ([]byte, saprobe.PCMFormat, saprobe.Report, error) {
+	tracked := saprobe.TrackReader(rs)
+	pcm, format, report, err := decode(tracked, opts)
+
+	return pcm, format, report, wrapError(tracked, err)
+}
+
+// wrapError categorizes a decoding error as a *saprobe.DecodeError.
+func wrapError(reader *saprobe.TrackedReader, err error) error {
+	return saprobe.WrapDecodeError(codecName, reader, err)
+}
+
+func decode(rs io.ReadSeeker, opts saprobe.Options) ([]byte, saprobe.PCMFormat, saprobe.Report, error) {
+	var report saprobe.Report
+
+	buf, stream, err := decodeChain(rs, opts)
+	if err != nil {
+		return nil, saprobe.PCMFormat{}, report, err
+	}
+
+	pcmFormat := stream.Format()
+	report.Damaged = stream.damaged
+
+	err = saprobe.CheckTruncation(opts, &report, stream.declared(), sampleCount(buf, pcmFormat), pcmFormat.SampleRate)
 	if err != nil {
-		return nil, saprobe.PCMFormat{}, fmt.Errorf("decoding vorbis: %w", err)
+		return nil, saprobe.PCMFormat{}, report, err
 	}
 
-	pcmFormat := saprobe.PCMFormat{
-		SampleRate: format.SampleRate,
-		BitDepth:   saprobe.Depth16,
-		Channels:   uint(format.Channels), //nolint:gosec // channel count is always small positive
+	if opts.Conceal != saprobe.ConcealSilence {
+		saprobe.Conceal(buf, pcmFormat, report.Damaged, opts.Conceal)
 	}
 
-	buf := make([]byte, len(samples)*2)
+	return buf, pcmFormat, report, nil
+}
+
+// decodeChain decodes every link of a chained stream, which must keep one format, and returns the
+// PCM with the stream it read. A stream cut inside a page is not an error: the samples before the
+// cut are returned.
+func decodeChain(reader io.Reader, opts saprobe.Options) ([]byte, *Stream, error) {
+	stream, err := NewStream(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stream.resilient = opts.Resilient
+	format := stream.Format()
 
-	for i, s := range samples {
-		scaled := math.Round(float64(s) * math.MaxInt16)
-		scaled = max(math.MinInt16, min(math.MaxInt16, scaled))
+	buf, err := io.ReadAll(stream)
 
-		binary.LittleEndian.PutUint16(buf[i*2:], uint16(int16(scaled))) //nolint:gosec // clamped to int16 range
+	switch {
+	case errors.Is(err, ErrFormatChange):
+		return nil, nil, formatChangeError(stream.Link(), format, stream.Format(), sampleCount(buf, format))
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		// Cut inside a page: the truncation check reports what is missing.
+	case err != nil:
+		return nil, nil, err //nolint:wrapcheck // already a *saprobe.DecodeError.
 	}
 
-	return buf, pcmFormat, nil
+	return buf, stream, nil
+}
+
+// formatChangeError reports a chained stream whose link changes format, which Decode cannot return.
+func formatChangeError(link int, from, to saprobe.PCMFormat, sample int64) error {
+	decodeErr := saprobe.NewDecodeError(codecName, saprobe.ErrUnsupported,
+		fmt.Errorf("%w: link %d goes from %d Hz, %d channels to %d Hz, %d channels; decode it with a Stream",
+			ErrFormatChange, link, from.SampleRate, from.Channels, to.SampleRate, to.Channels))
+	decodeErr.Sample = sample
+
+	return decodeErr
+}
+
+// sampleCount returns the number of samples per channel of 16-bit PCM.
+func sampleCount(buf []byte, format saprobe.PCMFormat) int64 {
+	channels := int(format.Channels) //nolint:gosec // channel count is always small positive
+
+	return int64(len(buf) / saprobe.Depth16.BytesPerSample() / channels)
+}
+
+// appendPCM16 appends float samples to buf as little-endian signed 16-bit PCM (see pcm16).
+func appendPCM16(buf []byte, samples []float32) []byte {
+	for _, sample := range samples {
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(pcm16(sample))) //nolint:gosec // two's complement.
+	}
+
+	return buf
+}
+
+// pcm16 converts a float sample to 16 bits, clipping it to [-1, 1] first.
+func pcm16(sample float32) int16 {
+	return int16(math.Round(float64(max(-1, min(1, sample))) * math.MaxInt16))
 }
@@ -0,0 +1,128 @@
+package vorbis_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/vorbis"
+)
+
+// TestDeclaredSamplesStartGranule checks that a stream whose granule positions do not start at
+// zero, as in a radio capture, declares its own length rather than its final granule position.
+func TestDeclaredSamplesStartGranule(t *testing.T) {
+	t.Parallel()
+
+	data := readFixture(t)
+
+	pcm, format, report, err := vorbis.DecodeWithOptions(bytes.NewReader(data), saprobe.Options{Strict: true})
+	if err != nil || report.Truncation != nil {
+		t.Fatalf("fixture: %v, truncation %v", err, report.Truncation)
+	}
+
+	captured := vorbis.Relink(data, 7, 48000*3600)
+
+	shifted, _, report, err := vorbis.DecodeWithOptions(bytes.NewReader(captured), saprobe.Options{Strict: true})
+	if err != nil || report.Truncation != nil {
+		t.Fatalf("capture: %v, truncation %v", err, report.Truncation)
+	}
+
+	// The capture keeps the samples the fixture trims from its start, as it starts mid-stream.
+	if len(shifted) < len(pcm) {
+		t.Errorf("capture decodes %d samples, the fixture %d",
+			vorbis.SampleCount(shifted, format), vorbis.SampleCount(pcm, format))
+	}
+
+	// A stream cut short still reports what is missing.
+	cut := captured[:len(captured)*2/3]
+
+	_, _, report, err = vorbis.DecodeWithOptions(bytes.NewReader(cut), saprobe.Options{})
+	if err != nil || report.Truncation == nil {
+		t.Errorf("cut capture: %v, truncation %v", err, report.Truncation)
+	}
+}
+
+// TestReadFormat checks that the format and sample count read from the headers and the last page
+// match the decode, for the fixture and for a capture starting mid-stream.
+func TestReadFormat(t *testing.T) {
+	t.Parallel()
+
+	data := readFixture(t)
+
+	for name, data := range map[string][]byte{"fixture": data, "capture": vorbis.Relink(data, 7, 48000*3600)} {
+		pcm, format, err := vorbis.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		read, samples, err := vorbis.ReadFormat(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if want := int64(vorbis.SampleCount(pcm, format)); read != format || samples != want {
+			t.Errorf("%s: read %+v, %d samples, want %+v, %d", name, read, samples, format, want)
+		}
+	}
+}
+
+// TestResilientLostPage checks that a page lost to a checksum mismatch is concealed up to the first
+// packet of the next page, which decoding resumes on: the rest of that page is not lost with it.
+func TestResilientLostPage(t *testing.T) {
+	t.Parallel()
+
+	data := vorbis.Repage(readFixture(t), 4)
+
+	clean, format, _, err := vorbis.DecodeWithOptions(bytes.NewReader(data), saprobe.Options{Resilient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pages []int
+
+	for pos := 0; pos >= 0; {
+		pages = append(pages, pos)
+
+		if next := bytes.Index(data[pos+1:], []byte("OggS")); next >= 0 {
+			pos += next + 1
+		} else {
+			pos = -1
+		}
+	}
+
+	granule := func(page int) int64 {
+		return int64(binary.LittleEndian.Uint64(data[pages[page]+6:])) //nolint:gosec // positive.
+	}
+
+	// Damage the fifth audio page, past the two header pages.
+	const lost = 6
+
+	damaged := append([]byte(nil), data...)
+	damaged[pages[lost+1]-1] ^= 0x01
+
+	pcm, _, report, err := vorbis.DecodeWithOptions(bytes.NewReader(damaged), saprobe.Options{Resilient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pcm) != len(clean) {
+		t.Fatalf("decoded %d bytes, want %d", len(pcm), len(clean))
+	}
+
+	if len(report.Damaged) != 1 {
+		t.Fatalf("damaged ranges: %v, want 1", report.Damaged)
+	}
+
+	damage := report.Damaged[0]
+	if damage.Start != granule(lost-1) || damage.End <= granule(lost) || damage.End >= granule(lost+1) {
+		t.Errorf("damaged range %d-%d, want from %d to within the page ending at %d",
+			damage.Start, damage.End, granule(lost-1), granule(lost+1))
+	}
+
+	sampleBytes := int(format.Channels) * format.BitDepth.BytesPerSample()
+	start, end := int(damage.Start)*sampleBytes, int(damage.End)*sampleBytes
+	if !bytes.Equal(pcm[:start], clean[:start]) || !bytes.Equal(pcm[end:], clean[end:]) {
+		t.Error("samples outside the damaged range differ from the intact stream's")
+	}
+}
@@ -0,0 +1,147 @@
+package vorbis
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/jfreymuth/vorbis"
+)
+
+//nolint:gochecknoglobals // test export
+var SampleCount = sampleCount
+
+// Offsets in the identification header packet.
+const (
+	idChannelsOffset = 11
+	idRateOffset     = 12
+)
+
+// editPages returns a copy of data with edit applied to every page, their checksums updated.
+func editPages(data []byte, edit func(pg page, raw []byte)) []byte {
+	data = append([]byte(nil), data...)
+
+	for pos := 0; pos < len(data); {
+		pg, ok, complete := parsePage(data[pos:], int64(pos))
+		if !ok || !complete {
+			break
+		}
+
+		raw := data[pos : pos+pg.size]
+		edit(pg, raw)
+		binary.LittleEndian.PutUint32(raw[pageCRCOffset:], pg.checksum(raw))
+
+		pos += pg.size
+	}
+
+	return data
+}
+
+// Relink sets the serial number of every page, as chaining needs links of distinct serials, and
+// moves the granule positions of the audio pages by shift.
+func Relink(data []byte, serial uint32, shift int64) []byte {
+	return editPages(data, func(pg page, raw []byte) {
+		binary.LittleEndian.PutUint32(raw[14:18], serial)
+
+		if pg.granule > 0 {
+			binary.LittleEndian.PutUint64(raw[6:14], uint64(pg.granule+shift)) //nolint:gosec // positive.
+		}
+	})
+}
+
+// SetFormat rewrites the sample rate and the channel count of the identification header. Only the
+// header changes: the audio packets still decode, as mono ones, however the stream declares them.
+func SetFormat(data []byte, rate uint32, channels byte) []byte {
+	return editPages(data, func(pg page, raw []byte) {
+		if pg.flags&flagFirst == 0 {
+			return
+		}
+
+		body := pageBody(raw)
+		body[idChannelsOffset] = channels
+		binary.LittleEndian.PutUint32(body[idRateOffset:], rate)
+	})
+}
+
+// Repage lays the audio packets of the single-link stream data out count to a page, none
+// continued across pages. The granule position of every page is that of the samples its packets
+// decode to, but the last page keeps the stream's.
+func Repage(data []byte, count int) []byte {
+	reader := newPacketReader(bytes.NewReader(data))
+	decoder := &vorbis.Decoder{}
+
+	var (
+		out      []byte
+		batch    [][]byte
+		position int64
+		sequence uint32
+	)
+
+	serial := binary.LittleEndian.Uint32(data[14:18])
+
+	for pos := 0; ; {
+		pg, _, _ := parsePage(data[pos:], int64(pos))
+		if pg.granule != 0 {
+			break
+		}
+
+		out = append(out, data[pos:pos+pg.size]...)
+		pos += pg.size
+		sequence++
+	}
+
+	for {
+		pkt, err := reader.next()
+		if errors.Is(err, io.EOF) {
+			return out
+		}
+
+		if err != nil {
+			panic(err)
+		}
+
+		if decoder.HeadersRead() {
+			samples, _ := decoder.Decode(pkt.data)
+			position += int64(len(samples) / decoder.Channels())
+			batch = append(batch, pkt.data)
+		} else if err := decoder.ReadHeader(pkt.data); err != nil {
+			panic(err)
+		}
+
+		if len(batch) == count || (pkt.last && len(batch) > 0) {
+			granule, flags := position, byte(0)
+			if pkt.last {
+				granule, flags = pkt.granule, flagLast
+			}
+
+			out = appendPage(out, batch, flags, granule, serial, sequence)
+			batch = nil
+			sequence++
+		}
+	}
+}
+
+// appendPage appends a page holding packets to data.
+func appendPage(data []byte, packets [][]byte, flags byte, granule int64, serial, sequence uint32) []byte {
+	var segments, body []byte
+
+	for _, pkt := range packets {
+		segments = append(segments, bytes.Repeat([]byte{lacingMax}, len(pkt)/lacingMax)...)
+		segments = append(segments, byte(len(pkt)%lacingMax))
+		body = append(body, pkt...)
+	}
+
+	raw := append(append([]byte(nil), capturePattern...), pageVersion, flags)
+	raw = binary.LittleEndian.AppendUint64(raw, uint64(granule)) //nolint:gosec // positive.
+	raw = binary.LittleEndian.AppendUint32(raw, serial)
+	raw = binary.LittleEndian.AppendUint32(raw, sequence)
+	raw = append(raw, make([]byte, pageCRCSize)...)
+	raw = append(raw, byte(len(segments)))
+	raw = append(append(raw, segments...), body...)
+
+	pg, _, _ := parsePage(raw, 0)
+	binary.LittleEndian.PutUint32(raw[pageCRCOffset:], pg.checksum(raw))
+
+	return append(data, raw...)
+}
@@ -0,0 +1,99 @@
+package vorbis
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/farcloser/saprobe"
+)
+
+// ReadMetadata returns the comments of the first Vorbis stream of an Ogg stream, as tags. Only the
+// stream headers are read.
+func ReadMetadata(rs io.ReadSeeker) (saprobe.Metadata, error) {
+	var metadata saprobe.Metadata
+
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return metadata, fmt.Errorf("seeking to start: %w", err)
+	}
+
+	stream, err := NewStream(rs)
+	if err != nil {
+		return metadata, err
+	}
+
+	for _, comment := range stream.decoder.Comments {
+		if key, value, ok := strings.Cut(comment, "="); ok {
+			metadata.Add(key, value)
+		}
+	}
+
+	return metadata, nil
+}
+
+// ReadFormat returns the format of the PCM Decode outputs, from the headers of the first Vorbis
+// stream, and the samples per channel its granule positions declare: that of the last page, less
+// the one the stream starts at. It returns 0 samples when the file does not end with a page of
+// that stream, as a chained stream does not. Only the headers, the first audio page and the end
+// of the file are read.
+func ReadFormat(rs io.ReadSeeker) (saprobe.PCMFormat, int64, error) {
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return saprobe.PCMFormat{}, 0, fmt.Errorf("seeking to start: %w", err)
+	}
+
+	stream, err := NewStream(rs)
+	if err != nil {
+		return saprobe.PCMFormat{}, 0, err
+	}
+
+	format := stream.Format()
+
+	// Decoding up to the first granule position tells where the stream starts: a stream without
+	// audio declares no length.
+	if stream.fill() != nil {
+		return format, 0, nil
+	}
+
+	last, err := finalGranule(rs, stream.packets.serial)
+	if err != nil || last == 0 {
+		return format, 0, err
+	}
+
+	return format, last - stream.starts[0], nil
+}
+
+// finalGranule returns the granule position of the last page of the file, which may be cut
+// short, when it belongs to the logical stream of the given serial, 0 otherwise.
+func finalGranule(rs io.ReadSeeker, serial uint32) (int64, error) {
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, fmt.Errorf("seeking to end: %w", err)
+	}
+
+	tail := make([]byte, min(size, maxPageSize))
+
+	if _, err := rs.Seek(size-int64(len(tail)), io.SeekStart); err != nil {
+		return 0, fmt.Errorf("seeking to the last page: %w", err)
+	}
+
+	if _, err := io.ReadFull(rs, tail); err != nil {
+		return 0, fmt.Errorf("reading the last page: %w", err)
+	}
+
+	// The last page is the one that runs to the end of the file.
+	for pos := bytes.LastIndex(tail, capturePattern); pos >= 0; pos = bytes.LastIndex(tail[:pos], capturePattern) {
+		pg, ok, complete := parsePage(tail[pos:], 0)
+		if !ok || (complete && pos+pg.size != len(tail)) {
+			continue
+		}
+
+		if pg.serial != serial || pg.granule < 0 {
+			return 0, nil
+		}
+
+		return pg.granule, nil
+	}
+
+	return 0, nil
+}
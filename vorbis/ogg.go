@@ -0,0 +1,338 @@
+package vorbis
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Ogg page layout (RFC 3533).
+const (
+	pageHeaderSize = 27 // Fixed part of the page header, before the segment table.
+	pageCRCOffset  = 22
+	pageCRCSize    = 4
+	pageVersion    = 0
+	crc32PolyOgg   = 0x04C11DB7
+	lacingMax      = 255 // a lacing value below this ends a packet
+	maxPageSize    = pageHeaderSize + lacingMax + lacingMax*lacingMax
+
+	flagContinued = 0x01 // the page starts with the rest of a packet
+	flagFirst     = 0x02 // the page begins a logical stream
+	flagLast      = 0x04 // the page ends a logical stream
+)
+
+var (
+	errCapturePattern = errors.New("ogg: missing capture pattern")
+	errPageChecksum   = errors.New("ogg: page checksum mismatch")
+)
+
+//nolint:gochecknoglobals // constant capture pattern
+var capturePattern = []byte("OggS")
+
+// page is a parsed Ogg page header with the location of its body.
+type page struct {
+	granule  int64
+	flags    byte
+	serial   uint32
+	sequence uint32
+	crc      uint32
+	offset   int64 // byte offset of the capture pattern
+	size     int   // header plus body, 0 when the segment table is cut
+}
+
+// parsePage parses the page starting at data[0]. ok is false when data does not start with a
+// well-formed page header; complete is false when the page extends past the end of data.
+func parsePage(data []byte, offset int64) (pg page, ok, complete bool) {
+	if len(data) < pageHeaderSize || !bytes.HasPrefix(data, capturePattern) || data[4] != pageVersion {
+		return page{}, false, false
+	}
+
+	pg = page{
+		granule:  int64(binary.LittleEndian.Uint64(data[6:14])), //nolint:gosec // -1 is a valid granule.
+		flags:    data[5],
+		serial:   binary.LittleEndian.Uint32(data[14:18]),
+		sequence: binary.LittleEndian.Uint32(data[18:22]),
+		crc:      binary.LittleEndian.Uint32(data[pageCRCOffset:]),
+		offset:   offset,
+	}
+
+	numSegments := int(data[pageHeaderSize-1])
+	if len(data) < pageHeaderSize+numSegments {
+		return pg, true, false // size unknown: the segment table itself is cut
+	}
+
+	pg.size = pageHeaderSize + numSegments
+
+	for _, s := range data[pageHeaderSize : pageHeaderSize+numSegments] {
+		pg.size += int(s)
+	}
+
+	return pg, true, pg.size <= len(data)
+}
+
+// pageBody returns the part of the body present in data of the page starting at data[0], nil when
+// its segment table is cut.
+func pageBody(data []byte) []byte {
+	start := pageHeaderSize + int(data[pageHeaderSize-1])
+	if start > len(data) {
+		return nil
+	}
+
+	return data[start:]
+}
+
+// checksum computes the CRC32 of a complete page, with its CRC field taken as zero.
+func (p page) checksum(raw []byte) uint32 {
+	crc := oggCRC(0, raw[:pageCRCOffset])
+	crc = oggCRC(crc, make([]byte, pageCRCSize))
+
+	return oggCRC(crc, raw[pageCRCOffset+pageCRCSize:p.size])
+}
+
+// oggCRC updates the Ogg CRC32 (polynomial 0x04C11DB7, MSB-first, no reflection, no final XOR).
+func oggCRC(crc uint32, data []byte) uint32 {
+	for _, b := range data {
+		crc ^= uint32(b) << 24
+
+		for range 8 {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ crc32PolyOgg
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
+
+// packet is a complete packet of the logical stream a packetReader follows.
+type packet struct {
+	data    []byte
+	first   bool  // the identification header of a logical stream
+	last    bool  // the last packet of its logical stream
+	granule int64 // granule position of the page the packet ends, -1 unless it is the last one there
+	offset  int64 // byte offset of the page the packet ends on
+}
+
+// packetReader reads the packets of the Vorbis streams of an Ogg physical stream, page by page, in
+// a single pass. It follows one logical stream at a time: the first Vorbis stream of the file,
+// then, in a chained file, the Vorbis stream each later group of beginning-of-stream pages starts.
+// Pages of the other logical streams are skipped.
+type packetReader struct {
+	source  *bufio.Reader
+	offset  int64 // byte offset of the next page
+	serial  uint32
+	started bool     // serial holds the followed stream
+	grouped bool     // the beginning-of-stream pages of the followed stream's group are still coming
+	partial []byte   // start of a packet continued on the next page
+	queue   []packet // complete packets of the last page read
+	err     error    // failure of the underlying reader, io.EOF excluded
+
+	// granules holds the last positive granule position of each followed stream, as page headers
+	// carry it: those of pages cut short or failing their checksum count too.
+	granules []int64
+}
+
+func newPacketReader(reader io.Reader) *packetReader {
+	return &packetReader{source: bufio.NewReaderSize(reader, maxPageSize)}
+}
+
+// next returns the next packet. It returns io.EOF at the end of the physical stream, and an error
+// matching io.ErrUnexpectedEOF when the stream ends inside a page. A page that is not well-formed,
+// or fails its checksum, is an error too, after which next resumes at the following capture
+// pattern: the packets of the lost page, and the one it continues, are dropped.
+func (r *packetReader) next() (packet, error) {
+	for len(r.queue) == 0 {
+		if err := r.readPage(); err != nil {
+			return packet{}, err
+		}
+	}
+
+	pkt := r.queue[0]
+	r.queue = r.queue[1:]
+
+	return pkt, nil
+}
+
+// readPage reads one page, queueing the packets it completes when it belongs to the followed stream.
+func (r *packetReader) readPage() error {
+	header, err := r.peek(pageHeaderSize)
+	if len(header) == 0 && errors.Is(err, io.ErrUnexpectedEOF) {
+		return io.EOF
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !bytes.HasPrefix(header, capturePattern) || header[4] != pageVersion {
+		return r.lose(errCapturePattern)
+	}
+
+	pg, _, _ := parsePage(header, r.offset)
+	r.note(pg)
+
+	raw, err := r.peek(pageHeaderSize + int(header[pageHeaderSize-1]))
+	if err != nil {
+		return err
+	}
+
+	pg, _, _ = parsePage(raw, r.offset)
+
+	raw, err = r.peek(pg.size)
+	if err != nil {
+		return err
+	}
+
+	if computed := pg.checksum(raw); computed != pg.crc {
+		return r.lose(fmt.Errorf("%w: stored 0x%08X, computed 0x%08X", errPageChecksum, pg.crc, computed))
+	}
+
+	segments := raw[pageHeaderSize : pageHeaderSize+int(raw[pageHeaderSize-1])]
+	body := raw[len(segments)+pageHeaderSize:]
+
+	if r.follows(pg, body) {
+		r.queuePackets(pg, segments, body)
+	}
+
+	r.skip(pg.size)
+
+	return nil
+}
+
+// note records the granule position of a page header of the followed stream.
+func (r *packetReader) note(pg page) {
+	if r.started && pg.serial == r.serial && pg.flags&flagFirst == 0 && pg.granule > 0 {
+		r.granules[len(r.granules)-1] = pg.granule
+	}
+}
+
+// lose skips to the next capture pattern after an unreadable page, and returns err, located at the
+// page.
+func (r *packetReader) lose(err error) error {
+	offset := r.offset
+	r.partial = nil
+
+	for r.skip(1) {
+		window, _ := r.source.Peek(len(capturePattern))
+		if bytes.Equal(window, capturePattern) || len(window) < len(capturePattern) {
+			break
+		}
+	}
+
+	return &pageError{offset: offset, err: err}
+}
+
+// peek returns the next size bytes without consuming them, recording a failure of the underlying
+// reader. It returns what is left and io.ErrUnexpectedEOF when the stream ends before.
+func (r *packetReader) peek(size int) ([]byte, error) {
+	buf, err := r.source.Peek(size)
+	if err != nil && !errors.Is(err, io.EOF) {
+		r.err = err
+	}
+
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return buf, err
+}
+
+// skip consumes size bytes, and reports whether the stream goes on.
+func (r *packetReader) skip(size int) bool {
+	skipped, _ := r.source.Discard(size)
+	r.offset += int64(skipped)
+
+	return skipped == size
+}
+
+// pageError is an unreadable page.
+type pageError struct {
+	offset int64
+	err    error
+}
+
+func (e *pageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *pageError) Unwrap() error {
+	return e.err
+}
+
+// follows reports whether the page belongs to the followed stream, switching to the next Vorbis
+// stream of a chain on its first page.
+func (r *packetReader) follows(pg page, body []byte) bool {
+	if pg.flags&flagFirst == 0 {
+		if pg.serial == r.serial {
+			r.grouped = false
+		}
+
+		return r.started && pg.serial == r.serial
+	}
+
+	// Beginning-of-stream pages of other streams multiplexed with the followed one come before
+	// any of its data pages; later ones start the next link of a chain.
+	if (r.started && r.grouped) || !isIdentification(body) {
+		return false
+	}
+
+	r.serial, r.started, r.grouped = pg.serial, true, true
+	r.partial = nil
+	r.granules = append(r.granules, 0)
+
+	return true
+}
+
+// queuePackets splits the body of a page of the followed stream into packets. The end of a packet
+// whose start was lost is dropped.
+func (r *packetReader) queuePackets(pg page, segments, body []byte) {
+	orphan := pg.flags&flagContinued != 0 && r.partial == nil
+	if pg.flags&flagContinued == 0 {
+		r.partial = nil
+	}
+
+	start := 0
+	last := -1
+
+	for _, lacing := range segments {
+		end := start + int(lacing)
+
+		if orphan {
+			start, orphan = end, lacing == lacingMax
+
+			continue
+		}
+
+		// Copied: body is only valid until the page is skipped.
+		r.partial = append(r.partial, body[start:end]...)
+		start = end
+
+		if lacing == lacingMax {
+			continue
+		}
+
+		r.queue = append(r.queue, packet{
+			data:    r.partial,
+			first:   pg.flags&flagFirst != 0 && last < 0,
+			granule: -1,
+			offset:  pg.offset,
+		})
+		r.partial = nil
+		last = len(r.queue) - 1
+	}
+
+	if last >= 0 {
+		r.queue[last].granule = pg.granule
+		r.queue[last].last = pg.flags&flagLast != 0
+	}
+}
+
+// isIdentification reports whether a packet is a Vorbis identification header.
+func isIdentification(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("\x01vorbis"))
+}
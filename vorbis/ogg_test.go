@@ -0,0 +1,18 @@
+package vorbis_test
+
+import (
+	"os"
+	"testing"
+)
+
+// readFixture returns testdata/test.ogg: 2 s of 44.1 kHz mono, from jfreymuth/oggvorbis.
+func readFixture(t *testing.T) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile("testdata/test.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
@@ -0,0 +1,429 @@
+package vorbis
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jfreymuth/vorbis"
+
+	"github.com/farcloser/saprobe"
+)
+
+// vorbisHeaders is the number of header packets starting every logical stream: identification,
+// comment and setup.
+const vorbisHeaders = 3
+
+// ErrFormatChange is returned by Stream.Read, with no data, where a chained stream continues with a
+// logical stream of another sample rate or channel count. Format then describes the PCM that
+// follows, and reading goes on.
+var ErrFormatChange = errors.New("vorbis: format change between chained streams")
+
+var errNoStream = errors.New("vorbis: no vorbis stream found")
+
+// channelLayouts holds the speaker assignments the Vorbis specification defines for 1-8 channels:
+// the center sits between the fronts, and the LFE comes last.
+//
+//nolint:gochecknoglobals // constant table
+var channelLayouts = [...]saprobe.ChannelLayout{
+	1: saprobe.LayoutMono,
+	2: saprobe.LayoutStereo,
+	3: {saprobe.SpeakerFrontLeft, saprobe.SpeakerFrontCenter, saprobe.SpeakerFrontRight},
+	4: saprobe.DefaultLayout(4),
+	5: {
+		saprobe.SpeakerFrontLeft, saprobe.SpeakerFrontCenter, saprobe.SpeakerFrontRight,
+		saprobe.SpeakerBackLeft, saprobe.SpeakerBackRight,
+	},
+	6: {
+		saprobe.SpeakerFrontLeft, saprobe.SpeakerFrontCenter, saprobe.SpeakerFrontRight,
+		saprobe.SpeakerBackLeft, saprobe.SpeakerBackRight, saprobe.SpeakerLowFrequency,
+	},
+	7: {
+		saprobe.SpeakerFrontLeft, saprobe.SpeakerFrontCenter, saprobe.SpeakerFrontRight,
+		saprobe.SpeakerSideLeft, saprobe.SpeakerSideRight, saprobe.SpeakerBackCenter, saprobe.SpeakerLowFrequency,
+	},
+	8: {
+		saprobe.SpeakerFrontLeft, saprobe.SpeakerFrontCenter, saprobe.SpeakerFrontRight,
+		saprobe.SpeakerSideLeft, saprobe.SpeakerSideRight, saprobe.SpeakerBackLeft, saprobe.SpeakerBackRight,
+		saprobe.SpeakerLowFrequency,
+	},
+}
+
+// layoutOf returns the layout of a channel count, or the unknown layout when there is none.
+func layoutOf(channels int) saprobe.ChannelLayout {
+	if channels < 0 || channels >= len(channelLayouts) {
+		return saprobe.ChannelLayout{}
+	}
+
+	return channelLayouts[channels]
+}
+
+// Stream decodes an Ogg Vorbis stream incrementally, to interleaved little-endian signed 16-bit PCM,
+// reading its source in a single pass. Chained streams (consecutive logical streams, as radio
+// captures and concatenated files have them) decode as one: links of the same format follow each
+// other seamlessly, and a change of format is reported by ErrFormatChange.
+//
+// Granule positions make the output sample-exact: the first audio page of a link trims the samples
+// its packets decode beyond its granule position from the start, and the last page trims them from
+// the end.
+type Stream struct {
+	packets   *packetReader
+	decoder   *vorbis.Decoder
+	next      *vorbis.Decoder // the next link's decoder, until Read reports its format change
+	format    saprobe.PCMFormat
+	link      int
+	samples   []float32       // decoded samples of the last packet, interleaved
+	held      []float32       // samples of the link held until its first granule position tells the start trimming
+	resumed   []float32       // samples decoded after damage, until a granule position places them
+	pending   []float32       // samples not converted to PCM yet
+	out       []byte          // PCM conversion buffer
+	pcm       []byte          // PCM Read has not returned yet
+	primed    bool            // the link's first granule position was seen
+	position  int64           // granule position of the last packet decoded in the link
+	starts    []int64         // granule position at which each link starts, 0 unless it starts mid-stream
+	decoded   int64           // samples per channel returned, or pending, in all links
+	err       error           // the error ending the stream, reported once pending is empty
+	resilient bool            // conceal undecodable packets and pages instead of failing
+	damage    *saprobe.Damage // damage in progress, until decoding resumes
+	damaged   []saprobe.Damage
+}
+
+// NewStream reads the headers of the first Vorbis stream of reader.
+func NewStream(reader io.Reader) (*Stream, error) {
+	stream := &Stream{packets: newPacketReader(reader)}
+
+	pkt, err := stream.packets.next()
+	if errors.Is(err, io.EOF) {
+		err = errNoStream
+	}
+
+	if err != nil {
+		return nil, stream.fail(err, stream.packets.offset)
+	}
+
+	decoder, err := stream.readHeaders(pkt)
+	if err != nil {
+		return nil, err
+	}
+
+	stream.start(decoder)
+
+	return stream, nil
+}
+
+// Format returns the format of the PCM Read returns.
+func (s *Stream) Format() saprobe.PCMFormat {
+	return s.format
+}
+
+// Link returns the index of the logical stream Read decodes, counting from 0 along the chain.
+func (s *Stream) Link() int {
+	return s.link
+}
+
+// Read decodes PCM into p. It returns io.EOF at the end of the stream, ErrFormatChange at a change
+// of format, and a *saprobe.DecodeError matching io.ErrUnexpectedEOF when the stream is cut inside
+// a page.
+func (s *Stream) Read(p []byte) (int, error) {
+	if len(s.pcm) == 0 {
+		if err := s.fill(); err != nil {
+			return 0, err
+		}
+
+		s.out = appendPCM16(s.out[:0], s.pending)
+		s.pcm, s.pending = s.out, nil
+	}
+
+	n := copy(p, s.pcm)
+	s.pcm = s.pcm[n:]
+
+	return n, nil
+}
+
+// appendPlanar appends samples to planes, one per channel, as 16-bit integers, until they hold limit
+// samples, and returns them. It stops short with the error Read would return at a change of format
+// or at the end of the stream. Blocks reads with it instead of Read, which it does not follow.
+func (s *Stream) appendPlanar(planes [][]int32, limit int) ([][]int32, error) {
+	channels := len(planes)
+
+	for len(planes[0]) < limit {
+		if err := s.fill(); err != nil {
+			return planes, err
+		}
+
+		count := min(limit-len(planes[0]), len(s.pending)/channels)
+
+		for idx := range count {
+			for ch, plane := range planes {
+				planes[ch] = append(plane, int32(pcm16(s.pending[idx*channels+ch])))
+			}
+		}
+
+		s.pending = s.pending[count*channels:]
+	}
+
+	return planes, nil
+}
+
+// fill decodes packets until samples are pending. It returns ErrFormatChange instead when the next
+// link changes format, and the error that ended the stream once it has no samples left.
+func (s *Stream) fill() error {
+	for len(s.pending) == 0 {
+		if s.next != nil {
+			s.link++
+			s.start(s.next)
+
+			return ErrFormatChange
+		}
+
+		if s.err != nil {
+			return s.err
+		}
+
+		s.err = s.decodePacket()
+	}
+
+	return nil
+}
+
+// start switches to the decoder of the next link.
+func (s *Stream) start(decoder *vorbis.Decoder) {
+	s.decoder, s.next = decoder, nil
+	s.format = formatOf(decoder)
+	s.samples = make([]float32, decoder.BufferSize())
+	s.resumed = nil
+	s.primed = false
+	s.position = 0
+	s.starts = append(s.starts, 0)
+}
+
+// decodePacket decodes the next packet, making its samples pending once the granule positions
+// that trim them are known.
+func (s *Stream) decodePacket() error {
+	pkt, err := s.packets.next()
+	if err != nil {
+		s.release(s.held)
+
+		return s.failOrConceal(err, s.packets.offset)
+	}
+
+	if pkt.first {
+		s.release(s.held)
+		s.damage = nil
+
+		decoder, err := s.readHeaders(pkt)
+		if err != nil {
+			return err
+		}
+
+		if formatOf(decoder) == s.format {
+			s.link++
+			s.start(decoder)
+		} else {
+			s.next = decoder
+		}
+
+		return nil
+	}
+
+	if s.damage != nil {
+		s.resume(pkt)
+
+		return nil
+	}
+
+	out, err := s.decoder.DecodeInto(pkt.data, s.samples)
+	if err != nil {
+		s.release(s.held)
+
+		return s.failOrConceal(fmt.Errorf("decoding vorbis: %w", err), pkt.offset)
+	}
+
+	channels := s.decoder.Channels()
+	s.position += int64(len(out) / channels)
+
+	samples := out
+
+	if !s.primed {
+		s.held = append(s.held, out...)
+
+		if pkt.granule < 0 {
+			return nil
+		}
+
+		samples, s.held, s.primed = s.held, nil, true
+		s.starts[len(s.starts)-1] = max(pkt.granule-s.position, 0)
+
+		// A first granule position lower than the samples decoded so far trims the start, unless
+		// it ends the stream too. Zero is a common encoder bug rather than a trimming.
+		if !pkt.last && pkt.granule > 0 {
+			if excess := s.position - pkt.granule; excess > 0 {
+				samples = samples[min(int(excess)*channels, len(samples)):]
+			}
+
+			s.position = pkt.granule
+		}
+	}
+
+	if pkt.last && pkt.granule >= 0 && s.position > pkt.granule {
+		samples = samples[:len(samples)-min(int(s.position-pkt.granule)*channels, len(samples))]
+		s.position = pkt.granule
+	}
+
+	s.release(samples)
+
+	return nil
+}
+
+// declared returns the number of samples the granule positions of the links read so far declare:
+// for every link, its last granule position less the one it starts at.
+func (s *Stream) declared() int64 {
+	var declared int64
+
+	for idx, end := range s.packets.granules {
+		declared += end
+		if idx < len(s.starts) {
+			declared -= s.starts[idx]
+		}
+	}
+
+	return declared
+}
+
+// release makes samples pending.
+func (s *Stream) release(samples []float32) {
+	if len(samples) == 0 {
+		return
+	}
+
+	s.pending = samples
+	s.decoded += int64(len(samples) / s.decoder.Channels())
+	s.held, s.primed = nil, true
+}
+
+// failOrConceal handles a packet that fails to decode, or a page that cannot be read. In resilient
+// mode decoding resumes after the damage; otherwise err, located at the page at offset, ends the
+// stream.
+func (s *Stream) failOrConceal(err error, offset int64) error {
+	err = s.fail(err, offset)
+
+	var decodeErr *saprobe.DecodeError
+
+	if !s.resilient || !errors.As(err, &decodeErr) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		!errors.Is(decodeErr.Kind, saprobe.ErrCorrupt) {
+		return err
+	}
+
+	if s.damage == nil {
+		s.damage = &saprobe.Damage{Start: s.decoded, End: s.decoded, Err: err}
+	}
+
+	s.decoder.Clear()
+	s.resumed = nil
+
+	return nil
+}
+
+// resume decodes the packets following damage, the first one priming the decoder, and holds their
+// samples until the granule position of a page places them: the packets that follow the first one
+// decode to the samples between its position and the granule position. The gap before it is filled
+// with silence.
+func (s *Stream) resume(pkt packet) {
+	out, err := s.decoder.DecodeInto(pkt.data, s.samples)
+	if err != nil {
+		s.decoder.Clear()
+		s.resumed = nil
+
+		return
+	}
+
+	s.resumed = append(s.resumed, out...)
+
+	if pkt.granule < 0 {
+		return
+	}
+
+	channels := s.decoder.Channels()
+	samples := s.resumed
+	start := pkt.granule - int64(len(samples)/channels)
+
+	// Samples decoded before the position reached already are dropped, from the end on the last
+	// page, whose granule position trims the stream.
+	if excess := min(max(s.position-start, 0)*int64(channels), int64(len(samples))); pkt.last {
+		samples = samples[:int64(len(samples))-excess]
+	} else {
+		samples = samples[excess:]
+	}
+
+	gap := max(start-s.position, 0)
+	s.position = pkt.granule
+	s.damage.End += gap
+
+	if last := len(s.damaged) - 1; last >= 0 && s.damaged[last].End == s.damage.Start {
+		s.damaged[last].End = s.damage.End
+	} else {
+		s.damaged = append(s.damaged, *s.damage)
+	}
+
+	s.damage, s.resumed = nil, nil
+	s.release(append(make([]float32, int(gap)*channels, int(gap)*channels+len(samples)), samples...))
+}
+
+// readHeaders reads the header packets of a logical stream, the first one given.
+func (s *Stream) readHeaders(pkt packet) (*vorbis.Decoder, error) {
+	decoder := &vorbis.Decoder{}
+
+	for idx := range vorbisHeaders {
+		if idx > 0 {
+			var err error
+
+			pkt, err = s.packets.next()
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+
+			if err != nil {
+				return nil, s.fail(err, s.packets.offset)
+			}
+		}
+
+		if err := decoder.ReadHeader(pkt.data); err != nil {
+			return nil, s.fail(fmt.Errorf("reading vorbis headers: %w", err), pkt.offset)
+		}
+	}
+
+	return decoder, nil
+}
+
+// fail locates an error at the page at offset, or at the unreadable page it reports, and at the
+// current sample, as a *saprobe.DecodeError. io.EOF, the clean end of the stream, is returned as is.
+func (s *Stream) fail(err error, offset int64) error {
+	if errors.Is(err, io.EOF) {
+		return io.EOF
+	}
+
+	var pageErr *pageError
+	if errors.As(err, &pageErr) {
+		offset = pageErr.offset
+	}
+
+	kind := saprobe.ErrCorrupt
+	if s.packets.err != nil {
+		kind = saprobe.ErrIO
+	}
+
+	decodeErr := saprobe.NewDecodeError(codecName, kind, err)
+	decodeErr.Offset = offset
+	decodeErr.Sample = s.decoded
+
+	return decodeErr
+}
+
+func formatOf(decoder *vorbis.Decoder) saprobe.PCMFormat {
+	return saprobe.PCMFormat{
+		SampleRate: decoder.SampleRate(),
+		BitDepth:   saprobe.Depth16,
+		Channels:   uint(decoder.Channels()), //nolint:gosec // channel count is always small positive
+		Layout:     layoutOf(decoder.Channels()),
+	}
+}
@@ -0,0 +1,121 @@
+package vorbis_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/vorbis"
+)
+
+// readLinks reads stream to its end and returns the samples per channel of each link, with the
+// format of each.
+func readLinks(t *testing.T, stream *vorbis.Stream) ([]int64, []saprobe.PCMFormat) {
+	t.Helper()
+
+	counts := []int64{0}
+	formats := []saprobe.PCMFormat{stream.Format()}
+	buf := make([]byte, 4096)
+
+	for {
+		n, err := stream.Read(buf)
+
+		// Links of the same format follow each other without ErrFormatChange.
+		for len(counts) <= stream.Link() {
+			counts = append(counts, 0)
+			formats = append(formats, stream.Format())
+		}
+
+		counts[stream.Link()] += vorbis.SampleCount(buf[:n], stream.Format())
+
+		switch {
+		case err == nil, errors.Is(err, vorbis.ErrFormatChange):
+		case errors.Is(err, io.EOF):
+			return counts, formats
+		default:
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestChainedStream(t *testing.T) {
+	t.Parallel()
+
+	data := readFixture(t)
+
+	single, format, err := vorbis.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	length := vorbis.SampleCount(single, format)
+
+	t.Run("same format", func(t *testing.T) {
+		t.Parallel()
+
+		chain := bytes.Join([][]byte{data, vorbis.Relink(data, 2, 0), vorbis.Relink(data, 3, 0)}, nil)
+
+		pcm, _, report, err := vorbis.DecodeWithOptions(bytes.NewReader(chain), saprobe.Options{Strict: true})
+		if err != nil || report.Truncation != nil {
+			t.Fatalf("%v, truncation %v", err, report.Truncation)
+		}
+
+		if !bytes.Equal(pcm, bytes.Repeat(single, 3)) {
+			t.Errorf("decoded %d samples, want the fixture 3 times: %d", vorbis.SampleCount(pcm, format), 3*length)
+		}
+
+		stream, err := vorbis.NewStream(bytes.NewReader(chain))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		counts, _ := readLinks(t, stream)
+		if len(counts) != 3 || counts[0] != length || counts[1] != length || counts[2] != length {
+			t.Errorf("link lengths %v, want 3 links of %d", counts, length)
+		}
+	})
+
+	changes := []struct {
+		name     string
+		rate     uint32
+		channels byte
+	}{
+		{"rate change", 22050, 1},
+		{"channel change", 44100, 2},
+	}
+
+	for _, change := range changes {
+		t.Run(change.name, func(t *testing.T) {
+			t.Parallel()
+
+			next := vorbis.SetFormat(vorbis.Relink(data, 2, 0), change.rate, change.channels)
+			chain := append(append([]byte(nil), data...), next...)
+
+			_, _, err := vorbis.Decode(bytes.NewReader(chain))
+			if !errors.Is(err, vorbis.ErrFormatChange) || !errors.Is(err, saprobe.ErrUnsupported) {
+				t.Errorf("Decode: %v, want ErrFormatChange", err)
+			}
+
+			var decodeErr *saprobe.DecodeError
+			if errors.As(err, &decodeErr) && decodeErr.Sample != length {
+				t.Errorf("format change at sample %d, want %d", decodeErr.Sample, length)
+			}
+
+			stream, err := vorbis.NewStream(bytes.NewReader(chain))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			counts, formats := readLinks(t, stream)
+			if len(counts) != 2 || counts[0] != length {
+				t.Fatalf("link lengths %v, want 2 links, the first of %d", counts, length)
+			}
+
+			if formats[1].SampleRate != int(change.rate) || formats[1].Channels != uint(change.channels) {
+				t.Errorf("second link format %+v, want %d Hz %d channels", formats[1], change.rate, change.channels)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package vorbis
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/farcloser/saprobe"
+)
+
+// Verify walks every Ogg page of the stream, checking page CRC32 checksums and page sequence
+// numbers, then decodes the stream to surface decoder errors and truncation. Every link of a chained
+// stream is decoded, whatever its format. Integrity failures are returned as findings; the error is
+// reserved for streams that cannot be read at all.
+func Verify(rs io.ReadSeeker) (saprobe.Verification, error) {
+	tracked := saprobe.TrackReader(rs)
+	verification, err := verify(tracked)
+
+	return verification, wrapError(tracked, err)
+}
+
+func verify(rs io.ReadSeeker) (saprobe.Verification, error) {
+	var verification saprobe.Verification
+
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return verification, fmt.Errorf("seeking to start: %w", err)
+	}
+
+	data, err := io.ReadAll(rs)
+	if err != nil {
+		return verification, fmt.Errorf("reading ogg stream: %w", err)
+	}
+
+	walkPages(&verification, data)
+
+	verification.Examined(saprobe.CheckBitstream)
+	verification.Examined(saprobe.CheckSampleCount)
+
+	decoded, stream, err := decodeLinks(data)
+
+	var (
+		decodeErr *saprobe.DecodeError
+		report    saprobe.Report
+	)
+
+	switch {
+	case errors.As(err, &decodeErr) && errors.Is(decodeErr.Kind, saprobe.ErrCorrupt):
+		verification.Fail(decodeErr.Finding(saprobe.CheckBitstream))
+	case err != nil:
+		return verification, err
+	default:
+		_ = saprobe.CheckTruncation(saprobe.Options{}, &report, stream.declared(), decoded, stream.Format().SampleRate)
+	}
+
+	if report.Truncation != nil {
+		verification.Fail(report.Truncation.Finding())
+	}
+
+	return verification, nil
+}
+
+// decodeLinks decodes every link of a chained stream and returns the number of samples per channel
+// decoded, with the stream it read. A stream cut inside a page is not an error.
+func decodeLinks(data []byte) (int64, *Stream, error) {
+	stream, err := NewStream(bytes.NewReader(data))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var decoded int64
+
+	channels := int(stream.Format().Channels) //nolint:gosec // channel count is always small positive
+	buf := make([]byte, readChunkSamples*saprobe.Depth16.BytesPerSample()*channels)
+
+	for {
+		n, err := stream.Read(buf)
+		decoded += sampleCount(buf[:n], stream.Format())
+
+		switch {
+		case err == nil, errors.Is(err, ErrFormatChange):
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			return decoded, stream, nil
+		default:
+			return decoded, nil, err
+		}
+	}
+}
+
+// walkPages checks the CRC and sequence number of every page in data.
+func walkPages(verification *saprobe.Verification, data []byte) {
+	type logicalStream struct {
+		sequence uint32
+		granule  int64
+	}
+
+	streams := map[uint32]*logicalStream{}
+	pos := 0
+
+	for pageIdx := 0; pos < len(data); pageIdx++ {
+		pg, ok, complete := parsePage(data[pos:], int64(pos))
+
+		switch {
+		case !ok:
+			next := bytes.Index(data[pos+1:], capturePattern)
+			if next < 0 {
+				fail(verification, saprobe.CheckBitstream, pageIdx, int64(pos), -1,
+					fmt.Sprintf("%d bytes of unrecognized data after the last page", len(data)-pos))
+
+				return
+			}
+
+			fail(verification, saprobe.CheckBitstream, pageIdx, int64(pos), -1,
+				fmt.Sprintf("lost sync, skipped %d bytes", next+1))
+
+			pos += next + 1
+
+			continue
+		case !complete:
+			fail(verification, saprobe.CheckBitstream, pageIdx, int64(pos), -1,
+				fmt.Sprintf("page truncated: %d bytes present", len(data)-pos))
+
+			return
+		}
+
+		// Audio on this page starts where the previous page of the same stream ended.
+		sample := int64(-1)
+		stream, seen := streams[pg.serial]
+
+		if seen {
+			sample = stream.granule
+
+			if pg.sequence != stream.sequence+1 {
+				fail(verification, saprobe.CheckBitstream, pageIdx, pg.offset, sample,
+					fmt.Sprintf("page sequence gap in stream %08x: expected %d, got %d",
+						pg.serial, stream.sequence+1, pg.sequence))
+			}
+		} else {
+			stream = &logicalStream{}
+			streams[pg.serial] = stream
+		}
+
+		verification.Examined(saprobe.CheckPageCRC)
+
+		stream.sequence = pg.sequence
+
+		if computed := pg.checksum(data[pos:]); computed != pg.crc {
+			fail(verification, saprobe.CheckPageCRC, pageIdx, pg.offset, sample,
+				fmt.Sprintf("CRC32 mismatch: stored 0x%08X, computed 0x%08X", pg.crc, computed))
+
+			// The damage may be in the segment table, so the page size cannot be trusted: resume at
+			// the next capture pattern, as decoding does.
+			next := bytes.Index(data[pos+1:], capturePattern)
+			if next < 0 {
+				return
+			}
+
+			pos += next + 1
+
+			continue
+		}
+
+		if pg.granule >= 0 {
+			stream.granule = pg.granule
+		}
+
+		pos += pg.size
+	}
+}
+
+func fail(verification *saprobe.Verification, check saprobe.Check, page int, offset, sample int64, detail string) {
+	verification.Fail(saprobe.Finding{
+		Check:  check,
+		Frame:  page,
+		Offset: offset,
+		Sample: sample,
+		Detail: detail,
+	})
+}
@@ -0,0 +1,50 @@
+package vorbis_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/vorbis"
+)
+
+// TestVerifySegmentTable checks that a page whose segment table is damaged fails its CRC without
+// throwing the page walk off: the pages after it are still checked, and no trailing data is
+// reported.
+func TestVerifySegmentTable(t *testing.T) {
+	t.Parallel()
+
+	data := readFixture(t)
+	chain := bytes.Join([][]byte{data, vorbis.Relink(data, 2, 0)}, nil)
+
+	// Lengthen the first lacing value of the last page of the first link, then damage the last
+	// page of the second link.
+	last := bytes.LastIndex(data, []byte("OggS"))
+	chain[last+27] += 100
+	chain[len(chain)-1] ^= 0x01
+
+	verification, err := vorbis.Verify(bytes.NewReader(chain))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var offsets []int64
+
+	for _, finding := range verification.Findings {
+		switch finding.Check {
+		case saprobe.CheckPageCRC:
+			offsets = append(offsets, finding.Offset)
+		case saprobe.CheckBitstream:
+			// Decoding loses the audio of the damaged page, and nothing else.
+			if finding.Offset != int64(last) {
+				t.Errorf("bitstream finding: %v", finding)
+			}
+		default:
+		}
+	}
+
+	if want := []int64{int64(last), int64(len(data) + last)}; !slices.Equal(offsets, want) {
+		t.Errorf("CRC failures at %v, want %v", offsets, want)
+	}
+}
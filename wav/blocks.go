@@ -0,0 +1,84 @@
+package wav
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/farcloser/saprobe"
+)
+
+// Blocks reads a RIFF WAVE stream and yields its data chunk in blocks of up to saprobe.BlockSize
+// planar samples (see saprobe.Block), reading the chunk a block at a time. A data chunk that ends
+// before its declared size ends without error; other failures are yielded as Decode would return
+// them.
+func Blocks(rs io.ReadSeeker) iter.Seq2[saprobe.Block, error] {
+	return func(yield func(saprobe.Block, error) bool) {
+		tracked := saprobe.TrackReader(rs)
+
+		err := decodeBlocks(tracked, func(block saprobe.Block) bool {
+			return yield(block, nil)
+		})
+		if err != nil {
+			yield(saprobe.Block{}, saprobe.WrapDecodeError(codecName, tracked, err, errUnsupportedCodec, errBitDepth))
+		}
+	}
+}
+
+// decodeBlocks reads the data chunk, handing it to yield a block at a time until it returns false.
+func decodeBlocks(rs io.ReadSeeker, yield func(saprobe.Block) bool) error {
+	chunks, err := readChunks(rs)
+	if err != nil {
+		return err
+	}
+
+	format, stored, err := readFormat(rs, chunks)
+	if err != nil {
+		return err
+	}
+
+	data, ok := findChunk(chunks, "data")
+	if !ok {
+		return errNoDataChunk
+	}
+
+	if _, err := rs.Seek(data.offset, io.SeekStart); err != nil {
+		return fmt.Errorf("seeking to data chunk: %w", err)
+	}
+
+	var reader io.Reader = rs
+
+	// Streaming writers that cannot seek back leave the size at its maximum: read to the end.
+	if data.size != unknownDataSize {
+		reader = io.LimitReader(rs, int64(data.size))
+	}
+
+	frameSize := stored.frameSize(format.Channels)
+	pcm := make([]byte, saprobe.BlockSize*frameSize)
+	block := saprobe.Block{Format: format}
+
+	for {
+		readN, err := io.ReadFull(reader, pcm)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("reading data chunk: %w", err)
+		}
+
+		// Keep whole sample frames only.
+		readN -= readN % frameSize
+
+		if readN > 0 {
+			block.SetPCM(stored.widen(pcm[:readN]))
+
+			if !yield(block) {
+				return nil
+			}
+
+			block.Start += int64(block.Len())
+		}
+
+		if err != nil {
+			return nil
+		}
+	}
+}
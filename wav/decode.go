@@ -0,0 +1,286 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/farcloser/saprobe"
+)
+
+const codecName = "wav"
+
+var (
+	errNotWAVE          = errors.New("wav: not a RIFF WAVE file")
+	errNoFormatChunk    = errors.New("wav: no fmt chunk")
+	errNoDataChunk      = errors.New("wav: no data chunk")
+	errInvalidFormat    = errors.New("wav: invalid fmt chunk")
+	errUnsupportedCodec = errors.New("wav: unsupported sample format (only integer PCM is supported)")
+	errBitDepth         = errors.New("wav: unsupported bit depth")
+)
+
+// RIFF layout.
+const (
+	riffHeaderSize  = 12 // "RIFF" (4) + size (4) + "WAVE" (4).
+	chunkHeaderSize = 8  // id (4) + size (4).
+	fmtChunkMinSize = 16 // PCMWAVEFORMAT.
+	fmtChunkExtSize = 40 // WAVEFORMATEXTENSIBLE.
+	extSubFormatPos = 24 // Offset of the SubFormat GUID inside WAVEFORMATEXTENSIBLE.
+	unknownDataSize = 0xFFFFFFFF
+)
+
+// Format tags.
+const (
+	formatPCM        = 0x0001
+	formatExtensible = 0xFFFE
+)
+
+// storage describes how the data chunk holds the samples Decode outputs.
+type storage struct {
+	containerBits int // bits each sample takes up: 8-bit samples are unsigned, and widened to 16 bits
+	validBits     int // significant bits, from the top
+}
+
+// frameSize returns the bytes a sample frame of channels takes up in the data chunk.
+func (st storage) frameSize(channels uint) int {
+	return st.containerBits / 8 * int(channels) //nolint:gosec // channel count is small.
+}
+
+// widen returns the samples of data as Decode outputs them: 8-bit unsigned samples become
+// 16-bit signed ones, other sizes are left as they are.
+func (st storage) widen(data []byte) []byte {
+	if st.containerBits != 8 {
+		return data
+	}
+
+	pcm := make([]byte, 2*len(data))
+	for idx, sample := range data {
+		pcm[2*idx+1] = sample ^ 0x80
+	}
+
+	return pcm
+}
+
+// chunk describes the position of a RIFF sub-chunk.
+type chunk struct {
+	id     string
+	offset int64 // payload offset
+	size   uint32
+	stored uint32 // payload bytes present in the stream: size, or fewer if the stream ends first
+}
+
+// Decode reads a RIFF WAVE stream and returns its interleaved little-endian signed PCM bytes.
+// 20-bit audio stored in 24-bit containers is reported as saprobe.Depth20 (left-aligned).
+func Decode(rs io.ReadSeeker) ([]byte, saprobe.PCMFormat, error) {
+	pcm, format, _, err := DecodeWithOptions(rs, saprobe.Options{})
+
+	return pcm, format, err
+}
+
+// DecodeWithOptions is Decode with shared decoder options. A data chunk that ends before its
+// declared size is reported as a Truncation, or fails in strict mode.
+func DecodeWithOptions(rs io.ReadSeeker, opts saprobe.Options) ([]byte, saprobe.PCMFormat, saprobe.Report, error) {
+	tracked := saprobe.TrackReader(rs)
+	pcm, format, report, err := decode(tracked, opts)
+
+	return pcm, format, report, saprobe.WrapDecodeError(codecName, tracked, err, errUnsupportedCodec, errBitDepth)
+}
+
+func decode(rs io.ReadSeeker, opts saprobe.Options) ([]byte, saprobe.PCMFormat, saprobe.Report, error) {
+	var report saprobe.Report
+
+	chunks, err := readChunks(rs)
+	if err != nil {
+		return nil, saprobe.PCMFormat{}, report, err
+	}
+
+	format, stored, err := readFormat(rs, chunks)
+	if err != nil {
+		return nil, saprobe.PCMFormat{}, report, err
+	}
+
+	data, ok := findChunk(chunks, "data")
+	if !ok {
+		return nil, saprobe.PCMFormat{}, report, errNoDataChunk
+	}
+
+	if _, err := rs.Seek(data.offset, io.SeekStart); err != nil {
+		return nil, saprobe.PCMFormat{}, report, fmt.Errorf("seeking to data chunk: %w", err)
+	}
+
+	frameSize := stored.frameSize(format.Channels)
+
+	// Streaming writers that cannot seek back leave the size at its maximum: read to the end.
+	if data.size == unknownDataSize {
+		pcm, err := io.ReadAll(rs)
+		if err != nil {
+			return nil, saprobe.PCMFormat{}, report, fmt.Errorf("reading data chunk: %w", err)
+		}
+
+		return stored.widen(pcm[:len(pcm)-len(pcm)%frameSize]), format, report, nil
+	}
+
+	// The declared size is only trusted as far as the stream goes: the rest is a truncation.
+	pcm := make([]byte, data.stored)
+
+	readN, err := io.ReadFull(rs, pcm)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, saprobe.PCMFormat{}, report, fmt.Errorf("reading data chunk: %w", err)
+	}
+
+	// Keep whole sample frames only.
+	readN -= readN % frameSize
+
+	declared := int64(data.size) / int64(frameSize)
+
+	err = saprobe.CheckTruncation(opts, &report, declared, int64(readN/frameSize), format.SampleRate)
+	if err != nil {
+		return nil, saprobe.PCMFormat{}, report, err
+	}
+
+	return stored.widen(pcm[:readN]), format, report, nil
+}
+
+// readChunks walks the top-level RIFF chunk list.
+func readChunks(rs io.ReadSeeker) ([]chunk, error) {
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("seeking to end: %w", err)
+	}
+
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seeking to start: %w", err)
+	}
+
+	var header [riffHeaderSize]byte
+	if _, err := io.ReadFull(rs, header[:]); err != nil {
+		return nil, fmt.Errorf("reading RIFF header: %w", err)
+	}
+
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return nil, errNotWAVE
+	}
+
+	var (
+		chunks []chunk
+		offset int64 = riffHeaderSize
+	)
+
+	for {
+		var ch [chunkHeaderSize]byte
+		if _, err := io.ReadFull(rs, ch[:]); err != nil {
+			break // end of file (or trailing garbage) terminates the chunk list
+		}
+
+		size := binary.LittleEndian.Uint32(ch[4:8])
+		offset += chunkHeaderSize
+		stored := uint32(min(int64(size), max(end-offset, 0))) //nolint:gosec // bounded by size.
+		chunks = append(chunks, chunk{id: string(ch[0:4]), offset: offset, size: size, stored: stored})
+
+		// Chunks are word-aligned.
+		next := offset + int64(size) + int64(size&1)
+		if _, err := rs.Seek(next, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("seeking past %q chunk: %w", ch[0:4], err)
+		}
+
+		offset = next
+	}
+
+	return chunks, nil
+}
+
+func findChunk(chunks []chunk, id string) (chunk, bool) {
+	for _, c := range chunks {
+		if c.id == id {
+			return c, true
+		}
+	}
+
+	return chunk{}, false
+}
+
+// readFormat parses the fmt chunk into a PCMFormat, and the storage of its samples.
+func readFormat(rs io.ReadSeeker, chunks []chunk) (saprobe.PCMFormat, storage, error) {
+	fmtChunk, ok := findChunk(chunks, "fmt ")
+	if !ok {
+		return saprobe.PCMFormat{}, storage{}, errNoFormatChunk
+	}
+
+	if fmtChunk.size < fmtChunkMinSize {
+		return saprobe.PCMFormat{}, storage{}, errInvalidFormat
+	}
+
+	if fmtChunk.stored < fmtChunk.size {
+		return saprobe.PCMFormat{}, storage{}, fmt.Errorf("reading fmt chunk: %w", io.ErrUnexpectedEOF)
+	}
+
+	payload := make([]byte, fmtChunk.size)
+
+	if _, err := rs.Seek(fmtChunk.offset, io.SeekStart); err != nil {
+		return saprobe.PCMFormat{}, storage{}, fmt.Errorf("seeking to fmt chunk: %w", err)
+	}
+
+	if _, err := io.ReadFull(rs, payload); err != nil {
+		return saprobe.PCMFormat{}, storage{}, fmt.Errorf("reading fmt chunk: %w", err)
+	}
+
+	formatTag := binary.LittleEndian.Uint16(payload[0:2])
+	channels := binary.LittleEndian.Uint16(payload[2:4])
+	sampleRate := binary.LittleEndian.Uint32(payload[4:8])
+	containerBits := binary.LittleEndian.Uint16(payload[14:16])
+	validBits := containerBits
+	layout := saprobe.DefaultLayout(uint(channels))
+
+	if formatTag == formatExtensible {
+		if len(payload) < fmtChunkExtSize {
+			return saprobe.PCMFormat{}, storage{}, errInvalidFormat
+		}
+
+		if bits := binary.LittleEndian.Uint16(payload[18:20]); bits != 0 {
+			validBits = bits
+		}
+
+		layout = maskLayout(binary.LittleEndian.Uint32(payload[channelMaskPos:]), uint(channels))
+
+		if !bytes.Equal(payload[extSubFormatPos:extSubFormatPos+len(subFormatPCM)], subFormatPCM[:]) {
+			return saprobe.PCMFormat{}, storage{}, errUnsupportedCodec
+		}
+	} else if formatTag != formatPCM {
+		return saprobe.PCMFormat{}, storage{}, fmt.Errorf("%w: format tag 0x%04x", errUnsupportedCodec, formatTag)
+	}
+
+	if channels == 0 {
+		return saprobe.PCMFormat{}, storage{}, errInvalidFormat
+	}
+
+	depth, err := containerDepth(containerBits, validBits)
+	if err != nil {
+		return saprobe.PCMFormat{}, storage{}, err
+	}
+
+	return saprobe.PCMFormat{
+		SampleRate: int(sampleRate),
+		BitDepth:   depth,
+		Channels:   uint(channels),
+		Layout:     layout,
+	}, storage{containerBits: int(containerBits), validBits: int(min(validBits, containerBits))}, nil
+}
+
+// containerDepth maps the container and valid bit counts onto a saprobe bit depth. 8-bit samples
+// are widened to 16 bits.
+func containerDepth(containerBits, validBits uint16) (saprobe.BitDepth, error) {
+	switch {
+	case containerBits == 8, containerBits == 16:
+		return saprobe.Depth16, nil
+	case containerBits == 24 && validBits == 20:
+		return saprobe.Depth20, nil
+	case containerBits == 24:
+		return saprobe.Depth24, nil
+	case containerBits == 32:
+		return saprobe.Depth32, nil
+	default:
+		return 0, fmt.Errorf("%w: %d-bit container", errBitDepth, containerBits)
+	}
+}
@@ -0,0 +1,172 @@
+package wav_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/wav"
+)
+
+// TestDecodeTruncated checks that a data chunk cut short keeps its whole sample frames and reports
+// the samples its size declares, and fails with ErrTruncated in strict mode.
+func TestDecodeTruncated(t *testing.T) {
+	t.Parallel()
+
+	format := saprobe.PCMFormat{SampleRate: 48000, BitDepth: saprobe.Depth24, Channels: 2}
+	pcm := make([]byte, 48000*6)
+
+	for idx := range pcm {
+		pcm[idx] = byte(idx * 7)
+	}
+
+	var buf bytes.Buffer
+	if err := wav.Encode(&buf, pcm, format, saprobe.Metadata{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Cut half a second and one byte of a sample frame.
+	cut := buf.Bytes()[:buf.Len()-24000*6-1]
+
+	decoded, _, report, err := wav.DecodeWithOptions(bytes.NewReader(cut), saprobe.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	truncation := report.Truncation
+	if truncation == nil || truncation.Declared != 48000 || truncation.Decoded != 23999 {
+		t.Fatalf("truncation %+v, want 23999 of 48000 samples decoded", truncation)
+	}
+
+	if !bytes.Equal(decoded, pcm[:23999*6]) {
+		t.Errorf("decoded %d bytes, want the first %d", len(decoded), 23999*6)
+	}
+
+	_, _, _, err = wav.DecodeWithOptions(bytes.NewReader(cut), saprobe.Options{Strict: true})
+	if !errors.Is(err, saprobe.ErrTruncated) {
+		t.Errorf("strict decode: %v, want ErrTruncated", err)
+	}
+}
+
+// TestDecodeOversizedChunk checks that chunk sizes larger than the stream are not allocated: a data
+// chunk is reported as a truncation, a fmt chunk fails as corrupt.
+func TestDecodeOversizedChunk(t *testing.T) {
+	t.Parallel()
+
+	format := saprobe.PCMFormat{SampleRate: 48000, BitDepth: saprobe.Depth16, Channels: 2}
+	pcm := make([]byte, 4800*4)
+
+	for idx := range pcm {
+		pcm[idx] = byte(idx * 7)
+	}
+
+	var buf bytes.Buffer
+	if err := wav.Encode(&buf, pcm, format, saprobe.Metadata{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A flipped high bit turns the data chunk size, which closes the file, into 1 GiB and more.
+	data := bytes.Clone(buf.Bytes())
+	data[len(data)-len(pcm)-1] |= 0x40
+
+	decoded, _, report, err := wav.DecodeWithOptions(bytes.NewReader(data), saprobe.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if truncation := report.Truncation; truncation == nil || truncation.Decoded != 4800 ||
+		truncation.Declared != int64(0x40000000+len(pcm))/4 {
+		t.Errorf("truncation %+v, want 4800 samples of the declared size decoded", truncation)
+	}
+
+	if !bytes.Equal(decoded, pcm) {
+		t.Errorf("decoded %d bytes, want the %d stored", len(decoded), len(pcm))
+	}
+
+	// The fmt chunk follows the RIFF header.
+	data = bytes.Clone(buf.Bytes())
+	data[12+7] |= 0x40
+
+	_, _, _, err = wav.DecodeWithOptions(bytes.NewReader(data), saprobe.Options{})
+	if !errors.Is(err, saprobe.ErrCorrupt) {
+		t.Errorf("oversized fmt chunk: %v, want ErrCorrupt", err)
+	}
+}
+
+// TestReadFormat checks that the format and sample count read from the chunk headers match the
+// decode.
+func TestReadFormat(t *testing.T) {
+	t.Parallel()
+
+	format := saprobe.PCMFormat{SampleRate: 44100, BitDepth: saprobe.Depth16, Channels: 2}
+
+	var buf bytes.Buffer
+	if err := wav.Encode(&buf, make([]byte, 30000*4), format, saprobe.Metadata{}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, decoded, err := wav.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read, samples, err := wav.ReadFormat(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if read != decoded || samples != 30000 {
+		t.Errorf("read %+v, %d samples, want %+v, 30000", read, samples, decoded)
+	}
+}
+
+// TestEncodeBits checks that samples of fewer bits than their PCM keep their size: 8-bit samples
+// in an unsigned 8-bit container, 12-bit ones as the valid bits of a 16-bit container. Both decode
+// back to the same PCM, and samples wider than declared are refused.
+func TestEncodeBits(t *testing.T) {
+	t.Parallel()
+
+	format := saprobe.PCMFormat{SampleRate: 44100, BitDepth: saprobe.Depth16, Channels: 2}
+
+	for _, test := range []struct {
+		bits          int
+		containerBits uint16
+	}{
+		{8, 8},
+		{12, 16},
+	} {
+		pcm := make([]byte, 1000*4)
+		for idx := 0; idx < len(pcm); idx += 2 {
+			sample := int16(idx*37) &^ (1<<(16-test.bits) - 1)
+			binary.LittleEndian.PutUint16(pcm[idx:], uint16(sample)) //nolint:gosec // test data.
+		}
+
+		var buf bytes.Buffer
+		if err := wav.EncodeBits(&buf, pcm, format, test.bits, saprobe.Metadata{}); err != nil {
+			t.Fatal(err)
+		}
+
+		// The fmt chunk follows the RIFF header and the fmt chunk header.
+		if containerBits := binary.LittleEndian.Uint16(buf.Bytes()[34:]); containerBits != test.containerBits {
+			t.Errorf("%d bits: %d-bit container, want %d", test.bits, containerBits, test.containerBits)
+		}
+
+		decoded, decodedFormat, err := wav.Decode(bytes.NewReader(buf.Bytes()))
+		if err != nil || decodedFormat.BitDepth != saprobe.Depth16 || !bytes.Equal(decoded, pcm) {
+			t.Errorf("%d bits: decoded %v (%v), samples differ: %t", test.bits, decodedFormat, err,
+				!bytes.Equal(decoded, pcm))
+		}
+
+		bits, err := wav.ReadBitsPerSample(bytes.NewReader(buf.Bytes()))
+		if err != nil || bits != test.bits {
+			t.Errorf("%d bits: read %d (%v)", test.bits, bits, err)
+		}
+
+		pcm[0] |= 1
+		if err := wav.EncodeBits(&buf, pcm, format, test.bits, saprobe.Metadata{}); err == nil {
+			t.Errorf("%d bits: a wider sample encodes", test.bits)
+		}
+	}
+}
@@ -0,0 +1,2 @@
+// Package wav reads and writes RIFF WAVE files holding integer PCM.
+package wav
@@ -0,0 +1,157 @@
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/farcloser/saprobe"
+	"github.com/farcloser/saprobe/internal/pcmio"
+)
+
+var (
+	errTooLarge   = errors.New("wav: PCM data exceeds the 4 GiB RIFF limit")
+	errEncodeBits = errors.New("wav: samples do not fit the bits per sample")
+)
+
+// subFormatPCM is KSDATAFORMAT_SUBTYPE_PCM.
+//
+//nolint:gochecknoglobals // constant GUID
+var subFormatPCM = [16]byte{
+	0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00,
+	0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71,
+}
+
+// Encode writes pcm (interleaved little-endian signed, as produced by the saprobe decoders)
+// as a RIFF WAVE file. Textual tags are stored in a LIST/INFO chunk; pictures are not
+// representable in WAVE and are ignored.
+func Encode(writer io.Writer, pcm []byte, format saprobe.PCMFormat, metadata saprobe.Metadata) error {
+	return EncodeBits(writer, pcm, format, int(format.BitDepth), metadata) //nolint:gosec // small.
+}
+
+// EncodeBits is Encode for samples of bitsPerSample bits, left-aligned in the PCM bit depth as
+// Decode outputs them. Samples of 8 bits or fewer in 16-bit PCM are stored as 8-bit unsigned
+// samples; others keep their container and declare their valid bits. The bits below must be zero.
+func EncodeBits(
+	writer io.Writer, pcm []byte, format saprobe.PCMFormat, bitsPerSample int, metadata saprobe.Metadata,
+) error {
+	if bitsPerSample < 1 || bitsPerSample > int(format.BitDepth) || //nolint:gosec // small.
+		!pcmio.FitsBits(pcm, format.BitDepth.BytesPerSample(), bitsPerSample) {
+		return fmt.Errorf("%w: %d bits in %d-bit PCM", errEncodeBits, bitsPerSample, format.BitDepth)
+	}
+
+	stored := storage{containerBits: 8 * format.BitDepth.BytesPerSample(), validBits: bitsPerSample}
+	if bitsPerSample <= 8 && format.BitDepth == saprobe.Depth16 {
+		stored.containerBits = 8
+		pcm = narrow(pcm)
+	}
+
+	if len(pcm) > math.MaxUint32-riffHeaderSize {
+		return errTooLarge
+	}
+
+	fmtChunk := formatChunk(format, stored)
+	infoChunk := encodeInfo(metadata)
+
+	dataSize := len(pcm) + len(pcm)&1
+	riffSize := 4 + chunkHeaderSize + len(fmtChunk) + chunkHeaderSize + dataSize
+
+	if len(infoChunk) > 0 {
+		riffSize += chunkHeaderSize + len(infoChunk)
+	}
+
+	if riffSize > math.MaxUint32 {
+		return errTooLarge
+	}
+
+	out := make([]byte, 0, riffHeaderSize+chunkHeaderSize+len(fmtChunk)+chunkHeaderSize+len(infoChunk))
+	out = append(out, "RIFF"...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(riffSize)) //nolint:gosec // bounded above.
+	out = append(out, "WAVE"...)
+	out = appendChunk(out, "fmt ", fmtChunk)
+
+	if len(infoChunk) > 0 {
+		out = appendChunk(out, "LIST", infoChunk)
+	}
+
+	out = append(out, "data"...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(pcm))) //nolint:gosec // bounded above.
+
+	if _, err := writer.Write(out); err != nil {
+		return fmt.Errorf("writing wav header: %w", err)
+	}
+
+	if _, err := writer.Write(pcm); err != nil {
+		return fmt.Errorf("writing wav data: %w", err)
+	}
+
+	if len(pcm)&1 != 0 {
+		if _, err := writer.Write([]byte{0}); err != nil {
+			return fmt.Errorf("writing wav padding: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// narrow returns 16-bit samples holding 8 bits as 8-bit unsigned samples.
+func narrow(pcm []byte) []byte {
+	data := make([]byte, len(pcm)/2)
+	for idx := range data {
+		data[idx] = pcm[2*idx+1] ^ 0x80
+	}
+
+	return data
+}
+
+// formatChunk builds the fmt chunk payload. WAVEFORMATEXTENSIBLE is used whenever plain
+// PCMWAVEFORMAT is ambiguous: more than two channels, more than 16 bits, or fewer valid bits than
+// the container holds, as in 20-bit audio.
+func formatChunk(format saprobe.PCMFormat, stored storage) []byte {
+	containerBits := stored.containerBits
+	blockAlign := stored.frameSize(format.Channels)
+	extensible := format.Channels > 2 || containerBits > 16 || stored.validBits != containerBits
+
+	tag := uint16(formatPCM)
+	if extensible {
+		tag = formatExtensible
+	}
+
+	//nolint:gosec // all values are small and bounded by the PCM format.
+	out := binary.LittleEndian.AppendUint16(nil, tag)
+	out = binary.LittleEndian.AppendUint16(out, uint16(format.Channels))
+	out = binary.LittleEndian.AppendUint32(out, uint32(format.SampleRate))
+	out = binary.LittleEndian.AppendUint32(out, uint32(format.SampleRate*blockAlign))
+	out = binary.LittleEndian.AppendUint16(out, uint16(blockAlign))
+	out = binary.LittleEndian.AppendUint16(out, uint16(containerBits))
+
+	if !extensible {
+		return out
+	}
+
+	// Layouts out of WAVE order (ALAC and Vorbis surround) cannot be told: keep the defaults.
+	mask := layoutMask(format.Layout)
+	if mask == 0 || format.Layout.Channels() != int(format.Channels) { //nolint:gosec // channel count is small.
+		mask = layoutMask(saprobe.DefaultLayout(format.Channels))
+	}
+
+	out = binary.LittleEndian.AppendUint16(out, fmtChunkExtSize-fmtChunkMinSize-2) // cbSize
+	out = binary.LittleEndian.AppendUint16(out, uint16(stored.validBits))          //nolint:gosec // at most 32.
+	out = binary.LittleEndian.AppendUint32(out, mask)
+
+	return append(out, subFormatPCM[:]...)
+}
+
+func appendChunk(out []byte, id string, payload []byte) []byte {
+	out = append(out, id...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(payload))) //nolint:gosec // chunk payloads are small.
+	out = append(out, payload...)
+
+	if len(payload)&1 != 0 {
+		out = append(out, 0)
+	}
+
+	return out
+}
@@ -0,0 +1,50 @@
+package wav
+
+import (
+	"math/bits"
+
+	"github.com/farcloser/saprobe"
+)
+
+const channelMaskPos = 20 // Offset of dwChannelMask inside WAVEFORMATEXTENSIBLE.
+
+// maskLayout returns the layout a WAVEFORMATEXTENSIBLE channel mask assigns to channels: one
+// speaker per set bit, in bit order. A zero mask leaves the WAVE defaults; a mask that does not
+// assign every channel, or assigns speakers saprobe does not know, leaves the layout unknown.
+func maskLayout(mask uint32, channels uint) saprobe.ChannelLayout {
+	if mask == 0 {
+		return saprobe.DefaultLayout(channels)
+	}
+
+	var layout saprobe.ChannelLayout
+
+	if uint(bits.OnesCount32(mask)) != channels || channels > saprobe.MaxLayoutChannels ||
+		mask>>saprobe.SpeakerTopBackRight != 0 {
+		return layout
+	}
+
+	for ch := range layout[:channels] {
+		speaker := bits.TrailingZeros32(mask)
+		layout[ch] = saprobe.Speaker(speaker + 1)
+		mask &^= 1 << speaker
+	}
+
+	return layout
+}
+
+// layoutMask returns the channel mask of layout, or 0 when it is unknown or its speakers are out of
+// the bit order WAVE requires.
+func layoutMask(layout saprobe.ChannelLayout) uint32 {
+	var mask uint32
+
+	for _, speaker := range layout.Speakers() {
+		bit := uint32(1) << (speaker - 1)
+		if bit <= mask {
+			return 0
+		}
+
+		mask |= bit
+	}
+
+	return mask
+}
@@ -0,0 +1,155 @@
+package wav
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/farcloser/saprobe"
+)
+
+// infoKeys maps RIFF INFO sub-chunk identifiers to saprobe tag keys.
+//
+//nolint:gochecknoglobals // constant table
+var infoKeys = []struct {
+	id  string
+	key string
+}{
+	{"INAM", saprobe.TagTitle},
+	{"IART", saprobe.TagArtist},
+	{"IPRD", saprobe.TagAlbum},
+	{"ICRD", saprobe.TagDate},
+	{"IGNR", saprobe.TagGenre},
+	{"ICMT", saprobe.TagComment},
+	{"ITRK", saprobe.TagTrackNumber},
+	{"ICOP", saprobe.TagCopyright},
+	{"ISFT", saprobe.TagEncoder},
+	{"IMUS", saprobe.TagComposer},
+}
+
+// ReadMetadata returns the tags stored in the LIST/INFO chunk of a RIFF WAVE stream.
+func ReadMetadata(rs io.ReadSeeker) (saprobe.Metadata, error) {
+	var metadata saprobe.Metadata
+
+	chunks, err := readChunks(rs)
+	if err != nil {
+		return metadata, err
+	}
+
+	for _, c := range chunks {
+		if c.id != "LIST" || c.size < 4 {
+			continue
+		}
+
+		if c.stored < c.size {
+			return metadata, fmt.Errorf("reading LIST chunk: %w", io.ErrUnexpectedEOF)
+		}
+
+		payload := make([]byte, c.size)
+
+		if _, err := rs.Seek(c.offset, io.SeekStart); err != nil {
+			return metadata, fmt.Errorf("seeking to LIST chunk: %w", err)
+		}
+
+		if _, err := io.ReadFull(rs, payload); err != nil {
+			return metadata, fmt.Errorf("reading LIST chunk: %w", err)
+		}
+
+		if string(payload[0:4]) == "INFO" {
+			decodeInfo(&metadata, payload[4:])
+		}
+	}
+
+	return metadata, nil
+}
+
+// ReadFormat returns the format of the PCM Decode outputs and the samples per channel the data
+// chunk declares, 0 when its size is left unknown. Only the chunk headers and the fmt chunk are
+// read.
+func ReadFormat(rs io.ReadSeeker) (saprobe.PCMFormat, int64, error) {
+	chunks, err := readChunks(rs)
+	if err != nil {
+		return saprobe.PCMFormat{}, 0, err
+	}
+
+	format, stored, err := readFormat(rs, chunks)
+	if err != nil {
+		return saprobe.PCMFormat{}, 0, err
+	}
+
+	data, ok := findChunk(chunks, "data")
+	if !ok {
+		return saprobe.PCMFormat{}, 0, errNoDataChunk
+	}
+
+	if data.size == unknownDataSize {
+		return format, 0, nil
+	}
+
+	return format, int64(data.size) / int64(stored.frameSize(format.Channels)), nil
+}
+
+// ReadBitsPerSample returns the significant bits per sample the fmt chunk declares. Decode widens
+// 8-bit samples to 16 bits, and left-aligns samples of fewer bits than their container, 12 in 16
+// say. Only the chunk headers and the fmt chunk are read.
+func ReadBitsPerSample(rs io.ReadSeeker) (int, error) {
+	chunks, err := readChunks(rs)
+	if err != nil {
+		return 0, err
+	}
+
+	_, stored, err := readFormat(rs, chunks)
+	if err != nil {
+		return 0, err
+	}
+
+	return stored.validBits, nil
+}
+
+func decodeInfo(metadata *saprobe.Metadata, data []byte) {
+	for len(data) >= chunkHeaderSize {
+		id := string(data[0:4])
+		size := int(binary.LittleEndian.Uint32(data[4:8]))
+		data = data[chunkHeaderSize:]
+
+		if size > len(data) {
+			return
+		}
+
+		value := strings.TrimRight(string(data[:size]), "\x00")
+
+		for _, k := range infoKeys {
+			if k.id == id {
+				metadata.Add(k.key, value)
+
+				break
+			}
+		}
+
+		data = data[min(size+size&1, len(data)):]
+	}
+}
+
+// encodeInfo builds a LIST chunk payload ("INFO" + sub-chunks) for the tags that INFO can hold.
+// Returns nil when there is nothing to write. The result always has an even length.
+func encodeInfo(metadata saprobe.Metadata) []byte {
+	var out []byte
+
+	for _, k := range infoKeys {
+		value, ok := metadata.Get(k.key)
+		if !ok {
+			continue
+		}
+
+		// Values are NUL-terminated and word-aligned.
+		payload := append([]byte(value), 0)
+		out = appendChunk(out, k.id, payload)
+	}
+
+	if len(out) == 0 {
+		return nil
+	}
+
+	return append([]byte("INFO"), out...)
+}